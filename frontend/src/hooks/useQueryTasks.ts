@@ -1,18 +1,28 @@
 import axios from 'axios'
 import { useQuery } from '@tanstack/react-query'
-import { Task } from '../types'
+import { Task, TaskList } from '../types'
 import { useError } from './useError'
 
 export const useQueryTasks = () => {
   // useErrorからswitchErrorHandlingの関数を読み込む.
   const { switchErrorHandling } = useError()
   // タスク一覧を取得するために、getTaskという関数を定義。axiosのgetメソッドでtasksのエンドポイントにアクセスしタスク一覧を取得できるようにしておく。
+  // 一覧はページング付きで返ってくるので、next_cursorをたどって最後のページまで取得し、tasksの配列をつなげて返す。
   const getTasks = async () => {
-    const { data } = await axios.get<Task[]>(
-      `${process.env.REACT_APP_API_URL}/tasks`,
-      { withCredentials: true }
-    )
-    return data
+    const tasks: Task[] = []
+    let cursor = ''
+    do {
+      const { data } = await axios.get<TaskList>(
+        `${process.env.REACT_APP_API_URL}/tasks`,
+        {
+          params: { limit: 200, ...(cursor && { cursor }) },
+          withCredentials: true,
+        }
+      )
+      tasks.push(...data.tasks)
+      cursor = data.has_more ? data.next_cursor : ''
+    } while (cursor)
+    return tasks
   }
 
   // カスタムフックのreturnの値としてuseQueryを実行した結果を渡す。
@@ -4,6 +4,15 @@ export type Task = {
   created_at: Date
   updated_at: Date
 }
+// タスク一覧のレスポンス。ページングの情報も一緒に返ってくる。
+export type TaskList = {
+  tasks: Task[]
+  next_cursor: string
+  has_more: boolean
+  limit: number
+  sort: string
+  order: string
+}
 export type CsrfToken = {
   csrf_token: string
 }
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"fmt"
+	"go_api/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type IActivityController interface {
+	GetTaskHistory(c echo.Context) error
+	GetActivities(c echo.Context) error
+}
+
+type activityController struct {
+	au usecase.IActivityUsecase
+}
+
+func NewActivityController(au usecase.IActivityUsecase) IActivityController {
+	return &activityController{au}
+}
+
+// タスクの変更履歴。/tasks/:taskId/history
+func (ac *activityController) GetTaskHistory(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+
+	activitiesRes, err := ac.au.InWorkspace(currentWorkspaceId(c)).GetTaskHistory(uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, activitiesRes)
+}
+
+// ユーザー全体のアクティビティ。/activity?limit=50&before=123
+func (ac *activityController) GetActivities(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	limit := 0
+	if l := c.QueryParam("limit"); l != "" {
+		v, err := strconv.Atoi(l)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, fmt.Sprintf("invalid limit: %s", l))
+		}
+		limit = v
+	}
+	var before uint
+	if b := c.QueryParam("before"); b != "" {
+		v, err := strconv.ParseUint(b, 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, fmt.Sprintf("invalid before: %s", b))
+		}
+		before = uint(v)
+	}
+	activitiesRes, err := ac.au.InWorkspace(currentWorkspaceId(c)).GetActivities(uint(userId.(float64)), before, limit)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, activitiesRes)
+}
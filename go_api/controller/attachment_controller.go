@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"errors"
+	"go_api/model"
+	"go_api/usecase"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type IAttachmentController interface {
+	GetAttachments(c echo.Context) error
+	CreateAttachment(c echo.Context) error
+	DownloadAttachment(c echo.Context) error
+	DeleteAttachment(c echo.Context) error
+}
+
+type attachmentController struct {
+	au usecase.IAttachmentUsecase
+}
+
+func NewAttachmentController(au usecase.IAttachmentUsecase) IAttachmentController {
+	return &attachmentController{au}
+}
+
+func (ac *attachmentController) GetAttachments(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+
+	attachmentsRes, err := ac.au.InWorkspace(currentWorkspaceId(c)).GetAttachments(uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return projectErrorJSON(c, err)
+	}
+	return c.JSON(http.StatusOK, attachmentsRes)
+}
+
+// multipart/form-dataのfileで送られてきたファイルを、タスクに添付する。
+// リクエスト全体の大きさは、ファイルの最大サイズにフォームの区切りなどの分を足したものまでにする。
+func (ac *attachmentController) CreateAttachment(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, model.MaxAttachmentSize+1<<20)
+	fh, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return c.JSON(http.StatusRequestEntityTooLarge, "file is too large")
+		}
+		return c.JSON(http.StatusBadRequest, "file is required")
+	}
+	if fh.Size > model.MaxAttachmentSize {
+		return c.JSON(http.StatusRequestEntityTooLarge, "file is too large")
+	}
+	file, err := fh.Open()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	defer file.Close()
+	attachment := model.Attachment{
+		FileName: fh.Filename,
+		Size:     fh.Size,
+		TaskId:   uint(taskId),
+		UserId:   uint(userId.(float64)),
+	}
+	attachmentRes, err := ac.au.InWorkspace(currentWorkspaceId(c)).CreateAttachment(attachment, fh.Header.Get(echo.HeaderContentType), file)
+	if err != nil {
+		return projectErrorJSON(c, err)
+	}
+	return c.JSON(http.StatusCreated, attachmentRes)
+}
+
+// ファイルの中身を、保存先から読みながらそのままレスポンスに書き出す。
+// ブラウザで開いてスクリプトが動かないように、常にダウンロードさせ、種類の推測(MIME sniffing)もさせない。
+func (ac *attachmentController) DownloadAttachment(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+	aid := c.Param("attachmentId")
+	attachmentId, _ := strconv.Atoi(aid)
+
+	attachmentRes, body, err := ac.au.InWorkspace(currentWorkspaceId(c)).OpenAttachment(uint(userId.(float64)), uint(taskId), uint(attachmentId))
+	if err != nil {
+		return projectErrorJSON(c, err)
+	}
+	defer body.Close()
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": attachmentRes.FileName})
+	if disposition == "" {
+		// ファイル名に制御文字などが含まれていて、ヘッダーに入れられない場合。
+		disposition = "attachment"
+	}
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentDisposition, disposition)
+	header.Set(echo.HeaderContentLength, strconv.FormatInt(attachmentRes.Size, 10))
+	header.Set(echo.HeaderXContentTypeOptions, "nosniff")
+	return c.Stream(http.StatusOK, attachmentRes.ContentType, body)
+}
+
+func (ac *attachmentController) DeleteAttachment(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+	aid := c.Param("attachmentId")
+	attachmentId, _ := strconv.Atoi(aid)
+
+	if err := ac.au.InWorkspace(currentWorkspaceId(c)).DeleteAttachment(uint(userId.(float64)), uint(taskId), uint(attachmentId)); err != nil {
+		return projectErrorJSON(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"go_api/model"
+	"go_api/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type IBoardController interface {
+	GetBoards(c echo.Context) error
+	GetBoardById(c echo.Context) error
+	CreateBoard(c echo.Context) error
+	UpdateBoard(c echo.Context) error
+	DeleteBoard(c echo.Context) error
+	CreateColumn(c echo.Context) error
+	UpdateColumn(c echo.Context) error
+	DeleteColumn(c echo.Context) error
+	PlaceCard(c echo.Context) error
+	RemoveCard(c echo.Context) error
+}
+
+type boardController struct {
+	bu usecase.IBoardUsecase
+}
+
+func NewBoardController(bu usecase.IBoardUsecase) IBoardController {
+	return &boardController{bu}
+}
+
+func (bc *boardController) GetBoards(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	boardsRes, err := bc.bu.InWorkspace(currentWorkspaceId(c)).GetBoards(uint(userId.(float64)))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, boardsRes)
+}
+
+// 列と、それぞれの列に置かれているカード(タスク)を1つのレスポンスで返す。
+func (bc *boardController) GetBoardById(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("boardId")
+	boardId, _ := strconv.Atoi(id)
+
+	boardRes, err := bc.bu.InWorkspace(currentWorkspaceId(c)).GetBoardById(uint(userId.(float64)), uint(boardId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, boardRes)
+}
+
+// bodyで名前と列を受け取る。(例: {"name": "開発", "columns": [{"name": "レビュー待ち", "wip_limit": 3}]})
+func (bc *boardController) CreateBoard(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	board := model.Board{}
+	if err := c.Bind(&board); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	// ボードを作成したユーザーは、ログインしているユーザーにする。
+	board.UserId = uint(userId.(float64))
+	boardRes, err := bc.bu.InWorkspace(currentWorkspaceId(c)).CreateBoard(board)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, boardRes)
+}
+
+func (bc *boardController) UpdateBoard(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("boardId")
+	boardId, _ := strconv.Atoi(id)
+
+	board := model.Board{}
+	if err := c.Bind(&board); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	boardRes, err := bc.bu.InWorkspace(currentWorkspaceId(c)).UpdateBoard(board, uint(userId.(float64)), uint(boardId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, boardRes)
+}
+
+func (bc *boardController) DeleteBoard(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("boardId")
+	boardId, _ := strconv.Atoi(id)
+
+	if err := bc.bu.InWorkspace(currentWorkspaceId(c)).DeleteBoard(uint(userId.(float64)), uint(boardId)); err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// bodyで列の名前・ステータス・WIP制限を受け取る。(例: {"name": "作業中", "status": "in_progress", "wip_limit": 3})
+func (bc *boardController) CreateColumn(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("boardId")
+	boardId, _ := strconv.Atoi(id)
+
+	column := model.BoardColumn{}
+	if err := c.Bind(&column); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	columnRes, err := bc.bu.InWorkspace(currentWorkspaceId(c)).CreateColumn(column, uint(userId.(float64)), uint(boardId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, columnRes)
+}
+
+func (bc *boardController) UpdateColumn(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("boardId")
+	boardId, _ := strconv.Atoi(id)
+	columnId, _ := strconv.Atoi(c.Param("columnId"))
+
+	column := model.BoardColumn{}
+	if err := c.Bind(&column); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	columnRes, err := bc.bu.InWorkspace(currentWorkspaceId(c)).UpdateColumn(column, uint(userId.(float64)), uint(boardId), uint(columnId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, columnRes)
+}
+
+func (bc *boardController) DeleteColumn(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("boardId")
+	boardId, _ := strconv.Atoi(id)
+	columnId, _ := strconv.Atoi(c.Param("columnId"))
+
+	if err := bc.bu.InWorkspace(currentWorkspaceId(c)).DeleteColumn(uint(userId.(float64)), uint(boardId), uint(columnId)); err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// bodyで置く列と前後のカードのタスクのidを受け取る。(例: {"column_id": 2, "after_id": 5})
+// 列がWIP制限に達している場合は409を返す。
+func (bc *boardController) PlaceCard(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("boardId")
+	boardId, _ := strconv.Atoi(id)
+	taskId, _ := strconv.Atoi(c.Param("taskId"))
+
+	move := model.BoardCardMove{}
+	if err := c.Bind(&move); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	cardRes, err := bc.bu.InWorkspace(currentWorkspaceId(c)).PlaceCard(move, uint(userId.(float64)), uint(boardId), uint(taskId))
+	if err != nil {
+		return taskErrorJSON(c, err)
+	}
+	c.Response().Header().Set("ETag", taskETag(cardRes.Task.Version))
+	return c.JSON(http.StatusOK, cardRes)
+}
+
+// カードをボードから外す。タスクは削除しない。
+func (bc *boardController) RemoveCard(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("boardId")
+	boardId, _ := strconv.Atoi(id)
+	taskId, _ := strconv.Atoi(c.Param("taskId"))
+
+	if err := bc.bu.InWorkspace(currentWorkspaceId(c)).RemoveCard(uint(userId.(float64)), uint(boardId), uint(taskId)); err != nil {
+		return taskErrorJSON(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"errors"
+	"go_api/model"
+	"go_api/taskio"
+	"go_api/usecase"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type ICalendarController interface {
+	GetFeed(c echo.Context) error
+	RotateFeed(c echo.Context) error
+	DeleteFeed(c echo.Context) error
+	ServeFeed(c echo.Context) error
+}
+
+type calendarController struct {
+	cu usecase.ICalendarUsecase
+}
+
+func NewCalendarController(cu usecase.ICalendarUsecase) ICalendarController {
+	return &calendarController{cu}
+}
+
+// カレンダーアプリに登録するフィードのURL。リクエストを受けたホストを使う。
+func calendarFeedURL(c echo.Context, feed model.CalendarFeedResponse) string {
+	return c.Scheme() + "://" + c.Request().Host + "/feeds/" + feed.Token + ".ics"
+}
+
+func (cc *calendarController) GetFeed(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	feedRes, err := cc.cu.InWorkspace(currentWorkspaceId(c)).GetFeed(uint(userId.(float64)))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	feedRes.URL = calendarFeedURL(c, feedRes)
+	return c.JSON(http.StatusOK, feedRes)
+}
+
+func (cc *calendarController) RotateFeed(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	feedRes, err := cc.cu.InWorkspace(currentWorkspaceId(c)).RotateFeed(uint(userId.(float64)))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	feedRes.URL = calendarFeedURL(c, feedRes)
+	return c.JSON(http.StatusOK, feedRes)
+}
+
+func (cc *calendarController) DeleteFeed(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	if err := cc.cu.InWorkspace(currentWorkspaceId(c)).DeleteFeed(uint(userId.(float64))); err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// /feeds/<トークン>.ics。カレンダーアプリはcookieを送らないので、ログインは必要なく、トークンで見分ける。
+// type=eventの場合は、タスクを予定(VEVENT)として書き出す。
+func (cc *calendarController) ServeFeed(c echo.Context) error {
+	token := strings.TrimSuffix(c.Param("file"), ".ics")
+	feedType := c.QueryParam("type")
+	if feedType == "" {
+		feedType = model.CalendarFeedTypeTodo
+	}
+	if feedType != model.CalendarFeedTypeTodo && feedType != model.CalendarFeedTypeEvent {
+		return c.JSON(http.StatusBadRequest, "type must be one of todo, event")
+	}
+	c.Response().Header().Set(echo.HeaderContentType, taskio.ContentType(taskio.FormatICS))
+	c.Response().Header().Set(echo.HeaderContentDisposition, "inline; filename=\"tasks.ics\"")
+	if err := cc.cu.WriteFeed(token, feedType, c.Response()); err != nil {
+		// 書き出しを始めた後はステータスコードを変えられないので、エラーはログに出すだけにする。
+		if c.Response().Committed {
+			c.Logger().Error(err)
+			return nil
+		}
+		c.Response().Header().Del(echo.HeaderContentDisposition)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, "feed not found")
+		}
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"errors"
+	"go_api/model"
+	"go_api/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type ICommentController interface {
+	GetComments(c echo.Context) error
+	CreateComment(c echo.Context) error
+	UpdateComment(c echo.Context) error
+	DeleteComment(c echo.Context) error
+}
+
+type commentController struct {
+	cu usecase.ICommentUsecase
+}
+
+func NewCommentController(cu usecase.ICommentUsecase) ICommentController {
+	return &commentController{cu}
+}
+
+// 他のユーザーのコメントを編集・削除しようとした場合は403を返す。
+func commentErrorJSON(c echo.Context, err error) error {
+	if errors.Is(err, model.ErrCommentForbidden) || errors.Is(err, model.ErrProjectForbidden) {
+		return c.JSON(http.StatusForbidden, err.Error())
+	}
+	return c.JSON(http.StatusInternalServerError, err.Error())
+}
+
+func (cc *commentController) GetComments(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+
+	commentsRes, err := cc.cu.InWorkspace(currentWorkspaceId(c)).GetComments(uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return commentErrorJSON(c, err)
+	}
+	return c.JSON(http.StatusOK, commentsRes)
+}
+
+func (cc *commentController) CreateComment(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+
+	comment := model.Comment{}
+	if err := c.Bind(&comment); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	// コメントを書いたのは、ログインしているユーザーにする。
+	comment.UserId = uint(userId.(float64))
+	comment.TaskId = uint(taskId)
+	commentRes, err := cc.cu.InWorkspace(currentWorkspaceId(c)).CreateComment(comment)
+	if err != nil {
+		return commentErrorJSON(c, err)
+	}
+	return c.JSON(http.StatusCreated, commentRes)
+}
+
+func (cc *commentController) UpdateComment(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+	cid := c.Param("commentId")
+	commentId, _ := strconv.Atoi(cid)
+
+	comment := model.Comment{}
+	if err := c.Bind(&comment); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	commentRes, err := cc.cu.InWorkspace(currentWorkspaceId(c)).UpdateComment(comment, uint(userId.(float64)), uint(taskId), uint(commentId))
+	if err != nil {
+		return commentErrorJSON(c, err)
+	}
+	return c.JSON(http.StatusOK, commentRes)
+}
+
+func (cc *commentController) DeleteComment(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+	cid := c.Param("commentId")
+	commentId, _ := strconv.Atoi(cid)
+
+	if err := cc.cu.InWorkspace(currentWorkspaceId(c)).DeleteComment(uint(userId.(float64)), uint(taskId), uint(commentId)); err != nil {
+		return commentErrorJSON(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
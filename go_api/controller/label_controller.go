@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"errors"
+	"go_api/model"
+	"go_api/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type ILabelController interface {
+	GetAllLabels(c echo.Context) error
+	CreateLabel(c echo.Context) error
+	UpdateLabel(c echo.Context) error
+	DeleteLabel(c echo.Context) error
+}
+
+type labelController struct {
+	lu usecase.ILabelUsecase
+}
+
+func NewLabelController(lu usecase.ILabelUsecase) ILabelController {
+	return &labelController{lu}
+}
+
+func (lc *labelController) GetAllLabels(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	labelsRes, err := lc.lu.InWorkspace(currentWorkspaceId(c)).GetAllLabels(uint(userId.(float64)))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, labelsRes)
+}
+
+func (lc *labelController) CreateLabel(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	label := model.Label{}
+	if err := c.Bind(&label); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	// ラベルの持ち主は、ログインしているユーザーにする。
+	label.UserId = uint(userId.(float64))
+	labelRes, err := lc.lu.InWorkspace(currentWorkspaceId(c)).CreateLabel(label)
+	if err != nil {
+		return labelErrorJSON(c, err)
+	}
+	return c.JSON(http.StatusCreated, labelRes)
+}
+
+func (lc *labelController) UpdateLabel(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("labelId")
+	labelId, _ := strconv.Atoi(id)
+
+	label := model.Label{}
+	if err := c.Bind(&label); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	labelRes, err := lc.lu.InWorkspace(currentWorkspaceId(c)).UpdateLabel(label, uint(userId.(float64)), uint(labelId))
+	if err != nil {
+		return labelErrorJSON(c, err)
+	}
+	return c.JSON(http.StatusOK, labelRes)
+}
+
+func (lc *labelController) DeleteLabel(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("labelId")
+	labelId, _ := strconv.Atoi(id)
+
+	if err := lc.lu.InWorkspace(currentWorkspaceId(c)).DeleteLabel(uint(userId.(float64)), uint(labelId)); err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ラベルの作成・更新のエラーをレスポンスにする。バリデーションのエラーは400、同じ名前のラベルがある場合は409を返す。
+func labelErrorJSON(c echo.Context, err error) error {
+	if isValidationError(err) {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if errors.Is(err, model.ErrLabelNameTaken) {
+		return c.JSON(http.StatusConflict, err.Error())
+	}
+	return c.JSON(http.StatusInternalServerError, err.Error())
+}
@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"errors"
+	"go_api/model"
+	"go_api/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type IProjectController interface {
+	GetAllProjects(c echo.Context) error
+	GetProjectById(c echo.Context) error
+	CreateProject(c echo.Context) error
+	UpdateProject(c echo.Context) error
+	DeleteProject(c echo.Context) error
+}
+
+type projectController struct {
+	pu usecase.IProjectUsecase
+}
+
+func NewProjectController(pu usecase.IProjectUsecase) IProjectController {
+	return &projectController{pu}
+}
+
+// /projects?archived=true でアーカイブしたプロジェクトも含めて取得する。
+func (pc *projectController) GetAllProjects(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	includeArchived := false
+	if archived := c.QueryParam("archived"); archived != "" {
+		b, err := strconv.ParseBool(archived)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, "invalid archived: "+archived)
+		}
+		includeArchived = b
+	}
+	projectsRes, err := pc.pu.InWorkspace(currentWorkspaceId(c)).GetAllProjects(uint(userId.(float64)), includeArchived)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, projectsRes)
+}
+
+func (pc *projectController) GetProjectById(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("projectId")
+	projectId, _ := strconv.Atoi(id)
+
+	projectRes, err := pc.pu.InWorkspace(currentWorkspaceId(c)).GetProjectById(uint(userId.(float64)), uint(projectId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, projectRes)
+}
+
+func (pc *projectController) CreateProject(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	project := model.Project{}
+	if err := c.Bind(&project); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	project.UserId = uint(userId.(float64))
+	projectRes, err := pc.pu.InWorkspace(currentWorkspaceId(c)).CreateProject(project)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, projectRes)
+}
+
+func (pc *projectController) UpdateProject(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("projectId")
+	projectId, _ := strconv.Atoi(id)
+
+	project := model.Project{}
+	if err := c.Bind(&project); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	projectRes, err := pc.pu.InWorkspace(currentWorkspaceId(c)).UpdateProject(project, uint(userId.(float64)), uint(projectId))
+	if err != nil {
+		return projectErrorJSON(c, err)
+	}
+	return c.JSON(http.StatusOK, projectRes)
+}
+
+// /projects/:projectId?mode=cascade でプロジェクト内のタスクも削除し、
+// mode=inbox(省略時)でタスクをプロジェクトなしに移してからプロジェクトを削除する。
+func (pc *projectController) DeleteProject(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("projectId")
+	projectId, _ := strconv.Atoi(id)
+
+	mode := c.QueryParam("mode")
+	if mode != "" && mode != model.ProjectDeleteCascade && mode != model.ProjectDeleteMoveToInbox {
+		return c.JSON(http.StatusBadRequest, "mode must be cascade or inbox")
+	}
+	if err := pc.pu.InWorkspace(currentWorkspaceId(c)).DeleteProject(uint(userId.(float64)), uint(projectId), mode); err != nil {
+		return projectErrorJSON(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// プロジェクトの権限に関するエラーをレスポンスにする。
+// 権限が足りない場合は403、最後のオーナーを外そうとした場合やすでにメンバーのユーザーを招待した場合は409、
+// 招待が無効な場合は404を返す。
+func projectErrorJSON(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, model.ErrProjectForbidden):
+		return c.JSON(http.StatusForbidden, err.Error())
+	case errors.Is(err, model.ErrLastProjectOwner), errors.Is(err, model.ErrAlreadyProjectMember):
+		return c.JSON(http.StatusConflict, err.Error())
+	case errors.Is(err, model.ErrInvitationInvalid):
+		return c.JSON(http.StatusNotFound, err.Error())
+	}
+	return c.JSON(http.StatusInternalServerError, err.Error())
+}
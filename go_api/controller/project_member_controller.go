@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"go_api/model"
+	"go_api/usecase"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type IProjectMemberController interface {
+	GetMembers(c echo.Context) error
+	UpdateMember(c echo.Context) error
+	DeleteMember(c echo.Context) error
+	GetInvitations(c echo.Context) error
+	CreateInvitation(c echo.Context) error
+	DeleteInvitation(c echo.Context) error
+	GetMyInvitations(c echo.Context) error
+	AcceptInvitation(c echo.Context) error
+	DeclineInvitation(c echo.Context) error
+}
+
+type projectMemberController struct {
+	pmu usecase.IProjectMemberUsecase
+}
+
+func NewProjectMemberController(pmu usecase.IProjectMemberUsecase) IProjectMemberController {
+	return &projectMemberController{pmu}
+}
+
+// 招待されたユーザーが開く、フロントエンドの招待の画面のURL。(メールなどで送る)
+func invitationURL(invitation model.ProjectInvitationResponse) string {
+	return strings.TrimSuffix(os.Getenv("FE_URL"), "/") + "/invitations/" + url.PathEscape(invitation.Token)
+}
+
+func (pmc *projectMemberController) GetMembers(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	projectId, _ := strconv.Atoi(c.Param("projectId"))
+
+	membersRes, err := pmc.pmu.InWorkspace(currentWorkspaceId(c)).GetMembers(uint(userId.(float64)), uint(projectId))
+	if err != nil {
+		return projectErrorJSON(c, err)
+	}
+	return c.JSON(http.StatusOK, membersRes)
+}
+
+// /projects/:projectId/members/:userId の権限を変更する。
+func (pmc *projectMemberController) UpdateMember(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	projectId, _ := strconv.Atoi(c.Param("projectId"))
+	memberId, _ := strconv.Atoi(c.Param("userId"))
+
+	update := model.ProjectMemberUpdate{}
+	if err := c.Bind(&update); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	memberRes, err := pmc.pmu.InWorkspace(currentWorkspaceId(c)).UpdateMember(update, uint(userId.(float64)), uint(projectId), uint(memberId))
+	if err != nil {
+		return projectErrorJSON(c, err)
+	}
+	return c.JSON(http.StatusOK, memberRes)
+}
+
+// 自分のユーザーidを指定すると、プロジェクトから抜ける。
+func (pmc *projectMemberController) DeleteMember(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	projectId, _ := strconv.Atoi(c.Param("projectId"))
+	memberId, _ := strconv.Atoi(c.Param("userId"))
+
+	if err := pmc.pmu.InWorkspace(currentWorkspaceId(c)).DeleteMember(uint(userId.(float64)), uint(projectId), uint(memberId)); err != nil {
+		return projectErrorJSON(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (pmc *projectMemberController) GetInvitations(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	projectId, _ := strconv.Atoi(c.Param("projectId"))
+
+	invitationsRes, err := pmc.pmu.InWorkspace(currentWorkspaceId(c)).GetInvitations(uint(userId.(float64)), uint(projectId))
+	if err != nil {
+		return projectErrorJSON(c, err)
+	}
+	for i := range invitationsRes {
+		invitationsRes[i].URL = invitationURL(invitationsRes[i])
+	}
+	return c.JSON(http.StatusOK, invitationsRes)
+}
+
+// メールアドレスと権限を指定して招待する。メールは送らないので、レスポンスのURLを招待するユーザーに伝える。
+func (pmc *projectMemberController) CreateInvitation(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	projectId, _ := strconv.Atoi(c.Param("projectId"))
+
+	invitation := model.ProjectInvitation{}
+	if err := c.Bind(&invitation); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	invitationRes, err := pmc.pmu.InWorkspace(currentWorkspaceId(c)).CreateInvitation(invitation, uint(userId.(float64)), uint(projectId))
+	if err != nil {
+		return projectErrorJSON(c, err)
+	}
+	invitationRes.URL = invitationURL(invitationRes)
+	return c.JSON(http.StatusCreated, invitationRes)
+}
+
+func (pmc *projectMemberController) DeleteInvitation(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	projectId, _ := strconv.Atoi(c.Param("projectId"))
+	invitationId, _ := strconv.Atoi(c.Param("invitationId"))
+
+	if err := pmc.pmu.InWorkspace(currentWorkspaceId(c)).DeleteInvitation(uint(userId.(float64)), uint(projectId), uint(invitationId)); err != nil {
+		return projectErrorJSON(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (pmc *projectMemberController) GetMyInvitations(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	invitationsRes, err := pmc.pmu.GetMyInvitations(uint(userId.(float64)))
+	if err != nil {
+		return projectErrorJSON(c, err)
+	}
+	for i := range invitationsRes {
+		invitationsRes[i].URL = invitationURL(invitationsRes[i])
+	}
+	return c.JSON(http.StatusOK, invitationsRes)
+}
+
+func (pmc *projectMemberController) AcceptInvitation(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	projectRes, err := pmc.pmu.AcceptInvitation(uint(userId.(float64)), c.Param("token"))
+	if err != nil {
+		return projectErrorJSON(c, err)
+	}
+	return c.JSON(http.StatusOK, projectRes)
+}
+
+func (pmc *projectMemberController) DeclineInvitation(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	if err := pmc.pmu.DeclineInvitation(uint(userId.(float64)), c.Param("token")); err != nil {
+		return projectErrorJSON(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"go_api/model"
+	"go_api/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type ISavedViewController interface {
+	GetSavedViews(c echo.Context) error
+	GetSavedViewById(c echo.Context) error
+	CreateSavedView(c echo.Context) error
+	UpdateSavedView(c echo.Context) error
+	DeleteSavedView(c echo.Context) error
+	GetViewTasks(c echo.Context) error
+}
+
+type savedViewController struct {
+	vu usecase.ISavedViewUsecase
+}
+
+func NewSavedViewController(vu usecase.ISavedViewUsecase) ISavedViewController {
+	return &savedViewController{vu}
+}
+
+func (vc *savedViewController) GetSavedViews(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	viewsRes, err := vc.vu.InWorkspace(currentWorkspaceId(c)).GetSavedViews(uint(userId.(float64)))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, viewsRes)
+}
+
+func (vc *savedViewController) GetSavedViewById(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("viewId")
+	viewId, _ := strconv.Atoi(id)
+
+	viewRes, err := vc.vu.InWorkspace(currentWorkspaceId(c)).GetSavedViewById(uint(userId.(float64)), uint(viewId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, viewRes)
+}
+
+// bodyで名前と絞り込みの式、並び順を受け取る。(例: {"name": "急ぎ", "query": "overdue and label = 急ぎ", "sort": "due_at", "order": "asc"})
+func (vc *savedViewController) CreateSavedView(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	view := model.SavedView{}
+	if err := c.Bind(&view); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	// ビューの持ち主は、ログインしているユーザーにする。
+	view.UserId = uint(userId.(float64))
+	viewRes, err := vc.vu.InWorkspace(currentWorkspaceId(c)).CreateSavedView(view)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, viewRes)
+}
+
+func (vc *savedViewController) UpdateSavedView(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("viewId")
+	viewId, _ := strconv.Atoi(id)
+
+	view := model.SavedView{}
+	if err := c.Bind(&view); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	viewRes, err := vc.vu.InWorkspace(currentWorkspaceId(c)).UpdateSavedView(view, uint(userId.(float64)), uint(viewId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, viewRes)
+}
+
+func (vc *savedViewController) DeleteSavedView(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("viewId")
+	viewId, _ := strconv.Atoi(id)
+
+	if err := vc.vu.InWorkspace(currentWorkspaceId(c)).DeleteSavedView(uint(userId.(float64)), uint(viewId)); err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ビューの条件に合うタスクの一覧を返す。並び順はビューのものを使い、クエリパラメーターでは件数とカーソル、
+// 条件の中のtodayや日付を解釈するタイムゾーンだけを受け取る。(例: /views/1/tasks?limit=20&tz=Asia/Tokyo)
+func (vc *savedViewController) GetViewTasks(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("viewId")
+	viewId, _ := strconv.Atoi(id)
+
+	page, err := parseTaskPage(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	loc, err := parseLocation(c.QueryParam("tz"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	taskRes, err := vc.vu.InWorkspace(currentWorkspaceId(c)).GetViewTasks(uint(userId.(float64)), uint(viewId), page, loc)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, taskRes)
+}
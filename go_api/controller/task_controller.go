@@ -1,21 +1,43 @@
 package controller
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"go_api/model"
+	"go_api/taskio"
 	"go_api/usecase"
+	"io"
 	"net/http"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
+	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 )
 
 type ITaskController interface {
 	GetAllTasks(c echo.Context) error
 	GetTaskById(c echo.Context) error
+	SearchTasks(c echo.Context) error
+	GetOccurrences(c echo.Context) error
 	CreateTask(c echo.Context) error
 	UpdateTask(c echo.Context) error
+	PatchTask(c echo.Context) error
+	AssignTask(c echo.Context) error
 	DeleteTask(c echo.Context) error
+	MoveTask(c echo.Context) error
+	GetDeletedTasks(c echo.Context) error
+	RestoreTask(c echo.Context) error
+	UndoTask(c echo.Context) error
+	RevertTask(c echo.Context) error
+	BulkTasks(c echo.Context) error
+	ExportTasks(c echo.Context) error
+	ImportTasks(c echo.Context) error
 }
 
 // 構造体を定義
@@ -40,12 +62,24 @@ func (tc *taskController) GetAllTasks(c echo.Context) error {
 	claims := user.Claims.(jwt.MapClaims)
 	userId := claims["user_id"]
 
+	// クエリパラメーターから絞り込み条件を取得する。(例: /tasks?status=done&overdue=true)
+	filter, err := parseTaskFilter(c, uint(userId.(float64)))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	// 並び替えとページングの指定を取得する。(例: /tasks?sort=due_at&order=desc&limit=20&cursor=xxx)
+	page, err := parseTaskPage(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
 	// コンテキストから取得した値はany型になっているので、いったんfloat64に型アサーションしてからuintに型変換する。
-	// そして、taskUsecaseのGetalltasksメソッドにユーザーidを引数として渡す。
-	// エラーが発生した場合、context.Jsonでクライアントにinternalservererrorとエラーメッセージを返す。
-	taskRes, err := tc.tu.GetAllTasks(uint(userId.(float64)))
+	// そして、taskUsecaseのGetalltasksメソッドにユーザーidと絞り込み条件、ページングの指定を引数として渡す。
+	// 絞り込み条件やページングの指定が正しくない場合は400、それ以外のエラーはinternalservererrorとエラーメッセージを返す。
+	taskRes, err := tc.tu.InWorkspace(currentWorkspaceId(c)).GetAllTasks(uint(userId.(float64)), filter, page)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		return taskErrorJSON(c, err)
 	}
 	return c.JSON(http.StatusOK, taskRes)
 }
@@ -65,14 +99,75 @@ func (tc *taskController) GetTaskById(c echo.Context) error {
 	taskId, _ := strconv.Atoi(id)
 	// usecaseのgetTaskByIDメソッドを呼び出す。
 	// 第一引数にuser_id,第二引数にtaskIdを渡す。
-	taskRes, err := tc.tu.GetTaskById(uint(userId.(float64)), uint(taskId))
-	// エラーが発生した場合、internalServerError、成功した場合は、StatusOKで取得したタスクをJSONでクライアントに返す。
+	taskRes, err := tc.tu.InWorkspace(currentWorkspaceId(c)).GetTaskById(uint(userId.(float64)), uint(taskId))
+	// タスクが見つからない場合は404、それ以外のエラーはinternalServerError、成功した場合は、StatusOKで取得したタスクをJSONでクライアントに返す。
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		return taskErrorJSON(c, err)
+	}
+	// タスクのバージョンをETagとして返す。If-None-Matchで同じETagが送られてきた場合は、変更がないので304を返す。
+	etag := taskETag(taskRes.Version)
+	c.Response().Header().Set("ETag", etag)
+	if ifNoneMatch(c.Request().Header.Get("If-None-Match"), etag) {
+		return c.NoContent(http.StatusNotModified)
 	}
 	return c.JSON(http.StatusOK, taskRes)
 }
 
+// 全文検索。/tasks/search?q=検索語&limit=20
+func (tc *taskController) SearchTasks(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	limit := 0
+	if l := c.QueryParam("limit"); l != "" {
+		v, err := strconv.Atoi(l)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, fmt.Sprintf("invalid limit: %s", l))
+		}
+		limit = v
+	}
+	// 検索語やlimitが正しくない場合は400を返す。
+	hitsRes, err := tc.tu.InWorkspace(currentWorkspaceId(c)).SearchTasks(uint(userId.(float64)), c.QueryParam("q"), limit)
+	if err != nil {
+		return taskErrorJSON(c, err)
+	}
+	return c.JSON(http.StatusOK, hitsRes)
+}
+
+// 繰り返しタスクの発生日時の一覧。/tasks/:taskId/occurrences?from=2023-07-01&to=2023-09-30&tz=Asia/Tokyo
+// fromを省略した場合は現在時刻から、toを省略した場合はfromから90日後までを展開する。
+func (tc *taskController) GetOccurrences(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+
+	loc, err := parseLocation(c.QueryParam("tz"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	from := time.Now().In(loc)
+	if f, err := parseTimeParam(c.QueryParam("from"), loc); err != nil {
+		return c.JSON(http.StatusBadRequest, fmt.Sprintf("invalid from: %s", err.Error()))
+	} else if f != nil {
+		from = f.In(loc)
+	}
+	to := from.AddDate(0, 0, 90)
+	if t, err := parseTimeParam(c.QueryParam("to"), loc); err != nil {
+		return c.JSON(http.StatusBadRequest, fmt.Sprintf("invalid to: %s", err.Error()))
+	} else if t != nil {
+		to = *t
+	}
+	// toがfromより前の場合は400、タスクが見つからない場合は404を返す。
+	occurrencesRes, err := tc.tu.InWorkspace(currentWorkspaceId(c)).GetOccurrences(uint(userId.(float64)), uint(taskId), from, to)
+	if err != nil {
+		return taskErrorJSON(c, err)
+	}
+	return c.JSON(http.StatusOK, occurrencesRes)
+}
+
 func (tc *taskController) CreateTask(c echo.Context) error {
 	// コンテキストからユーザーIDを取得。
 	user := c.Get("user").(*jwt.Token)
@@ -87,10 +182,11 @@ func (tc *taskController) CreateTask(c echo.Context) error {
 	// タスクオブジェクトのユーザーidのフィールドにコンテキストから取得したユーザーidの値を格納する。
 	task.UserId = uint(userId.(float64))
 	// そのタスクオブジェクトをtaskusecaseのCreateTaskに引数として渡す。
-	taskRes, err := tc.tu.CreateTask(task)
+	taskRes, err := tc.tu.InWorkspace(currentWorkspaceId(c)).CreateTask(task)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		return taskErrorJSON(c, err)
 	}
+	c.Response().Header().Set("ETag", taskETag(taskRes.Version))
 	return c.JSON(http.StatusCreated, taskRes)
 }
 
@@ -109,12 +205,74 @@ func (tc *taskController) UpdateTask(c echo.Context) error {
 	if err := c.Bind(&task); err != nil {
 		return c.JSON(http.StatusBadRequest, err.Error())
 	}
+	// バージョンはbodyではなく、If-Matchヘッダーで受け取る。
+	version, ok := parseIfMatch(c.Request().Header.Get("If-Match"))
+	if !ok {
+		return c.JSON(http.StatusPreconditionFailed, model.ErrTaskVersionConflict.Error())
+	}
+	task.Version = version
 	// タスクusecaseのupdateTaskを呼び出す。第一引数：userId、第二引数：taskId
-	taskRes, err := tc.tu.UpdateTask(task, uint(userId.(float64)), uint(taskId))
+	taskRes, err := tc.tu.InWorkspace(currentWorkspaceId(c)).UpdateTask(task, uint(userId.(float64)), uint(taskId))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		return taskErrorJSON(c, err)
 	}
 	// 成功した場合、更新後のタスクの値をステータスOKでクライアントにjsonで返す。
+	c.Response().Header().Set("ETag", taskETag(taskRes.Version))
+	return c.JSON(http.StatusOK, taskRes)
+}
+
+// JSON Merge Patch(RFC 7396)による部分更新。送られてきたフィールドだけを変更する。
+func (tc *taskController) PatchTask(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+
+	// Content-Typeはapplication/merge-patch+jsonを基本とし、application/jsonも受け付ける。
+	contentType := c.Request().Header.Get(echo.HeaderContentType)
+	if !strings.HasPrefix(contentType, "application/merge-patch+json") && !strings.HasPrefix(contentType, echo.MIMEApplicationJSON) {
+		return c.JSON(http.StatusUnsupportedMediaType, "content type must be application/merge-patch+json")
+	}
+	// フィールドが送られてきたかどうかとnullを区別するために、値はデコードせずにフィールドごとに受け取る。
+	// パッチがオブジェクトでない場合(配列など)はタスク全体を置き換えることになるので、受け付けない。
+	patch := map[string]json.RawMessage{}
+	if err := json.NewDecoder(c.Request().Body).Decode(&patch); err != nil {
+		return c.JSON(http.StatusBadRequest, "patch must be a JSON object")
+	}
+	version, ok := parseIfMatch(c.Request().Header.Get("If-Match"))
+	if !ok {
+		return c.JSON(http.StatusPreconditionFailed, model.ErrTaskVersionConflict.Error())
+	}
+	taskRes, err := tc.tu.InWorkspace(currentWorkspaceId(c)).PatchTask(patch, version, uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return taskErrorJSON(c, err)
+	}
+	c.Response().Header().Set("ETag", taskETag(taskRes.Version))
+	return c.JSON(http.StatusOK, taskRes)
+}
+
+// 担当者を変える。bodyで担当者のユーザーidを受け取る。(例: {"assignee_id": 3}、外す場合は{"assignee_id": null})
+func (tc *taskController) AssignTask(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+
+	req := model.TaskAssignRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	version, ok := parseIfMatch(c.Request().Header.Get("If-Match"))
+	if !ok {
+		return c.JSON(http.StatusPreconditionFailed, model.ErrTaskVersionConflict.Error())
+	}
+	taskRes, err := tc.tu.InWorkspace(currentWorkspaceId(c)).AssignTask(req.AssigneeId, version, uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return taskErrorJSON(c, err)
+	}
+	c.Response().Header().Set("ETag", taskETag(taskRes.Version))
 	return c.JSON(http.StatusOK, taskRes)
 }
 
@@ -125,10 +283,410 @@ func (tc *taskController) DeleteTask(c echo.Context) error {
 	id := c.Param("taskId")
 	taskId, _ := strconv.Atoi(id)
 
-	err := tc.tu.DeleteTask(uint(userId.(float64)), uint(taskId))
+	version, ok := parseIfMatch(c.Request().Header.Get("If-Match"))
+	if !ok {
+		return c.JSON(http.StatusPreconditionFailed, model.ErrTaskVersionConflict.Error())
+	}
+	err := tc.tu.InWorkspace(currentWorkspaceId(c)).DeleteTask(uint(userId.(float64)), uint(taskId), version)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		return taskErrorJSON(c, err)
 	}
 	// NoContentに関する解説はなかった。
 	return c.NoContent(http.StatusNoContent)
 }
+
+// 並び替え。リクエストbodyで移動先の前後のタスクのidを受け取る。(例: {"after_id": 3, "before_id": 8})
+func (tc *taskController) MoveTask(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+
+	move := model.TaskMove{}
+	if err := c.Bind(&move); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	taskRes, err := tc.tu.InWorkspace(currentWorkspaceId(c)).MoveTask(move, uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return taskErrorJSON(c, err)
+	}
+	return c.JSON(http.StatusOK, taskRes)
+}
+
+// 複数のタスクの作成・更新・削除・完了を1回のリクエストでまとめて行う。
+// atomicモードで失敗した場合は、何も反映されていないことを422で返す。
+func (tc *taskController) BulkTasks(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	req := model.TaskBulkRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	bulkRes, err := tc.tu.InWorkspace(currentWorkspaceId(c)).BulkTasks(req, uint(userId.(float64)))
+	if err != nil {
+		return taskErrorJSON(c, err)
+	}
+	if !bulkRes.Committed {
+		return c.JSON(http.StatusUnprocessableEntity, bulkRes)
+	}
+	return c.JSON(http.StatusOK, bulkRes)
+}
+
+// タスクをファイルとしてダウンロードする。(例: /tasks/export?format=csv)
+// タスクは取得しながら順に書き出すので、件数が多くてもまとめてメモリーに載せない。
+func (tc *taskController) ExportTasks(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	format := c.QueryParam("format")
+	if format == "" {
+		format = taskio.FormatJSON
+	}
+	if taskio.ContentType(format) == "" {
+		return c.JSON(http.StatusBadRequest, "format must be one of json, csv, md, ics")
+	}
+	c.Response().Header().Set(echo.HeaderContentType, taskio.ContentType(format))
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"tasks.%s\"", format))
+	if err := tc.tu.InWorkspace(currentWorkspaceId(c)).ExportTasks(uint(userId.(float64)), format, c.Response()); err != nil {
+		// 書き出しを始めた後はステータスコードを変えられないので、エラーはログに出すだけにする。
+		if c.Response().Committed {
+			c.Logger().Error(err)
+			return nil
+		}
+		return taskErrorJSON(c, err)
+	}
+	return nil
+}
+
+// インポートするファイルの最大サイズ。
+const maxTaskImportSize = 10 << 20
+
+// ファイルからタスクを作成する。ファイルはリクエストbodyにそのまま入れるか、multipart/form-dataのfileで送る。
+// 形式はformatで指定し、指定しない場合はContent-Typeから判定する。
+// dry_run=trueの場合は、作成した場合の結果だけを返す。duplicates=createの場合は、重複していても作成する。
+func (tc *taskController) ImportTasks(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	opts := model.TaskImportOptions{
+		Format:     c.QueryParam("format"),
+		Duplicates: c.QueryParam("duplicates"),
+	}
+	if v := c.QueryParam("dry_run"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, "dry_run must be true or false")
+		}
+		opts.DryRun = dryRun
+	}
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, maxTaskImportSize)
+	var body io.Reader = c.Request().Body
+	contentType := c.Request().Header.Get(echo.HeaderContentType)
+	if strings.HasPrefix(contentType, echo.MIMEMultipartForm) {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, "file is required")
+		}
+		file, err := fh.Open()
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		defer file.Close()
+		body = file
+		contentType = fh.Header.Get(echo.HeaderContentType)
+		if opts.Format == "" {
+			opts.Format = strings.TrimPrefix(strings.ToLower(filepath.Ext(fh.Filename)), ".")
+		}
+	}
+	if opts.Format == "" {
+		opts.Format = taskio.FormatFromContentType(contentType)
+	}
+	importRes, err := tc.tu.InWorkspace(currentWorkspaceId(c)).ImportTasks(uint(userId.(float64)), opts, body)
+	if err != nil {
+		// ファイルの構文が壊れている場合は、何行目かを含めたエラーを返す。形式などの指定が正しくない場合も400を返す。
+		var lineErr *taskio.LineError
+		if errors.As(err, &lineErr) {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		return taskErrorJSON(c, err)
+	}
+	if importRes.Failed > 0 {
+		return c.JSON(http.StatusUnprocessableEntity, importRes)
+	}
+	return c.JSON(http.StatusOK, importRes)
+}
+
+// ゴミ箱(削除済み)のタスクの一覧。
+func (tc *taskController) GetDeletedTasks(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	tasksRes, err := tc.tu.InWorkspace(currentWorkspaceId(c)).GetDeletedTasks(uint(userId.(float64)))
+	if err != nil {
+		return taskErrorJSON(c, err)
+	}
+	return c.JSON(http.StatusOK, tasksRes)
+}
+
+// ゴミ箱のタスクを復元する。一緒に削除されたサブタスクも復元される。
+func (tc *taskController) RestoreTask(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+
+	taskRes, err := tc.tu.InWorkspace(currentWorkspaceId(c)).RestoreTask(uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return taskErrorJSON(c, err)
+	}
+	c.Response().Header().Set("ETag", taskETag(taskRes.Version))
+	return c.JSON(http.StatusOK, taskRes)
+}
+
+// ユーザーの最後のタスクの操作を取り消す。
+// 取り消す操作の後に別の操作でタスクが変わっている場合は、If-Matchで指定したわけではないので409を返す。
+func (tc *taskController) UndoTask(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	res, err := tc.tu.InWorkspace(currentWorkspaceId(c)).UndoTask(uint(userId.(float64)))
+	if errors.Is(err, model.ErrTaskVersionConflict) {
+		return c.JSON(http.StatusConflict, err.Error())
+	}
+	if err != nil {
+		return taskErrorJSON(c, err)
+	}
+	if res.Task != nil {
+		c.Response().Header().Set("ETag", taskETag(res.Task.Version))
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// タスクを、指定したバージョンのときの状態に戻す。(例: POST /tasks/3/revert?to=5)
+// If-Matchを指定した場合は、今のタスクがそのバージョンのときだけ戻す。
+func (tc *taskController) RevertTask(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+
+	to, err := strconv.ParseUint(c.QueryParam("to"), 10, 64)
+	if err != nil || to == 0 {
+		return c.JSON(http.StatusBadRequest, "to must be a positive version number")
+	}
+	version, ok := parseIfMatch(c.Request().Header.Get("If-Match"))
+	if !ok {
+		return c.JSON(http.StatusPreconditionFailed, model.ErrTaskVersionConflict.Error())
+	}
+	taskRes, err := tc.tu.InWorkspace(currentWorkspaceId(c)).RevertTask(uint(userId.(float64)), uint(taskId), uint(to), version)
+	if err != nil {
+		return taskErrorJSON(c, err)
+	}
+	c.Response().Header().Set("ETag", taskETag(taskRes.Version))
+	return c.JSON(http.StatusOK, taskRes)
+}
+
+// タスクのバージョンからETagを作る。(例: "3")
+func taskETag(version uint) string {
+	return fmt.Sprintf("\"%d\"", version)
+}
+
+// If-Matchヘッダーから、更新・削除してよいタスクのバージョンを取り出す。
+// ヘッダーがない場合と「*」の場合は、バージョンを確認しない(0を返す)。
+// If-Matchは強い比較なので、弱いETag(W/"3")や、このAPIが返していない形式のETagは一致しないものとしてokにfalseを返す。
+func parseIfMatch(header string) (uint, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, true
+	}
+	if len(header) < 3 || header[0] != '"' || header[len(header)-1] != '"' {
+		return 0, false
+	}
+	version, err := strconv.ParseUint(header[1:len(header)-1], 10, 64)
+	if err != nil || version == 0 {
+		return 0, false
+	}
+	return uint(version), true
+}
+
+// If-None-MatchヘッダーのいずれかのETagがetagと一致するか判定する。If-None-Matchは弱い比較なので、W/は無視する。
+func ifNoneMatch(header string, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == "*" || v == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ozzo-validationのバリデーションのエラーかどうか。(ルールの中で起きた内部のエラーは含めない)
+func isValidationError(err error) bool {
+	var internal validation.InternalError
+	if errors.As(err, &internal) {
+		return false
+	}
+	var errs validation.Errors
+	var single validation.Error
+	return errors.As(err, &errs) || errors.As(err, &single)
+}
+
+// タスクの取得・更新・削除のエラーをレスポンスにする。バリデーションのエラーは400、タスクが見つからない場合は404を返す。
+// 他のリクエストで先に更新されていた場合は412を返す。
+// 取り消せる操作や、戻す先のスナップショットがない場合は404、共有しているプロジェクトの権限が足りない場合は403を返す。
+// 担当者やウォッチャーに、タスクを見ることができないユーザーを指定した場合は422、
+// 依存関係が循環する場合と、終わっていないタスクに依存しているタスクを完了にしようとした場合は409を返す。
+func taskErrorJSON(c echo.Context, err error) error {
+	if isValidationError(err) {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, err.Error())
+	}
+	if errors.Is(err, model.ErrTaskVersionConflict) {
+		return c.JSON(http.StatusPreconditionFailed, err.Error())
+	}
+	if errors.Is(err, model.ErrTaskUserNoAccess) {
+		return c.JSON(http.StatusUnprocessableEntity, err.Error())
+	}
+	if errors.Is(err, model.ErrTaskDependencyCycle) || errors.Is(err, model.ErrTaskBlocked) || errors.Is(err, model.ErrBoardWipLimitExceeded) {
+		return c.JSON(http.StatusConflict, err.Error())
+	}
+	if errors.Is(err, model.ErrNothingToUndo) || errors.Is(err, model.ErrTaskRevisionNotFound) {
+		return c.JSON(http.StatusNotFound, err.Error())
+	}
+	return projectErrorJSON(c, err)
+}
+
+// クエリパラメーターからタスク一覧の絞り込み条件を作成する。assignee=meはuserIdのユーザーにする。
+func parseTaskFilter(c echo.Context, userId uint) (model.TaskFilter, error) {
+	filter := model.TaskFilter{
+		Status: c.QueryParam("status"),
+		Title:  c.QueryParam("title"),
+		// /tasks?label=仕事&label=急ぎ のように複数指定できる。
+		Labels: c.QueryParams()["label"],
+	}
+	// 日付だけで指定された場合に使うタイムゾーン。
+	loc, err := parseLocation(c.QueryParam("tz"))
+	if err != nil {
+		return filter, err
+	}
+	// /tasks?project=1 でプロジェクトのタスク、/tasks?project=inbox でプロジェクトに属さないタスクに絞り込む。
+	if project := c.QueryParam("project"); project == "inbox" {
+		filter.Inbox = true
+	} else if project != "" {
+		id, err := strconv.ParseUint(project, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid project: %s", project)
+		}
+		projectId := uint(id)
+		filter.ProjectId = &projectId
+	}
+	// /tasks?parent=1 でサブタスク、/tasks?parent=root でサブタスクではないタスクに絞り込む。
+	if parent := c.QueryParam("parent"); parent == "root" {
+		filter.RootOnly = true
+	} else if parent != "" {
+		id, err := strconv.ParseUint(parent, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid parent: %s", parent)
+		}
+		parentId := uint(id)
+		filter.ParentId = &parentId
+	}
+	// /tasks?assignee=me で自分が担当しているタスク、/tasks?assignee=none で担当者のいないタスク、/tasks?assignee=3 でそのユーザーが担当しているタスクに絞り込む。
+	if assignee := c.QueryParam("assignee"); assignee == "me" {
+		filter.AssigneeId = &userId
+	} else if assignee == "none" {
+		filter.Unassigned = true
+	} else if assignee != "" {
+		id, err := strconv.ParseUint(assignee, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid assignee: %s", assignee)
+		}
+		assigneeId := uint(id)
+		filter.AssigneeId = &assigneeId
+	}
+	// /tasks?blocked=false で今すぐ取りかかれる(終わっていないタスクに依存していない)タスク、blocked=true で依存しているタスクに絞り込む。
+	if blocked := c.QueryParam("blocked"); blocked != "" {
+		b, err := strconv.ParseBool(blocked)
+		if err != nil {
+			return filter, fmt.Errorf("invalid blocked: %s", blocked)
+		}
+		filter.Blocked = &b
+	}
+	dueBefore, err := parseTimeParam(c.QueryParam("due_before"), loc)
+	if err != nil {
+		return filter, fmt.Errorf("invalid due_before: %w", err)
+	}
+	filter.DueBefore = dueBefore
+	dueAfter, err := parseTimeParam(c.QueryParam("due_after"), loc)
+	if err != nil {
+		return filter, fmt.Errorf("invalid due_after: %w", err)
+	}
+	filter.DueAfter = dueAfter
+	if overdue := c.QueryParam("overdue"); overdue != "" {
+		b, err := strconv.ParseBool(overdue)
+		if err != nil {
+			return filter, fmt.Errorf("invalid overdue: %s", overdue)
+		}
+		filter.Overdue = b
+	}
+	return filter, nil
+}
+
+// クエリパラメーターからページングと並び替えの指定を作成する。
+func parseTaskPage(c echo.Context) (model.TaskPage, error) {
+	page := model.TaskPage{
+		Sort:   c.QueryParam("sort"),
+		Order:  c.QueryParam("order"),
+		Cursor: c.QueryParam("cursor"),
+	}
+	if limit := c.QueryParam("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return page, fmt.Errorf("invalid limit: %s", limit)
+		}
+		page.Limit = l
+	}
+	return page, nil
+}
+
+// tzパラメーターでIANAのタイムゾーン名(例: Asia/Tokyo)を指定できる。省略した場合はUTCにする。
+func parseLocation(tz string) (*time.Location, error) {
+	if tz == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tz: %s", tz)
+	}
+	return loc, nil
+}
+
+// 日時のクエリパラメーターを解析する。空の場合はnilを返す。
+// RFC3339形式(例: 2023-07-01T09:00:00+09:00)はそのタイムゾーンで、
+// 日付だけの形式(例: 2023-07-01)はlocのタイムゾーンの0時として扱う。
+func parseTimeParam(value string, loc *time.Location) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	// URLエンコードされていない"+09:00"の"+"はスペースに変換されてしまうので元に戻す。
+	value = strings.ReplaceAll(value, " ", "+")
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not RFC3339 or YYYY-MM-DD", value)
+	}
+	return &t, nil
+}
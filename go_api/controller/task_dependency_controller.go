@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"go_api/model"
+	"go_api/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type ITaskDependencyController interface {
+	GetDependencies(c echo.Context) error
+	AddDependency(c echo.Context) error
+	RemoveDependency(c echo.Context) error
+}
+
+type taskDependencyController struct {
+	du usecase.ITaskDependencyUsecase
+}
+
+func NewTaskDependencyController(du usecase.ITaskDependencyUsecase) ITaskDependencyController {
+	return &taskDependencyController{du}
+}
+
+// タスクの依存関係のグラフ(DAG)を、頂点と辺の一覧で返す。
+func (dc *taskDependencyController) GetDependencies(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+
+	graphRes, err := dc.du.InWorkspace(currentWorkspaceId(c)).GetDependencies(uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return taskErrorJSON(c, err)
+	}
+	return c.JSON(http.StatusOK, graphRes)
+}
+
+// bodyで依存するタスクのidを受け取る。(例: {"depends_on_id": 3})
+func (dc *taskDependencyController) AddDependency(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+
+	req := model.TaskDependencyRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	dependencyRes, err := dc.du.InWorkspace(currentWorkspaceId(c)).AddDependency(req, uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return taskErrorJSON(c, err)
+	}
+	return c.JSON(http.StatusCreated, dependencyRes)
+}
+
+func (dc *taskDependencyController) RemoveDependency(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+	dependsOnId, _ := strconv.Atoi(c.Param("dependsOnId"))
+
+	if err := dc.du.InWorkspace(currentWorkspaceId(c)).RemoveDependency(uint(userId.(float64)), uint(taskId), uint(dependsOnId)); err != nil {
+		return taskErrorJSON(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
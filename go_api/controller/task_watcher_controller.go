@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"go_api/model"
+	"go_api/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type ITaskWatcherController interface {
+	GetWatchers(c echo.Context) error
+	AddWatcher(c echo.Context) error
+	RemoveWatcher(c echo.Context) error
+}
+
+type taskWatcherController struct {
+	wu usecase.ITaskWatcherUsecase
+}
+
+func NewTaskWatcherController(wu usecase.ITaskWatcherUsecase) ITaskWatcherController {
+	return &taskWatcherController{wu}
+}
+
+func (wc *taskWatcherController) GetWatchers(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+
+	watchersRes, err := wc.wu.InWorkspace(currentWorkspaceId(c)).GetWatchers(uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return taskErrorJSON(c, err)
+	}
+	return c.JSON(http.StatusOK, watchersRes)
+}
+
+// bodyのuser_idを省略した場合は、自分をウォッチャーにする。
+func (wc *taskWatcherController) AddWatcher(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+
+	req := model.TaskWatcherRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	watcherRes, err := wc.wu.InWorkspace(currentWorkspaceId(c)).AddWatcher(req, uint(userId.(float64)), uint(taskId))
+	if err != nil {
+		return taskErrorJSON(c, err)
+	}
+	return c.JSON(http.StatusCreated, watcherRes)
+}
+
+func (wc *taskWatcherController) RemoveWatcher(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("taskId")
+	taskId, _ := strconv.Atoi(id)
+	watcherId, _ := strconv.Atoi(c.Param("userId"))
+
+	if err := wc.wu.InWorkspace(currentWorkspaceId(c)).RemoveWatcher(uint(userId.(float64)), uint(taskId), uint(watcherId)); err != nil {
+		return taskErrorJSON(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
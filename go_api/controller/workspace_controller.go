@@ -0,0 +1,200 @@
+package controller
+
+import (
+	"errors"
+	"go_api/model"
+	"go_api/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type IWorkspaceController interface {
+	// リクエストのワークスペースを決めるミドルウェア。JWTのミドルウェアの後に使う。
+	RequireWorkspace(next echo.HandlerFunc) echo.HandlerFunc
+	GetWorkspaces(c echo.Context) error
+	CreateWorkspace(c echo.Context) error
+	UpdateWorkspace(c echo.Context) error
+	DeleteWorkspace(c echo.Context) error
+	GetMembers(c echo.Context) error
+	CreateMember(c echo.Context) error
+	UpdateMember(c echo.Context) error
+	DeleteMember(c echo.Context) error
+}
+
+type workspaceController struct {
+	wu usecase.IWorkspaceUsecase
+}
+
+func NewWorkspaceController(wu usecase.IWorkspaceUsecase) IWorkspaceController {
+	return &workspaceController{wu}
+}
+
+// ワークスペースのエラーを、HTTPのステータスコードに変換して返す。
+func workspaceErrorJSON(c echo.Context, err error) error {
+	switch {
+	case isValidationError(err):
+		return c.JSON(http.StatusBadRequest, err.Error())
+	case errors.Is(err, model.ErrWorkspaceNotFound), errors.Is(err, model.ErrWorkspaceUserNotFound):
+		return c.JSON(http.StatusNotFound, err.Error())
+	case errors.Is(err, model.ErrWorkspaceForbidden):
+		return c.JSON(http.StatusForbidden, err.Error())
+	case errors.Is(err, model.ErrLastWorkspaceOwner), errors.Is(err, model.ErrAlreadyWorkspaceMember), errors.Is(err, model.ErrPersonalWorkspace):
+		return c.JSON(http.StatusConflict, err.Error())
+	}
+	return c.JSON(http.StatusInternalServerError, err.Error())
+}
+
+// RequireWorkspaceで決めた、リクエストのワークスペースのid。
+func currentWorkspaceId(c echo.Context) uint {
+	return c.Get("workspace_id").(uint)
+}
+
+// X-Workspace-IDヘッダーのワークスペースのメンバーか確認し、idをcontextのworkspace_idに入れる。
+// ヘッダーがない場合は、ユーザーの個人用のワークスペースを使う。メンバーでないワークスペースは403にする。
+func (wc *workspaceController) RequireWorkspace(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user := c.Get("user").(*jwt.Token)
+		claims := user.Claims.(jwt.MapClaims)
+		userId := claims["user_id"]
+
+		var workspaceId uint64
+		if v := c.Request().Header.Get(model.WorkspaceHeader); v != "" {
+			id, err := strconv.ParseUint(v, 10, 32)
+			if err != nil || id == 0 {
+				return c.JSON(http.StatusBadRequest, "invalid "+model.WorkspaceHeader+" header")
+			}
+			workspaceId = id
+		}
+		workspaceRes, err := wc.wu.GetCurrentWorkspace(uint(userId.(float64)), uint(workspaceId))
+		if err != nil {
+			return workspaceErrorJSON(c, err)
+		}
+		c.Set("workspace_id", workspaceRes.ID)
+		return next(c)
+	}
+}
+
+func (wc *workspaceController) GetWorkspaces(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	workspacesRes, err := wc.wu.GetWorkspaces(uint(userId.(float64)))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, workspacesRes)
+}
+
+func (wc *workspaceController) CreateWorkspace(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	workspace := model.Workspace{}
+	if err := c.Bind(&workspace); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	workspace.UserId = uint(userId.(float64))
+	workspaceRes, err := wc.wu.CreateWorkspace(workspace)
+	if err != nil {
+		return workspaceErrorJSON(c, err)
+	}
+	return c.JSON(http.StatusCreated, workspaceRes)
+}
+
+func (wc *workspaceController) UpdateWorkspace(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	workspaceId, _ := strconv.Atoi(c.Param("workspaceId"))
+
+	workspace := model.Workspace{}
+	if err := c.Bind(&workspace); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	workspaceRes, err := wc.wu.UpdateWorkspace(workspace, uint(userId.(float64)), uint(workspaceId))
+	if err != nil {
+		return workspaceErrorJSON(c, err)
+	}
+	return c.JSON(http.StatusOK, workspaceRes)
+}
+
+// ワークスペースのタスク・プロジェクト・ラベルもすべて削除する。
+func (wc *workspaceController) DeleteWorkspace(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	workspaceId, _ := strconv.Atoi(c.Param("workspaceId"))
+
+	if err := wc.wu.DeleteWorkspace(uint(userId.(float64)), uint(workspaceId)); err != nil {
+		return workspaceErrorJSON(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (wc *workspaceController) GetMembers(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	workspaceId, _ := strconv.Atoi(c.Param("workspaceId"))
+
+	membersRes, err := wc.wu.GetMembers(uint(userId.(float64)), uint(workspaceId))
+	if err != nil {
+		return workspaceErrorJSON(c, err)
+	}
+	return c.JSON(http.StatusOK, membersRes)
+}
+
+// メールアドレスと権限を指定して、登録済みのユーザーをメンバーに追加する。
+func (wc *workspaceController) CreateMember(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	workspaceId, _ := strconv.Atoi(c.Param("workspaceId"))
+
+	req := model.WorkspaceMemberRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	memberRes, err := wc.wu.CreateMember(req, uint(userId.(float64)), uint(workspaceId))
+	if err != nil {
+		return workspaceErrorJSON(c, err)
+	}
+	return c.JSON(http.StatusCreated, memberRes)
+}
+
+func (wc *workspaceController) UpdateMember(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	workspaceId, _ := strconv.Atoi(c.Param("workspaceId"))
+	memberId, _ := strconv.Atoi(c.Param("userId"))
+
+	req := model.WorkspaceMemberRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	memberRes, err := wc.wu.UpdateMember(req, uint(userId.(float64)), uint(workspaceId), uint(memberId))
+	if err != nil {
+		return workspaceErrorJSON(c, err)
+	}
+	return c.JSON(http.StatusOK, memberRes)
+}
+
+// 自分のユーザーidを指定すると、ワークスペースから抜ける。
+func (wc *workspaceController) DeleteMember(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	workspaceId, _ := strconv.Atoi(c.Param("workspaceId"))
+	memberId, _ := strconv.Atoi(c.Param("userId"))
+
+	if err := wc.wu.DeleteMember(uint(userId.(float64)), uint(workspaceId), uint(memberId)); err != nil {
+		return workspaceErrorJSON(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"go_api/model"
+	"go_api/repository"
+	"go_api/usecase"
+	"go_api/validator"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// メンバーになっているワークスペースだけを返すリポジトリ。作成と更新は何もせずに成功する。ほかのメソッドは呼ばれない。
+type memberWorkspaceRepository struct {
+	repository.IWorkspaceRepository
+	userId      uint
+	workspaceId uint
+}
+
+func (r *memberWorkspaceRepository) GetWorkspaceById(workspace *model.Workspace, userId uint, workspaceId uint) error {
+	if userId != r.userId || workspaceId != r.workspaceId {
+		return gorm.ErrRecordNotFound
+	}
+	*workspace = model.Workspace{ID: workspaceId, Name: "team", UserId: userId, Role: model.WorkspaceRoleOwner}
+	return nil
+}
+
+func (r *memberWorkspaceRepository) GetPersonalWorkspace(workspace *model.Workspace, userId uint) error {
+	*workspace = model.Workspace{ID: 1, Name: model.PersonalWorkspaceName, Personal: true, UserId: userId, Role: model.WorkspaceRoleOwner}
+	return nil
+}
+
+func (r *memberWorkspaceRepository) CreateWorkspace(workspace *model.Workspace) error {
+	workspace.ID = r.workspaceId
+	return nil
+}
+
+func (r *memberWorkspaceRepository) UpdateWorkspace(workspace *model.Workspace, workspaceId uint) error {
+	workspace.ID = workspaceId
+	return nil
+}
+
+func TestRequireWorkspace(t *testing.T) {
+	wr := &memberWorkspaceRepository{userId: 10, workspaceId: 20}
+	wc := NewWorkspaceController(usecase.NewWorkspaceUsecase(wr, nil, nil, nil))
+	tests := []struct {
+		name            string
+		userId          uint
+		header          string
+		wantStatus      int
+		wantWorkspaceId uint
+	}{
+		{"personal workspace without header", 10, "", http.StatusOK, 1},
+		{"member", 10, "20", http.StatusOK, 20},
+		{"non-member", 11, "20", http.StatusForbidden, 0},
+		{"unknown workspace", 10, "30", http.StatusForbidden, 0},
+		{"invalid header", 10, "abc", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+			if tt.header != "" {
+				req.Header.Set(model.WorkspaceHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.Set("user", &jwt.Token{Claims: jwt.MapClaims{"user_id": float64(tt.userId)}})
+
+			var gotWorkspaceId uint
+			err := wc.RequireWorkspace(func(c echo.Context) error {
+				gotWorkspaceId = currentWorkspaceId(c)
+				return c.NoContent(http.StatusOK)
+			})(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if gotWorkspaceId != tt.wantWorkspaceId {
+				t.Errorf("workspace_id = %d, want %d", gotWorkspaceId, tt.wantWorkspaceId)
+			}
+		})
+	}
+}
+
+// 名前の検証エラーは、作成でも更新でも400にする。
+func TestWorkspaceValidation(t *testing.T) {
+	wr := &memberWorkspaceRepository{userId: 10, workspaceId: 20}
+	wc := NewWorkspaceController(usecase.NewWorkspaceUsecase(wr, nil, validator.NewWorkspaceValidator(), nil))
+	tests := []struct {
+		name       string
+		method     string
+		userId     uint
+		body       string
+		wantStatus int
+	}{
+		{"create", http.MethodPost, 10, `{"name":"team"}`, http.StatusCreated},
+		{"create without name", http.MethodPost, 10, `{"name":""}`, http.StatusBadRequest},
+		{"create with long name", http.MethodPost, 10, `{"name":"` + strings.Repeat("あ", 31) + `"}`, http.StatusBadRequest},
+		{"update", http.MethodPut, 10, `{"name":"team"}`, http.StatusOK},
+		{"update without name", http.MethodPut, 10, `{"name":""}`, http.StatusBadRequest},
+		{"update by non-member", http.MethodPut, 11, `{"name":"team"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(tt.method, "/workspaces", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.Set("user", &jwt.Token{Claims: jwt.MapClaims{"user_id": float64(tt.userId)}})
+
+			var err error
+			if tt.method == http.MethodPost {
+				err = wc.CreateWorkspace(c)
+			} else {
+				c.SetParamNames("workspaceId")
+				c.SetParamValues("20")
+				err = wc.UpdateWorkspace(c)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+}
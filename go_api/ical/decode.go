@@ -0,0 +1,223 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// 読み込みのエラー。何行目で起きたかを持つ。
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// VCALENDARの中のコンポーネント(VTODO、VEVENTなど)を、1つずつ読み込む。
+// ファイル全体をメモリーに載せないので、大きなファイルも読み込める。
+type Decoder struct {
+	scanner *bufio.Scanner
+	line    int
+	// 折り返しの続きかどうかを確認するために先読みした行。
+	pending *physicalLine
+	// 今読んでいるVCALENDARと、そのプロパティ(PRODIDなど)。
+	Calendar   *Component
+	inCalendar bool
+}
+
+type physicalLine struct {
+	number int
+	text   string
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	return &Decoder{scanner: scanner}
+}
+
+// 次のコンポーネントを返す。最後まで読んだらio.EOFを返す。
+// 1つのファイルにVCALENDARが複数ある場合も、続けて読み込む。
+func (d *Decoder) Next() (*Component, error) {
+	for {
+		text, number, err := d.readLine()
+		if err == io.EOF {
+			if d.inCalendar {
+				return nil, &ParseError{Line: d.line, Err: errors.New("missing END:VCALENDAR")}
+			}
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		p, err := parseProperty(text)
+		if err != nil {
+			return nil, &ParseError{Line: number, Err: err}
+		}
+		p.Line = number
+		isCalendar := strings.EqualFold(p.Value, VCalendar)
+		switch {
+		case p.Name == "BEGIN" && isCalendar:
+			if d.inCalendar {
+				return nil, &ParseError{Line: number, Err: errors.New("nested VCALENDAR")}
+			}
+			d.inCalendar = true
+			d.Calendar = &Component{Name: VCalendar, Line: number}
+		case p.Name == "END" && isCalendar:
+			if !d.inCalendar {
+				return nil, &ParseError{Line: number, Err: errors.New("unexpected END:VCALENDAR")}
+			}
+			d.inCalendar = false
+		case !d.inCalendar:
+			return nil, &ParseError{Line: number, Err: errors.New("expected BEGIN:VCALENDAR")}
+		case p.Name == "BEGIN":
+			return d.readComponent(strings.ToUpper(p.Value), number)
+		case p.Name == "END":
+			return nil, &ParseError{Line: number, Err: fmt.Errorf("unexpected END:%s", p.Value)}
+		default:
+			d.Calendar.Properties = append(d.Calendar.Properties, p)
+		}
+	}
+}
+
+// BEGINの次の行から、対応するENDまでを読み込む。
+func (d *Decoder) readComponent(name string, line int) (*Component, error) {
+	c := &Component{Name: name, Line: line}
+	for {
+		text, number, err := d.readLine()
+		if err == io.EOF {
+			return nil, &ParseError{Line: d.line, Err: fmt.Errorf("missing END:%s", name)}
+		}
+		if err != nil {
+			return nil, err
+		}
+		p, err := parseProperty(text)
+		if err != nil {
+			return nil, &ParseError{Line: number, Err: err}
+		}
+		p.Line = number
+		switch p.Name {
+		case "BEGIN":
+			child, err := d.readComponent(strings.ToUpper(p.Value), number)
+			if err != nil {
+				return nil, err
+			}
+			c.Components = append(c.Components, child)
+		case "END":
+			if !strings.EqualFold(p.Value, name) {
+				return nil, &ParseError{Line: number, Err: fmt.Errorf("expected END:%s", name)}
+			}
+			return c, nil
+		default:
+			c.Properties = append(c.Properties, p)
+		}
+	}
+}
+
+// 折り返しを戻した1行と、その行が始まる行番号を返す。空行は読み飛ばす。
+func (d *Decoder) readLine() (string, int, error) {
+	first := d.pending
+	d.pending = nil
+	for first == nil || first.text == "" {
+		l, err := d.readPhysicalLine()
+		if err != nil {
+			return "", 0, err
+		}
+		first = l
+	}
+	var b strings.Builder
+	b.WriteString(first.text)
+	for {
+		l, err := d.readPhysicalLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", 0, err
+		}
+		// 空白またはタブで始まる行は、前の行の続き。
+		if strings.HasPrefix(l.text, " ") || strings.HasPrefix(l.text, "\t") {
+			b.WriteString(l.text[1:])
+			continue
+		}
+		d.pending = l
+		break
+	}
+	return b.String(), first.number, nil
+}
+
+func (d *Decoder) readPhysicalLine() (*physicalLine, error) {
+	if !d.scanner.Scan() {
+		if err := d.scanner.Err(); err != nil {
+			return nil, &ParseError{Line: d.line + 1, Err: err}
+		}
+		return nil, io.EOF
+	}
+	d.line++
+	text := strings.TrimSuffix(d.scanner.Text(), "\r")
+	if d.line == 1 {
+		text = strings.TrimPrefix(text, "\ufeff")
+	}
+	return &physicalLine{number: d.line, text: text}, nil
+}
+
+// 「名前;パラメーター=値:値」の形式の1行を解析する。パラメーターの値はダブルクォートで囲まれていてもよい。
+func parseProperty(line string) (Property, error) {
+	p := Property{}
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return p, errors.New("invalid content line")
+	}
+	p.Name = strings.ToUpper(line[:i])
+	rest := line[i:]
+	for rest[0] == ';' {
+		rest = rest[1:]
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return p, errors.New("invalid parameter")
+		}
+		param := Param{Name: strings.ToUpper(rest[:eq])}
+		rest = rest[eq+1:]
+		for {
+			var v string
+			if strings.HasPrefix(rest, `"`) {
+				end := strings.IndexByte(rest[1:], '"')
+				if end < 0 {
+					return p, errors.New("unterminated quoted parameter")
+				}
+				v = rest[1 : end+1]
+				rest = rest[end+2:]
+			} else {
+				end := strings.IndexAny(rest, ",;:")
+				if end < 0 {
+					return p, errors.New("invalid parameter")
+				}
+				v = rest[:end]
+				rest = rest[end:]
+			}
+			param.Values = append(param.Values, v)
+			if !strings.HasPrefix(rest, ",") {
+				break
+			}
+			rest = rest[1:]
+		}
+		p.Params = append(p.Params, param)
+		if rest == "" {
+			return p, errors.New("missing value")
+		}
+	}
+	if rest[0] != ':' {
+		return p, errors.New("invalid content line")
+	}
+	p.Value = rest[1:]
+	return p, nil
+}
@@ -0,0 +1,110 @@
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// 1行の最大の長さ(オクテット)。改行のCRLFは含まない。
+const maxLineOctets = 75
+
+// コンポーネントを書き出す。VCALENDARの中身を少しずつ書き出したい場合は、Encoderを使う。
+func Encode(w io.Writer, c *Component) error {
+	e := NewEncoder(w)
+	if err := e.Encode(c); err != nil {
+		return err
+	}
+	return e.Flush()
+}
+
+// コンポーネントを順番に書き出す。
+// Begin・Endでコンポーネントの開始と終了を書き、その間でEncodeを呼ぶと、入れ子になったコンポーネントをすべてメモリーに載せずに書き出せる。
+type Encoder struct {
+	w *bufio.Writer
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: bufio.NewWriter(w)}
+}
+
+func (e *Encoder) Begin(name string) error {
+	return e.writeLine("BEGIN:" + name)
+}
+
+func (e *Encoder) End(name string) error {
+	return e.writeLine("END:" + name)
+}
+
+// プロパティを1行書き出す。
+func (e *Encoder) WriteProperty(p Property) error {
+	var b strings.Builder
+	b.WriteString(strings.ToUpper(p.Name))
+	for _, param := range p.Params {
+		b.WriteByte(';')
+		b.WriteString(strings.ToUpper(param.Name))
+		b.WriteByte('=')
+		for i, v := range param.Values {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(quoteParam(v))
+		}
+	}
+	b.WriteByte(':')
+	b.WriteString(p.Value)
+	return e.writeLine(b.String())
+}
+
+// コンポーネントを、中のコンポーネントも含めて書き出す。
+func (e *Encoder) Encode(c *Component) error {
+	if err := e.Begin(c.Name); err != nil {
+		return err
+	}
+	for _, p := range c.Properties {
+		if err := e.WriteProperty(p); err != nil {
+			return err
+		}
+	}
+	for _, child := range c.Components {
+		if err := e.Encode(child); err != nil {
+			return err
+		}
+	}
+	return e.End(c.Name)
+}
+
+func (e *Encoder) Flush() error {
+	return e.w.Flush()
+}
+
+// 1行を75オクテットごとに折り返して書き出す。続きの行は空白で始める。
+// UTF-8の文字の途中では折り返さない。
+func (e *Encoder) writeLine(line string) error {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		if _, err := e.w.WriteString(line[:cut] + "\r\n "); err != nil {
+			return err
+		}
+		line = line[cut:]
+		// 続きの行は先頭の空白の分だけ短くする。
+		limit = maxLineOctets - 1
+	}
+	_, err := e.w.WriteString(line + "\r\n")
+	return err
+}
+
+// パラメーターの値にコロンやセミコロン、カンマが含まれる場合は、ダブルクォートで囲む。
+// ダブルクォートは値に含められないので取り除く。
+func quoteParam(v string) string {
+	v = strings.ReplaceAll(v, `"`, "")
+	if strings.ContainsAny(v, ":;,") {
+		return `"` + v + `"`
+	}
+	return v
+}
@@ -0,0 +1,201 @@
+// iCalendar(RFC 5545)の形式を読み書きするパッケージ
+// コンポーネント(BEGIN:〜END:)とプロパティ(名前;パラメーター:値)の入れ子を、そのままの構造で扱う。
+// 書き出すときは行を75オクテットで折り返してCRLFで区切り、読み込むときは折り返しを元に戻す。
+package ical
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// よく使うコンポーネントの名前。
+const (
+	VCalendar = "VCALENDAR"
+	VTodo     = "VTODO"
+	VEvent    = "VEVENT"
+)
+
+// プロパティのパラメーター(例: TZID=Asia/Tokyo)。1つのパラメーターに複数の値を持てる。
+type Param struct {
+	Name   string
+	Values []string
+}
+
+// プロパティ。Valueはエスケープしていない値を持つ。
+// TEXT型の値はAddTextで追加し、TextValueで取り出すと、エスケープを意識しなくてよい。
+type Property struct {
+	Name   string
+	Params []Param
+	Value  string
+	// 読み込んだときの、プロパティが始まる行番号(1始まり)。
+	Line int
+}
+
+// パラメーターの最初の値を返す。ない場合は空文字。
+func (p Property) Param(name string) string {
+	for _, v := range p.Params {
+		if strings.EqualFold(v.Name, name) && len(v.Values) > 0 {
+			return v.Values[0]
+		}
+	}
+	return ""
+}
+
+// TEXT型の値のエスケープを戻して返す。
+func (p Property) TextValue() string {
+	return UnescapeText(p.Value)
+}
+
+// コンポーネント。VCALENDARの中にVTODOがあるように、コンポーネントの中にコンポーネントを持てる。
+type Component struct {
+	Name       string
+	Properties []Property
+	Components []*Component
+	// 読み込んだときの、BEGINの行番号(1始まり)。
+	Line int
+}
+
+func NewComponent(name string) *Component {
+	return &Component{Name: name}
+}
+
+// プロパティを追加する。値はエスケープしないので、TEXT型の値はAddTextを使う。
+func (c *Component) Add(name string, value string, params ...Param) {
+	c.Properties = append(c.Properties, Property{Name: name, Params: params, Value: value})
+}
+
+// TEXT型のプロパティを、エスケープして追加する。
+func (c *Component) AddText(name string, value string, params ...Param) {
+	c.Add(name, EscapeText(value), params...)
+}
+
+// 日時のプロパティを、UTCの形式(例: 20240501T090000Z)で追加する。
+func (c *Component) AddDateTime(name string, t time.Time) {
+	c.Add(name, FormatDateTime(t))
+}
+
+// 名前が一致する最初のプロパティを返す。
+func (c *Component) Get(name string) (Property, bool) {
+	for _, p := range c.Properties {
+		if strings.EqualFold(p.Name, name) {
+			return p, true
+		}
+	}
+	return Property{}, false
+}
+
+// 名前が一致するすべてのプロパティを返す。(CATEGORIESのように、同じプロパティが複数回出てくるもの用)
+func (c *Component) GetAll(name string) []Property {
+	props := []Property{}
+	for _, p := range c.Properties {
+		if strings.EqualFold(p.Name, name) {
+			props = append(props, p)
+		}
+	}
+	return props
+}
+
+// TEXT型の値のエスケープ。バックスラッシュ、セミコロン、カンマ、改行をエスケープする。
+func EscapeText(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ReplaceAll(s, "\r\n", "\n") {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case ';':
+			b.WriteString(`\;`)
+		case ',':
+			b.WriteString(`\,`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\n`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// EscapeTextの逆。不正なエスケープ(\x など)は、バックスラッシュを除いてそのままにする。
+func UnescapeText(s string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range s {
+		if !escaped {
+			if r == '\\' {
+				escaped = true
+			} else {
+				b.WriteRune(r)
+			}
+			continue
+		}
+		escaped = false
+		switch r {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// カンマ区切りのTEXT型の値(CATEGORIESなど)を分割する。エスケープされたカンマでは分割しない。
+func SplitText(s string) []string {
+	values := []string{}
+	start := 0
+	escaped := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case s[i] == '\\':
+			escaped = true
+		case s[i] == ',':
+			values = append(values, UnescapeText(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(values, UnescapeText(s[start:]))
+}
+
+const (
+	dateTimeFormat    = "20060102T150405Z"
+	dateTimeLocFormat = "20060102T150405"
+	dateFormat        = "20060102"
+)
+
+func FormatDateTime(t time.Time) string {
+	return t.UTC().Format(dateTimeFormat)
+}
+
+// DATE-TIME型またはDATE型の値を解析する。
+// 末尾にZのあるものはUTC、TZIDパラメーターのあるものはそのタイムゾーン、どちらもないもの(フローティング)はlocの時刻として扱う。
+// TZIDがIANAのタイムゾーン名でない場合(VTIMEZONEで独自に定義されたものなど)もlocを使う。
+// DATE型の場合はlocの0時にし、dateOnlyにtrueを返す。
+func ParseDateTime(p Property, loc *time.Location) (t time.Time, dateOnly bool, err error) {
+	value := strings.TrimSpace(p.Value)
+	if tzid := p.Param("TZID"); tzid != "" {
+		if l, err := time.LoadLocation(strings.TrimPrefix(tzid, "/")); err == nil {
+			loc = l
+		}
+	}
+	if strings.EqualFold(p.Param("VALUE"), "DATE") || len(value) == len(dateFormat) {
+		t, err = time.ParseInLocation(dateFormat, value, loc)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid date %q", value)
+		}
+		return t, true, nil
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.Parse(dateTimeFormat, value)
+	} else {
+		t, err = time.ParseInLocation(dateTimeLocFormat, value, loc)
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid date-time %q", value)
+	}
+	return t, false, nil
+}
@@ -0,0 +1,227 @@
+package ical
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+	"unicode/utf8"
+)
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"買い物", "買い物"},
+		{`a\b`, `a\\b`},
+		{"a;b,c", `a\;b\,c`},
+		{"1行目\n2行目", `1行目\n2行目`},
+		{"1行目\r\n2行目", `1行目\n2行目`},
+	}
+	for _, tt := range tests {
+		got := EscapeText(tt.in)
+		if got != tt.want {
+			t.Errorf("EscapeText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if back := UnescapeText(got); back != strings.ReplaceAll(tt.in, "\r\n", "\n") {
+			t.Errorf("UnescapeText(%q) = %q", got, back)
+		}
+	}
+	if got := UnescapeText(`a\Nb\xc\`); got != "a\nbxc" {
+		t.Errorf("UnescapeText() = %q", got)
+	}
+}
+
+func TestSplitText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"家", []string{"家"}},
+		{"家,急ぎ", []string{"家", "急ぎ"}},
+		{`a\,b,c\\,d`, []string{"a,b", `c\`, "d"}},
+		{"", []string{""}},
+	}
+	for _, tt := range tests {
+		if got := SplitText(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// 75オクテットで折り返し、UTF-8の文字の途中では切らない。読み込むと元の値に戻る。
+func TestEncodeFolding(t *testing.T) {
+	c := NewComponent(VTodo)
+	summary := strings.Repeat("あいうえおabc", 20)
+	c.AddText("SUMMARY", summary)
+	c.Add("X-LONG", strings.Repeat("x", 200), Param{Name: "X-PARAM", Values: []string{"a:b", `c"d`}})
+	var buf bytes.Buffer
+	if err := Encode(&buf, c); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.HasSuffix(out, "END:VTODO\r\n") {
+		t.Errorf("output does not end with CRLF: %q", out)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(line) > maxLineOctets {
+			t.Errorf("line %q is %d octets", line, len(line))
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("line %q is split in the middle of a character", line)
+		}
+	}
+
+	dec := NewDecoder(strings.NewReader("BEGIN:VCALENDAR\r\n" + out + "END:VCALENDAR\r\n"))
+	got, err := dec.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := got.Get("SUMMARY"); p.TextValue() != summary {
+		t.Errorf("SUMMARY = %q, want %q", p.TextValue(), summary)
+	}
+	p, _ := got.Get("X-LONG")
+	if p.Value != strings.Repeat("x", 200) {
+		t.Errorf("X-LONG = %q", p.Value)
+	}
+	if want := []Param{{Name: "X-PARAM", Values: []string{"a:b", "cd"}}}; !reflect.DeepEqual(p.Params, want) {
+		t.Errorf("X-LONG params = %v, want %v", p.Params, want)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	in := strings.Join([]string{
+		"\ufeffBEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//example//JA",
+		"BEGIN:VTIMEZONE",
+		"TZID:Asia/Tokyo",
+		"END:VTIMEZONE",
+		"",
+		"begin:vtodo",
+		"UID:1",
+		"SUMMARY;LANGUAGE=ja:買い",
+		" 物",
+		"CATEGORIES:家,急ぎ",
+		"CATEGORIES:仕事",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"END:VALARM",
+		"END:VTODO",
+		"END:VCALENDAR",
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"UID:2",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+	dec := NewDecoder(strings.NewReader(in))
+	names := []string{}
+	var todo *Component
+	for {
+		c, err := dec.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, c.Name)
+		if c.Name == VTodo {
+			todo = c
+		}
+	}
+	if want := []string{"VTIMEZONE", VTodo, VEvent}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("components = %v, want %v", names, want)
+	}
+	if todo.Line != 8 {
+		t.Errorf("VTODO line = %d, want 8", todo.Line)
+	}
+	p, _ := todo.Get("summary")
+	if p.Value != "買い物" || p.Param("language") != "ja" || p.Line != 10 {
+		t.Errorf("SUMMARY = %+v", p)
+	}
+	if got := todo.GetAll("CATEGORIES"); len(got) != 2 || got[1].Value != "仕事" {
+		t.Errorf("CATEGORIES = %+v", got)
+	}
+	if len(todo.Components) != 1 || todo.Components[0].Name != "VALARM" {
+		t.Errorf("VTODO components = %+v", todo.Components)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		line int
+	}{
+		{"not a calendar", "BEGIN:VTODO\nEND:VTODO\n", 1},
+		{"invalid content line", "BEGIN:VCALENDAR\nBEGIN:VTODO\nSUMMARY\nEND:VTODO\nEND:VCALENDAR\n", 3},
+		{"invalid parameter", "BEGIN:VCALENDAR\nBEGIN:VTODO\nSUMMARY;LANGUAGE:a\nEND:VTODO\nEND:VCALENDAR\n", 3},
+		{"unterminated quote", "BEGIN:VCALENDAR\nBEGIN:VTODO\nSUMMARY;X=\"a:b\nEND:VTODO\nEND:VCALENDAR\n", 3},
+		{"mismatched end", "BEGIN:VCALENDAR\nBEGIN:VTODO\nEND:VEVENT\nEND:VCALENDAR\n", 3},
+		{"missing end", "BEGIN:VCALENDAR\nBEGIN:VTODO\nSUMMARY:a\n", 3},
+		{"missing end of calendar", "BEGIN:VCALENDAR\nVERSION:2.0\n", 2},
+		{"nested calendar", "BEGIN:VCALENDAR\nBEGIN:VCALENDAR\n", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(tt.in))
+			var err error
+			for err == nil {
+				_, err = dec.Next()
+			}
+			var pe *ParseError
+			if !errors.As(err, &pe) || pe.Line != tt.line {
+				t.Errorf("Next() = %v, want ParseError on line %d", err, tt.line)
+			}
+		})
+	}
+}
+
+func TestParseDateTime(t *testing.T) {
+	jst := time.FixedZone("JST", 9*60*60)
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tzid := func(v string) []Param {
+		return []Param{{Name: "TZID", Values: []string{v}}}
+	}
+	tests := []struct {
+		name     string
+		prop     Property
+		want     time.Time
+		dateOnly bool
+	}{
+		{"utc", Property{Value: "20240501T090000Z"}, time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC), false},
+		{"floating uses loc", Property{Value: "20240501T090000"}, time.Date(2024, 5, 1, 9, 0, 0, 0, jst), false},
+		{"tzid", Property{Value: "20240501T090000", Params: tzid("Asia/Tokyo")}, time.Date(2024, 5, 1, 9, 0, 0, 0, tokyo), false},
+		{"unknown tzid uses loc", Property{Value: "20240501T090000", Params: tzid("Tokyo Standard Time")}, time.Date(2024, 5, 1, 9, 0, 0, 0, jst), false},
+		{"date", Property{Value: "20240501", Params: []Param{{Name: "VALUE", Values: []string{"DATE"}}}}, time.Date(2024, 5, 1, 0, 0, 0, 0, jst), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, dateOnly, err := ParseDateTime(tt.prop, jst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) || dateOnly != tt.dateOnly {
+				t.Errorf("ParseDateTime() = %v, %v, want %v, %v", got, dateOnly, tt.want, tt.dateOnly)
+			}
+		})
+	}
+	for _, v := range []string{"", "2024-05-01", "20240501T0900", "20241301T090000Z"} {
+		if got, _, err := ParseDateTime(Property{Value: v}, jst); err == nil {
+			t.Errorf("ParseDateTime(%q) = %v, want error", v, got)
+		}
+	}
+	if got := FormatDateTime(time.Date(2024, 5, 1, 18, 0, 0, 0, jst)); got != "20240501T090000Z" {
+		t.Errorf("FormatDateTime() = %q", got)
+	}
+}
@@ -5,8 +5,13 @@ import (
 	"go_api/db"
 	"go_api/repository"
 	"go_api/router"
+	"go_api/storage"
 	"go_api/usecase"
 	"go_api/validator"
+	"log"
+	"os"
+	"strconv"
+	"time"
 )
 
 func main() {
@@ -15,21 +20,72 @@ func main() {
 	// validatorのコンストラクターを実行し、構造体のインスタンスを作成する。
 	userValidator := validator.NewUserValidator()
 	taskValidator := validator.NewTaskValidator()
+	labelValidator := validator.NewLabelValidator()
+	projectValidator := validator.NewProjectValidator()
+	attachmentValidator := validator.NewAttachmentValidator()
+	commentValidator := validator.NewCommentValidator()
+	projectMemberValidator := validator.NewProjectMemberValidator()
+	workspaceValidator := validator.NewWorkspaceValidator()
+	boardValidator := validator.NewBoardValidator()
+	savedViewValidator := validator.NewSavedViewValidator()
 	// リポジトリで作ったコンストラクターを起動する。 repositoryパッケージで作成したものを実行する。インスタンス化してあるdbを引数として注入。
 	userRepository := repository.NewUserRepository(db)
 	taskRepository := repository.NewTaskRepository(db)
 	mypageRepository := repository.NewMypageRepository(db)
+	labelRepository := repository.NewLabelRepository(db)
+	projectRepository := repository.NewProjectRepository(db)
+	calendarFeedRepository := repository.NewCalendarFeedRepository(db)
+	attachmentRepository := repository.NewAttachmentRepository(db)
+	commentRepository := repository.NewCommentRepository(db)
+	activityRepository := repository.NewActivityRepository(db)
+	projectMemberRepository := repository.NewProjectMemberRepository(db)
+	workspaceRepository := repository.NewWorkspaceRepository(db)
+	taskWatcherRepository := repository.NewTaskWatcherRepository(db)
+	taskDependencyRepository := repository.NewTaskDependencyRepository(db)
+	boardRepository := repository.NewBoardRepository(db)
+	savedViewRepository := repository.NewSavedViewRepository(db)
+	// 添付ファイルの中身の保存先。環境変数STORAGE_DRIVERで、ローカルのディレクトリかS3互換のストレージかを選ぶ。
+	fileStorage, err := storage.NewStorageFromEnv()
+	if err != nil {
+		log.Fatalln(err)
+	}
 	// ユースケースとタスクのコンストラクターも起動する。userRepositoryを引数にする。
 	// validatorのインスタンスをユースケースのコンストラクターに渡す。
 	userUsecase := usecase.NewUserUsecase(userRepository, userValidator)
-	taskUsecase := usecase.NewTaskUsecase(taskRepository, taskValidator)
+	taskUsecase := usecase.NewTaskUsecase(taskRepository, projectRepository, taskValidator, labelValidator, fileStorage)
 	mypageUsecase := usecase.NewMypageUsecase(mypageRepository)
+	labelUsecase := usecase.NewLabelUsecase(labelRepository, labelValidator)
+	projectUsecase := usecase.NewProjectUsecase(projectRepository, projectValidator)
+	calendarUsecase := usecase.NewCalendarUsecase(calendarFeedRepository, taskRepository)
+	attachmentUsecase := usecase.NewAttachmentUsecase(attachmentRepository, taskRepository, attachmentValidator, fileStorage)
+	commentUsecase := usecase.NewCommentUsecase(commentRepository, taskRepository, commentValidator)
+	activityUsecase := usecase.NewActivityUsecase(activityRepository)
+	projectMemberUsecase := usecase.NewProjectMemberUsecase(projectMemberRepository, projectRepository, mypageRepository, projectMemberValidator)
+	workspaceUsecase := usecase.NewWorkspaceUsecase(workspaceRepository, userRepository, workspaceValidator, fileStorage)
+	taskWatcherUsecase := usecase.NewTaskWatcherUsecase(taskWatcherRepository, taskRepository)
+	taskDependencyUsecase := usecase.NewTaskDependencyUsecase(taskDependencyRepository, taskRepository)
+	boardUsecase := usecase.NewBoardUsecase(boardRepository, taskUsecase, boardValidator)
+	savedViewUsecase := usecase.NewSavedViewUsecase(savedViewRepository, taskUsecase, savedViewValidator)
 	// コントローラーのコンストラクターを起動する。userUsecase, taskUsecaseのインスタンスを引数として注入
 	userController := controller.NewUserController(userUsecase)
 	taskController := controller.NewTaskController(taskUsecase)
 	mypageController := controller.NewMypageContorller(mypageUsecase)
+	labelController := controller.NewLabelController(labelUsecase)
+	projectController := controller.NewProjectController(projectUsecase)
+	calendarController := controller.NewCalendarController(calendarUsecase)
+	attachmentController := controller.NewAttachmentController(attachmentUsecase)
+	commentController := controller.NewCommentController(commentUsecase)
+	activityController := controller.NewActivityController(activityUsecase)
+	projectMemberController := controller.NewProjectMemberController(projectMemberUsecase)
+	workspaceController := controller.NewWorkspaceController(workspaceUsecase)
+	taskWatcherController := controller.NewTaskWatcherController(taskWatcherUsecase)
+	taskDependencyController := controller.NewTaskDependencyController(taskDependencyUsecase)
+	boardController := controller.NewBoardController(boardUsecase)
+	savedViewController := controller.NewSavedViewController(savedViewUsecase)
+	// ゴミ箱のタスクを、保存期間が過ぎたものから完全に削除する処理をバックグラウンドで動かしておく。
+	go purgeDeletedTasks(taskUsecase)
 	// routerの呼び出し。コントローラーを引数として注入。
-	e := router.NewRouter(userController, taskController, mypageController)
+	e := router.NewRouter(userController, taskController, mypageController, labelController, projectController, calendarController, attachmentController, commentController, activityController, projectMemberController, workspaceController, taskWatcherController, taskDependencyController, boardController, savedViewController)
 	// echoインスタンスを使用し、サーバーを起動する。
 	// e.Startで起動できる。ポートは8080。エラーが発生したとき、echoのLogger機能を使いログ情報を出力した後にプログラムを強制終了する。
 	e.Logger.Fatal(e.Start(":8080"))
@@ -37,3 +93,30 @@ func main() {
 	// dependency injectionを追加。
 
 }
+
+// ゴミ箱の保存期間の初期値(日数)。環境変数TASK_TRASH_RETENTION_DAYSで変更できる。
+const defaultTrashRetentionDays = 30
+
+// 1時間ごとに、保存期間が過ぎたゴミ箱のタスクを完全に削除する。
+func purgeDeletedTasks(tu usecase.ITaskUsecase) {
+	days := defaultTrashRetentionDays
+	if v := os.Getenv("TASK_TRASH_RETENTION_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			log.Printf("invalid TASK_TRASH_RETENTION_DAYS %q, using %d days", v, defaultTrashRetentionDays)
+		} else {
+			days = n
+		}
+	}
+	retention := time.Duration(days) * 24 * time.Hour
+	for {
+		count, err := tu.PurgeDeletedTasks(retention)
+		if err != nil {
+			log.Printf("failed to purge deleted tasks: %v", err)
+		}
+		if count > 0 {
+			log.Printf("purged %d deleted tasks", count)
+		}
+		time.Sleep(time.Hour)
+	}
+}
@@ -0,0 +1,222 @@
+// ユーザーが書いたMarkdownを、保存する前に無害化するパッケージ
+// フロントエンドでHTMLに変換して表示するので、スクリプトが動く原因になるものを取り除く。
+//   - 生のHTML: コードの外の「<」を「&lt;」にして、タグとして解釈されないようにする。
+//   - リンク: http、https、mailto以外のスキーム(javascript:など)のURLを「#」に置き換える。
+//
+// コードブロック(```、~~~)とインラインコード(`)の中は表示されるだけなので、そのままにする。
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// リンクに使えるスキーム。スキームのない相対URLはそのまま使える。
+var allowedSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+// 「<」を残してよい自動リンク(例: <https://example.com>)。
+var autolinkPattern = regexp.MustCompile(`^<(?i:https?://|mailto:)[^<>\s]*>`)
+
+// インラインのリンク・画像のURL部分。空白か対応する「)」の手前まで。(URLの中の1段までのかっこは含める)
+const inlineDestination = `(<[^>]*>|(?:[^\s()]|\([^\s()]*\))*)`
+
+// 以下のパターンは、1番目のグループがURLの手前の部分、2番目のグループがURL。
+var (
+	// インラインのリンク・画像(例: [a](https://example.com))の「](」から。
+	inlineLinkPattern = regexp.MustCompile(`^(\]\(\s*)` + inlineDestination)
+	// 「](」で終わった行の次の行の先頭。
+	inlineContinuationPattern = regexp.MustCompile(`^(\s*)` + inlineDestination)
+	// 参照リンクの定義(例: [id]: https://example.com)。
+	linkDefinitionPattern = regexp.MustCompile(`^( {0,3}\[[^\]]+\]:\s*)(<[^>]*>|\S+)`)
+	// 「[id]:」で終わった行の次の行の先頭。
+	definitionContinuationPattern = regexp.MustCompile(`^(\s*)(<[^>]*>|\S+)`)
+	// 前の行から続いている参照リンクのラベルの終わり(例: [長い\nラベル]: https://example.com の2行目)。
+	labelContinuationPattern = regexp.MustCompile(`^([^\[\]]*\]:\s*)(<[^>]*>|\S+)`)
+)
+
+// 行の終わりで、リンクが次の行に続いているかを判定するパターン。
+var (
+	openDefinitionPattern = regexp.MustCompile(`^ {0,3}\[[^\]]+\]:\s*$`)
+	openLabelPattern      = regexp.MustCompile(`^ {0,3}\[[^\]]*$`)
+	openLabelEndPattern   = regexp.MustCompile(`^[^\[\]]*\]:\s*$`)
+	labelContinuesPattern = regexp.MustCompile(`^[^\[\]]*$`)
+)
+
+// Markdownでは、リンクのURLの手前で1回まで改行でき、参照リンクのラベルの途中でも改行できる。(例: [a](改行javascript:alert(1)))
+// 行ごとに無害化するので、前の行がどこで終わったかを次の行に引き継ぐ。
+type continuation int
+
+const (
+	continuesNone continuation = iota
+	// 「](」で行が終わり、次の行の先頭がURL。
+	continuesInlineDestination
+	// 「[id]:」で行が終わり、次の行の先頭がURL。
+	continuesDefinitionDestination
+	// 「[」で始まった参照リンクのラベルが、次の行に続いている。
+	continuesDefinitionLabel
+)
+
+func Sanitize(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	lines := strings.Split(s, "\n")
+	fence := ""
+	cont := continuesNone
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		if fence != "" {
+			// コードブロックの中。開始と同じ記号で閉じるまではそのままにする。
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]+" ") == "" {
+				fence = ""
+			}
+			lines[i] = removeControlChars(line)
+			continue
+		}
+		if f := fenceOf(trimmed); f != "" && len(line)-len(trimmed) <= 3 {
+			fence = f
+			cont = continuesNone
+			lines[i] = removeControlChars(line)
+			continue
+		}
+		lines[i], cont = sanitizeLine(removeControlChars(line), cont)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// コードブロックの開始の行なら、その記号(```や~~~など、3文字以上)を返す。
+func fenceOf(line string) string {
+	for _, c := range []string{"`", "~"} {
+		n := 0
+		for n < len(line) && line[n:n+1] == c {
+			n++
+		}
+		if n >= 3 {
+			return strings.Repeat(c, n)
+		}
+	}
+	return ""
+}
+
+// 改行とタブ以外の制御文字を取り除く。
+func removeControlChars(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\t' || r >= 0x20 && r != 0x7f {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// コードブロックの外の1行を無害化する。contは前の行から続いているもの。
+// この行の続きが次の行にある場合は、それを返す。
+func sanitizeLine(line string, cont continuation) (string, continuation) {
+	if strings.TrimSpace(line) == "" {
+		// 空行をまたいでは、リンクは続かない。
+		return line, continuesNone
+	}
+	head, next := "", continuesNone
+	switch cont {
+	case continuesInlineDestination:
+		head, line = splitDestination(line, inlineContinuationPattern)
+	case continuesDefinitionDestination:
+		head, line = splitDestination(line, definitionContinuationPattern)
+	case continuesDefinitionLabel:
+		switch {
+		case labelContinuationPattern.MatchString(line):
+			head, line = splitDestination(line, labelContinuationPattern)
+		case openLabelEndPattern.MatchString(line):
+			next = continuesDefinitionDestination
+		case labelContinuesPattern.MatchString(line):
+			next = continuesDefinitionLabel
+		}
+	default:
+		switch {
+		case linkDefinitionPattern.MatchString(line):
+			head, line = splitDestination(line, linkDefinitionPattern)
+		case openDefinitionPattern.MatchString(line):
+			next = continuesDefinitionDestination
+		case openLabelPattern.MatchString(line):
+			next = continuesDefinitionLabel
+		}
+	}
+	var b strings.Builder
+	for i := 0; i < len(line); {
+		switch {
+		case line[i] == '`':
+			// インラインコード。同じ数のバッククォートで閉じるまでをそのまま書き出す。
+			n := 1
+			for i+n < len(line) && line[i+n] == '`' {
+				n++
+			}
+			ticks := line[i : i+n]
+			end := strings.Index(line[i+n:], ticks)
+			if end < 0 {
+				b.WriteString(ticks)
+				i += n
+				continue
+			}
+			b.WriteString(line[i : i+n+end+n])
+			i += n + end + n
+		case line[i] == '<':
+			if m := autolinkPattern.FindString(line[i:]); m != "" {
+				b.WriteString(m)
+				i += len(m)
+				continue
+			}
+			b.WriteString("&lt;")
+			i++
+		case strings.HasPrefix(line[i:], "]("):
+			safe, rest := splitDestination(line[i:], inlineLinkPattern)
+			b.WriteString(safe)
+			i = len(line) - len(rest)
+			if rest == "" && strings.HasSuffix(strings.TrimRight(safe, " \t"), "](") {
+				next = continuesInlineDestination
+			}
+		default:
+			b.WriteByte(line[i])
+			i++
+		}
+	}
+	return head + b.String(), next
+}
+
+// patternに一致した行の先頭のURLを無害化し、無害化した部分と残りの部分に分ける。
+// 一致しない場合は、行をそのまま残りの部分として返す。
+func splitDestination(line string, pattern *regexp.Regexp) (string, string) {
+	m := pattern.FindStringSubmatch(line)
+	if m == nil {
+		return "", line
+	}
+	url := safeURL(m[2])
+	if !strings.HasPrefix(url, "<") {
+		// <>で囲まれたURLは、「<」をエスケープせずに書き出す。
+		url = strings.ReplaceAll(url, "<", "&lt;")
+	}
+	return m[1] + url, line[len(m[0]):]
+}
+
+// 使えないスキームのURLを「#」に置き換える。
+// ブラウザはスキームの中の空白や制御文字を無視する(例: java\tscript:)ので、取り除いてから判定する。
+func safeURL(url string) string {
+	inner := strings.TrimSuffix(strings.TrimPrefix(url, "<"), ">")
+	if strings.ContainsAny(inner, "<>") {
+		return "#"
+	}
+	// Markdownでは、URLの中の文字参照(例: &#106;)とバックスラッシュのエスケープ(例: \:)が元の文字に戻される。
+	compact := strings.Map(func(r rune) rune {
+		if r <= 0x20 || r == 0x7f || r == '\\' {
+			return -1
+		}
+		return r
+	}, html.UnescapeString(inner))
+	colon := strings.Index(compact, ":")
+	if colon < 0 || strings.ContainsAny(compact[:colon], "/?#") {
+		return url
+	}
+	scheme := strings.ToLower(compact[:colon])
+	if !allowedSchemes[scheme] {
+		return "#"
+	}
+	return url
+}
@@ -0,0 +1,48 @@
+package markdown
+
+import "testing"
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain text", "**太字** と _斜体_", "**太字** と _斜体_"},
+		{"html", `<script>alert(1)</script>`, `&lt;script>alert(1)&lt;/script>`},
+		{"autolink", "<https://example.com> <javascript:alert(1)>", "<https://example.com> &lt;javascript:alert(1)>"},
+		{"allowed link", "[a](https://example.com/a_(b)) [m](mailto:a@example.com)", "[a](https://example.com/a_(b)) [m](mailto:a@example.com)"},
+		{"relative link", "[a](/tasks/1?x=y:z) ![i](image.png)", "[a](/tasks/1?x=y:z) ![i](image.png)"},
+		{"javascript link", "[a](javascript:alert(1))", "[a](#)"},
+		{"javascript image", "![i]( JavaScript:alert(1) 'title')", "![i]( # 'title')"},
+		{"bracketed link", "[a](<javascript:alert(1)>) [b](<https://example.com>)", "[a](#) [b](<https://example.com>)"},
+		{"obfuscated scheme", "[a](java&#9;script:x) [b](&#106;avascript:x) [c](java\\script:x) [d](data:text/html,x)", "[a](#) [b](#) [c](#) [d](#)"},
+		{"link definition", "[r]: javascript:alert(1)\n[s]: https://example.com", "[r]: #\n[s]: https://example.com"},
+		{"inline code", "`<b>` と `[a](javascript:x)` ``a ` <i>``", "`<b>` と `[a](javascript:x)` ``a ` <i>``"},
+		{"code block", "```\n<b>[a](javascript:x)\n```\n<b>", "```\n<b>[a](javascript:x)\n```\n&lt;b>"},
+		{"tilde code block", "~~~~\n~~~\n<b>\n~~~~\n<b>", "~~~~\n~~~\n<b>\n~~~~\n&lt;b>"},
+		{"control chars", "a\x00b\x1b[31m\r\nc", "ab[31m\nc"},
+
+		// URLの手前と、参照リンクのラベルの途中には改行を入れられる。
+		{"link destination on the next line", "[a](\njavascript:alert(1))", "[a](\n#)"},
+		{"link destination after spaces", "[a](  \n   javascript:alert(1) \"t\")", "[a](  \n   # \"t\")"},
+		{"bracketed destination on the next line", "![i](\n<javascript:alert(1)>)", "![i](\n#)"},
+		{"allowed destination on the next line", "[a](\nhttps://example.com)", "[a](\nhttps://example.com)"},
+		{"definition destination on the next line", "[r]:\njavascript:alert(1)", "[r]:\n#"},
+		{"definition destination on the next line with title", "   [r]:  \n  <javascript:alert(1)> \"t\"", "   [r]:  \n  # \"t\""},
+		{"definition label across lines", "[長い\nラベル]: javascript:alert(1)", "[長い\nラベル]: #"},
+		{"definition label and destination across lines", "[長い\nラベル]:\njavascript:alert(1)", "[長い\nラベル]:\n#"},
+		{"definition label over three lines", "[a\nb\nc]: javascript:alert(1)", "[a\nb\nc]: #"},
+		{"blank line ends the link", "[a](\n\njavascript:alert(1)", "[a](\n\njavascript:alert(1)"},
+		{"only the next line", "[a](\nhttps://example.com)\njavascript:alert(1)", "[a](\nhttps://example.com)\njavascript:alert(1)"},
+		{"code block ends the link", "[r]:\n```\njavascript:alert(1)\n```", "[r]:\n```\njavascript:alert(1)\n```"},
+		{"crlf", "[a](\r\njavascript:alert(1))", "[a](\n#)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sanitize(tt.in); got != tt.want {
+				t.Errorf("Sanitize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
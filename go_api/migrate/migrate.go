@@ -4,8 +4,18 @@ import (
 	"fmt"
 	"go_api/db"
 	"go_api/model"
+	"log"
+
+	"gorm.io/gorm"
 )
 
+// SQLを直接実行する。途中で失敗した場合は、続きのマイグレーションを実行せずに終了する。
+func exec(dbConn *gorm.DB, sql string, values ...interface{}) {
+	if err := dbConn.Exec(sql, values...).Error; err != nil {
+		log.Fatalln(err)
+	}
+}
+
 // マイグレーションファイルはmainパッケージに所属させる。
 // マイグレーションを実行したい場合は、gorm.DBをポインタレシーバとして、オートマイグレートというメソッドが実装されているのでこれを呼び出す。
 func main() {
@@ -13,5 +23,59 @@ func main() {
 	defer fmt.Println("Successfully Migrated")
 	defer db.CloseDB(dbConn)
 	// 引数に、データベースに反映させたいモデル構造を渡す。
-	dbConn.AutoMigrate(&model.User{}, &model.Task{})
+	// タスクのworkspace_idはNOT NULLなので、先にワークスペースと、タスクのワークスペースを決めるのに使うテーブルを作って埋めておく。
+	if err := dbConn.AutoMigrate(&model.User{}, &model.Workspace{}, &model.WorkspaceMember{}, &model.Project{}, &model.Label{}, &model.CalendarFeed{}); err != nil {
+		log.Fatalln(err)
+	}
+	// ワークスペースを追加する前のユーザーには、個人用のワークスペースを作ってオーナーにする。
+	exec(dbConn, `INSERT INTO workspaces (name, personal, user_id, created_at, updated_at)
+		SELECT ?, true, id, NOW(), NOW() FROM users
+		WHERE NOT EXISTS (SELECT 1 FROM workspaces WHERE workspaces.user_id = users.id AND workspaces.personal)`, model.PersonalWorkspaceName)
+	exec(dbConn, `INSERT INTO workspace_members (workspace_id, user_id, role, created_at, updated_at)
+		SELECT id, user_id, 'owner', NOW(), NOW() FROM workspaces WHERE personal
+		ON CONFLICT (workspace_id, user_id) DO NOTHING`)
+	// ワークスペースがまだないプロジェクト・ラベル・フィードは、作成したユーザーの個人用のワークスペースに入れる。
+	for _, table := range []string{"projects", "labels", "calendar_feeds"} {
+		exec(dbConn, `UPDATE `+table+` SET workspace_id = workspaces.id FROM workspaces
+			WHERE workspaces.user_id = `+table+`.user_id AND workspaces.personal AND `+table+`.workspace_id IS NULL`)
+	}
+	// ワークスペースを追加する前のタスクは、workspace_idをNULLのまま追加して埋めてから、AutoMigrateでNOT NULLにする。
+	if dbConn.Migrator().HasTable(&model.Task{}) {
+		exec(dbConn, "ALTER TABLE tasks ADD COLUMN IF NOT EXISTS workspace_id bigint")
+		// プロジェクトのタスクはプロジェクトのワークスペースに、それ以外は作成したユーザーの個人用のワークスペースに入れる。
+		exec(dbConn, `UPDATE tasks SET workspace_id = projects.workspace_id FROM projects
+			WHERE projects.id = tasks.project_id AND tasks.workspace_id IS NULL`)
+		exec(dbConn, `UPDATE tasks SET workspace_id = workspaces.id FROM workspaces
+			WHERE workspaces.user_id = tasks.user_id AND workspaces.personal AND tasks.workspace_id IS NULL`)
+	}
+	if err := dbConn.AutoMigrate(&model.Task{}, &model.Attachment{}, &model.Comment{}, &model.Activity{}, &model.TaskRevision{}, &model.ProjectMember{}, &model.ProjectInvitation{}, &model.TaskWatcher{}, &model.TaskDependency{}, &model.Board{}, &model.BoardColumn{}, &model.BoardCard{}, &model.SavedView{}); err != nil {
+		log.Fatalln(err)
+	}
+	// 全文検索用に、タイトルと説明文から作るtsvectorの生成列とGINインデックスを追加する。
+	// 生成列はAutoMigrateでは作れないので、SQLを直接実行する。タイトルの一致を説明文より重く評価する。
+	exec(dbConn, `ALTER TABLE tasks ADD COLUMN IF NOT EXISTS search_vector tsvector
+		GENERATED ALWAYS AS (
+			setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+			setweight(to_tsvector('simple', coalesce(description, '')), 'B')
+		) STORED`)
+	exec(dbConn, "CREATE INDEX IF NOT EXISTS idx_tasks_search_vector ON tasks USING GIN (search_vector)")
+	// メンバー機能を入れる前に作られたプロジェクトは、作成したユーザーをオーナーとしてメンバーに入れる。
+	exec(dbConn, `INSERT INTO project_members (project_id, user_id, role, created_at, updated_at)
+		SELECT id, user_id, 'owner', NOW(), NOW() FROM projects
+		ON CONFLICT (project_id, user_id) DO NOTHING`)
+	// スナップショットとアクティビティはタスクのワークスペースに、完全に削除されたタスクのものは操作したユーザーの個人用のワークスペースに入れる。
+	for _, table := range []string{"task_revisions", "activities"} {
+		exec(dbConn, `UPDATE `+table+` SET workspace_id = tasks.workspace_id FROM tasks
+			WHERE tasks.id = `+table+`.task_id AND `+table+`.workspace_id IS NULL`)
+		exec(dbConn, `UPDATE `+table+` SET workspace_id = workspaces.id FROM workspaces
+			WHERE workspaces.user_id = `+table+`.user_id AND workspaces.personal AND `+table+`.workspace_id IS NULL`)
+	}
+	// 共有されているプロジェクトのメンバーは、プロジェクトのワークスペースのメンバーにする。
+	exec(dbConn, `INSERT INTO workspace_members (workspace_id, user_id, role, created_at, updated_at)
+		SELECT DISTINCT projects.workspace_id, project_members.user_id, 'member', NOW(), NOW()
+		FROM project_members JOIN projects ON projects.id = project_members.project_id
+		ON CONFLICT (workspace_id, user_id) DO NOTHING`)
+	// ラベル名とフィードは、ワークスペースごとに一意にしたので、ユーザーごとの一意インデックスを削除する。
+	exec(dbConn, "DROP INDEX IF EXISTS idx_labels_user_name")
+	exec(dbConn, "DROP INDEX IF EXISTS idx_calendar_feeds_user_id")
 }
@@ -0,0 +1,110 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// アクティビティの種類。
+const (
+	ActivityActionCreated  = "created"
+	ActivityActionUpdated  = "updated"
+	ActivityActionMoved    = "moved"
+	ActivityActionDeleted  = "deleted"
+	ActivityActionRestored = "restored"
+	// 指定したバージョンに戻した(/tasks/:taskId/revert)、直前の操作を取り消した(/tasks/undo)
+	ActivityActionReverted = "reverted"
+	ActivityActionUndone   = "undone"
+)
+
+// 記録したアクティビティを変更・削除しようとしたときのエラー。
+var ErrActivityImmutable = errors.New("activity cannot be modified")
+
+// アクティビティの一覧で、1回に取得する件数の初期値と最大値。
+const (
+	DefaultActivityLimit = 50
+	MaxActivityLimit     = 200
+)
+
+// 1つのフィールドの変更前と変更後の値。作成のときのBeforeと削除のときのAfterはnullになる。
+type FieldChange struct {
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// フィールド名(JSONの名前)ごとの変更。jsonbの列にJSONのまま保存する。
+type FieldChanges map[string]FieldChange
+
+func (fc FieldChanges) Value() (driver.Value, error) {
+	if fc == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(fc)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (fc *FieldChanges) Scan(value interface{}) error {
+	var b []byte
+	switch v := value.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	case nil:
+		*fc = FieldChanges{}
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into FieldChanges", value)
+	}
+	return json.Unmarshal(b, fc)
+}
+
+// タスクの作成・更新・削除の記録(監査ログ)。だれが(UserId)、いつ(CreatedAt)、どのフィールドをどう変えたか(Changes)を持つ。
+// タスクが完全に削除された後も残すので、TaskIdには外部キーを付けない。(タイトルは記録したときのものを持っておく)
+// 一度記録したら変更・削除できない。
+type Activity struct {
+	ID        uint         `json:"id" gorm:"primaryKey"`
+	Action    string       `json:"action" gorm:"not null"`
+	TaskId    uint         `json:"task_id" gorm:"not null;index"`
+	TaskTitle string       `json:"task_title" gorm:"not null;default:''"`
+	Changes   FieldChanges `json:"changes" gorm:"type:jsonb;not null;default:'{}'"`
+	CreatedAt time.Time    `json:"created_at" gorm:"index"`
+	User      User         `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId    uint         `json:"user_id" gorm:"not null;index"`
+	// 操作したワークスペース。完全に削除されたタスクの履歴を、ワークスペースごとに絞り込むのに使う。
+	WorkspaceId uint `json:"workspace_id" gorm:"index"`
+}
+
+// gormのフックで、保存済みのアクティビティの更新と削除を止める。
+func (a *Activity) BeforeUpdate(tx *gorm.DB) error {
+	return ErrActivityImmutable
+}
+
+func (a *Activity) BeforeDelete(tx *gorm.DB) error {
+	return ErrActivityImmutable
+}
+
+type ActivityResponse struct {
+	ID        uint         `json:"id"`
+	Action    string       `json:"action"`
+	TaskId    uint         `json:"task_id"`
+	TaskTitle string       `json:"task_title"`
+	Changes   FieldChanges `json:"changes"`
+	UserId    uint         `json:"user_id"`
+	UserName  string       `json:"user_name"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// アクティビティの一覧のレスポンス。次のページがある場合は、next_beforeをbeforeに指定して続きを取得する。
+type ActivityListResponse struct {
+	Activities []ActivityResponse `json:"activities"`
+	NextBefore *uint              `json:"next_before"`
+}
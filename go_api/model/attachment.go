@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+// 添付ファイルの最大サイズ(バイト)。
+const MaxAttachmentSize int64 = 20 << 20
+
+// 添付できるファイルの種類。ファイルの中身から判定した種類で確認する。
+var AttachmentContentTypes = []interface{}{
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+	"application/pdf",
+	"application/zip",
+	"text/plain",
+	"text/csv",
+	"text/markdown",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+}
+
+// タスクの添付ファイルのメタデータ。ファイルの中身はstorageパッケージの保存先に、StorageKeyのキーで保存する。
+// タスクがゴミ箱から完全に削除されるときに、ファイルの中身も一緒に削除する。
+type Attachment struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	FileName    string    `json:"file_name" gorm:"not null"`
+	ContentType string    `json:"content_type" gorm:"not null"`
+	Size        int64     `json:"size" gorm:"not null"`
+	StorageKey  string    `json:"-" gorm:"not null;uniqueIndex"`
+	CreatedAt   time.Time `json:"created_at"`
+	Task        Task      `json:"task" gorm:"foreignKey:TaskId; constraint:OnDelete:CASCADE"`
+	TaskId      uint      `json:"task_id" gorm:"not null;index"`
+	User        User      `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId      uint      `json:"user_id" gorm:"not null"`
+}
+
+type AttachmentResponse struct {
+	ID          uint      `json:"id"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	TaskId      uint      `json:"task_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,98 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+// 仕掛かり数の上限(WIP制限)に達している列に、カードを移そうとしたときのエラー。
+var ErrBoardWipLimitExceeded = errors.New("column has reached its WIP limit")
+
+// 1つのボードに作れる列の最大数。
+const MaxBoardColumns = 20
+
+// カンバンボード。ワークスペースのメンバーなら誰でも見ることができ、列の設定も変えられる。
+// カードはタスクをボードの列に置いたもので、カードを動かすにはタスクの編集者以上の権限が必要になる。
+type Board struct {
+	ID          uint          `json:"id" gorm:"primaryKey"`
+	Name        string        `json:"name" gorm:"not null"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+	Workspace   Workspace     `json:"-" gorm:"foreignKey:WorkspaceId; constraint:OnDelete:CASCADE"`
+	WorkspaceId uint          `json:"workspace_id" gorm:"not null;index"`
+	User        User          `json:"-" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId      uint          `json:"user_id" gorm:"not null"`
+	Columns     []BoardColumn `json:"columns" gorm:"foreignKey:BoardId"`
+}
+
+// ボードの列。Statusを指定した列はタスクのステータスに対応し、カードをその列に移すとタスクのステータスも変わる。
+// Statusが空の列は、ステータスとは関係のない独自の状態(レビュー待ちなど)を表す。
+// WipLimitは列に置けるカードの数の上限で、0の場合は上限なし。
+type BoardColumn struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"not null"`
+	Status    string    `json:"status" gorm:"not null;default:''"`
+	WipLimit  uint      `json:"wip_limit" gorm:"not null;default:0"`
+	Position  int       `json:"position" gorm:"not null;default:0"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Board     Board     `json:"-" gorm:"foreignKey:BoardId; constraint:OnDelete:CASCADE"`
+	BoardId   uint      `json:"board_id" gorm:"not null;index"`
+}
+
+// ボードのカード。1つのタスクは、1つのボードに1枚だけ置ける。
+// Positionは列の中での順番で、タスクの並び順と同じくpositionパッケージで作成する。
+type BoardCard struct {
+	ID        uint        `json:"id" gorm:"primaryKey"`
+	Position  string      `json:"position" gorm:"not null;default:''"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	Board     Board       `json:"-" gorm:"foreignKey:BoardId; constraint:OnDelete:CASCADE"`
+	BoardId   uint        `json:"board_id" gorm:"not null;uniqueIndex:idx_board_cards_board_task"`
+	Column    BoardColumn `json:"-" gorm:"foreignKey:ColumnId; constraint:OnDelete:CASCADE"`
+	ColumnId  uint        `json:"column_id" gorm:"not null;index"`
+	Task      Task        `json:"-" gorm:"foreignKey:TaskId; constraint:OnDelete:CASCADE"`
+	TaskId    uint        `json:"task_id" gorm:"not null;uniqueIndex:idx_board_cards_board_task;index"`
+}
+
+// 列ごとのカードの数。
+type BoardColumnCount struct {
+	ColumnId uint
+	Count    int
+}
+
+type BoardResponse struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	UserId    uint      `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// 1件取得したときだけ、列とカードを入れて返す。
+	Columns []BoardColumnResponse `json:"columns,omitempty"`
+}
+
+type BoardColumnResponse struct {
+	ID       uint   `json:"id"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	WipLimit uint   `json:"wip_limit"`
+	Position int    `json:"position"`
+	// WIP制限と比べる、列に置かれているカードの数。見ることができないタスクのカードも数える。
+	CardCount int                 `json:"card_count"`
+	Cards     []BoardCardResponse `json:"cards"`
+}
+
+type BoardCardResponse struct {
+	TaskId   uint         `json:"task_id"`
+	ColumnId uint         `json:"column_id"`
+	Position string       `json:"position"`
+	Task     TaskResponse `json:"task"`
+}
+
+// カードを置く(または移す)ときのリクエスト。column_idの列の、AfterIdのタスクの直後、BeforeIdのタスクの直前に置く。
+// どちらも指定しない場合は列の末尾に置く。
+type BoardCardMove struct {
+	ColumnId uint  `json:"column_id"`
+	AfterId  *uint `json:"after_id"`
+	BeforeId *uint `json:"before_id"`
+}
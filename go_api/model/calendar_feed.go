@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// カレンダーアプリから購読するための、ユーザーとワークスペースごとのiCalendarのフィード。
+// URLに含まれるトークンを知っていれば誰でも読めるので、漏れた場合はトークンを作り直す。
+type CalendarFeed struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Token     string    `json:"token" gorm:"not null;uniqueIndex"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	User      User      `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_calendar_feeds_user_workspace"`
+	// フィードに含めるタスクのワークスペース。タスクと同じく、マイグレーションで個人用のワークスペースを入れる。
+	Workspace   Workspace `json:"-" gorm:"foreignKey:WorkspaceId; constraint:OnDelete:CASCADE"`
+	WorkspaceId uint      `json:"workspace_id" gorm:"uniqueIndex:idx_calendar_feeds_user_workspace"`
+}
+
+// URLはリクエストのホストから作るので、コントローラーで設定する。
+type CalendarFeedResponse struct {
+	Token     string    `json:"token"`
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// フィードのタスクを、VTODO(ToDo)とVEVENT(予定)のどちらで表すか。クエリパラメーターのtypeで指定する。
+// Googleカレンダーのように、VTODOを表示しないカレンダーアプリではeventを使う。
+const (
+	CalendarFeedTypeTodo  = "todo"
+	CalendarFeedTypeEvent = "event"
+)
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+// 自分が書いたのではないコメントを、編集・削除しようとしたときのエラー。
+var ErrCommentForbidden = errors.New("only the author can modify this comment")
+
+// タスクに付けるコメント。本文はMarkdownで、保存する前に無害化する。(markdownパッケージ)
+type Comment struct {
+	ID   uint   `json:"id" gorm:"primaryKey"`
+	Body string `json:"body" gorm:"not null"`
+	// 本文を最後に編集した日時。編集していない場合はnil。
+	EditedAt  *time.Time `json:"edited_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Task      Task       `json:"task" gorm:"foreignKey:TaskId; constraint:OnDelete:CASCADE"`
+	TaskId    uint       `json:"task_id" gorm:"not null;index"`
+	// コメントを書いたユーザー。
+	User   User `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId uint `json:"user_id" gorm:"not null"`
+}
+
+type CommentResponse struct {
+	ID         uint       `json:"id"`
+	TaskId     uint       `json:"task_id"`
+	Body       string     `json:"body"`
+	AuthorId   uint       `json:"author_id"`
+	AuthorName string     `json:"author_name"`
+	EditedAt   *time.Time `json:"edited_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
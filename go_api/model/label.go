@@ -0,0 +1,34 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+// 色を指定しなかったときのラベルの色。
+const DefaultLabelColor = "#9ca3af"
+
+// 同じワークスペースに、同じ名前のラベルをすでに持っている場合のエラー。
+var ErrLabelNameTaken = errors.New("label with this name already exists")
+
+// タスクに付けるラベル。ラベル名はワークスペースとユーザーごとに一意にする。
+type Label struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"not null;uniqueIndex:idx_labels_workspace_user_name"`
+	Color     string    `json:"color" gorm:"not null;default:'#9ca3af'"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	User      User      `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_labels_workspace_user_name"`
+	// 所属するワークスペース。タスクと同じく、マイグレーションで個人用のワークスペースを入れる。
+	Workspace   Workspace `json:"-" gorm:"foreignKey:WorkspaceId; constraint:OnDelete:CASCADE"`
+	WorkspaceId uint      `json:"workspace_id" gorm:"uniqueIndex:idx_labels_workspace_user_name"`
+}
+
+type LabelResponse struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+// タスクをまとめるプロジェクト(リスト)。アーカイブしたプロジェクトは一覧に表示しない。
+// UserIdはプロジェクトを作成したユーザー。アクセスできるかどうかは、project_membersのメンバーの権限で決める。
+type Project struct {
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	Name     string `json:"name" gorm:"not null"`
+	Color    string `json:"color" gorm:"not null;default:'#3b82f6'"`
+	Archived bool   `json:"archived" gorm:"not null;default:false"`
+	// ログインしているユーザーの権限。project_membersから読み込むだけで、projectsテーブルの列にはしない。
+	Role      string    `json:"role" gorm:"->;-:migration"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	User      User      `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId    uint      `json:"user_id" gorm:"not null;index"`
+	// 所属するワークスペース。タスクと同じく、マイグレーションで個人用のワークスペースを入れる。
+	Workspace   Workspace `json:"-" gorm:"foreignKey:WorkspaceId; constraint:OnDelete:CASCADE"`
+	WorkspaceId uint      `json:"workspace_id" gorm:"index"`
+}
+
+type ProjectResponse struct {
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	Name     string `json:"name"`
+	Color    string `json:"color"`
+	Archived bool   `json:"archived"`
+	// ログインしているユーザーの、このプロジェクトでの権限。(model.ProjectRoleViewerなど)
+	Role        string    `json:"role"`
+	WorkspaceId uint      `json:"workspace_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// プロジェクトを削除するときの、プロジェクト内のタスクの扱い。
+const (
+	// プロジェクト内のタスクも一緒に削除する。
+	ProjectDeleteCascade = "cascade"
+	// プロジェクト内のタスクはプロジェクトなし(インボックス)に移す。
+	ProjectDeleteMoveToInbox = "inbox"
+)
@@ -0,0 +1,111 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+// プロジェクトのメンバーの権限。
+const (
+	// タスクやコメント、添付ファイルを見ることができる。
+	ProjectRoleViewer = "viewer"
+	// タスクの作成・更新・削除や、コメント・添付ファイルの追加もできる。
+	ProjectRoleEditor = "editor"
+	// プロジェクトの変更・削除や、メンバーの招待・削除もできる。
+	ProjectRoleOwner = "owner"
+)
+
+// 権限の強さ。数字が大きいほど、できることが多い。
+var projectRoleRanks = map[string]int{
+	ProjectRoleViewer: 1,
+	ProjectRoleEditor: 2,
+	ProjectRoleOwner:  3,
+}
+
+// roleがrequired以上の権限か判定する。
+func HasProjectRole(role string, required string) bool {
+	rank, ok := projectRoleRanks[role]
+	return ok && rank >= projectRoleRanks[required]
+}
+
+// required以上の権限の一覧。(SQLのINの条件に使う)
+func ProjectRolesAtLeast(required string) []string {
+	roles := []string{}
+	for _, role := range []string{ProjectRoleViewer, ProjectRoleEditor, ProjectRoleOwner} {
+		if HasProjectRole(role, required) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// プロジェクトやタスクは見えるが、その操作をする権限がないときのエラー。
+var ErrProjectForbidden = errors.New("you do not have permission for this project")
+
+// 最後のオーナーを削除したり、オーナー以外に変えようとしたときのエラー。
+var ErrLastProjectOwner = errors.New("project must have at least one owner")
+
+// 招待したユーザーがすでにメンバーのときのエラー。
+var ErrAlreadyProjectMember = errors.New("user is already a member of this project")
+
+// 招待が見つからない、期限切れ、または別のメールアドレス宛てのときのエラー。
+var ErrInvitationInvalid = errors.New("invitation is invalid or expired")
+
+// プロジェクトのメンバー。プロジェクトを作成したユーザーも、オーナーとしてメンバーに入れる。
+type ProjectMember struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Role      string    `json:"role" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Project   Project   `json:"project" gorm:"foreignKey:ProjectId; constraint:OnDelete:CASCADE"`
+	ProjectId uint      `json:"project_id" gorm:"not null;uniqueIndex:idx_project_members_project_user"`
+	User      User      `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_project_members_project_user;index"`
+}
+
+type ProjectMemberResponse struct {
+	UserId    uint      `json:"user_id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// メンバーの権限の変更に使う。
+type ProjectMemberUpdate struct {
+	Role string `json:"role"`
+}
+
+// 招待の有効期間。
+const ProjectInvitationTTL = 7 * 24 * time.Hour
+
+// プロジェクトへの招待。招待されたメールアドレスでログインしたユーザーが承認すると、メンバーになる。
+// 承認・辞退・取り消しをしたら削除する。同じメールアドレスを招待し直した場合は、権限と期限を更新する。
+type ProjectInvitation struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Email     string    `json:"email" gorm:"not null;uniqueIndex:idx_project_invitations_project_email"`
+	Role      string    `json:"role" gorm:"not null"`
+	Token     string    `json:"-" gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Project   Project   `json:"project" gorm:"foreignKey:ProjectId; constraint:OnDelete:CASCADE"`
+	ProjectId uint      `json:"project_id" gorm:"not null;uniqueIndex:idx_project_invitations_project_email"`
+	// 招待したユーザー。
+	User   User `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId uint `json:"user_id" gorm:"not null"`
+}
+
+// 招待のレスポンス。URLはフロントエンドの招待を承認する画面のURL。
+type ProjectInvitationResponse struct {
+	ID          uint      `json:"id"`
+	ProjectId   uint      `json:"project_id"`
+	ProjectName string    `json:"project_name"`
+	Email       string    `json:"email"`
+	Role        string    `json:"role"`
+	Token       string    `json:"token"`
+	URL         string    `json:"url"`
+	InviterName string    `json:"inviter_name"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
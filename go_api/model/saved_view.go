@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// 保存したビュー。絞り込みの式(taskfilterパッケージ)と並び順に名前を付けて保存しておき、あとで同じ条件のタスクの一覧を取得する。
+// ラベルと同じく、ワークスペースの中でユーザーごとに持つ。
+type SavedView struct {
+	ID    uint   `json:"id" gorm:"primaryKey"`
+	Name  string `json:"name" gorm:"not null"`
+	Query string `json:"query" gorm:"not null;default:''"`
+	// 並び替えのキーと向き。orderはSQLの予約語なので、カラム名はsort_orderにする。空の場合は、タスク一覧と同じく並び順(position)の昇順にする。
+	Sort        string    `json:"sort" gorm:"not null;default:''"`
+	Order       string    `json:"order" gorm:"column:sort_order;not null;default:''"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	User        User      `json:"-" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId      uint      `json:"user_id" gorm:"not null;index"`
+	Workspace   Workspace `json:"-" gorm:"foreignKey:WorkspaceId; constraint:OnDelete:CASCADE"`
+	WorkspaceId uint      `json:"workspace_id" gorm:"not null;index"`
+}
+
+type SavedViewResponse struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Query     string    `json:"query"`
+	Sort      string    `json:"sort"`
+	Order     string    `json:"order"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
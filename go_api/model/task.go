@@ -1,19 +1,358 @@
 package model
 
-import "time"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// タスクのステータス。todo → in_progress → done の流れを基本とし、cancelledで中止を表す。
+const (
+	TaskStatusTodo       = "todo"
+	TaskStatusInProgress = "in_progress"
+	TaskStatusDone       = "done"
+	TaskStatusCancelled  = "cancelled"
+)
+
+// バリデーションなどで使用する、ステータスの一覧。
+var TaskStatuses = []interface{}{TaskStatusTodo, TaskStatusInProgress, TaskStatusDone, TaskStatusCancelled}
+
+// If-Matchで指定されたバージョンと今のバージョンが異なる(他のリクエストで先に更新された)ときのエラー。
+var ErrTaskVersionConflict = errors.New("task has been modified by another request")
+
+// 担当者やウォッチャーに、タスクを見ることができないユーザーを指定したときのエラー。
+var ErrTaskUserNoAccess = errors.New("user does not have access to this task")
 
 type Task struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	Title     string    `json:"title" gorm:"not null"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	User      User      `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
-	UserId    uint      `json:"user_id" gorm:"not null"`
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Title       string     `json:"title" gorm:"not null"`
+	Description string     `json:"description" gorm:"not null;default:''"`
+	Status      string     `json:"status" gorm:"not null;default:todo;index"`
+	CompletedAt *time.Time `json:"completed_at"`
+	StartAt     *time.Time `json:"start_at"`
+	DueAt       *time.Time `json:"due_at" gorm:"index"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	// 削除した日時。削除したタスクはゴミ箱に入り、保存期間が過ぎるまでは復元できる。(gormの論理削除)
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+	// タスクを作成したユーザー。担当者は別にAssigneeIdで持つ。
+	User   User `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId uint `json:"user_id" gorm:"not null"`
+	// 担当者。nilの場合は誰も担当していない。担当者にできるのは、タスクを見ることができるユーザーだけ。
+	AssigneeId *uint `json:"assignee_id" gorm:"index"`
+	Assignee   *User `json:"-" gorm:"foreignKey:AssigneeId; constraint:OnDelete:SET NULL"`
+	// 所属するワークスペース。ワークスペースを追加する前のタスクは、マイグレーションで先にワークスペースを入れてからNOT NULLにする。
+	Workspace   Workspace `json:"-" gorm:"foreignKey:WorkspaceId; constraint:OnDelete:CASCADE"`
+	WorkspaceId uint      `json:"workspace_id" gorm:"not null;index"`
+	// 所属するプロジェクト。nilの場合はどのプロジェクトにも属さない(インボックス)。
+	ProjectId *uint    `json:"project_id" gorm:"index"`
+	Project   *Project `json:"-" gorm:"foreignKey:ProjectId; constraint:OnDelete:SET NULL"`
+	// 親タスク。nilの場合はサブタスクではない。親タスクを削除すると、サブタスクも一緒に削除される。
+	ParentId *uint `json:"parent_id" gorm:"index"`
+	Parent   *Task `json:"-" gorm:"foreignKey:ParentId; constraint:OnDelete:CASCADE"`
+	// タスクの中のチェックリスト。配列の順番がそのまま表示順になる。JSONのままjsonbの列に保存する。
+	Checklist Checklist `json:"checklist" gorm:"type:jsonb;not null;default:'[]'"`
+	// iCalendarのRRULE形式の繰り返しルール(例: FREQ=WEEKLY;BYDAY=MO)。空の場合は繰り返さない。
+	// 期限(due_at)を最初の発生日時として、完了すると次の発生日時を期限にしたタスクが作成される。
+	Recurrence string `json:"recurrence" gorm:"not null;default:''"`
+	// ユーザーが並び替えた順番。文字列のバイト順で並ぶ。(positionパッケージで作成する)
+	Position string `json:"position" gorm:"not null;default:''"`
+	// 楽観的排他制御のためのバージョン。タスクを更新するたびに1ずつ増える。ETagとして返す。
+	Version uint `json:"version" gorm:"not null;default:1"`
+	// タスクとラベルは多対多の関係なので、task_labelsという中間テーブルで関連付ける。
+	Labels []Label `json:"labels" gorm:"many2many:task_labels; constraint:OnDelete:CASCADE"`
+	// 作成・更新のリクエストで付けたいラベルのidを受け取るためのフィールド。テーブルには保存しない。
+	// 送られてこなかった(nil)場合は、更新時に今のラベルのままにする。空の配列の場合はすべて外す。
+	LabelIds []uint `json:"label_ids" gorm:"-"`
 }
 
 type TaskResponse struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	Title     string    `json:"title" gorm:"not null"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Title       string     `json:"title" gorm:"not null"`
+	Description string     `json:"description"`
+	Status      string     `json:"status"`
+	CompletedAt *time.Time `json:"completed_at"`
+	StartAt     *time.Time `json:"start_at"`
+	DueAt       *time.Time `json:"due_at"`
+	CreatorId   uint       `json:"creator_id"`
+	AssigneeId  *uint      `json:"assignee_id"`
+	ProjectId   *uint      `json:"project_id"`
+	ParentId    *uint      `json:"parent_id"`
+	Checklist   Checklist  `json:"checklist"`
+	Recurrence  string     `json:"recurrence"`
+	Position    string     `json:"position"`
+	Version     uint       `json:"version"`
+	// チェックリストとサブタスクのうち、完了したものの割合(0〜100)。
+	Progress  int             `json:"progress"`
+	Labels    []LabelResponse `json:"labels"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	// ゴミ箱の一覧のときだけ、削除した日時を入れて返す。
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// 1件取得したときだけ、サブタスクを階層のまま入れて返す。
+	Subtasks []TaskResponse `json:"subtasks,omitempty"`
+	// 繰り返しタスクを完了したときに作成された、次の回のタスク。
+	NextOccurrence *TaskResponse `json:"next_occurrence,omitempty"`
+}
+
+// 担当者を変えるときのリクエスト。assignee_idにnullを指定すると、担当者を外す。
+type TaskAssignRequest struct {
+	AssigneeId *uint `json:"assignee_id"`
+}
+
+// タスクを並び替えるときのリクエスト。移動先の前後のタスクのidを指定する。
+// AfterIdのタスクの直後、BeforeIdのタスクの直前に移動する。どちらも指定しない場合は末尾に移動する。
+type TaskMove struct {
+	AfterId  *uint `json:"after_id"`
+	BeforeId *uint `json:"before_id"`
+}
+
+// 繰り返しタスクの、これからの発生日時の一覧。
+type TaskOccurrencesResponse struct {
+	TaskId      uint        `json:"task_id"`
+	Recurrence  string      `json:"recurrence"`
+	Occurrences []time.Time `json:"occurrences"`
+}
+
+// チェックリストの1項目。
+type ChecklistItem struct {
+	Text string `json:"text"`
+	Done bool   `json:"done"`
+}
+
+// チェックリスト。データベースにはJSONの配列として保存する。
+type Checklist []ChecklistItem
+
+// Valueはdriver.Valuerインターフェースのメソッドで、保存するときにJSONの文字列に変換する。
+// nilの場合も空の配列として保存する。
+func (cl Checklist) Value() (driver.Value, error) {
+	if cl == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(cl)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scanはsql.Scannerインターフェースのメソッドで、取得したJSONをChecklistに変換する。
+func (cl *Checklist) Scan(value interface{}) error {
+	var b []byte
+	switch v := value.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	case nil:
+		*cl = Checklist{}
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into Checklist", value)
+	}
+	return json.Unmarshal(b, cl)
+}
+
+// 親タスクごとのサブタスクの数と、そのうち完了した数。
+type SubtaskCount struct {
+	ParentId uint
+	Total    int
+	Done     int
+}
+
+// タスク一覧を取得するときの絞り込み条件。
+// 空の値は「条件なし」を表す。
+type TaskFilter struct {
+	Status string
+	// タイトルの部分一致検索
+	Title     string
+	DueBefore *time.Time
+	DueAfter  *time.Time
+	// trueの場合、期限を過ぎていて完了・中止になっていないタスクだけを取得する。
+	Overdue bool
+	// 指定したプロジェクトのタスクだけを取得する。Inboxがtrueの場合はプロジェクトに属さないタスクだけを取得する。
+	ProjectId *uint
+	Inbox     bool
+	// 指定した親タスクのサブタスクだけを取得する。RootOnlyがtrueの場合はサブタスクではないタスクだけを取得する。
+	ParentId *uint
+	RootOnly bool
+	// 指定したラベル名がすべて付いているタスクだけを取得する。
+	Labels []string
+	// 指定したユーザーが担当しているタスクだけを取得する。Unassignedがtrueの場合は担当者のいないタスクだけを取得する。
+	AssigneeId *uint
+	Unassigned bool
+	// trueの場合は終わっていないタスクに依存しているタスクだけを、falseの場合はそれ以外(今すぐ取りかかれるタスク)だけを取得する。
+	Blocked *bool
+	// taskfilterパッケージの式で絞り込む。(保存したビューの条件)
+	Query string
+	// Queryの中のtodayや日付を解釈するタイムゾーン。nilの場合はUTCにする。
+	Location *time.Location
+}
+
+// タスク一覧の並び替えに使えるキー。
+const (
+	TaskSortCreatedAt = "created_at"
+	TaskSortUpdatedAt = "updated_at"
+	TaskSortTitle     = "title"
+	TaskSortDueAt     = "due_at"
+	TaskSortPosition  = "position"
+)
+
+var TaskSortKeys = []interface{}{TaskSortPosition, TaskSortCreatedAt, TaskSortUpdatedAt, TaskSortTitle, TaskSortDueAt}
+
+// 1ページあたりの件数の初期値と上限。
+const (
+	DefaultTaskPageLimit = 50
+	MaxTaskPageLimit     = 200
+)
+
+// タスク一覧のページングと並び替えの指定。
+// Cursorは前のページのレスポンスで返したnext_cursorをそのまま受け取る。
+type TaskPage struct {
+	Sort   string
+	Order  string
+	Limit  int
+	Cursor string
+}
+
+// カーソルをデコードしたもの。前のページの最後のタスクの並び替えキーの値とidを持つ。
+// 並び替えキーの値が同じタスクがあっても、idで順番が一意に決まるようにしている。
+type TaskCursor struct {
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+// タスク一覧のレスポンス。ページングの情報も一緒に返す。
+type TaskListResponse struct {
+	Tasks      []TaskResponse `json:"tasks"`
+	NextCursor string         `json:"next_cursor"`
+	HasMore    bool           `json:"has_more"`
+	Limit      int            `json:"limit"`
+	Sort       string         `json:"sort"`
+	Order      string         `json:"order"`
+}
+
+// 全文検索でヒットしたタスク。検索の順位(rank)とハイライトした抜粋(snippet)を一緒に持つ。
+type TaskSearchHit struct {
+	Task
+	Rank    float64
+	Snippet string
+}
+
+type TaskSearchResponse struct {
+	Task    TaskResponse `json:"task"`
+	Rank    float64      `json:"rank"`
+	Snippet string       `json:"snippet"`
+}
+
+// 一括操作の種類。
+const (
+	TaskBulkOpCreate   = "create"
+	TaskBulkOpUpdate   = "update"
+	TaskBulkOpDelete   = "delete"
+	TaskBulkOpComplete = "complete"
+)
+
+var TaskBulkOps = []interface{}{TaskBulkOpCreate, TaskBulkOpUpdate, TaskBulkOpDelete, TaskBulkOpComplete}
+
+// 一括操作のモード。atomicは1つでも失敗したらすべて取り消し、best_effortは失敗した操作だけを取り消して残りを反映する。
+const (
+	TaskBulkModeAtomic     = "atomic"
+	TaskBulkModeBestEffort = "best_effort"
+)
+
+// 1回の一括操作で受け付ける操作の最大数。
+const MaxTaskBulkOperations = 100
+
+// 一括操作のリクエスト。
+type TaskBulkRequest struct {
+	Mode       string              `json:"mode"`
+	Operations []TaskBulkOperation `json:"operations"`
+}
+
+// 一括操作の1つ分。
+// createのときはTaskに作成するタスクを、updateのときはTaskにJSON Merge Patchを入れる。
+// update・delete・completeでVersionを指定した場合は、If-Matchと同じようにそのバージョンのときだけ操作する。
+type TaskBulkOperation struct {
+	Op      string          `json:"op"`
+	TaskId  uint            `json:"task_id"`
+	Version uint            `json:"version"`
+	Task    json.RawMessage `json:"task"`
+}
+
+// 操作ごとの結果のステータス。
+const (
+	TaskBulkStatusOk         = "ok"
+	TaskBulkStatusError      = "error"
+	TaskBulkStatusRolledBack = "rolled_back"
+	TaskBulkStatusSkipped    = "skipped"
+)
+
+// 操作ごとの結果。Indexはリクエストのoperationsの何番目(0始まり)の操作かを表す。
+type TaskBulkResult struct {
+	Index  int           `json:"index"`
+	Op     string        `json:"op"`
+	TaskId uint          `json:"task_id,omitempty"`
+	Status string        `json:"status"`
+	Error  string        `json:"error,omitempty"`
+	Task   *TaskResponse `json:"task,omitempty"`
+}
+
+// 一括操作のレスポンス。Committedは変更がデータベースに反映されたかどうか。
+type TaskBulkResponse struct {
+	Mode      string           `json:"mode"`
+	Committed bool             `json:"committed"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Results   []TaskBulkResult `json:"results"`
+}
+
+// インポートするファイルの中に、すでにあるタスク(またはファイルの中の前のタスク)とタイトルと期限が同じタスクがあった場合の扱い。
+// skipは読み飛ばし、createは重複していても作成する。
+const (
+	TaskImportDuplicatesSkip   = "skip"
+	TaskImportDuplicatesCreate = "create"
+)
+
+// 1回のインポートで受け付けるタスクの最大数。
+const MaxTaskImportRecords = 5000
+
+// インポートの指定。DryRunがtrueの場合は、結果だけを返してデータベースには反映しない。
+type TaskImportOptions struct {
+	Format     string
+	DryRun     bool
+	Duplicates string
+}
+
+// インポートしたタスクごとの扱い。
+const (
+	TaskImportActionCreate = "create"
+	TaskImportActionSkip   = "skip"
+	TaskImportActionError  = "error"
+)
+
+// インポートしたタスクごとの結果。Lineはファイルの何行目のタスクか(1始まり)。
+type TaskImportResult struct {
+	Line    int    `json:"line"`
+	Title   string `json:"title"`
+	Action  string `json:"action"`
+	TaskId  uint   `json:"task_id,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// インポートのレスポンス。Committedは作成したタスクがデータベースに反映されたかどうか。
+// エラーのタスクが1件でもある場合は、何も反映しない。
+type TaskImportResponse struct {
+	Format    string             `json:"format"`
+	DryRun    bool               `json:"dry_run"`
+	Committed bool               `json:"committed"`
+	Total     int                `json:"total"`
+	Created   int                `json:"created"`
+	Skipped   int                `json:"skipped"`
+	Failed    int                `json:"failed"`
+	Results   []TaskImportResult `json:"results"`
 }
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+// 依存関係を追加すると、依存関係が循環してしまうときのエラー。(自分自身への依存も含む)
+var ErrTaskDependencyCycle = errors.New("dependency would create a cycle")
+
+// 終わっていない(完了・中止になっていない)タスクに依存しているタスクを、完了にしようとしたときのエラー。
+var ErrTaskBlocked = errors.New("task is blocked by unfinished tasks")
+
+// タスクの依存関係。TaskIdのタスクは、DependsOnIdのタスクが終わるまで始められない。(DependsOnIdのタスクがTaskIdのタスクをブロックしている)
+// 依存関係は同じワークスペースのタスクの間だけで作ることができ、循環しないようにする。
+type TaskDependency struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time `json:"created_at"`
+	Task        Task      `json:"-" gorm:"foreignKey:TaskId; constraint:OnDelete:CASCADE"`
+	TaskId      uint      `json:"task_id" gorm:"not null;uniqueIndex:idx_task_dependencies_task_depends_on"`
+	DependsOn   Task      `json:"-" gorm:"foreignKey:DependsOnId; constraint:OnDelete:CASCADE"`
+	DependsOnId uint      `json:"depends_on_id" gorm:"not null;uniqueIndex:idx_task_dependencies_task_depends_on;index"`
+	// 依存関係を追加したユーザー。
+	User   User `json:"-" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId uint `json:"user_id" gorm:"not null"`
+}
+
+// 依存関係を追加するときのリクエスト。
+type TaskDependencyRequest struct {
+	DependsOnId uint `json:"depends_on_id"`
+}
+
+// 依存関係のグラフの辺。task_idのタスクが、depends_on_idのタスクに依存している。
+type TaskDependencyResponse struct {
+	TaskId      uint      `json:"task_id"`
+	DependsOnId uint      `json:"depends_on_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// 依存関係のグラフの頂点。Blockedは、終わっていないタスクに依存しているかどうか。
+type TaskDependencyNode struct {
+	ID      uint   `json:"id"`
+	Title   string `json:"title"`
+	Status  string `json:"status"`
+	Blocked bool   `json:"blocked"`
+}
+
+// タスクの依存関係のグラフ(DAG)。タスクが依存しているタスクと、タスクに依存しているタスクをたどれるだけたどったもの。
+// 見ることができないタスクは、頂点にも辺にも含めない。
+type TaskDependencyGraphResponse struct {
+	TaskId uint                     `json:"task_id"`
+	Nodes  []TaskDependencyNode     `json:"nodes"`
+	Edges  []TaskDependencyResponse `json:"edges"`
+}
@@ -0,0 +1,82 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 元に戻せる操作がないときのエラー。
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// 指定したバージョンのスナップショットがないときのエラー。
+var ErrTaskRevisionNotFound = errors.New("revision not found")
+
+// ある時点のタスクの状態。元に戻すときに、このとおりにタスクを書き戻す。
+type TaskSnapshot struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Status      string     `json:"status"`
+	CompletedAt *time.Time `json:"completed_at"`
+	StartAt     *time.Time `json:"start_at"`
+	DueAt       *time.Time `json:"due_at"`
+	AssigneeId  *uint      `json:"assignee_id"`
+	ProjectId   *uint      `json:"project_id"`
+	ParentId    *uint      `json:"parent_id"`
+	Checklist   Checklist  `json:"checklist"`
+	Recurrence  string     `json:"recurrence"`
+	Position    string     `json:"position"`
+	LabelIds    []uint     `json:"label_ids"`
+	CreatedAt   time.Time  `json:"created_at"`
+	// タスクを作成したユーザー。これを保存する前のスナップショットでは0になる。
+	UserId uint `json:"user_id"`
+}
+
+func (ts TaskSnapshot) Value() (driver.Value, error) {
+	b, err := json.Marshal(ts)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (ts *TaskSnapshot) Scan(value interface{}) error {
+	var b []byte
+	switch v := value.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into TaskSnapshot", value)
+	}
+	return json.Unmarshal(b, ts)
+}
+
+// タスクを変更するたびに保存する、変更後のタスクのスナップショット。(Versionは変更後のバージョン)
+// 削除した場合は、削除する直前の状態をDeletedをtrueにして保存する。
+// 完全に削除されたタスクも作り直せるように、TaskIdには外部キーを付けない。
+// UndoneAtは、/tasks/undoで取り消した日時。取り消した操作は、もう一度取り消す対象にしない。
+type TaskRevision struct {
+	ID        uint         `json:"id" gorm:"primaryKey"`
+	TaskId    uint         `json:"task_id" gorm:"not null;index"`
+	Version   uint         `json:"version" gorm:"not null"`
+	Action    string       `json:"action" gorm:"not null"`
+	Deleted   bool         `json:"deleted" gorm:"not null;default:false"`
+	Snapshot  TaskSnapshot `json:"snapshot" gorm:"type:jsonb;not null"`
+	UndoneAt  *time.Time   `json:"undone_at"`
+	CreatedAt time.Time    `json:"created_at"`
+	User      User         `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId    uint         `json:"user_id" gorm:"not null;index"`
+	// 取り消し(/tasks/undo)は、ワークスペースごとに最後の操作を対象にする。
+	WorkspaceId uint `json:"workspace_id" gorm:"index"`
+}
+
+// /tasks/undoのレスポンス。取り消した結果タスクが削除された場合は、taskはnullになる。
+type TaskUndoResponse struct {
+	UndoneAction string        `json:"undone_action"`
+	TaskId       uint          `json:"task_id"`
+	Task         *TaskResponse `json:"task"`
+}
@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// タスクのウォッチャー。担当者や作成したユーザーのほかに、タスクの変更を追いかけたいユーザー。
+// ウォッチャーにできるのは、タスクを見ることができるユーザーだけ。
+type TaskWatcher struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	Task      Task      `json:"task" gorm:"foreignKey:TaskId; constraint:OnDelete:CASCADE"`
+	TaskId    uint      `json:"task_id" gorm:"not null;uniqueIndex:idx_task_watchers_task_user"`
+	User      User      `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_task_watchers_task_user;index"`
+}
+
+type TaskWatcherResponse struct {
+	UserId    uint      `json:"user_id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ウォッチャーを追加するときのリクエスト。user_idを指定しない場合は、自分をウォッチャーにする。
+type TaskWatcherRequest struct {
+	UserId uint `json:"user_id"`
+}
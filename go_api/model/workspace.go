@@ -0,0 +1,89 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+// ワークスペースのメンバーの権限。
+const (
+	// ワークスペースのタスク・プロジェクト・ラベルを作成したり、使ったりできる。
+	WorkspaceRoleMember = "member"
+	// ワークスペースの名前の変更・削除や、メンバーの追加・削除もできる。
+	WorkspaceRoleOwner = "owner"
+)
+
+// サインアップしたときに作る、個人用のワークスペースの名前。
+const PersonalWorkspaceName = "Personal"
+
+// リクエストでワークスペースを指定するヘッダー。指定しない場合は、ユーザーの個人用のワークスペースになる。
+const WorkspaceHeader = "X-Workspace-ID"
+
+// ワークスペースが見つからない、またはメンバーでないときのエラー。
+var ErrWorkspaceNotFound = errors.New("workspace not found")
+
+// ワークスペースのオーナーでないのに、オーナーだけができる操作をしたときのエラー。
+var ErrWorkspaceForbidden = errors.New("you do not have permission for this workspace")
+
+// 最後のオーナーを削除したり、オーナー以外に変えようとしたときのエラー。
+var ErrLastWorkspaceOwner = errors.New("workspace must have at least one owner")
+
+// 追加しようとしたユーザーが、すでにメンバーのときのエラー。
+var ErrAlreadyWorkspaceMember = errors.New("user is already a member of this workspace")
+
+// 追加しようとしたメールアドレスのユーザーが、登録されていないときのエラー。
+var ErrWorkspaceUserNotFound = errors.New("user with this email does not exist")
+
+// 個人用のワークスペースを削除したり、メンバーを追加・削除しようとしたときのエラー。
+var ErrPersonalWorkspace = errors.New("personal workspace cannot be changed")
+
+// タスク・プロジェクト・ラベルをまとめるワークスペース(チーム)。ユーザーは複数のワークスペースに入ることができる。
+// サインアップしたときに、ユーザーごとに個人用(Personal)のワークスペースを作る。
+// UserIdはワークスペースを作成したユーザー。アクセスできるかどうかは、workspace_membersで決める。
+type Workspace struct {
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	Name     string `json:"name" gorm:"not null"`
+	Personal bool   `json:"personal" gorm:"not null;default:false"`
+	// ログインしているユーザーの権限。workspace_membersから読み込むだけで、workspacesテーブルの列にはしない。
+	Role      string    `json:"role" gorm:"->;-:migration"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	User      User      `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId    uint      `json:"user_id" gorm:"not null;index"`
+}
+
+type WorkspaceResponse struct {
+	ID       uint   `json:"id"`
+	Name     string `json:"name"`
+	Personal bool   `json:"personal"`
+	// ログインしているユーザーの、このワークスペースでの権限。(model.WorkspaceRoleMemberなど)
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ワークスペースのメンバー。
+type WorkspaceMember struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Role        string    `json:"role" gorm:"not null"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Workspace   Workspace `json:"workspace" gorm:"foreignKey:WorkspaceId; constraint:OnDelete:CASCADE"`
+	WorkspaceId uint      `json:"workspace_id" gorm:"not null;uniqueIndex:idx_workspace_members_workspace_user"`
+	User        User      `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId      uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_workspace_members_workspace_user;index"`
+}
+
+type WorkspaceMemberResponse struct {
+	UserId    uint      `json:"user_id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// メンバーの追加に使う。登録済みのユーザーをメールアドレスで指定する。
+type WorkspaceMemberRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
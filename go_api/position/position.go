@@ -0,0 +1,164 @@
+// 並び順を表す文字列(フラクショナルインデックス)を作るパッケージ
+// 2つの位置の間に入る文字列をいつでも作れるので、並び替えたときに動かした1件だけを更新すればよい。
+// 文字列はバイト順(PostgreSQLではCOLLATE "C")で比較する。
+package position
+
+import "fmt"
+
+// 使用する文字。バイト順に並んでいる必要がある。
+const digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// 位置の文字列がこれより長くなったら、Spreadで振り直すことを勧める長さ。
+// 同じ場所への挿入や末尾への追加を繰り返すと、少しずつ文字列が長くなっていくため。
+const MaxLength = 8
+
+// Spreadで作る位置の文字列の長さ。
+const spreadLength = 6
+
+// aとbの間に入る位置を返す。aが空の場合は先頭、bが空の場合は末尾として扱う。
+// 例: Between("", "") == "V"、Between("V", "") == "V00001"
+func Between(a string, b string) (string, error) {
+	if err := check(a); err != nil {
+		return "", err
+	}
+	if err := check(b); err != nil {
+		return "", err
+	}
+	if a != "" && b != "" && a >= b {
+		return "", fmt.Errorf("position %q must be before %q", a, b)
+	}
+	// 末尾への追加はよく行われるので、真ん中ではなく1つ後ろの値にして文字列が長くなりにくいようにする。
+	if a != "" && b == "" {
+		return increment(a), nil
+	}
+	// 先頭への追加も、1文字目を1つ前の文字にできる場合はそうする。
+	if a == "" && b != "" {
+		if i := indexOf(b[0]); i > 1 {
+			return string(digits[i-1]), nil
+		}
+	}
+	return midpoint(a, b, b != ""), nil
+}
+
+// aより後ろになる値を返す。文字列を62進数の数値とみなして、長さを変えずに1つ進める。
+// 繰り上がった桁より後ろは、末尾が"0"にならないように"1"にする。
+// 短い文字列は、桁が足りなくならないように"0"を付け足してから進める。(末尾の"0"は並び順に影響しない)
+// すべて最大の文字の場合は、真ん中の文字を付け足す。
+func increment(a string) string {
+	b := []byte(a)
+	for len(b) < spreadLength {
+		b = append(b, digits[0])
+	}
+	for i := len(b) - 1; i >= 0; i-- {
+		if d := indexOf(b[i]); d < len(digits)-1 {
+			b[i] = digits[d+1]
+			for j := i + 1; j < len(b); j++ {
+				b[j] = digits[1]
+			}
+			return string(b)
+		}
+	}
+	return a + string(digits[len(digits)/2])
+}
+
+// 位置として使える文字列か確認する。末尾が"0"だとその前に入る位置を作れなくなるので使えない。
+func check(s string) error {
+	for i := 0; i < len(s); i++ {
+		if indexOf(s[i]) < 0 {
+			return fmt.Errorf("invalid position: %q", s)
+		}
+	}
+	if s != "" && s[len(s)-1] == digits[0] {
+		return fmt.Errorf("invalid position: %q", s)
+	}
+	return nil
+}
+
+// aとbの間の文字列を作る。hasBがfalseの場合、bは上限なし(末尾)を表す。
+func midpoint(a string, b string, hasB bool) string {
+	if hasB {
+		// 共通の先頭部分はそのまま使い、残りの部分の間を求める。
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			rest := ""
+			if n < len(a) {
+				rest = a[n:]
+			}
+			return b[:n] + midpoint(rest, b[n:], true)
+		}
+	}
+	digitA := 0
+	if a != "" {
+		digitA = indexOf(a[0])
+	}
+	digitB := len(digits)
+	if hasB {
+		digitB = indexOf(b[0])
+	}
+	// 1文字目の間に別の文字が入る場合は、その真ん中の文字にする。
+	if digitB-digitA > 1 {
+		return string(digits[(digitA+digitB+1)/2])
+	}
+	// 1文字目が隣り合っている場合は、bが2文字以上ならbの1文字目だけで間に入る。
+	if hasB && len(b) > 1 {
+		return b[:1]
+	}
+	// そうでなければaの1文字目の後ろに、aの残りより後ろになる文字列を付け足す。
+	rest := ""
+	if len(a) > 1 {
+		rest = a[1:]
+	}
+	return string(digits[digitA]) + midpoint(rest, "", false)
+}
+
+// i文字目を返す。文字列がそれより短い場合は、一番小さい文字として扱う。
+func digitAt(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return digits[0]
+}
+
+func indexOf(c byte) int {
+	for i := 0; i < len(digits); i++ {
+		if digits[i] == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// n件分の位置を、間隔を空けて作り直す。並び順はそのままで、文字列の長さをそろえたいときに使う。
+// 後ろに追加する余地を残すため、使える範囲の前半だけを使う。
+func Spread(n int) []string {
+	space := int64(1)
+	for i := 0; i < spreadLength; i++ {
+		space *= int64(len(digits))
+	}
+	step := space / 2 / int64(n+1)
+	if step < 1 {
+		step = 1
+	}
+	positions := make([]string, 0, n)
+	for i := 1; i <= n; i++ {
+		positions = append(positions, encode(int64(i)*step))
+	}
+	return positions
+}
+
+// 数値を62進数の固定長の文字列にする。末尾の"0"は並び順に影響しないので取り除く。
+func encode(v int64) string {
+	b := make([]byte, spreadLength)
+	for i := spreadLength - 1; i >= 0; i-- {
+		b[i] = digits[v%int64(len(digits))]
+		v /= int64(len(digits))
+	}
+	end := len(b)
+	for end > 0 && b[end-1] == digits[0] {
+		end--
+	}
+	return string(b[:end])
+}
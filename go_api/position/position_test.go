@@ -0,0 +1,125 @@
+package position
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string
+	}{
+		{"", "", "V"},
+		{"V", "", "V00001"},
+		{"V00001", "", "V00002"},
+		{"Vzzzzz", "", "W11111"},
+		{"", "V", "U"},
+		{"", "1", "0V"},
+		{"A", "C", "B"},
+		{"A", "B", "AV"},
+		{"A", "A1", "A0V"},
+	}
+	for _, tt := range tests {
+		got, err := Between(tt.a, tt.b)
+		if err != nil {
+			t.Errorf("Between(%q, %q) error: %v", tt.a, tt.b, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Between(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestBetweenInvalid(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"B", "A"},
+		{"A", "A"},
+		{"A0", ""},
+		{"", "a-b"},
+		{"あ", ""},
+	}
+	for _, tt := range tests {
+		if got, err := Between(tt.a, tt.b); err == nil {
+			t.Errorf("Between(%q, %q) = %q, want error", tt.a, tt.b, got)
+		}
+	}
+}
+
+// ランダムな場所に挿入を繰り返しても、作った位置は常に前後の位置の間にあり、位置として使える。
+func TestBetweenKeepsOrder(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	positions := []string{}
+	for i := 0; i < 2000; i++ {
+		at := r.Intn(len(positions) + 1)
+		a, b := "", ""
+		if at > 0 {
+			a = positions[at-1]
+		}
+		if at < len(positions) {
+			b = positions[at]
+		}
+		p, err := Between(a, b)
+		if err != nil {
+			t.Fatalf("Between(%q, %q) error: %v", a, b, err)
+		}
+		if err := check(p); err != nil {
+			t.Fatalf("Between(%q, %q) = %q: %v", a, b, p, err)
+		}
+		if (a != "" && p <= a) || (b != "" && p >= b) {
+			t.Fatalf("Between(%q, %q) = %q, not in between", a, b, p)
+		}
+		positions = append(positions[:at], append([]string{p}, positions[at:]...)...)
+	}
+}
+
+// 末尾への追加を繰り返しても、文字列は長くならない。
+func TestBetweenAppendStaysShort(t *testing.T) {
+	last := ""
+	for i := 0; i < 10000; i++ {
+		p, err := Between(last, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(p) > spreadLength {
+			t.Fatalf("position %q after %d appends is longer than %d", p, i, spreadLength)
+		}
+		last = p
+	}
+}
+
+func TestSpread(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 100, 10000} {
+		positions := Spread(n)
+		if len(positions) != n {
+			t.Fatalf("Spread(%d) returned %d positions", n, len(positions))
+		}
+		if !sort.StringsAreSorted(positions) {
+			t.Errorf("Spread(%d) is not sorted", n)
+		}
+		for i, p := range positions {
+			if err := check(p); err != nil {
+				t.Errorf("Spread(%d)[%d] = %q: %v", n, i, p, err)
+			}
+			if len(p) > spreadLength {
+				t.Errorf("Spread(%d)[%d] = %q is longer than %d", n, i, p, spreadLength)
+			}
+			if i > 0 && positions[i-1] == p {
+				t.Errorf("Spread(%d) has duplicate %q", n, p)
+			}
+		}
+		// 振り直した後も、末尾に追加する余地と、間に入れる余地がある。
+		if n > 1 {
+			if p, err := Between(positions[n-1], ""); err != nil || len(p) > MaxLength {
+				t.Errorf("Between(%q, \"\") = %q, %v after Spread(%d)", positions[n-1], p, err, n)
+			}
+			if p, err := Between(positions[0], positions[1]); err != nil || len(p) > MaxLength {
+				t.Errorf("Between(%q, %q) = %q, %v after Spread(%d)", positions[0], positions[1], p, err, n)
+			}
+		}
+	}
+}
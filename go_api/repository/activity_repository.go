@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"go_api/model"
+
+	"gorm.io/gorm"
+)
+
+// アクティビティは記録したら変えないので、読み込みのメソッドだけを持つ。
+// 記録はタスクの変更と同じトランザクションで行うので、ITaskRepositoryのCreateActivityを使う。
+type IActivityRepository interface {
+	GetTaskActivities(activities *[]model.Activity, userId uint, taskId uint) error
+	GetActivities(activities *[]model.Activity, userId uint, before uint, limit int) error
+	// workspaceIdのワークスペースのアクティビティだけを扱うリポジトリを返す。
+	InWorkspace(workspaceId uint) IActivityRepository
+}
+
+type activityRepository struct {
+	db          *gorm.DB
+	workspaceId uint
+}
+
+func NewActivityRepository(db *gorm.DB) IActivityRepository {
+	return &activityRepository{db: db}
+}
+
+func (ar *activityRepository) InWorkspace(workspaceId uint) IActivityRepository {
+	return &activityRepository{ar.db, workspaceId}
+}
+
+// ユーザーがアクセスできるタスク(ゴミ箱のものも含む)の履歴を、新しい順に取得する。
+func (ar *activityRepository) GetTaskActivities(activities *[]model.Activity, userId uint, taskId uint) error {
+	err := ar.db.Joins("User").
+		Where("activities.task_id = ? AND activities.task_id IN (?)", taskId, accessibleTaskIds(ar.db, ar.workspaceId, userId, model.ProjectRoleViewer)).
+		Order("activities.id DESC").Find(activities).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// ワークスペースでユーザーがアクセスできるタスクの履歴と、ユーザー自身の操作の履歴を新しい順に取得する。(完全に削除されたタスクの履歴は、操作したユーザーにだけ見える)
+// beforeが0より大きい場合は、そのidより前のものだけを取得する。
+func (ar *activityRepository) GetActivities(activities *[]model.Activity, userId uint, before uint, limit int) error {
+	query := ar.db.Joins("User").
+		Where("((activities.user_id = ? AND activities.workspace_id IN (?)) OR activities.task_id IN (?))", userId, memberWorkspaceIds(ar.db, ar.workspaceId, userId), accessibleTaskIds(ar.db, ar.workspaceId, userId, model.ProjectRoleViewer))
+	if before > 0 {
+		query = query.Where("activities.id < ?", before)
+	}
+	if err := query.Order("activities.id DESC").Limit(limit).Find(activities).Error; err != nil {
+		return err
+	}
+	return nil
+}
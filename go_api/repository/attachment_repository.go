@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"go_api/model"
+
+	"gorm.io/gorm"
+)
+
+type IAttachmentRepository interface {
+	GetAttachmentsByTaskId(attachments *[]model.Attachment, userId uint, taskId uint) error
+	GetAttachmentById(attachment *model.Attachment, userId uint, taskId uint, attachmentId uint) error
+	CreateAttachment(attachment *model.Attachment) error
+	DeleteAttachment(attachment *model.Attachment) error
+	// workspaceIdのワークスペースのタスクの添付ファイルだけを扱うリポジトリを返す。
+	InWorkspace(workspaceId uint) IAttachmentRepository
+}
+
+type attachmentRepository struct {
+	db          *gorm.DB
+	workspaceId uint
+}
+
+func NewAttachmentRepository(db *gorm.DB) IAttachmentRepository {
+	return &attachmentRepository{db: db}
+}
+
+func (ar *attachmentRepository) InWorkspace(workspaceId uint) IAttachmentRepository {
+	return &attachmentRepository{ar.db, workspaceId}
+}
+
+// タスクの添付ファイルを、追加した順で取得する。共有しているプロジェクトのタスクは、ほかのメンバーが追加したものも含める。
+func (ar *attachmentRepository) GetAttachmentsByTaskId(attachments *[]model.Attachment, userId uint, taskId uint) error {
+	if err := ar.db.Where("task_id = ? AND task_id IN (?)", taskId, accessibleTaskIds(ar.db, ar.workspaceId, userId, model.ProjectRoleViewer)).Order("created_at, id").Find(attachments).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ar *attachmentRepository) GetAttachmentById(attachment *model.Attachment, userId uint, taskId uint, attachmentId uint) error {
+	if err := ar.db.Where("task_id = ? AND task_id IN (?)", taskId, accessibleTaskIds(ar.db, ar.workspaceId, userId, model.ProjectRoleViewer)).First(attachment, attachmentId).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ar *attachmentRepository) CreateAttachment(attachment *model.Attachment) error {
+	if err := ar.db.Create(attachment).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ar *attachmentRepository) DeleteAttachment(attachment *model.Attachment) error {
+	if err := ar.db.Delete(attachment).Error; err != nil {
+		return err
+	}
+	return nil
+}
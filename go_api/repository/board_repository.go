@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"fmt"
+	"go_api/model"
+
+	"gorm.io/gorm"
+)
+
+type IBoardRepository interface {
+	// ワークスペースのボードを、作成した順に取得する。
+	GetBoards(boards *[]model.Board, userId uint) error
+	// ボードを、列を並び順に並べて取得する。
+	GetBoardById(board *model.Board, userId uint, boardId uint) error
+	// ボードを、board.Columnsの列と一緒に作成する。
+	CreateBoard(board *model.Board) error
+	UpdateBoard(board *model.Board, userId uint, boardId uint) error
+	// ボードを削除する。列とカードも外部キーで一緒に削除される。(タスクは削除しない)
+	DeleteBoard(userId uint, boardId uint) error
+	CreateColumn(column *model.BoardColumn) error
+	UpdateColumn(column *model.BoardColumn, boardId uint, columnId uint) error
+	// 列を削除する。列に置かれていたカードも一緒に削除される。
+	DeleteColumn(boardId uint, columnId uint) error
+	CountColumns(count *int64, boardId uint) error
+	// ボードのカードのうち、userIdのユーザーが見ることができるタスクのカードを、列の中の順番で取得する。Taskにタスクを読み込む。
+	GetCards(cards *[]model.BoardCard, userId uint, boardId uint) error
+	// 列ごとのカードの数を取得する。ゴミ箱のタスクのカードは数えない。
+	CountCards(counts *[]model.BoardColumnCount, boardId uint) error
+	// workspaceIdのワークスペースのボードだけを扱うリポジトリを返す。
+	InWorkspace(workspaceId uint) IBoardRepository
+}
+
+type boardRepository struct {
+	db          *gorm.DB
+	workspaceId uint
+}
+
+func NewBoardRepository(db *gorm.DB) IBoardRepository {
+	return &boardRepository{db: db}
+}
+
+func (br *boardRepository) InWorkspace(workspaceId uint) IBoardRepository {
+	return &boardRepository{br.db, workspaceId}
+}
+
+// workspaceIdのワークスペースのメンバーが見ることができるボードに絞り込む。
+func boardScope(workspaceId uint, userId uint) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("boards.workspace_id IN (?)", memberWorkspaceIds(db, workspaceId, userId))
+	}
+}
+
+func (br *boardRepository) GetBoards(boards *[]model.Board, userId uint) error {
+	if err := br.db.Scopes(boardScope(br.workspaceId, userId)).Order("boards.created_at").Order("boards.id").Find(boards).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (br *boardRepository) GetBoardById(board *model.Board, userId uint, boardId uint) error {
+	err := br.db.Preload("Columns", func(db *gorm.DB) *gorm.DB {
+		return db.Order("board_columns.position").Order("board_columns.id")
+	}).Scopes(boardScope(br.workspaceId, userId)).First(board, boardId).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (br *boardRepository) CreateBoard(board *model.Board) error {
+	board.WorkspaceId = br.workspaceId
+	if err := br.db.Create(board).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// nameを更新する。
+func (br *boardRepository) UpdateBoard(board *model.Board, userId uint, boardId uint) error {
+	result := br.db.Model(&model.Board{}).Scopes(boardScope(br.workspaceId, userId)).Where("boards.id = ?", boardId).Update("name", board.Name)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return fmt.Errorf("object does not exist")
+	}
+	return nil
+}
+
+func (br *boardRepository) DeleteBoard(userId uint, boardId uint) error {
+	result := br.db.Scopes(boardScope(br.workspaceId, userId)).Where("boards.id = ?", boardId).Delete(&model.Board{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return fmt.Errorf("object does not exist")
+	}
+	return nil
+}
+
+func (br *boardRepository) CreateColumn(column *model.BoardColumn) error {
+	if err := br.db.Create(column).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// name, status, wip_limit, positionを更新する。
+func (br *boardRepository) UpdateColumn(column *model.BoardColumn, boardId uint, columnId uint) error {
+	result := br.db.Model(column).Where("id = ? AND board_id = ?", columnId, boardId).Updates(map[string]interface{}{
+		"name":      column.Name,
+		"status":    column.Status,
+		"wip_limit": column.WipLimit,
+		"position":  column.Position,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return fmt.Errorf("object does not exist")
+	}
+	return nil
+}
+
+func (br *boardRepository) DeleteColumn(boardId uint, columnId uint) error {
+	result := br.db.Where("id = ? AND board_id = ?", columnId, boardId).Delete(&model.BoardColumn{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return fmt.Errorf("object does not exist")
+	}
+	return nil
+}
+
+func (br *boardRepository) CountColumns(count *int64, boardId uint) error {
+	return br.db.Model(&model.BoardColumn{}).Where("board_id = ?", boardId).Count(count).Error
+}
+
+// ゴミ箱のタスクのカードは、復元したときにまた見えるように残しておき、取得するときに除く。
+func (br *boardRepository) GetCards(cards *[]model.BoardCard, userId uint, boardId uint) error {
+	visible := br.db.Session(&gorm.Session{NewDB: true}).Model(&model.Task{}).Select("tasks.id").Scopes(taskScope(br.workspaceId, userId, model.ProjectRoleViewer))
+	err := br.db.Preload("Task.Labels", func(db *gorm.DB) *gorm.DB {
+		return db.Order("labels.name")
+	}).Where("board_cards.board_id = ? AND board_cards.task_id IN (?)", boardId, visible).
+		Order(`board_cards.position COLLATE "C"`).Order("board_cards.id").
+		Find(cards).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (br *boardRepository) CountCards(counts *[]model.BoardColumnCount, boardId uint) error {
+	err := br.db.Model(&model.BoardCard{}).
+		Select("board_cards.column_id, COUNT(*) AS count").
+		Joins("JOIN tasks ON tasks.id = board_cards.task_id AND tasks.deleted_at IS NULL").
+		Where("board_cards.board_id = ?", boardId).
+		Group("board_cards.column_id").
+		Scan(counts).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
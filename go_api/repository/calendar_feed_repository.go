@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"fmt"
+	"go_api/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ICalendarFeedRepository interface {
+	GetFeedByUserId(feed *model.CalendarFeed, userId uint) error
+	GetFeedByToken(feed *model.CalendarFeed, token string) error
+	CreateFeed(feed *model.CalendarFeed) error
+	UpdateFeedToken(feed *model.CalendarFeed, userId uint, token string) error
+	DeleteFeed(userId uint) error
+	// workspaceIdのワークスペースのフィードだけを扱うリポジトリを返す。トークンでの取得はワークスペースに関係なく行う。
+	InWorkspace(workspaceId uint) ICalendarFeedRepository
+}
+
+type calendarFeedRepository struct {
+	db          *gorm.DB
+	workspaceId uint
+}
+
+func NewCalendarFeedRepository(db *gorm.DB) ICalendarFeedRepository {
+	return &calendarFeedRepository{db: db}
+}
+
+func (cr *calendarFeedRepository) InWorkspace(workspaceId uint) ICalendarFeedRepository {
+	return &calendarFeedRepository{cr.db, workspaceId}
+}
+
+func (cr *calendarFeedRepository) GetFeedByUserId(feed *model.CalendarFeed, userId uint) error {
+	if err := cr.db.Where("user_id=? AND workspace_id=?", userId, cr.workspaceId).First(feed).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cr *calendarFeedRepository) GetFeedByToken(feed *model.CalendarFeed, token string) error {
+	if err := cr.db.Where("token=?", token).First(feed).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cr *calendarFeedRepository) CreateFeed(feed *model.CalendarFeed) error {
+	feed.WorkspaceId = cr.workspaceId
+	if err := cr.db.Create(feed).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// トークンを新しいものに置き換える。更新後のフィードは引数のfeedに書き込まれる。
+func (cr *calendarFeedRepository) UpdateFeedToken(feed *model.CalendarFeed, userId uint, token string) error {
+	result := cr.db.Model(feed).Clauses(clause.Returning{}).Where("user_id=? AND workspace_id=?", userId, cr.workspaceId).Update("token", token)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return fmt.Errorf("object does not exist")
+	}
+	return nil
+}
+
+func (cr *calendarFeedRepository) DeleteFeed(userId uint) error {
+	result := cr.db.Where("user_id=? AND workspace_id=?", userId, cr.workspaceId).Delete(&model.CalendarFeed{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return fmt.Errorf("object does not exist")
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"fmt"
+	"go_api/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ICommentRepository interface {
+	GetCommentsByTaskId(comments *[]model.Comment, userId uint, taskId uint) error
+	GetCommentById(comment *model.Comment, userId uint, taskId uint, commentId uint) error
+	CreateComment(comment *model.Comment) error
+	UpdateComment(comment *model.Comment, userId uint, taskId uint, commentId uint) error
+	DeleteComment(userId uint, taskId uint, commentId uint) error
+	// workspaceIdのワークスペースのタスクのコメントだけを扱うリポジトリを返す。
+	InWorkspace(workspaceId uint) ICommentRepository
+}
+
+type commentRepository struct {
+	db          *gorm.DB
+	workspaceId uint
+}
+
+func NewCommentRepository(db *gorm.DB) ICommentRepository {
+	return &commentRepository{db: db}
+}
+
+func (cr *commentRepository) InWorkspace(workspaceId uint) ICommentRepository {
+	return &commentRepository{cr.db, workspaceId}
+}
+
+// タスクのコメントを、書いた順に書いたユーザーと一緒に取得する。
+func (cr *commentRepository) GetCommentsByTaskId(comments *[]model.Comment, userId uint, taskId uint) error {
+	if err := cr.db.Joins("User").Where("comments.task_id = ? AND comments.task_id IN (?)", taskId, accessibleTaskIds(cr.db, cr.workspaceId, userId, model.ProjectRoleViewer)).Order("comments.created_at, comments.id").Find(comments).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cr *commentRepository) GetCommentById(comment *model.Comment, userId uint, taskId uint, commentId uint) error {
+	if err := cr.db.Joins("User").Where("comments.task_id = ? AND comments.task_id IN (?)", taskId, accessibleTaskIds(cr.db, cr.workspaceId, userId, model.ProjectRoleViewer)).First(comment, commentId).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cr *commentRepository) CreateComment(comment *model.Comment) error {
+	if err := cr.db.Create(comment).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// 本文を更新し、編集した日時を記録する。書いたユーザー本人のコメントだけを更新できる。
+func (cr *commentRepository) UpdateComment(comment *model.Comment, userId uint, taskId uint, commentId uint) error {
+	result := cr.db.Model(&model.Comment{}).Where("id=? AND task_id=? AND user_id=?", commentId, taskId, userId).Where("task_id IN (?)", accessibleTaskIds(cr.db, cr.workspaceId, userId, model.ProjectRoleViewer)).Updates(map[string]interface{}{
+		"body":      comment.Body,
+		"edited_at": time.Now(),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return fmt.Errorf("object does not exist")
+	}
+	return cr.GetCommentById(comment, userId, taskId, commentId)
+}
+
+func (cr *commentRepository) DeleteComment(userId uint, taskId uint, commentId uint) error {
+	result := cr.db.Where("id=? AND task_id=? AND user_id=?", commentId, taskId, userId).Where("task_id IN (?)", accessibleTaskIds(cr.db, cr.workspaceId, userId, model.ProjectRoleViewer)).Delete(&model.Comment{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return fmt.Errorf("object does not exist")
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"go_api/model"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ILabelRepository interface {
+	GetAllLabels(labels *[]model.Label, userId uint) error
+	GetLabelById(label *model.Label, userId uint, labelId uint) error
+	CreateLabel(label *model.Label) error
+	UpdateLabel(label *model.Label, userId uint, labelId uint) error
+	DeleteLabel(userId uint, labelId uint) error
+	// workspaceIdのワークスペースのラベルだけを扱うリポジトリを返す。
+	InWorkspace(workspaceId uint) ILabelRepository
+}
+
+type labelRepository struct {
+	db          *gorm.DB
+	workspaceId uint
+}
+
+func NewLabelRepository(db *gorm.DB) ILabelRepository {
+	return &labelRepository{db: db}
+}
+
+func (lr *labelRepository) InWorkspace(workspaceId uint) ILabelRepository {
+	return &labelRepository{lr.db, workspaceId}
+}
+
+// ワークスペースの、ログインしているユーザーのラベルを名前順で取得する。
+func (lr *labelRepository) GetAllLabels(labels *[]model.Label, userId uint) error {
+	if err := lr.db.Scopes(labelScope(lr.workspaceId, userId)).Order("name").Find(labels).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (lr *labelRepository) GetLabelById(label *model.Label, userId uint, labelId uint) error {
+	if err := lr.db.Scopes(labelScope(lr.workspaceId, userId)).First(label, labelId).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// ラベル名の一意制約に違反した場合は、ErrLabelNameTakenにする。それ以外のエラーはそのまま返す。
+func labelError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return model.ErrLabelNameTaken
+	}
+	return err
+}
+
+// 同じ名前のラベルをすでに持っている場合は、ErrLabelNameTakenを返す。
+func (lr *labelRepository) CreateLabel(label *model.Label) error {
+	label.WorkspaceId = lr.workspaceId
+	if err := lr.db.Create(label).Error; err != nil {
+		return labelError(err)
+	}
+	return nil
+}
+
+// nameとcolorを更新する。更新後のラベルはClauses(clause.Returning{})で引数のlabelに書き込まれる。
+// 同じ名前のラベルをすでに持っている場合は、ErrLabelNameTakenを返す。
+func (lr *labelRepository) UpdateLabel(label *model.Label, userId uint, labelId uint) error {
+	result := lr.db.Model(label).Clauses(clause.Returning{}).Where("labels.id=?", labelId).Scopes(labelScope(lr.workspaceId, userId)).Updates(map[string]interface{}{
+		"name":  label.Name,
+		"color": label.Color,
+	})
+	if result.Error != nil {
+		return labelError(result.Error)
+	}
+	if result.RowsAffected < 1 {
+		return fmt.Errorf("object does not exist")
+	}
+	return nil
+}
+
+// ラベルを削除する。タスクとの関連付け(task_labels)も一緒に削除するので、トランザクションの中で実行する。
+func (lr *labelRepository) DeleteLabel(userId uint, labelId uint) error {
+	return lr.db.Transaction(func(tx *gorm.DB) error {
+		label := model.Label{}
+		if err := tx.Scopes(labelScope(lr.workspaceId, userId)).First(&label, labelId).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM task_labels WHERE label_id = ?", label.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&label).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"fmt"
+	"go_api/model"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IProjectMemberRepository interface {
+	// プロジェクトのメンバーを、オーナー・編集者・閲覧者の順に取得する。
+	GetMembers(members *[]model.ProjectMember, projectId uint) error
+	GetMember(member *model.ProjectMember, projectId uint, userId uint) error
+	// メンバーを追加する。すでにメンバーの場合は、権限を更新する。
+	SaveMember(member *model.ProjectMember) error
+	UpdateMemberRole(projectId uint, userId uint, role string) error
+	DeleteMember(projectId uint, userId uint) error
+	// オーナーの数を数える。最後のオーナーを外さないように、変更が終わるまでオーナーの行をロックしておく。
+	CountOwners(count *int64, projectId uint) error
+	// メールアドレスのユーザーがメンバーか判定する。
+	IsMemberByEmail(isMember *bool, projectId uint, email string) error
+	GetInvitations(invitations *[]model.ProjectInvitation, projectId uint) error
+	// メールアドレス宛ての、期限が切れていない招待を取得する。
+	GetInvitationsByEmail(invitations *[]model.ProjectInvitation, email string) error
+	GetInvitationByToken(invitation *model.ProjectInvitation, token string) error
+	// 招待を作成する。同じメールアドレスへの招待がある場合は、権限・トークン・期限を更新する。
+	SaveInvitation(invitation *model.ProjectInvitation) error
+	DeleteInvitation(projectId uint, invitationId uint) error
+	// プロジェクトに招待されたユーザーを、プロジェクトのワークスペースのメンバーにする。すでにメンバーの場合は何もしない。
+	JoinWorkspace(workspaceId uint, userId uint) error
+	// fnを1つのトランザクションの中で実行する。
+	Transaction(fn func(pmr IProjectMemberRepository) error) error
+}
+
+type projectMemberRepository struct {
+	db *gorm.DB
+}
+
+func NewProjectMemberRepository(db *gorm.DB) IProjectMemberRepository {
+	return &projectMemberRepository{db}
+}
+
+// 権限の強い順に並べるためのORDER BYの式。
+const projectRoleOrder = "CASE project_members.role WHEN 'owner' THEN 0 WHEN 'editor' THEN 1 ELSE 2 END"
+
+func (pmr *projectMemberRepository) GetMembers(members *[]model.ProjectMember, projectId uint) error {
+	if err := pmr.db.Joins("User").Where("project_members.project_id = ?", projectId).Order(projectRoleOrder).Order("project_members.created_at").Find(members).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (pmr *projectMemberRepository) GetMember(member *model.ProjectMember, projectId uint, userId uint) error {
+	if err := pmr.db.Joins("User").Where("project_members.project_id = ? AND project_members.user_id = ?", projectId, userId).First(member).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (pmr *projectMemberRepository) SaveMember(member *model.ProjectMember) error {
+	err := pmr.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "project_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"role", "updated_at"}),
+	}).Create(member).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (pmr *projectMemberRepository) UpdateMemberRole(projectId uint, userId uint, role string) error {
+	result := pmr.db.Model(&model.ProjectMember{}).Where("project_id = ? AND user_id = ?", projectId, userId).Update("role", role)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return fmt.Errorf("object does not exist")
+	}
+	return nil
+}
+
+func (pmr *projectMemberRepository) DeleteMember(projectId uint, userId uint) error {
+	result := pmr.db.Where("project_id = ? AND user_id = ?", projectId, userId).Delete(&model.ProjectMember{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return fmt.Errorf("object does not exist")
+	}
+	return nil
+}
+
+func (pmr *projectMemberRepository) CountOwners(count *int64, projectId uint) error {
+	ids := []uint{}
+	err := pmr.db.Model(&model.ProjectMember{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("project_id = ? AND role = ?", projectId, model.ProjectRoleOwner).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return err
+	}
+	*count = int64(len(ids))
+	return nil
+}
+
+// メールアドレスは大文字と小文字を区別せずに比較する。
+func (pmr *projectMemberRepository) IsMemberByEmail(isMember *bool, projectId uint, email string) error {
+	var count int64
+	err := pmr.db.Model(&model.ProjectMember{}).
+		Joins("JOIN users ON users.id = project_members.user_id").
+		Where("project_members.project_id = ? AND LOWER(users.email) = ?", projectId, strings.ToLower(email)).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	*isMember = count > 0
+	return nil
+}
+
+// プロジェクトはJoinsで取得すると、読み込むだけの列(Project.Role)もSELECTしてしまうので、Preloadで取得する。
+func (pmr *projectMemberRepository) GetInvitations(invitations *[]model.ProjectInvitation, projectId uint) error {
+	if err := pmr.db.Preload("Project").Joins("User").Where("project_invitations.project_id = ?", projectId).Order("project_invitations.created_at").Find(invitations).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (pmr *projectMemberRepository) GetInvitationsByEmail(invitations *[]model.ProjectInvitation, email string) error {
+	err := pmr.db.Preload("Project").Joins("User").
+		Where("project_invitations.email = ? AND project_invitations.expires_at > NOW()", strings.ToLower(email)).
+		Order("project_invitations.created_at").Find(invitations).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (pmr *projectMemberRepository) GetInvitationByToken(invitation *model.ProjectInvitation, token string) error {
+	if err := pmr.db.Preload("Project").Joins("User").Where("project_invitations.token = ?", token).First(invitation).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (pmr *projectMemberRepository) SaveInvitation(invitation *model.ProjectInvitation) error {
+	err := pmr.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "project_id"}, {Name: "email"}},
+		DoUpdates: clause.AssignmentColumns([]string{"role", "token", "expires_at", "user_id", "updated_at"}),
+	}).Create(invitation).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (pmr *projectMemberRepository) DeleteInvitation(projectId uint, invitationId uint) error {
+	result := pmr.db.Where("id = ? AND project_id = ?", invitationId, projectId).Delete(&model.ProjectInvitation{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return fmt.Errorf("object does not exist")
+	}
+	return nil
+}
+
+func (pmr *projectMemberRepository) JoinWorkspace(workspaceId uint, userId uint) error {
+	member := model.WorkspaceMember{WorkspaceId: workspaceId, UserId: userId, Role: model.WorkspaceRoleMember}
+	if err := pmr.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&member).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (pmr *projectMemberRepository) Transaction(fn func(pmr IProjectMemberRepository) error) error {
+	return pmr.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&projectMemberRepository{tx})
+	})
+}
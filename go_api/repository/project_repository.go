@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"fmt"
+	"go_api/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IProjectRepository interface {
+	// ユーザーがメンバーになっているプロジェクトを取得する。Project.Roleにユーザーの権限が入る。
+	// includeArchivedがfalseの場合は、アーカイブしたプロジェクトを除いて取得する。
+	GetAllProjects(projects *[]model.Project, userId uint, includeArchived bool) error
+	GetProjectById(project *model.Project, userId uint, projectId uint) error
+	// プロジェクトを作成し、作成したユーザーをオーナーとしてメンバーに追加する。
+	CreateProject(project *model.Project) error
+	// オーナーだけが更新できる。
+	UpdateProject(project *model.Project, userId uint, projectId uint) error
+	// オーナーだけが削除できる。
+	// modeでプロジェクト内のタスクの扱いを指定する。(model.ProjectDeleteCascade, model.ProjectDeleteMoveToInbox)
+	DeleteProject(userId uint, projectId uint, mode string) error
+	// workspaceIdのワークスペースのプロジェクトだけを扱うリポジトリを返す。
+	InWorkspace(workspaceId uint) IProjectRepository
+}
+
+type projectRepository struct {
+	db          *gorm.DB
+	workspaceId uint
+}
+
+func NewProjectRepository(db *gorm.DB) IProjectRepository {
+	return &projectRepository{db: db}
+}
+
+func (pr *projectRepository) InWorkspace(workspaceId uint) IProjectRepository {
+	return &projectRepository{pr.db, workspaceId}
+}
+
+func (pr *projectRepository) GetAllProjects(projects *[]model.Project, userId uint, includeArchived bool) error {
+	query := pr.db.Scopes(withProjectRole(pr.workspaceId, userId))
+	if !includeArchived {
+		query = query.Where("projects.archived = ?", false)
+	}
+	if err := query.Order("projects.created_at").Find(projects).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (pr *projectRepository) GetProjectById(project *model.Project, userId uint, projectId uint) error {
+	if err := pr.db.Scopes(withProjectRole(pr.workspaceId, userId)).First(project, projectId).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (pr *projectRepository) CreateProject(project *model.Project) error {
+	project.WorkspaceId = pr.workspaceId
+	return pr.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(project).Error; err != nil {
+			return err
+		}
+		member := model.ProjectMember{ProjectId: project.ID, UserId: project.UserId, Role: model.ProjectRoleOwner}
+		if err := tx.Create(&member).Error; err != nil {
+			return err
+		}
+		project.Role = member.Role
+		return nil
+	})
+}
+
+// name, color, archivedを更新する。
+func (pr *projectRepository) UpdateProject(project *model.Project, userId uint, projectId uint) error {
+	result := pr.db.Model(project).Clauses(clause.Returning{}).Where("projects.id=?", projectId).Scopes(projectScope(pr.workspaceId, userId, model.ProjectRoleOwner)).Updates(map[string]interface{}{
+		"name":     project.Name,
+		"color":    project.Color,
+		"archived": project.Archived,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return fmt.Errorf("object does not exist")
+	}
+	project.Role = model.ProjectRoleOwner
+	return nil
+}
+
+// プロジェクトを削除する。プロジェクト内のタスクの削除・移動と一緒に、トランザクションの中で実行する。
+// メンバーと招待は、外部キーでプロジェクトと一緒に削除される。
+func (pr *projectRepository) DeleteProject(userId uint, projectId uint, mode string) error {
+	return pr.db.Transaction(func(tx *gorm.DB) error {
+		project := model.Project{}
+		if err := tx.Scopes(projectScope(pr.workspaceId, userId, model.ProjectRoleOwner)).First(&project, projectId).Error; err != nil {
+			return err
+		}
+		switch mode {
+		case model.ProjectDeleteCascade:
+			// プロジェクトのタスクをサブタスクと一緒にゴミ箱に移す。(論理削除)
+			// プロジェクトを削除すると外部キーでproject_idが空になるので、復元したタスクは作成したユーザーのインボックスに戻る。
+			ids := []uint{}
+			err := tx.Raw(`WITH RECURSIVE subtree AS (
+					SELECT id FROM tasks WHERE project_id = ? AND deleted_at IS NULL
+					UNION
+					SELECT tasks.id FROM tasks JOIN subtree ON tasks.parent_id = subtree.id WHERE tasks.deleted_at IS NULL
+				) SELECT id FROM subtree`, project.ID).Scan(&ids).Error
+			if err != nil {
+				return err
+			}
+			if len(ids) > 0 {
+				if err := tx.Where("id IN ?", ids).Delete(&model.Task{}).Error; err != nil {
+					return err
+				}
+			}
+		case model.ProjectDeleteMoveToInbox:
+			// タスクは、それぞれ作成したユーザーのインボックスに移る。
+			if err := tx.Model(&model.Task{}).Where("project_id = ?", project.ID).Update("project_id", nil).Error; err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown delete mode: %s", mode)
+		}
+		if err := tx.Delete(&project).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"fmt"
+	"go_api/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ISavedViewRepository interface {
+	GetSavedViews(views *[]model.SavedView, userId uint) error
+	GetSavedViewById(view *model.SavedView, userId uint, viewId uint) error
+	CreateSavedView(view *model.SavedView) error
+	UpdateSavedView(view *model.SavedView, userId uint, viewId uint) error
+	DeleteSavedView(userId uint, viewId uint) error
+	// workspaceIdのワークスペースのビューだけを扱うリポジトリを返す。
+	InWorkspace(workspaceId uint) ISavedViewRepository
+}
+
+type savedViewRepository struct {
+	db          *gorm.DB
+	workspaceId uint
+}
+
+func NewSavedViewRepository(db *gorm.DB) ISavedViewRepository {
+	return &savedViewRepository{db: db}
+}
+
+func (vr *savedViewRepository) InWorkspace(workspaceId uint) ISavedViewRepository {
+	return &savedViewRepository{vr.db, workspaceId}
+}
+
+// ワークスペースの、ログインしているユーザーのビューを名前順で取得する。
+func (vr *savedViewRepository) GetSavedViews(views *[]model.SavedView, userId uint) error {
+	if err := vr.db.Scopes(savedViewScope(vr.workspaceId, userId)).Order("name").Order("id").Find(views).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (vr *savedViewRepository) GetSavedViewById(view *model.SavedView, userId uint, viewId uint) error {
+	if err := vr.db.Scopes(savedViewScope(vr.workspaceId, userId)).First(view, viewId).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (vr *savedViewRepository) CreateSavedView(view *model.SavedView) error {
+	view.WorkspaceId = vr.workspaceId
+	if err := vr.db.Create(view).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// name, query, sort, sort_orderを更新する。更新後のビューはClauses(clause.Returning{})で引数のviewに書き込まれる。
+func (vr *savedViewRepository) UpdateSavedView(view *model.SavedView, userId uint, viewId uint) error {
+	result := vr.db.Model(view).Clauses(clause.Returning{}).Where("saved_views.id=?", viewId).Scopes(savedViewScope(vr.workspaceId, userId)).Updates(map[string]interface{}{
+		"name":       view.Name,
+		"query":      view.Query,
+		"sort":       view.Sort,
+		"sort_order": view.Order,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return fmt.Errorf("object does not exist")
+	}
+	return nil
+}
+
+func (vr *savedViewRepository) DeleteSavedView(userId uint, viewId uint) error {
+	result := vr.db.Scopes(savedViewScope(vr.workspaceId, userId)).Where("saved_views.id=?", viewId).Delete(&model.SavedView{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return fmt.Errorf("object does not exist")
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"go_api/model"
+
+	"gorm.io/gorm"
+)
+
+// ワークスペースとプロジェクトのメンバーの権限で、アクセスできるプロジェクトやタスクに絞り込むためのスコープ。
+// プロジェクトやタスク、ラベルを扱うクエリは、user_idやworkspace_idを直接比較せずに、ここの関数を通して絞り込む。
+// workspaceIdのワークスペースのメンバーでない場合は、どの行にもアクセスできない。
+
+// userIdのユーザーがworkspaceIdのワークスペースのメンバーの場合だけ、workspaceIdを返すサブクエリ。
+func memberWorkspaceIds(db *gorm.DB, workspaceId uint, userId uint) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true}).Model(&model.WorkspaceMember{}).
+		Select("workspace_id").
+		Where("workspace_id = ? AND user_id = ?", workspaceId, userId)
+}
+
+// userIdのユーザーが、role以上の権限を持っているプロジェクトのidを取得するサブクエリ。
+func memberProjectIds(db *gorm.DB, userId uint, role string) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true}).Model(&model.ProjectMember{}).
+		Select("project_id").
+		Where("user_id = ? AND role IN ?", userId, model.ProjectRolesAtLeast(role))
+}
+
+// ワークスペースの中で、プロジェクトに入っていないタスクは作成したユーザーだけが、プロジェクトのタスクはそのプロジェクトのメンバーがアクセスできる。
+// 生のSQLでは、taskAccessArgsの値と一緒に使う。
+const taskAccessCondition = "(tasks.workspace_id IN (?) AND ((tasks.project_id IS NULL AND tasks.user_id = ?) OR tasks.project_id IN (?)))"
+
+func taskAccessArgs(db *gorm.DB, workspaceId uint, userId uint, role string) []interface{} {
+	return []interface{}{memberWorkspaceIds(db, workspaceId, userId), userId, memberProjectIds(db, userId, role)}
+}
+
+// userIdのユーザーが、workspaceIdのワークスペースでrole以上の権限でアクセスできるタスクに絞り込む。
+func taskScope(workspaceId uint, userId uint, role string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(taskAccessCondition, taskAccessArgs(db, workspaceId, userId, role)...)
+	}
+}
+
+// userIdのユーザーが、workspaceIdのワークスペースでrole以上の権限を持っているプロジェクトに絞り込む。
+func projectScope(workspaceId uint, userId uint, role string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("projects.workspace_id IN (?) AND projects.id IN (?)", memberWorkspaceIds(db, workspaceId, userId), memberProjectIds(db, userId, role))
+	}
+}
+
+// workspaceIdのワークスペースで、userIdのユーザーがメンバーになっているプロジェクトに絞り込み、ユーザーの権限をProject.Roleに読み込む。
+func withProjectRole(workspaceId uint, userId uint) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Select("projects.*, project_members.role AS role").
+			Joins("JOIN project_members ON project_members.project_id = projects.id AND project_members.user_id = ?", userId).
+			Where("projects.workspace_id IN (?)", memberWorkspaceIds(db, workspaceId, userId))
+	}
+}
+
+// workspaceIdのワークスペースの、userIdのユーザーのラベルに絞り込む。
+func labelScope(workspaceId uint, userId uint) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("labels.workspace_id IN (?) AND labels.user_id = ?", memberWorkspaceIds(db, workspaceId, userId), userId)
+	}
+}
+
+// userIdのユーザーが、workspaceIdのワークスペースでrole以上の権限でアクセスできるタスクのidを取得するサブクエリ。
+// タスクに紐づくコメントやアクティビティなどを絞り込むときに使う。削除済み(ゴミ箱)のタスクも含める。
+func accessibleTaskIds(db *gorm.DB, workspaceId uint, userId uint, role string) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true}).Unscoped().Model(&model.Task{}).
+		Select("tasks.id").
+		Scopes(taskScope(workspaceId, userId, role))
+}
+
+// workspaceIdのワークスペースの、userIdのユーザーの保存したビューに絞り込む。
+func savedViewScope(workspaceId uint, userId uint) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("saved_views.workspace_id IN (?) AND saved_views.user_id = ?", memberWorkspaceIds(db, workspaceId, userId), userId)
+	}
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"fmt"
+	"go_api/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ITaskDependencyRepository interface {
+	// ワークスペースのすべての依存関係を取得する。循環の確認に使うので、見ることができないタスクやゴミ箱のタスクの依存関係も含める。
+	GetEdges(edges *[]model.TaskDependency) error
+	// taskIdsのうちuserIdのユーザーが見ることができるタスクを、依存関係のグラフの頂点として取得する。
+	GetNodes(nodes *[]model.TaskDependencyNode, userId uint, taskIds []uint) error
+	// すでに同じ依存関係がある場合は、何もしない。
+	CreateDependency(dependency *model.TaskDependency) error
+	DeleteDependency(taskId uint, dependsOnId uint) error
+	// fnを1つのトランザクションの中で実行する。
+	// 循環の確認と追加の間にほかのリクエストで依存関係が追加されないように、ワークスペースの行をロックしておく。
+	Transaction(fn func(dr ITaskDependencyRepository) error) error
+	// workspaceIdのワークスペースのタスクの依存関係だけを扱うリポジトリを返す。
+	InWorkspace(workspaceId uint) ITaskDependencyRepository
+}
+
+type taskDependencyRepository struct {
+	db          *gorm.DB
+	workspaceId uint
+}
+
+func NewTaskDependencyRepository(db *gorm.DB) ITaskDependencyRepository {
+	return &taskDependencyRepository{db: db}
+}
+
+func (dr *taskDependencyRepository) InWorkspace(workspaceId uint) ITaskDependencyRepository {
+	return &taskDependencyRepository{dr.db, workspaceId}
+}
+
+// tasksのタスクが、終わっていない(完了・中止になっていない)タスクに依存しているかどうか。ゴミ箱のタスクへの依存は数えない。
+// 生のSQLなので、終わったとみなすステータスの一覧と一緒に使う。
+const taskBlockedCondition = `EXISTS (
+	SELECT 1 FROM task_dependencies
+	JOIN tasks AS blockers ON blockers.id = task_dependencies.depends_on_id
+	WHERE task_dependencies.task_id = tasks.id AND blockers.deleted_at IS NULL AND blockers.status NOT IN ?)`
+
+var taskFinishedStatuses = []string{model.TaskStatusDone, model.TaskStatusCancelled}
+
+func (dr *taskDependencyRepository) GetEdges(edges *[]model.TaskDependency) error {
+	err := dr.db.Where("task_id IN (?)", dr.db.Session(&gorm.Session{NewDB: true}).Unscoped().Model(&model.Task{}).Select("id").Where("workspace_id = ?", dr.workspaceId)).
+		Order("id").Find(edges).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (dr *taskDependencyRepository) GetNodes(nodes *[]model.TaskDependencyNode, userId uint, taskIds []uint) error {
+	*nodes = []model.TaskDependencyNode{}
+	if len(taskIds) == 0 {
+		return nil
+	}
+	err := dr.db.Model(&model.Task{}).
+		Select("tasks.id, tasks.title, tasks.status, "+taskBlockedCondition+" AS blocked", taskFinishedStatuses).
+		Scopes(taskScope(dr.workspaceId, userId, model.ProjectRoleViewer)).
+		Where("tasks.id IN ?", taskIds).
+		Order("tasks.id").
+		Scan(nodes).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (dr *taskDependencyRepository) CreateDependency(dependency *model.TaskDependency) error {
+	if err := dr.db.Clauses(clause.OnConflict{DoNothing: true}).Create(dependency).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (dr *taskDependencyRepository) DeleteDependency(taskId uint, dependsOnId uint) error {
+	result := dr.db.Where("task_id = ? AND depends_on_id = ?", taskId, dependsOnId).Delete(&model.TaskDependency{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return fmt.Errorf("object does not exist")
+	}
+	return nil
+}
+
+func (dr *taskDependencyRepository) Transaction(fn func(dr ITaskDependencyRepository) error) error {
+	return dr.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&model.Workspace{}, dr.workspaceId).Error; err != nil {
+			return err
+		}
+		return fn(&taskDependencyRepository{tx, dr.workspaceId})
+	})
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"go_api/model"
+	"go_api/taskfilter"
+	"strings"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// taskfilterの式を、WHEREの条件にする。
+// SQLにするのは下の表のカラムと決まった形の条件だけで、式に書かれた値はすべてプレースホルダーで渡す。
+
+// 式のフィールドに対応するカラム。labelはtask_labelsのサブクエリで絞り込むので、ここには入れない。
+var taskQueryColumns = map[string]string{
+	"status":      "tasks.status",
+	"title":       "tasks.title",
+	"description": "tasks.description",
+	"project":     "tasks.project_id",
+	"parent":      "tasks.parent_id",
+	"assignee":    "tasks.assignee_id",
+	"creator":     "tasks.user_id",
+	"due":         "tasks.due_at",
+	"start":       "tasks.start_at",
+	"completed":   "tasks.completed_at",
+	"created":     "tasks.created_at",
+	"updated":     "tasks.updated_at",
+}
+
+// NULLになることがあるカラム。notで反転したときにNULLの行が漏れないように、条件がNULLにならない形にする。
+var taskQueryNullable = map[string]bool{
+	"project":   true,
+	"parent":    true,
+	"assignee":  true,
+	"due":       true,
+	"start":     true,
+	"completed": true,
+}
+
+// taskQueryCondition
+// 式に書かれたtodayやnowは、nowの時刻とタイムゾーンで決める。meはuserIdのユーザーにする。
+func (tr *taskRepository) taskQueryCondition(e taskfilter.Expr, userId uint, now time.Time) clause.Expression {
+	switch v := e.(type) {
+	case taskfilter.And:
+		return clause.Expr{SQL: "(? AND ?)", Vars: []interface{}{tr.taskQueryCondition(v.Left, userId, now), tr.taskQueryCondition(v.Right, userId, now)}}
+	case taskfilter.Or:
+		return clause.Expr{SQL: "(? OR ?)", Vars: []interface{}{tr.taskQueryCondition(v.Left, userId, now), tr.taskQueryCondition(v.Right, userId, now)}}
+	case taskfilter.Not:
+		return clause.Expr{SQL: "(NOT ?)", Vars: []interface{}{tr.taskQueryCondition(v.Expr, userId, now)}}
+	case taskfilter.Flag:
+		if v.Name == "blocked" {
+			return clause.Expr{SQL: taskBlockedCondition, Vars: []interface{}{taskFinishedStatuses}}
+		}
+		return clause.Expr{SQL: "(tasks.due_at IS NOT NULL AND tasks.due_at < ? AND tasks.status NOT IN ?)", Vars: []interface{}{now, taskFinishedStatuses}}
+	case taskfilter.Condition:
+		if v.Field == "label" {
+			return tr.taskQueryLabel(v, userId)
+		}
+		return taskQueryComparison(v, userId, now)
+	}
+	// taskfilterの構文木はここで扱うものだけなので、ここには来ない。
+	return clause.Expr{SQL: "FALSE"}
+}
+
+// ラベルの条件。GetAllTasksのラベルの絞り込みと同じく、ユーザー自身のラベルの名前で探す。
+func (tr *taskRepository) taskQueryLabel(cond taskfilter.Condition, userId uint) clause.Expression {
+	sql := `tasks.id IN (
+			SELECT task_labels.task_id FROM task_labels
+			JOIN labels ON labels.id = task_labels.label_id
+			WHERE labels.user_id = ? AND labels.workspace_id = ? AND labels.name = ?)`
+	if cond.Op == "!=" {
+		sql = "NOT " + sql
+	}
+	return clause.Expr{SQL: "(" + sql + ")", Vars: []interface{}{userId, tr.workspaceId, cond.Value.Str}}
+}
+
+// カラムと値を比べる条件。!=は=の条件を反転させ、NULLのカラムはNULLの行も含める。
+// それ以外の演算子では、NULLのカラムはNULLの行を含めない。
+func taskQueryComparison(cond taskfilter.Condition, userId uint, now time.Time) clause.Expression {
+	column := taskQueryColumns[cond.Field]
+	if cond.Value.Kind == taskfilter.ValueNone {
+		if cond.Op == "!=" {
+			return clause.Expr{SQL: column + " IS NOT NULL"}
+		}
+		return clause.Expr{SQL: column + " IS NULL"}
+	}
+	op := cond.Op
+	if op == "!=" {
+		op = "="
+	}
+	var expr clause.Expr
+	switch cond.Value.Kind {
+	case taskfilter.ValueId:
+		expr = clause.Expr{SQL: column + " = ?", Vars: []interface{}{cond.Value.Id}}
+	case taskfilter.ValueMe:
+		expr = clause.Expr{SQL: column + " = ?", Vars: []interface{}{userId}}
+	case taskfilter.ValueTime:
+		expr = taskQueryTime(column, op, cond.Value.Time, now)
+	default:
+		if op == "~" {
+			escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(cond.Value.Str)
+			expr = clause.Expr{SQL: column + " ILIKE ?", Vars: []interface{}{"%" + escaped + "%"}}
+		} else {
+			expr = clause.Expr{SQL: column + " = ?", Vars: []interface{}{cond.Value.Str}}
+		}
+	}
+	nullable := taskQueryNullable[cond.Field]
+	if cond.Op == "!=" {
+		if nullable {
+			return clause.Expr{SQL: "(" + column + " IS NULL OR NOT ?)", Vars: []interface{}{expr}}
+		}
+		return clause.Expr{SQL: "(NOT ?)", Vars: []interface{}{expr}}
+	}
+	if nullable {
+		return clause.Expr{SQL: "(" + column + " IS NOT NULL AND ?)", Vars: []interface{}{expr}}
+	}
+	return expr
+}
+
+// 日時の条件。1日全体を表す値(todayや日付)は、その日の0時から翌日の0時までの範囲として比べる。
+func taskQueryTime(column string, op string, value taskfilter.TimeValue, now time.Time) clause.Expr {
+	start, end := value.Range(now)
+	switch op {
+	case "<":
+		return clause.Expr{SQL: column + " < ?", Vars: []interface{}{start}}
+	case "<=":
+		if value.IsDay() {
+			return clause.Expr{SQL: column + " < ?", Vars: []interface{}{end}}
+		}
+		return clause.Expr{SQL: column + " <= ?", Vars: []interface{}{end}}
+	case ">":
+		if value.IsDay() {
+			return clause.Expr{SQL: column + " >= ?", Vars: []interface{}{end}}
+		}
+		return clause.Expr{SQL: column + " > ?", Vars: []interface{}{end}}
+	case ">=":
+		return clause.Expr{SQL: column + " >= ?", Vars: []interface{}{start}}
+	}
+	if value.IsDay() {
+		return clause.Expr{SQL: "(" + column + " >= ? AND " + column + " < ?)", Vars: []interface{}{start, end}}
+	}
+	return clause.Expr{SQL: column + " = ?", Vars: []interface{}{start}}
+}
+
+// filter.Queryの式を解析して、WHEREの条件にする。式が空の場合はnilを返す。
+func (tr *taskRepository) taskQueryFilter(filter model.TaskFilter, userId uint) (clause.Expression, error) {
+	e, err := taskfilter.Parse(filter.Query)
+	if err != nil || e == nil {
+		return nil, err
+	}
+	loc := filter.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	return tr.taskQueryCondition(e, userId, time.Now().In(loc)), nil
+}
@@ -3,39 +3,220 @@ package repository
 import (
 	"fmt"
 	"go_api/model"
+	"go_api/position"
+	"sort"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
 type ITaskRepository interface {
-	// ログインしているユーザーが作成したタスクの一覧を取得するメソッド。
+	// ログインしているユーザーがアクセスできるタスクの一覧を取得するメソッド。
+	// 自分が作成したプロジェクトなしのタスクと、メンバーになっているプロジェクトのタスクが対象になる。
 	// タスクの一覧を配列に格納するためにmodelタスクのスライスのポインタを第一引数で渡す。
 	// 第2引数はログインしているユーザーのidを渡す。
-	GetAllTasks(task *[]model.Task, userId uint) error
+	// 第3引数のfilterでステータスなどの絞り込み条件、第4引数のpageで並び順と件数を渡す。
+	// 第5引数のafterがnilでない場合は、そのカーソルより後ろのタスクを取得する。
+	GetAllTasks(task *[]model.Task, userId uint, filter model.TaskFilter, page model.TaskPage, after *model.TaskCursor) error
 	GetTaskById(task *model.Task, userId uint, taskId uint) error
+	// タスクに対するユーザーの権限(model.ProjectRoleViewerなど)を取得する。ゴミ箱のタスクも含める。
+	GetTaskRole(role *string, userId uint, taskId uint) error
+	// タイトルと説明文を全文検索し、関連度の高い順にlimit件まで取得する。
+	SearchTasks(hits *[]model.TaskSearchHit, userId uint, query string, limit int) error
 	CreateTask(task *model.Task) error
 	UpdateTask(task *model.Task, userId uint, taskId uint) error
-	DeleteTask(userId uint, taskId uint) error
+	DeleteTask(userId uint, taskId uint, version uint) error
+	// taskIdのタスクの子孫(サブタスク、サブタスクのサブタスク…)をすべて取得する。
+	GetSubtasks(tasks *[]model.Task, userId uint, taskId uint) error
+	// parentIdsの各タスクについて、直下のサブタスクの数と完了した数を取得する。
+	GetSubtaskCounts(counts *[]model.SubtaskCount, userId uint, parentIds []uint) error
+	// 並び順はワークスペースで1つなので、位置の取得と振り直しは、ユーザーが見ることができるかに関係なくワークスペースのすべてのタスクを対象にする。
+	// (見ることができるタスクだけで計算すると、共有プロジェクトのほかのメンバーのタスクと位置が重なる)
+	// ワークスペースのタスクの中で一番後ろの位置を取得する。excludeIdのタスクは除く。タスクがない場合は空文字になる。
+	GetLastPosition(position *string, excludeId uint) error
+	// fromの位置の直後(afterがtrue)または直前(afterがfalse)のタスクの位置を取得する。excludeIdのタスクは除く。
+	// 該当するタスクがない場合は空文字になる。
+	GetAdjacentPosition(position *string, from string, after bool, excludeId uint) error
+	UpdatePosition(userId uint, taskId uint, position string) error
+	// ワークスペースのすべてのタスクの位置を、今の並び順のまま間隔を空けて振り直す。
+	RebalancePositions() error
+	// タスクに付いているuserIdのユーザーのラベルを、labelIdsのラベルに置き換える。ラベルはuserIdのユーザーのものだけ指定できる。
+	// 共有しているプロジェクトのタスクに、ほかのメンバーが付けたラベルはそのまま残す。
+	ReplaceTaskLabels(task *model.Task, userId uint, labelIds []uint) error
+	// ゴミ箱(削除済み)のタスクを、削除した日時の新しい順に取得する。一緒に削除されたサブタスクは含めない。
+	GetDeletedTasks(tasks *[]model.Task, userId uint) error
+	// 削除済みのタスクを、一緒に削除されたサブタスクと合わせて復元する。
+	RestoreTask(task *model.Task, userId uint, taskId uint) error
+	// beforeより前に削除されたタスクを、すべてのユーザーについて完全に削除する。削除した件数をcountに入れる。
+	PurgeDeletedTasks(count *int64, storageKeys *[]string, before time.Time) error
+	CreateActivity(activity *model.Activity) error
+	CreateRevision(revision *model.TaskRevision) error
+	GetLastUndoableRevision(revision *model.TaskRevision, userId uint) error
+	GetPreviousRevision(revision *model.TaskRevision, taskId uint, beforeId uint) error
+	CountNewerRevisions(count *int64, taskId uint, afterId uint) error
+	GetRevisionAtVersion(revision *model.TaskRevision, userId uint, taskId uint, version uint) error
+	MarkRevisionUndone(revisionId uint) error
+	GetTaskWithDeleted(task *model.Task, userId uint, taskId uint) error
+	ReplaceTask(task *model.Task, userId uint, taskId uint) error
+	GetExistingLabelIds(ids *[]uint, userId uint, labelIds []uint) error
+	// fnを1つのトランザクションの中で実行する。fnに渡すリポジトリの操作はすべてこのトランザクションで行われる。
+	// fnがエラーを返すとロールバックする。トランザクションの中で呼んだ場合は、セーブポイントになる。
+	Transaction(fn func(tr ITaskRepository) error) error
+	// ユーザーがアクセスできるすべてのタスクを、親タスクが子より先になる順番でbatchSize件ずつfnに渡す。(エクスポート用)
+	ExportTasks(userId uint, batchSize int, fn func(tasks []model.Task) error) error
+	// 名前が同じラベルを取得し、ない場合は作成する。
+	FindOrCreateLabels(labels *[]model.Label, userId uint, wanted []model.Label) error
+	// タイトルと期限が同じタスクの数を取得する。(インポートの重複確認用)
+	CountDuplicateTasks(count *int64, userId uint, title string, dueAt *time.Time) error
+	// taskIdのタスクが依存している、終わっていない(完了・中止になっていない)タスクの数を取得する。見ることができないタスクも数える。
+	CountBlockers(count *int64, taskId uint) error
+	// ワークスペースのボードの列を取得し、行をロックする。
+	GetBoardColumn(column *model.BoardColumn, boardId uint, columnId uint) error
+	// ボードの、statusに対応する列を取得し、行をロックする。
+	GetStatusColumn(column *model.BoardColumn, boardId uint, status string) error
+	GetBoardCard(card *model.BoardCard, boardId uint, taskId uint) error
+	// taskIdのタスクの、すべてのボードのカードを取得する。
+	GetTaskBoardCards(cards *[]model.BoardCard, taskId uint) error
+	// 列のカードを、列の中の順番で取得する。
+	GetColumnCards(cards *[]model.BoardCard, columnId uint) error
+	// 列に置かれているカードの数を取得する。excludeTaskIdのタスクのカードと、ゴミ箱のタスクのカードは数えない。
+	CountColumnCards(count *int64, columnId uint, excludeTaskId uint) error
+	// カードを置く。(ボードにすでにタスクのカードがある場合は移す)
+	SaveBoardCard(card *model.BoardCard) error
+	// 列のカードの位置を、今の並び順のまま振り直す。
+	RebalanceColumnCards(columnId uint) error
+	DeleteBoardCard(boardId uint, taskId uint) error
+	// workspaceIdのワークスペースのタスクだけを扱うリポジトリを返す。
+	InWorkspace(workspaceId uint) ITaskRepository
 }
 
 // まずはtaskRepositoryという構造体を定義する。
+// workspaceIdは、InWorkspaceで指定したワークスペース。すべてのクエリをこのワークスペースに絞り込み、作成するタスクもこのワークスペースに入れる。
 type taskRepository struct {
-	db *gorm.DB
+	db          *gorm.DB
+	workspaceId uint
 }
 
 // NewTaskRepositoryというコンストラクターを作成。
 // 外側(上)でインスタンス化されたdbを引数として受け取って、受け取ったDBを使ってTaskRepositoryの構造体の実体を作成する。
 // そして、その実体のアドレスを取得してreturnで返す。
 func NewTaskRepository(db *gorm.DB) ITaskRepository {
-	return &taskRepository{db}
+	return &taskRepository{db: db}
 }
 
-// ログイン済みのユーザーが作成したすべての投稿一覧を取得
+func (tr *taskRepository) InWorkspace(workspaceId uint) ITaskRepository {
+	return &taskRepository{tr.db, workspaceId}
+}
+
+// 並び替えキーごとの、ORDER BYとカーソルの比較に使うSQLの式。
+// 期限(due_at)がないタスクは、一番遅い期限として扱う。
+// 並び順(position)はバイト順で比較するので、COLLATE "C"を指定する。
+var taskSortColumns = map[string]string{
+	model.TaskSortPosition:  `tasks.position COLLATE "C"`,
+	model.TaskSortCreatedAt: "tasks.created_at",
+	model.TaskSortUpdatedAt: "tasks.updated_at",
+	model.TaskSortTitle:     "tasks.title",
+	model.TaskSortDueAt:     "COALESCE(tasks.due_at, 'infinity'::timestamptz)",
+}
+
+// カーソルの値と比較するときのプレースホルダー。日時は文字列で持っているのでキャストする。
+var taskCursorPlaceholders = map[string]string{
+	model.TaskSortPosition:  `? COLLATE "C"`,
+	model.TaskSortCreatedAt: "?::timestamptz",
+	model.TaskSortUpdatedAt: "?::timestamptz",
+	model.TaskSortTitle:     "?",
+	model.TaskSortDueAt:     "?::timestamptz",
+}
+
+// ログイン済みのユーザーがアクセスできる投稿一覧を、1ページ分だけ取得
+// ページの続きがあるか判定できるように、page.Limitより1件多く取得する。
 // ブレークポイントとはソフトウェアのデバッグ中にプログラムの実行を一時停止するための指定されたポイント
-func (tr *taskRepository) GetAllTasks(tasks *[]model.Task, userId uint) error {
+func (tr *taskRepository) GetAllTasks(tasks *[]model.Task, userId uint, filter model.TaskFilter, page model.TaskPage, after *model.TaskCursor) error {
 	// taskテーブルとuserテーブルをJoinで結合。そして、タスク情報とそれに関するユーザー情報を取得。
-	if err := tr.db.Joins("User").Where("user_id=?", userId).Order("created_at").Find(tasks).Error; err != nil {
+	// 付いているラベルもPreloadで一緒に取得する。
+	query := tr.db.Joins("User").Preload("Labels", func(db *gorm.DB) *gorm.DB {
+		return db.Order("labels.name")
+	}).Scopes(taskScope(tr.workspaceId, userId, model.ProjectRoleViewer))
+	// ステータスの指定がある場合だけ、条件を追加する。
+	if filter.Status != "" {
+		query = query.Where("tasks.status=?", filter.Status)
+	}
+	// タイトルの部分一致。%や_はワイルドカードにならないようにエスケープする。
+	if filter.Title != "" {
+		escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(filter.Title)
+		query = query.Where("tasks.title ILIKE ?", "%"+escaped+"%")
+	}
+	if filter.DueBefore != nil {
+		query = query.Where("tasks.due_at < ?", *filter.DueBefore)
+	}
+	if filter.DueAfter != nil {
+		query = query.Where("tasks.due_at > ?", *filter.DueAfter)
+	}
+	// 期限切れのタスクは、期限が現在時刻より前で、まだ終わっていないもの。
+	if filter.Overdue {
+		query = query.Where("tasks.due_at < ? AND tasks.status NOT IN ?", time.Now(), []string{model.TaskStatusDone, model.TaskStatusCancelled})
+	}
+	if filter.ProjectId != nil {
+		query = query.Where("tasks.project_id = ?", *filter.ProjectId)
+	}
+	if filter.Inbox {
+		query = query.Where("tasks.project_id IS NULL")
+	}
+	if filter.ParentId != nil {
+		query = query.Where("tasks.parent_id = ?", *filter.ParentId)
+	}
+	if filter.RootOnly {
+		query = query.Where("tasks.parent_id IS NULL")
+	}
+	if filter.AssigneeId != nil {
+		query = query.Where("tasks.assignee_id = ?", *filter.AssigneeId)
+	}
+	if filter.Unassigned {
+		query = query.Where("tasks.assignee_id IS NULL")
+	}
+	if filter.Blocked != nil && *filter.Blocked {
+		query = query.Where(taskBlockedCondition, taskFinishedStatuses)
+	} else if filter.Blocked != nil {
+		query = query.Where("NOT "+taskBlockedCondition, taskFinishedStatuses)
+	}
+	if filter.Query != "" {
+		cond, err := tr.taskQueryFilter(filter, userId)
+		if err != nil {
+			return err
+		}
+		if cond != nil {
+			query = query.Where(cond)
+		}
+	}
+	// 指定したラベルがすべて付いているタスクに絞り込む。付いているラベルの数が指定した数と一致するものを探す。
+	if len(filter.Labels) > 0 {
+		query = query.Where(`tasks.id IN (
+			SELECT task_labels.task_id FROM task_labels
+			JOIN labels ON labels.id = task_labels.label_id
+			WHERE labels.user_id = ? AND labels.workspace_id = ? AND labels.name IN ?
+			GROUP BY task_labels.task_id
+			HAVING COUNT(DISTINCT labels.id) = ?)`, userId, tr.workspaceId, filter.Labels, len(filter.Labels))
+	}
+
+	column := taskSortColumns[page.Sort]
+	direction := "ASC"
+	operator := ">"
+	if page.Order == "desc" {
+		direction = "DESC"
+		operator = "<"
+	}
+	// カーソルがある場合は、(並び替えキー, id)の組がカーソルより後ろのものだけを取得する。(キーセットページネーション)
+	if after != nil {
+		value := after.Value
+		if page.Sort == model.TaskSortDueAt && value == "" {
+			value = "infinity"
+		}
+		cond := fmt.Sprintf("(%s, tasks.id) %s (%s, ?)", column, operator, taskCursorPlaceholders[page.Sort])
+		query = query.Where(cond, value, after.ID)
+	}
+	if err := query.Order(column + " " + direction).Order("tasks.id " + direction).Limit(page.Limit + 1).Find(tasks).Error; err != nil {
 		return err
 	}
 	return nil
@@ -45,7 +226,95 @@ func (tr *taskRepository) GetAllTasks(tasks *[]model.Task, userId uint) error {
 func (tr *taskRepository) GetTaskById(task *model.Task, userId uint, taskId uint) error {
 	// taskの主キー(id)が引数で受け取ったtaskIDに一致するtaskを取得する。
 	// そして、取得したタスクオブジェクトを引数で受け取っていたポインタアドレスが指し示す先のメモリー領域に書き込む
-	if err := tr.db.Joins("User").Where("user_id=?", userId).First(task, taskId).Error; err != nil {
+	if err := tr.db.Joins("User").Preload("Labels").Scopes(taskScope(tr.workspaceId, userId, model.ProjectRoleViewer)).First(task, taskId).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetTaskRoleメソッド
+// プロジェクトに入っていないタスクは、作成したユーザーだけがアクセスできるので、オーナーとして扱う。
+func (tr *taskRepository) GetTaskRole(role *string, userId uint, taskId uint) error {
+	roles := []string{}
+	err := tr.db.Unscoped().Model(&model.Task{}).
+		Select("CASE WHEN tasks.project_id IS NULL THEN ? ELSE project_members.role END", model.ProjectRoleOwner).
+		Joins("LEFT JOIN project_members ON project_members.project_id = tasks.project_id AND project_members.user_id = ?", userId).
+		Where("tasks.id = ?", taskId).
+		Scopes(taskScope(tr.workspaceId, userId, model.ProjectRoleViewer)).
+		Scan(&roles).Error
+	if err != nil {
+		return err
+	}
+	if len(roles) == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	*role = roles[0]
+	return nil
+}
+
+// taskIdのタスクの子孫のidを、再帰クエリ(WITH RECURSIVE)で取得するサブクエリ。
+// taskIdのタスクにアクセスできるかは、呼び出し側で確認する。生のSQLなので、削除済みのタスクは自分で除く。
+const subtreeIdsQuery = `WITH RECURSIVE subtree AS (
+		SELECT id FROM tasks WHERE parent_id = ? AND deleted_at IS NULL
+		UNION
+		SELECT tasks.id FROM tasks JOIN subtree ON tasks.parent_id = subtree.id WHERE tasks.deleted_at IS NULL
+	) SELECT id FROM subtree`
+
+// taskIdのタスクと一緒に(同じ日時に)削除された子孫のidを取得するサブクエリ。
+const deletedSubtreeIdsQuery = `WITH RECURSIVE subtree AS (
+		SELECT id FROM tasks WHERE parent_id = ? AND deleted_at = ?
+		UNION
+		SELECT tasks.id FROM tasks JOIN subtree ON tasks.parent_id = subtree.id WHERE tasks.deleted_at = ?
+	) SELECT id FROM subtree`
+
+// GetSubtasksメソッド
+// 子孫のうち、ユーザーがアクセスできるタスクだけを取得する。
+func (tr *taskRepository) GetSubtasks(tasks *[]model.Task, userId uint, taskId uint) error {
+	if err := tr.db.Preload("Labels").Scopes(taskScope(tr.workspaceId, userId, model.ProjectRoleViewer)).Where("tasks.id IN ("+subtreeIdsQuery+")", taskId).Order(`position COLLATE "C"`).Order("id").Find(tasks).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetSubtaskCountsメソッド
+// 中止(cancelled)したサブタスクは、進み具合の計算から外す。
+func (tr *taskRepository) GetSubtaskCounts(counts *[]model.SubtaskCount, userId uint, parentIds []uint) error {
+	if len(parentIds) == 0 {
+		return nil
+	}
+	err := tr.db.Model(&model.Task{}).
+		Select("parent_id, COUNT(*) AS total, COUNT(*) FILTER (WHERE status = ?) AS done", model.TaskStatusDone).
+		Scopes(taskScope(tr.workspaceId, userId, model.ProjectRoleViewer)).
+		Where("parent_id IN ? AND status <> ?", parentIds, model.TaskStatusCancelled).
+		Group("parent_id").
+		Scan(counts).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// 全文検索のハイライトの開始と終了を表す区切り文字。
+// タイトルなどに含まれるHTMLと区別できるように制御文字を使い、usecase側で<mark>タグに置き換える。
+const (
+	SearchHighlightStart = "\x02"
+	SearchHighlightStop  = "\x03"
+)
+
+// SearchTasksメソッド
+// tasks.search_vectorはマイグレーションで追加しているtsvectorの生成列で、GINインデックスを張っている。
+// websearch_to_tsqueryを使うので、"買い物 -牛乳"のような検索エンジン風の書き方ができる。
+func (tr *taskRepository) SearchTasks(hits *[]model.TaskSearchHit, userId uint, query string, limit int) error {
+	options := fmt.Sprintf("StartSel=%s, StopSel=%s, MaxFragments=2, MinWords=5, MaxWords=20", SearchHighlightStart, SearchHighlightStop)
+	sql := `SELECT tasks.*,
+		ts_rank(tasks.search_vector, query) AS rank,
+		ts_headline('simple', tasks.title || ' ' || tasks.description, query, ?) AS snippet
+		FROM tasks, websearch_to_tsquery('simple', ?) AS query
+		WHERE ` + taskAccessCondition + ` AND tasks.deleted_at IS NULL AND tasks.search_vector @@ query
+		ORDER BY rank DESC, tasks.id DESC
+		LIMIT ?`
+	args := append([]interface{}{options, query}, taskAccessArgs(tr.db, tr.workspaceId, userId, model.ProjectRoleViewer)...)
+	if err := tr.db.Raw(sql, append(args, limit)...).Scan(hits).Error; err != nil {
 		return err
 	}
 	return nil
@@ -53,6 +322,7 @@ func (tr *taskRepository) GetTaskById(task *model.Task, userId uint, taskId uint
 
 // CreateTaskメソッド
 func (tr *taskRepository) CreateTask(task *model.Task) error {
+	task.WorkspaceId = tr.workspaceId
 	if err := tr.db.Create(task).Error; err != nil {
 		return err
 	}
@@ -61,29 +331,592 @@ func (tr *taskRepository) CreateTask(task *model.Task) error {
 
 // UpdateTaskメソッド
 // Clauses(clause.Returning{})をつけると、更新したあとのタスクのオブジェクトをこのタスクのポインタが指し示す先に書き込んでくれる。
-// titleの値を引数で受け取るTaskオブジェクトのTitleの値に更新する。
+// title, description, status, completed_at, start_at, due_at, assignee_id, project_id, parent_id, checklist, recurrenceの値を引数で受け取るTaskオブジェクトの値に更新する。
+// completed_atはnilにすることもあるので、Updatesにはmapを渡す。
 func (tr *taskRepository) UpdateTask(task *model.Task, userId uint, taskId uint) error {
 	// 処理の返り値をresultという変数に代入し、reslt.Errorでエラーを取得する。
-	result := tr.db.Model(task).Clauses(clause.Returning{}).Where("id=? AND user_id=?", taskId, userId).Update("title", task.Title)
+	// task.Versionが指定されている場合は、そのバージョンのときだけ更新する。(楽観的排他制御)
+	query := tr.db.Model(task).Clauses(clause.Returning{}).Where("tasks.id=?", taskId).Scopes(taskScope(tr.workspaceId, userId, model.ProjectRoleEditor))
+	if task.Version > 0 {
+		query = query.Where("version=?", task.Version)
+	}
+	result := query.Updates(map[string]interface{}{
+		"title":        task.Title,
+		"description":  task.Description,
+		"status":       task.Status,
+		"completed_at": task.CompletedAt,
+		"start_at":     task.StartAt,
+		"due_at":       task.DueAt,
+		"assignee_id":  task.AssigneeId,
+		"project_id":   task.ProjectId,
+		"parent_id":    task.ParentId,
+		"checklist":    task.Checklist,
+		"recurrence":   task.Recurrence,
+		"version":      gorm.Expr("version + 1"),
+	})
 	if result.Error != nil {
 		return result.Error
 	}
 	// updateの場合、更新しようとしたオブジェクトが存在しないときはエラーにならない仕様になっている。
-	// RowsAffectedで実際に更新されたレコードの数を取得することができ、その数が1より小さい(=0)の時は、更新が行われなかったことを意味するので、エラーを返す。
+	// RowsAffectedで実際に更新されたレコードの数を取得することができ、その数が1より小さい(=0)の時は、更新が行われなかったことを意味するので、理由を調べてエラーを返す。
 	if result.RowsAffected < 1 {
-		return fmt.Errorf("object does not exist")
+		return tr.notUpdatedError(userId, taskId)
 	}
 	return nil
 }
 
+// 更新した行がなかった理由を調べる。更新できるタスクがない場合はgorm.ErrRecordNotFound、
+// タスクはあるがバージョンが違った場合はmodel.ErrTaskVersionConflictを返す。
+func (tr *taskRepository) notUpdatedError(userId uint, taskId uint) error {
+	var count int64
+	if err := tr.db.Model(&model.Task{}).Where("tasks.id=?", taskId).Scopes(taskScope(tr.workspaceId, userId, model.ProjectRoleEditor)).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return model.ErrTaskVersionConflict
+}
+
 // DeleteTask
-func (tr *taskRepository) DeleteTask(userId uint, taskId uint) error {
-	result := tr.db.Where("id=? AND user_id=?", taskId, userId).Delete(&model.Task{})
+// サブタスクも含めて削除する。タスクとラベルの関連付け(task_labels)も一緒に削除するので、トランザクションの中で実行する。
+// versionが0より大きい場合は、そのバージョンのときだけ削除する。
+func (tr *taskRepository) DeleteTask(userId uint, taskId uint, version uint) error {
+	return tr.db.Transaction(func(tx *gorm.DB) error {
+		task := model.Task{}
+		// バージョンを確認してから削除するまでの間に更新されないように、行をロックしておく。
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("tasks.id=?", taskId).Scopes(taskScope(tr.workspaceId, userId, model.ProjectRoleEditor)).First(&task).Error; err != nil {
+			return err
+		}
+		if version > 0 && task.Version != version {
+			return model.ErrTaskVersionConflict
+		}
+		// 削除するタスクのidの一覧。自分自身とすべての子孫。
+		ids := []uint{task.ID}
+		subtreeIds := []uint{}
+		if err := tx.Raw(subtreeIdsQuery, task.ID).Scan(&subtreeIds).Error; err != nil {
+			return err
+		}
+		ids = append(ids, subtreeIds...)
+		// 論理削除なので、ラベルの関連付けは復元に備えて残しておく。
+		// 1つのUPDATE文で削除するので、子孫のタスクにも同じ削除日時が入る。(復元のときに、一緒に削除されたタスクを見分けるのに使う)
+		if err := tx.Where("id IN ?", ids).Delete(&model.Task{}).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// 位置を計算するときに対象にする、ワークスペースのすべてのタスク。
+// ゴミ箱のタスクも、復元したときに元の位置に戻るように含める。
+func (tr *taskRepository) positionScope() *gorm.DB {
+	return tr.db.Unscoped().Model(&model.Task{}).Where("tasks.workspace_id = ?", tr.workspaceId)
+}
+
+// GetLastPositionメソッド
+func (tr *taskRepository) GetLastPosition(position *string, excludeId uint) error {
+	positions := []string{}
+	if err := tr.positionScope().Where("tasks.id <> ?", excludeId).Order(`position COLLATE "C" DESC`).Limit(1).Pluck("position", &positions).Error; err != nil {
+		return err
+	}
+	*position = ""
+	if len(positions) > 0 {
+		*position = positions[0]
+	}
+	return nil
+}
+
+// GetAdjacentPositionメソッド
+func (tr *taskRepository) GetAdjacentPosition(position *string, from string, after bool, excludeId uint) error {
+	query := tr.positionScope().Where("tasks.id <> ?", excludeId)
+	if after {
+		query = query.Where(`position COLLATE "C" > ?`, from).Order(`position COLLATE "C"`)
+	} else {
+		query = query.Where(`position COLLATE "C" < ?`, from).Order(`position COLLATE "C" DESC`)
+	}
+	positions := []string{}
+	if err := query.Limit(1).Pluck("position", &positions).Error; err != nil {
+		return err
+	}
+	*position = ""
+	if len(positions) > 0 {
+		*position = positions[0]
+	}
+	return nil
+}
+
+// UpdatePositionメソッド
+// 並び替えは他の項目に影響しないように、positionだけを更新する。
+func (tr *taskRepository) UpdatePosition(userId uint, taskId uint, position string) error {
+	result := tr.db.Model(&model.Task{}).Where("tasks.id=?", taskId).Scopes(taskScope(tr.workspaceId, userId, model.ProjectRoleEditor)).Updates(map[string]interface{}{
+		"position": position,
+		"version":  gorm.Expr("version + 1"),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RebalancePositionsメソッド
+// 位置が空のタスク(並び替えの機能を追加する前に作成したタスク)は、作成した順番で先頭に並ぶ。
+// すべてのタスクを更新するので、位置の文字列が長くなりすぎたときや、位置が重なっているときだけ使う。
+// 並び順は変わらないので、ほかのメンバーのタスクや閲覧だけできる共有プロジェクトのタスクも振り直す。
+// ほかのリクエストの並び替えと混ざらないように、ワークスペースのタスクの行をロックしてから振り直す。
+func (tr *taskRepository) RebalancePositions() error {
+	return tr.db.Transaction(func(tx *gorm.DB) error {
+		ids := []uint{}
+		if err := tx.Unscoped().Model(&model.Task{}).Clauses(clause.Locking{Strength: "UPDATE"}).Where("tasks.workspace_id = ?", tr.workspaceId).Order(`position COLLATE "C"`).Order("id").Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		for i, p := range position.Spread(len(ids)) {
+			if err := tx.Unscoped().Model(&model.Task{}).Where("id = ?", ids[i]).UpdateColumn("position", p).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ReplaceTaskLabels
+// 他のユーザーや他のワークスペースのラベルを付けられないように、ワークスペースのuserIdのユーザーのラベルだけを取得してから関連付けを置き換える。
+// ほかのメンバーのラベルは、今付いているものを残す。
+func (tr *taskRepository) ReplaceTaskLabels(task *model.Task, userId uint, labelIds []uint) error {
+	labels := []model.Label{}
+	if len(labelIds) > 0 {
+		if err := tr.db.Scopes(labelScope(tr.workspaceId, userId)).Where("id IN ?", labelIds).Order("name").Find(&labels).Error; err != nil {
+			return err
+		}
+	}
+	// 重複したidが送られてきても問題ないように、一意なidの数と比較する。
+	unique := map[uint]bool{}
+	for _, id := range labelIds {
+		unique[id] = true
+	}
+	if len(labels) != len(unique) {
+		return fmt.Errorf("label does not exist")
+	}
+	others := []model.Label{}
+	err := tr.db.Joins("JOIN task_labels ON task_labels.label_id = labels.id").
+		Where("task_labels.task_id = ? AND labels.user_id <> ?", task.ID, userId).
+		Find(&others).Error
+	if err != nil {
+		return err
+	}
+	labels = append(labels, others...)
+	sort.SliceStable(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	if err := tr.db.Model(task).Association("Labels").Replace(labels); err != nil {
+		return err
+	}
+	task.Labels = labels
+	return nil
+}
+
+// GetDeletedTasksメソッド
+// 削除済みのタスクはgormの通常の検索では取得できないので、Unscopedを使う。
+// 親と同じ日時に削除されたタスクは親と一緒に復元するので、一覧には親だけを出す。
+func (tr *taskRepository) GetDeletedTasks(tasks *[]model.Task, userId uint) error {
+	err := tr.db.Unscoped().Preload("Labels").
+		Scopes(taskScope(tr.workspaceId, userId, model.ProjectRoleViewer)).
+		Where("tasks.deleted_at IS NOT NULL").
+		Where("NOT EXISTS (SELECT 1 FROM tasks AS parent WHERE parent.id = tasks.parent_id AND parent.deleted_at = tasks.deleted_at)").
+		Order("deleted_at DESC").Order("id DESC").
+		Find(tasks).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RestoreTaskメソッド
+// 親タスクがまだ削除されたままの場合は、親から外して復元する。(削除済みの親の下に戻すと見えなくなるため)
+func (tr *taskRepository) RestoreTask(task *model.Task, userId uint, taskId uint) error {
+	return tr.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("tasks.id=? AND tasks.deleted_at IS NOT NULL", taskId).Scopes(taskScope(tr.workspaceId, userId, model.ProjectRoleEditor)).First(task).Error; err != nil {
+			return err
+		}
+		ids := []uint{task.ID}
+		subtreeIds := []uint{}
+		if err := tx.Raw(deletedSubtreeIdsQuery, task.ID, task.DeletedAt, task.DeletedAt).Scan(&subtreeIds).Error; err != nil {
+			return err
+		}
+		ids = append(ids, subtreeIds...)
+		if err := tx.Unscoped().Model(&model.Task{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"deleted_at": nil,
+			"version":    gorm.Expr("version + 1"),
+		}).Error; err != nil {
+			return err
+		}
+		if task.ParentId != nil {
+			var count int64
+			if err := tx.Model(&model.Task{}).Where("id = ?", *task.ParentId).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				if err := tx.Model(&model.Task{}).Where("id = ?", task.ID).Update("parent_id", nil).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Preload("Labels").First(task, task.ID).Error
+	})
+}
+
+// PurgeDeletedTasksメソッド
+// 削除済みの親タスクを完全に削除すると、外部キーのON DELETE CASCADEで子孫も削除されるので、
+// 先に子孫も含めたラベルの関連付けと添付ファイルのメタデータを消しておく。
+// 添付ファイルの中身はDBの外にあるので、保存先のキーをstorageKeysに入れて返し、呼び出し側で削除する。
+func (tr *taskRepository) PurgeDeletedTasks(count *int64, storageKeys *[]string, before time.Time) error {
+	return tr.db.Transaction(func(tx *gorm.DB) error {
+		ids := []uint{}
+		err := tx.Raw(`WITH RECURSIVE subtree AS (
+				SELECT id FROM tasks WHERE deleted_at < ?
+				UNION
+				SELECT tasks.id FROM tasks JOIN subtree ON tasks.parent_id = subtree.id
+			) SELECT id FROM subtree`, before).Scan(&ids).Error
+		if err != nil {
+			return err
+		}
+		*count = 0
+		*storageKeys = []string{}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := tx.Exec("DELETE FROM task_labels WHERE task_id IN ?", ids).Error; err != nil {
+			return err
+		}
+		if err := tx.Raw("DELETE FROM attachments WHERE task_id IN ? RETURNING storage_key", ids).Scan(storageKeys).Error; err != nil {
+			return err
+		}
+		result := tx.Unscoped().Where("id IN ?", ids).Delete(&model.Task{})
+		if result.Error != nil {
+			return result.Error
+		}
+		*count = result.RowsAffected
+		return nil
+	})
+}
+
+// CreateActivityメソッド
+// タスクの変更と同じトランザクションで記録できるように、タスクのリポジトリに置いている。
+func (tr *taskRepository) CreateActivity(activity *model.Activity) error {
+	activity.WorkspaceId = tr.workspaceId
+	if err := tr.db.Create(activity).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// CreateRevisionメソッド
+// アクティビティと同じく、タスクの変更と同じトランザクションで保存する。
+func (tr *taskRepository) CreateRevision(revision *model.TaskRevision) error {
+	revision.WorkspaceId = tr.workspaceId
+	if err := tr.db.Create(revision).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetLastUndoableRevisionメソッド
+// ワークスペースでのユーザーの操作のうち、まだ取り消していない最後のものを取得する。(取り消しの操作自体は除く)
+// 同時に2回取り消されないように、行をロックしておく。
+func (tr *taskRepository) GetLastUndoableRevision(revision *model.TaskRevision, userId uint) error {
+	err := tr.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND workspace_id IN (?) AND undone_at IS NULL AND action <> ?", userId, memberWorkspaceIds(tr.db, tr.workspaceId, userId), model.ActivityActionUndone).
+		Order("id DESC").First(revision).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetPreviousRevisionメソッド
+// beforeIdのスナップショットの1つ前の、同じタスクのスナップショットを取得する。
+func (tr *taskRepository) GetPreviousRevision(revision *model.TaskRevision, taskId uint, beforeId uint) error {
+	if err := tr.db.Where("task_id = ? AND id < ?", taskId, beforeId).Order("id DESC").First(revision).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// CountNewerRevisionsメソッド
+// afterIdより後の同じタスクのスナップショットのうち、取り消されていない操作のものを数える。
+func (tr *taskRepository) CountNewerRevisions(count *int64, taskId uint, afterId uint) error {
+	err := tr.db.Model(&model.TaskRevision{}).
+		Where("task_id = ? AND id > ? AND undone_at IS NULL AND action <> ?", taskId, afterId, model.ActivityActionUndone).
+		Count(count).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetRevisionAtVersionメソッド
+// バージョンがversion以下で一番新しい、削除されていない状態のスナップショットを取得する。
+// 編集できるタスクのスナップショットと、完全に削除されたタスクの場合はユーザー自身がワークスペースで保存したスナップショットを対象にする。
+func (tr *taskRepository) GetRevisionAtVersion(revision *model.TaskRevision, userId uint, taskId uint, version uint) error {
+	err := tr.db.Where("task_id = ? AND version <= ? AND deleted = false", taskId, version).
+		Where("((user_id = ? AND workspace_id IN (?)) OR task_id IN (?))", userId, memberWorkspaceIds(tr.db, tr.workspaceId, userId), accessibleTaskIds(tr.db, tr.workspaceId, userId, model.ProjectRoleEditor)).
+		Order("version DESC, id DESC").First(revision).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (tr *taskRepository) MarkRevisionUndone(revisionId uint) error {
+	if err := tr.db.Model(&model.TaskRevision{}).Where("id = ?", revisionId).Update("undone_at", time.Now()).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetTaskWithDeletedメソッド
+// ゴミ箱のタスクも含めて、編集できるタスクを取得する。
+func (tr *taskRepository) GetTaskWithDeleted(task *model.Task, userId uint, taskId uint) error {
+	if err := tr.db.Unscoped().Preload("Labels").Scopes(taskScope(tr.workspaceId, userId, model.ProjectRoleEditor)).First(task, taskId).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// ReplaceTaskメソッド
+// UpdateTaskの項目に加えて、完了日時と並び順の位置もtaskの値にする。(スナップショットに戻すときに使う)
+func (tr *taskRepository) ReplaceTask(task *model.Task, userId uint, taskId uint) error {
+	query := tr.db.Model(task).Clauses(clause.Returning{}).Where("tasks.id=?", taskId).Scopes(taskScope(tr.workspaceId, userId, model.ProjectRoleEditor))
+	if task.Version > 0 {
+		query = query.Where("version=?", task.Version)
+	}
+	result := query.Updates(map[string]interface{}{
+		"title":        task.Title,
+		"description":  task.Description,
+		"status":       task.Status,
+		"completed_at": task.CompletedAt,
+		"start_at":     task.StartAt,
+		"due_at":       task.DueAt,
+		"assignee_id":  task.AssigneeId,
+		"project_id":   task.ProjectId,
+		"parent_id":    task.ParentId,
+		"checklist":    task.Checklist,
+		"recurrence":   task.Recurrence,
+		"position":     task.Position,
+		"version":      gorm.Expr("version + 1"),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return tr.notUpdatedError(userId, taskId)
+	}
+	return nil
+}
+
+// GetExistingLabelIdsメソッド
+// labelIdsのうち、今もあるユーザーのラベルのidだけを返す。(スナップショットの後で削除されたラベルを除く)
+func (tr *taskRepository) GetExistingLabelIds(ids *[]uint, userId uint, labelIds []uint) error {
+	*ids = []uint{}
+	if len(labelIds) == 0 {
+		return nil
+	}
+	if err := tr.db.Model(&model.Label{}).Scopes(labelScope(tr.workspaceId, userId)).Where("id IN ?", labelIds).Pluck("id", ids).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// Transactionメソッド
+// トランザクションのgorm.DBを持ったtaskRepositoryを作ってfnに渡す。
+func (tr *taskRepository) Transaction(fn func(tr ITaskRepository) error) error {
+	return tr.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&taskRepository{tx, tr.workspaceId})
+	})
+}
+
+// ExportTasksメソッド
+// 親タスクより先に子が出てくると、インポートのときに親子関係を戻せないので、階層の浅い順に並べる。
+// 先に並び順どおりのidだけを取得しておき、タスクの中身はbatchSize件ずつ取得する。
+// 親タスクにアクセスできないタスク(共有プロジェクトの、他人のタスクのサブタスクなど)は、一番上の階層として扱う。
+func (tr *taskRepository) ExportTasks(userId uint, batchSize int, fn func(tasks []model.Task) error) error {
+	ids := []uint{}
+	err := tr.db.Raw(`WITH RECURSIVE accessible AS (
+			SELECT id, parent_id FROM tasks WHERE `+taskAccessCondition+` AND deleted_at IS NULL
+		), tree AS (
+			SELECT id, 0 AS depth FROM accessible WHERE parent_id IS NULL OR parent_id NOT IN (SELECT id FROM accessible)
+			UNION ALL
+			SELECT accessible.id, tree.depth + 1 FROM accessible JOIN tree ON accessible.parent_id = tree.id
+		) SELECT id FROM tree ORDER BY depth, id`, taskAccessArgs(tr.db, tr.workspaceId, userId, model.ProjectRoleViewer)...).Scan(&ids).Error
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(ids); start += batchSize {
+		end := start + batchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		found := []model.Task{}
+		if err := tr.db.Preload("Labels", func(db *gorm.DB) *gorm.DB {
+			return db.Order("labels.name")
+		}).Scopes(taskScope(tr.workspaceId, userId, model.ProjectRoleViewer)).Where("tasks.id IN ?", ids[start:end]).Find(&found).Error; err != nil {
+			return err
+		}
+		// INで取得すると順番が保証されないので、idの並び順に戻す。
+		byId := map[uint]model.Task{}
+		for _, v := range found {
+			byId[v.ID] = v
+		}
+		tasks := make([]model.Task, 0, len(found))
+		for _, id := range ids[start:end] {
+			if v, ok := byId[id]; ok {
+				tasks = append(tasks, v)
+			}
+		}
+		if err := fn(tasks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindOrCreateLabelsメソッド
+// 作成するときの色はwantedの色を使う。色が空の場合は初期値の色になる。
+func (tr *taskRepository) FindOrCreateLabels(labels *[]model.Label, userId uint, wanted []model.Label) error {
+	*labels = []model.Label{}
+	for _, w := range wanted {
+		label := model.Label{}
+		if err := tr.db.Where(model.Label{UserId: userId, WorkspaceId: tr.workspaceId, Name: w.Name}).Attrs(model.Label{Color: w.Color}).FirstOrCreate(&label).Error; err != nil {
+			return err
+		}
+		*labels = append(*labels, label)
+	}
+	return nil
+}
+
+// CountDuplicateTasksメソッド
+func (tr *taskRepository) CountDuplicateTasks(count *int64, userId uint, title string, dueAt *time.Time) error {
+	query := tr.db.Model(&model.Task{}).Scopes(taskScope(tr.workspaceId, userId, model.ProjectRoleViewer)).Where("tasks.title = ?", title)
+	if dueAt != nil {
+		query = query.Where("due_at = ?", *dueAt)
+	} else {
+		query = query.Where("due_at IS NULL")
+	}
+	return query.Count(count).Error
+}
+
+// CountBlockersメソッド
+// タスクを完了にしてよいか確認するために使うので、タスクの変更と同じトランザクションで呼ぶ。
+func (tr *taskRepository) CountBlockers(count *int64, taskId uint) error {
+	err := tr.db.Model(&model.TaskDependency{}).
+		Joins("JOIN tasks AS blockers ON blockers.id = task_dependencies.depends_on_id").
+		Where("task_dependencies.task_id = ? AND blockers.deleted_at IS NULL AND blockers.status NOT IN ?", taskId, taskFinishedStatuses).
+		Count(count).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetBoardColumnメソッド
+// 列のカードの数を数えてから置くまでの間に、ほかのリクエストで同じ列にカードが置かれないように、列の行をロックしておく。
+func (tr *taskRepository) GetBoardColumn(column *model.BoardColumn, boardId uint, columnId uint) error {
+	err := tr.db.Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "board_columns"}}).
+		Joins("JOIN boards ON boards.id = board_columns.board_id AND boards.workspace_id = ?", tr.workspaceId).
+		Where("board_columns.board_id = ? AND board_columns.id = ?", boardId, columnId).
+		First(column).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetStatusColumnメソッド
+// statusに対応する列が複数ある場合は、並び順で先頭の列にする。GetBoardColumnと同じく、ワークスペースのボードの列だけを対象にし、列の行をロックする。
+func (tr *taskRepository) GetStatusColumn(column *model.BoardColumn, boardId uint, status string) error {
+	err := tr.db.Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "board_columns"}}).
+		Joins("JOIN boards ON boards.id = board_columns.board_id AND boards.workspace_id = ?", tr.workspaceId).
+		Where("board_columns.board_id = ? AND board_columns.status = ?", boardId, status).
+		Order("board_columns.position").Order("board_columns.id").
+		First(column).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (tr *taskRepository) GetBoardCard(card *model.BoardCard, boardId uint, taskId uint) error {
+	if err := tr.db.Where("board_id = ? AND task_id = ?", boardId, taskId).First(card).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetTaskBoardCardsメソッド
+// ステータスに合わせて列を移すときに使うので、カードが置かれている列をColumnに読み込む。
+func (tr *taskRepository) GetTaskBoardCards(cards *[]model.BoardCard, taskId uint) error {
+	if err := tr.db.Joins("Column").Where("board_cards.task_id = ?", taskId).Order("board_cards.id").Find(cards).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetColumnCardsメソッド
+// 位置を作るために使うので、ゴミ箱のタスクのカードも含める。
+func (tr *taskRepository) GetColumnCards(cards *[]model.BoardCard, columnId uint) error {
+	if err := tr.db.Where("column_id = ?", columnId).Order(`position COLLATE "C"`).Order("id").Find(cards).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// CountColumnCardsメソッド
+func (tr *taskRepository) CountColumnCards(count *int64, columnId uint, excludeTaskId uint) error {
+	err := tr.db.Model(&model.BoardCard{}).
+		Joins("JOIN tasks ON tasks.id = board_cards.task_id AND tasks.deleted_at IS NULL").
+		Where("board_cards.column_id = ? AND board_cards.task_id <> ?", columnId, excludeTaskId).
+		Count(count).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// SaveBoardCardメソッド
+// ボードにタスクのカードがすでにある場合は、列と位置だけを更新する。
+func (tr *taskRepository) SaveBoardCard(card *model.BoardCard) error {
+	err := tr.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "board_id"}, {Name: "task_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"column_id", "position", "updated_at"}),
+	}).Create(card).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RebalanceColumnCardsメソッド
+// RebalancePositionsと同じく、今の並び順のまま間隔を空けて振り直す。
+func (tr *taskRepository) RebalanceColumnCards(columnId uint) error {
+	ids := []uint{}
+	if err := tr.db.Model(&model.BoardCard{}).Where("column_id = ?", columnId).Order(`position COLLATE "C"`).Order("id").Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for i, p := range position.Spread(len(ids)) {
+		if err := tr.db.Model(&model.BoardCard{}).Where("id = ?", ids[i]).UpdateColumn("position", p).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (tr *taskRepository) DeleteBoardCard(boardId uint, taskId uint) error {
+	result := tr.db.Where("board_id = ? AND task_id = ?", boardId, taskId).Delete(&model.BoardCard{})
 	if result.Error != nil {
 		return result.Error
 	}
 	if result.RowsAffected < 1 {
-		return fmt.Errorf("object does not exist")
+		return gorm.ErrRecordNotFound
 	}
 	return nil
 }
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"fmt"
+	"go_api/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ITaskWatcherRepository interface {
+	// タスクのウォッチャーを、追加した順にユーザーと一緒に取得する。userIdのユーザーが見ることができるタスクだけが対象になる。
+	GetWatchers(watchers *[]model.TaskWatcher, userId uint, taskId uint) error
+	// すでにウォッチャーになっている場合は、何もしない。
+	CreateWatcher(watcher *model.TaskWatcher) error
+	DeleteWatcher(taskId uint, watcherId uint) error
+	// workspaceIdのワークスペースのタスクのウォッチャーだけを扱うリポジトリを返す。
+	InWorkspace(workspaceId uint) ITaskWatcherRepository
+}
+
+type taskWatcherRepository struct {
+	db          *gorm.DB
+	workspaceId uint
+}
+
+func NewTaskWatcherRepository(db *gorm.DB) ITaskWatcherRepository {
+	return &taskWatcherRepository{db: db}
+}
+
+func (wr *taskWatcherRepository) InWorkspace(workspaceId uint) ITaskWatcherRepository {
+	return &taskWatcherRepository{wr.db, workspaceId}
+}
+
+func (wr *taskWatcherRepository) GetWatchers(watchers *[]model.TaskWatcher, userId uint, taskId uint) error {
+	if err := wr.db.Joins("User").Where("task_watchers.task_id = ? AND task_watchers.task_id IN (?)", taskId, accessibleTaskIds(wr.db, wr.workspaceId, userId, model.ProjectRoleViewer)).Order("task_watchers.created_at, task_watchers.id").Find(watchers).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (wr *taskWatcherRepository) CreateWatcher(watcher *model.TaskWatcher) error {
+	if err := wr.db.Clauses(clause.OnConflict{DoNothing: true}).Create(watcher).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (wr *taskWatcherRepository) DeleteWatcher(taskId uint, watcherId uint) error {
+	result := wr.db.Where("task_id = ? AND user_id = ?", taskId, watcherId).Delete(&model.TaskWatcher{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return fmt.Errorf("object does not exist")
+	}
+	return nil
+}
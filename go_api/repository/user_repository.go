@@ -38,11 +38,15 @@ func (ur *userRepository) GetUserByEmail(user *model.User, email string) error {
 	return nil
 }
 
+// ユーザーと一緒に、ユーザーの個人用のワークスペースも作成する。
 func (ur *userRepository) CreateUser(user *model.User) error {
-	// 引数で受け取ったユーザーオブジェクトのポインタを渡す。
-	// めっちゃ重要：ユーザー作成に成功した場合、このポインタが指し示す値が新しく作成されたユーザーの情報に書き変わる。
-	if err := ur.db.Create(user).Error; err != nil {
-		return err
-	}
-	return nil
+	return ur.db.Transaction(func(tx *gorm.DB) error {
+		// 引数で受け取ったユーザーオブジェクトのポインタを渡す。
+		// めっちゃ重要：ユーザー作成に成功した場合、このポインタが指し示す値が新しく作成されたユーザーの情報に書き変わる。
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		workspace := model.Workspace{Name: model.PersonalWorkspaceName, Personal: true, UserId: user.ID}
+		return createWorkspace(tx, &workspace)
+	})
 }
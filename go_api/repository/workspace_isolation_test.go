@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"fmt"
+	"go_api/model"
+	"os"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// TEST_DATABASE_URLのデータベースに接続し、テーブルを作成する。指定がない場合はテストをスキップする。
+// テストで作ったユーザーは、終わったときに削除する。(ワークスペースやタスクなどは外部キーで一緒に削除される)
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := gorm.Open(postgres.Open(url), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.User{}, &model.Project{}, &model.Task{}, &model.Label{}, &model.ProjectMember{}, &model.Workspace{}, &model.WorkspaceMember{}, &model.SavedView{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func createTestUser(t *testing.T, db *gorm.DB) model.User {
+	t.Helper()
+	user := model.User{Email: fmt.Sprintf("isolation-%d@example.com", time.Now().UnixNano()), Password: "password"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Delete(&user)
+	})
+	return user
+}
+
+func createTestWorkspace(t *testing.T, db *gorm.DB, userId uint, name string) model.Workspace {
+	t.Helper()
+	workspace := model.Workspace{Name: name, UserId: userId}
+	if err := NewWorkspaceRepository(db).CreateWorkspace(&workspace); err != nil {
+		t.Fatal(err)
+	}
+	return workspace
+}
+
+// 同じユーザーがメンバーになっている2つのワークスペースa, bを作り、bにデータを作る。
+// InWorkspace(a)のリポジトリからは、bのデータを取得・更新・削除できないことを確認する。
+func TestWorkspaceIsolation(t *testing.T) {
+	db := openTestDB(t)
+	user := createTestUser(t, db)
+	a := createTestWorkspace(t, db, user.ID, "a")
+	b := createTestWorkspace(t, db, user.ID, "b")
+
+	t.Run("tasks", func(t *testing.T) {
+		inB := NewTaskRepository(db).InWorkspace(b.ID)
+		task := model.Task{Title: "task in b", UserId: user.ID, Checklist: model.Checklist{}}
+		if err := inB.CreateTask(&task); err != nil {
+			t.Fatal(err)
+		}
+		inA := NewTaskRepository(db).InWorkspace(a.ID)
+
+		tasks := []model.Task{}
+		page := model.TaskPage{Sort: model.TaskSortPosition, Order: "asc", Limit: model.MaxTaskPageLimit}
+		if err := inA.GetAllTasks(&tasks, user.ID, model.TaskFilter{}, page, nil); err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range tasks {
+			if v.ID == task.ID {
+				t.Errorf("GetAllTasks in a returned task %d of b", task.ID)
+			}
+		}
+		if err := inA.GetTaskById(&model.Task{}, user.ID, task.ID); err == nil {
+			t.Errorf("GetTaskById in a found task %d of b", task.ID)
+		}
+		if err := inA.UpdateTask(&model.Task{Title: "changed", Checklist: model.Checklist{}}, user.ID, task.ID); err == nil {
+			t.Errorf("UpdateTask in a updated task %d of b", task.ID)
+		}
+		if err := inA.DeleteTask(user.ID, task.ID, 0); err == nil {
+			t.Errorf("DeleteTask in a deleted task %d of b", task.ID)
+		}
+
+		got := model.Task{}
+		if err := inB.GetTaskById(&got, user.ID, task.ID); err != nil {
+			t.Fatalf("task %d of b is gone: %v", task.ID, err)
+		}
+		if got.Title != task.Title {
+			t.Errorf("title = %q, want %q", got.Title, task.Title)
+		}
+	})
+
+	t.Run("projects", func(t *testing.T) {
+		inB := NewProjectRepository(db).InWorkspace(b.ID)
+		project := model.Project{Name: "project in b", Color: "#3b82f6", UserId: user.ID}
+		if err := inB.CreateProject(&project); err != nil {
+			t.Fatal(err)
+		}
+		inA := NewProjectRepository(db).InWorkspace(a.ID)
+
+		projects := []model.Project{}
+		if err := inA.GetAllProjects(&projects, user.ID, true); err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range projects {
+			if v.ID == project.ID {
+				t.Errorf("GetAllProjects in a returned project %d of b", project.ID)
+			}
+		}
+		if err := inA.GetProjectById(&model.Project{}, user.ID, project.ID); err == nil {
+			t.Errorf("GetProjectById in a found project %d of b", project.ID)
+		}
+		if err := inA.UpdateProject(&model.Project{Name: "changed", Color: "#000000"}, user.ID, project.ID); err == nil {
+			t.Errorf("UpdateProject in a updated project %d of b", project.ID)
+		}
+		if err := inA.DeleteProject(user.ID, project.ID, model.ProjectDeleteMoveToInbox); err == nil {
+			t.Errorf("DeleteProject in a deleted project %d of b", project.ID)
+		}
+
+		got := model.Project{}
+		if err := inB.GetProjectById(&got, user.ID, project.ID); err != nil {
+			t.Fatalf("project %d of b is gone: %v", project.ID, err)
+		}
+		if got.Name != project.Name {
+			t.Errorf("name = %q, want %q", got.Name, project.Name)
+		}
+	})
+
+	t.Run("labels", func(t *testing.T) {
+		inB := NewLabelRepository(db).InWorkspace(b.ID)
+		label := model.Label{Name: "label in b", Color: model.DefaultLabelColor, UserId: user.ID}
+		if err := inB.CreateLabel(&label); err != nil {
+			t.Fatal(err)
+		}
+		inA := NewLabelRepository(db).InWorkspace(a.ID)
+
+		labels := []model.Label{}
+		if err := inA.GetAllLabels(&labels, user.ID); err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range labels {
+			if v.ID == label.ID {
+				t.Errorf("GetAllLabels in a returned label %d of b", label.ID)
+			}
+		}
+		if err := inA.GetLabelById(&model.Label{}, user.ID, label.ID); err == nil {
+			t.Errorf("GetLabelById in a found label %d of b", label.ID)
+		}
+		if err := inA.UpdateLabel(&model.Label{Name: "changed", Color: "#000000"}, user.ID, label.ID); err == nil {
+			t.Errorf("UpdateLabel in a updated label %d of b", label.ID)
+		}
+		if err := inA.DeleteLabel(user.ID, label.ID); err == nil {
+			t.Errorf("DeleteLabel in a deleted label %d of b", label.ID)
+		}
+
+		got := model.Label{}
+		if err := inB.GetLabelById(&got, user.ID, label.ID); err != nil {
+			t.Fatalf("label %d of b is gone: %v", label.ID, err)
+		}
+		if got.Name != label.Name {
+			t.Errorf("name = %q, want %q", got.Name, label.Name)
+		}
+	})
+
+	t.Run("saved views", func(t *testing.T) {
+		inB := NewSavedViewRepository(db).InWorkspace(b.ID)
+		view := model.SavedView{Name: "view in b", Query: "status:todo", UserId: user.ID}
+		if err := inB.CreateSavedView(&view); err != nil {
+			t.Fatal(err)
+		}
+		inA := NewSavedViewRepository(db).InWorkspace(a.ID)
+
+		views := []model.SavedView{}
+		if err := inA.GetSavedViews(&views, user.ID); err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range views {
+			if v.ID == view.ID {
+				t.Errorf("GetSavedViews in a returned view %d of b", view.ID)
+			}
+		}
+		if err := inA.GetSavedViewById(&model.SavedView{}, user.ID, view.ID); err == nil {
+			t.Errorf("GetSavedViewById in a found view %d of b", view.ID)
+		}
+		if err := inA.UpdateSavedView(&model.SavedView{Name: "changed"}, user.ID, view.ID); err == nil {
+			t.Errorf("UpdateSavedView in a updated view %d of b", view.ID)
+		}
+		if err := inA.DeleteSavedView(user.ID, view.ID); err == nil {
+			t.Errorf("DeleteSavedView in a deleted view %d of b", view.ID)
+		}
+
+		got := model.SavedView{}
+		if err := inB.GetSavedViewById(&got, user.ID, view.ID); err != nil {
+			t.Fatalf("view %d of b is gone: %v", view.ID, err)
+		}
+		if got.Name != view.Name {
+			t.Errorf("name = %q, want %q", got.Name, view.Name)
+		}
+	})
+}
+
+// メンバーでないワークスペースのデータは、ワークスペースを指定しても見ることができない。
+func TestWorkspaceIsolationNonMember(t *testing.T) {
+	db := openTestDB(t)
+	owner := createTestUser(t, db)
+	other := createTestUser(t, db)
+	b := createTestWorkspace(t, db, owner.ID, "b")
+
+	task := model.Task{Title: "task in b", UserId: owner.ID, Checklist: model.Checklist{}}
+	if err := NewTaskRepository(db).InWorkspace(b.ID).CreateTask(&task); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewTaskRepository(db).InWorkspace(b.ID).GetTaskById(&model.Task{}, other.ID, task.ID); err == nil {
+		t.Errorf("non-member found task %d of b", task.ID)
+	}
+	if err := NewWorkspaceRepository(db).GetWorkspaceById(&model.Workspace{}, other.ID, b.ID); err == nil {
+		t.Errorf("non-member found workspace %d", b.ID)
+	}
+}
@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"fmt"
+	"go_api/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IWorkspaceRepository interface {
+	// ユーザーがメンバーになっているワークスペースを、個人用・作成した順に取得する。Workspace.Roleにユーザーの権限が入る。
+	GetWorkspaces(workspaces *[]model.Workspace, userId uint) error
+	// メンバーになっていないワークスペースは見つからない。
+	GetWorkspaceById(workspace *model.Workspace, userId uint, workspaceId uint) error
+	// ユーザーの個人用のワークスペースを取得する。
+	GetPersonalWorkspace(workspace *model.Workspace, userId uint) error
+	// ワークスペースを作成し、作成したユーザーをオーナーとしてメンバーに追加する。
+	CreateWorkspace(workspace *model.Workspace) error
+	UpdateWorkspace(workspace *model.Workspace, workspaceId uint) error
+	// ワークスペースを削除する。タスク・プロジェクト・ラベルは外部キーで一緒に削除される。
+	// 添付ファイルの中身はDBの外にあるので、保存先のキーをstorageKeysに入れて返し、呼び出し側で削除する。
+	DeleteWorkspace(storageKeys *[]string, workspaceId uint) error
+	GetMembers(members *[]model.WorkspaceMember, workspaceId uint) error
+	GetMember(member *model.WorkspaceMember, workspaceId uint, userId uint) error
+	CreateMember(member *model.WorkspaceMember) error
+	UpdateMemberRole(workspaceId uint, userId uint, role string) error
+	// メンバーを削除する。ワークスペースのプロジェクトのメンバーからも外す。
+	DeleteMember(workspaceId uint, userId uint) error
+	// オーナーの数を数える。最後のオーナーを外さないように、変更が終わるまでオーナーの行をロックしておく。
+	CountOwners(count *int64, workspaceId uint) error
+	// fnを1つのトランザクションの中で実行する。
+	Transaction(fn func(wr IWorkspaceRepository) error) error
+}
+
+type workspaceRepository struct {
+	db *gorm.DB
+}
+
+func NewWorkspaceRepository(db *gorm.DB) IWorkspaceRepository {
+	return &workspaceRepository{db}
+}
+
+// userIdのユーザーがメンバーになっているワークスペースに絞り込み、ユーザーの権限をWorkspace.Roleに読み込む。
+func withWorkspaceRole(userId uint) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Select("workspaces.*, workspace_members.role AS role").
+			Joins("JOIN workspace_members ON workspace_members.workspace_id = workspaces.id AND workspace_members.user_id = ?", userId)
+	}
+}
+
+func (wr *workspaceRepository) GetWorkspaces(workspaces *[]model.Workspace, userId uint) error {
+	if err := wr.db.Scopes(withWorkspaceRole(userId)).Order("workspaces.personal DESC").Order("workspaces.created_at").Find(workspaces).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (wr *workspaceRepository) GetWorkspaceById(workspace *model.Workspace, userId uint, workspaceId uint) error {
+	if err := wr.db.Scopes(withWorkspaceRole(userId)).First(workspace, workspaceId).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (wr *workspaceRepository) GetPersonalWorkspace(workspace *model.Workspace, userId uint) error {
+	if err := wr.db.Scopes(withWorkspaceRole(userId)).Where("workspaces.personal = ? AND workspaces.user_id = ?", true, userId).First(workspace).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (wr *workspaceRepository) CreateWorkspace(workspace *model.Workspace) error {
+	return wr.db.Transaction(func(tx *gorm.DB) error {
+		return createWorkspace(tx, workspace)
+	})
+}
+
+// ワークスペースと、作成したユーザーをオーナーにしたメンバーを作成する。(サインアップのときにも使う)
+func createWorkspace(tx *gorm.DB, workspace *model.Workspace) error {
+	if err := tx.Create(workspace).Error; err != nil {
+		return err
+	}
+	member := model.WorkspaceMember{WorkspaceId: workspace.ID, UserId: workspace.UserId, Role: model.WorkspaceRoleOwner}
+	if err := tx.Create(&member).Error; err != nil {
+		return err
+	}
+	workspace.Role = member.Role
+	return nil
+}
+
+// nameを更新する。
+func (wr *workspaceRepository) UpdateWorkspace(workspace *model.Workspace, workspaceId uint) error {
+	result := wr.db.Model(workspace).Clauses(clause.Returning{}).Where("id=?", workspaceId).Update("name", workspace.Name)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return fmt.Errorf("object does not exist")
+	}
+	return nil
+}
+
+func (wr *workspaceRepository) DeleteWorkspace(storageKeys *[]string, workspaceId uint) error {
+	return wr.db.Transaction(func(tx *gorm.DB) error {
+		*storageKeys = []string{}
+		if err := tx.Raw("DELETE FROM attachments WHERE task_id IN (SELECT id FROM tasks WHERE workspace_id = ?) RETURNING storage_key", workspaceId).Scan(storageKeys).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&model.Workspace{}, workspaceId)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected < 1 {
+			return fmt.Errorf("object does not exist")
+		}
+		return nil
+	})
+}
+
+// オーナー、メンバーの順に、追加した順で取得する。
+func (wr *workspaceRepository) GetMembers(members *[]model.WorkspaceMember, workspaceId uint) error {
+	err := wr.db.Joins("User").Where("workspace_members.workspace_id = ?", workspaceId).
+		Order("CASE workspace_members.role WHEN 'owner' THEN 0 ELSE 1 END").
+		Order("workspace_members.created_at").
+		Find(members).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (wr *workspaceRepository) GetMember(member *model.WorkspaceMember, workspaceId uint, userId uint) error {
+	if err := wr.db.Joins("User").Where("workspace_members.workspace_id = ? AND workspace_members.user_id = ?", workspaceId, userId).First(member).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (wr *workspaceRepository) CreateMember(member *model.WorkspaceMember) error {
+	if err := wr.db.Create(member).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (wr *workspaceRepository) UpdateMemberRole(workspaceId uint, userId uint, role string) error {
+	result := wr.db.Model(&model.WorkspaceMember{}).Where("workspace_id = ? AND user_id = ?", workspaceId, userId).Update("role", role)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return fmt.Errorf("object does not exist")
+	}
+	return nil
+}
+
+func (wr *workspaceRepository) DeleteMember(workspaceId uint, userId uint) error {
+	return wr.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("user_id = ? AND project_id IN (?)", userId, tx.Session(&gorm.Session{NewDB: true}).Model(&model.Project{}).Select("id").Where("workspace_id = ?", workspaceId)).
+			Delete(&model.ProjectMember{}).Error
+		if err != nil {
+			return err
+		}
+		result := tx.Where("workspace_id = ? AND user_id = ?", workspaceId, userId).Delete(&model.WorkspaceMember{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected < 1 {
+			return fmt.Errorf("object does not exist")
+		}
+		return nil
+	})
+}
+
+func (wr *workspaceRepository) CountOwners(count *int64, workspaceId uint) error {
+	ids := []uint{}
+	err := wr.db.Model(&model.WorkspaceMember{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("workspace_id = ? AND role = ?", workspaceId, model.WorkspaceRoleOwner).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return err
+	}
+	*count = int64(len(ids))
+	return nil
+}
+
+func (wr *workspaceRepository) Transaction(fn func(wr IWorkspaceRepository) error) error {
+	return wr.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&workspaceRepository{tx})
+	})
+}
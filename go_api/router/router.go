@@ -2,6 +2,7 @@ package router
 
 import (
 	"go_api/controller"
+	"go_api/model"
 	"net/http"
 	"os"
 
@@ -12,7 +13,7 @@ import (
 )
 
 // ルーターの中でタスクコントローラーを使用できるようにするために、引数にタスクコントローラーも追加。
-func NewRouter(uc controller.IUserController, tc controller.ITaskController, mc controller.IMypageController) *echo.Echo {
+func NewRouter(uc controller.IUserController, tc controller.ITaskController, mc controller.IMypageController, lc controller.ILabelController, pc controller.IProjectController, cc controller.ICalendarController, ac controller.IAttachmentController, cmc controller.ICommentController, avc controller.IActivityController, pmc controller.IProjectMemberController, wc controller.IWorkspaceController, twc controller.ITaskWatcherController, tdc controller.ITaskDependencyController, bc controller.IBoardController, svc controller.ISavedViewController) *echo.Echo {
 	// echoのインスタンスに対し、エンドポイントを作成。
 	e := echo.New()
 
@@ -23,9 +24,11 @@ func NewRouter(uc controller.IUserController, tc controller.ITaskController, mc
 		AllowOrigins: []string{"http://localhost:3000", os.Getenv("FE_URL")},
 		// 許可するヘッダーを書いていく。echoのHeaderXCSRFTOKENを含めることによって、ヘッダー経由でCSRFトークンを受け取れるようにしている。
 		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept,
-			echo.HeaderAccessControlAllowHeaders, echo.HeaderXCSRFToken},
+			echo.HeaderAccessControlAllowHeaders, echo.HeaderXCSRFToken, "If-Match", "If-None-Match", model.WorkspaceHeader},
 		// 許可したいメソッドを追加。
-		AllowMethods: []string{"GET", "PUT", "POST", "DELETE"},
+		AllowMethods: []string{"GET", "PUT", "PATCH", "POST", "DELETE"},
+		// タスクのバージョンをフロントエンドから読めるように、ETagヘッダーを公開する。
+		ExposeHeaders: []string{"ETag"},
 		// cookieの送受信を可能にするため、trueにする。
 		AllowCredentials: true,
 	}))
@@ -60,13 +63,49 @@ func NewRouter(uc controller.IUserController, tc controller.ITaskController, mc
 		// 今回はcookieの中にtokenという名前でjwtトークンを格納するように実装しているのでこの書き方。
 		TokenLookup: "cookie:token",
 	}))
+	// JWTのミドルウェアの後に、X-Workspace-IDヘッダーからリクエストのワークスペースを決めるミドルウェアを適用する。
+	// タスク・プロジェクト・ラベルなどは、このワークスペースのものだけを扱う。
+	t.Use(wc.RequireWorkspace)
 	// タスク関連のエンドポイントを追加しておく。
 	// グループ化されているので、xxx.com/tasks/以降のurlになる。
 	t.GET("", tc.GetAllTasks)
+	// /tasks/searchは/:taskIdより優先してマッチする。
+	t.GET("/search", tc.SearchTasks)
+	t.GET("/trash", tc.GetDeletedTasks)
+	t.GET("/export", tc.ExportTasks)
 	t.GET("/:taskId", tc.GetTaskById)
+	t.GET("/:taskId/occurrences", tc.GetOccurrences)
 	t.POST("", tc.CreateTask)
+	t.POST("/bulk", tc.BulkTasks)
+	t.POST("/import", tc.ImportTasks)
+	t.POST("/undo", tc.UndoTask)
 	t.PUT("/:taskId", tc.UpdateTask)
+	t.PATCH("/:taskId", tc.PatchTask)
+	t.PUT("/:taskId/move", tc.MoveTask)
+	t.PUT("/:taskId/assignee", tc.AssignTask)
+	t.POST("/:taskId/restore", tc.RestoreTask)
+	t.POST("/:taskId/revert", tc.RevertTask)
 	t.DELETE("/:taskId", tc.DeleteTask)
+	// タスクの添付ファイル。
+	t.GET("/:taskId/attachments", ac.GetAttachments)
+	t.POST("/:taskId/attachments", ac.CreateAttachment)
+	t.GET("/:taskId/attachments/:attachmentId", ac.DownloadAttachment)
+	t.DELETE("/:taskId/attachments/:attachmentId", ac.DeleteAttachment)
+	// タスクのコメント。
+	t.GET("/:taskId/comments", cmc.GetComments)
+	t.POST("/:taskId/comments", cmc.CreateComment)
+	t.PUT("/:taskId/comments/:commentId", cmc.UpdateComment)
+	t.DELETE("/:taskId/comments/:commentId", cmc.DeleteComment)
+	// タスクの変更履歴。
+	t.GET("/:taskId/history", avc.GetTaskHistory)
+	// タスクのウォッチャー。
+	t.GET("/:taskId/watchers", twc.GetWatchers)
+	t.POST("/:taskId/watchers", twc.AddWatcher)
+	t.DELETE("/:taskId/watchers/:userId", twc.RemoveWatcher)
+	// タスクの依存関係。
+	t.GET("/:taskId/dependencies", tdc.GetDependencies)
+	t.POST("/:taskId/dependencies", tdc.AddDependency)
+	t.DELETE("/:taskId/dependencies/:dependsOnId", tdc.RemoveDependency)
 
 	m := e.Group("/mypage")
 	m.Use(echojwt.WithConfig(echojwt.Config{
@@ -76,5 +115,115 @@ func NewRouter(uc controller.IUserController, tc controller.ITaskController, mc
 
 	m.GET("", mc.GetUser)
 
+	// ラベル関連のエンドポイント。タスクと同じようにJWTのミドルウェアを適用する。
+	l := e.Group("/labels")
+	l.Use(echojwt.WithConfig(echojwt.Config{
+		SigningKey:  []byte(os.Getenv("SECRET")),
+		TokenLookup: "cookie:token",
+	}))
+	l.Use(wc.RequireWorkspace)
+	l.GET("", lc.GetAllLabels)
+	l.POST("", lc.CreateLabel)
+	l.PUT("/:labelId", lc.UpdateLabel)
+	l.DELETE("/:labelId", lc.DeleteLabel)
+
+	p := e.Group("/projects")
+	p.Use(echojwt.WithConfig(echojwt.Config{
+		SigningKey:  []byte(os.Getenv("SECRET")),
+		TokenLookup: "cookie:token",
+	}))
+	p.Use(wc.RequireWorkspace)
+	p.GET("", pc.GetAllProjects)
+	p.GET("/:projectId", pc.GetProjectById)
+	p.POST("", pc.CreateProject)
+	p.PUT("/:projectId", pc.UpdateProject)
+	p.DELETE("/:projectId", pc.DeleteProject)
+	// プロジェクトのメンバーと招待。
+	p.GET("/:projectId/members", pmc.GetMembers)
+	p.PUT("/:projectId/members/:userId", pmc.UpdateMember)
+	p.DELETE("/:projectId/members/:userId", pmc.DeleteMember)
+	p.GET("/:projectId/invitations", pmc.GetInvitations)
+	p.POST("/:projectId/invitations", pmc.CreateInvitation)
+	p.DELETE("/:projectId/invitations/:invitationId", pmc.DeleteInvitation)
+
+	// 自分宛ての招待の一覧・承認・辞退。
+	inv := e.Group("/invitations")
+	inv.Use(echojwt.WithConfig(echojwt.Config{
+		SigningKey:  []byte(os.Getenv("SECRET")),
+		TokenLookup: "cookie:token",
+	}))
+	inv.GET("", pmc.GetMyInvitations)
+	inv.POST("/:token/accept", pmc.AcceptInvitation)
+	inv.DELETE("/:token", pmc.DeclineInvitation)
+
+	// ワークスペースとそのメンバー。ワークスペースはURLで指定するので、RequireWorkspaceは適用しない。
+	w := e.Group("/workspaces")
+	w.Use(echojwt.WithConfig(echojwt.Config{
+		SigningKey:  []byte(os.Getenv("SECRET")),
+		TokenLookup: "cookie:token",
+	}))
+	w.GET("", wc.GetWorkspaces)
+	w.POST("", wc.CreateWorkspace)
+	w.PUT("/:workspaceId", wc.UpdateWorkspace)
+	w.DELETE("/:workspaceId", wc.DeleteWorkspace)
+	w.GET("/:workspaceId/members", wc.GetMembers)
+	w.POST("/:workspaceId/members", wc.CreateMember)
+	w.PUT("/:workspaceId/members/:userId", wc.UpdateMember)
+	w.DELETE("/:workspaceId/members/:userId", wc.DeleteMember)
+
+	// カンバンボードと、その列・カード。
+	b := e.Group("/boards")
+	b.Use(echojwt.WithConfig(echojwt.Config{
+		SigningKey:  []byte(os.Getenv("SECRET")),
+		TokenLookup: "cookie:token",
+	}))
+	b.Use(wc.RequireWorkspace)
+	b.GET("", bc.GetBoards)
+	b.GET("/:boardId", bc.GetBoardById)
+	b.POST("", bc.CreateBoard)
+	b.PUT("/:boardId", bc.UpdateBoard)
+	b.DELETE("/:boardId", bc.DeleteBoard)
+	b.POST("/:boardId/columns", bc.CreateColumn)
+	b.PUT("/:boardId/columns/:columnId", bc.UpdateColumn)
+	b.DELETE("/:boardId/columns/:columnId", bc.DeleteColumn)
+	b.PUT("/:boardId/cards/:taskId", bc.PlaceCard)
+	b.DELETE("/:boardId/cards/:taskId", bc.RemoveCard)
+
+	// 保存したビュー(名前を付けた絞り込みの条件と並び順)。
+	v := e.Group("/views")
+	v.Use(echojwt.WithConfig(echojwt.Config{
+		SigningKey:  []byte(os.Getenv("SECRET")),
+		TokenLookup: "cookie:token",
+	}))
+	v.Use(wc.RequireWorkspace)
+	v.GET("", svc.GetSavedViews)
+	v.GET("/:viewId", svc.GetSavedViewById)
+	v.GET("/:viewId/tasks", svc.GetViewTasks)
+	v.POST("", svc.CreateSavedView)
+	v.PUT("/:viewId", svc.UpdateSavedView)
+	v.DELETE("/:viewId", svc.DeleteSavedView)
+
+	// ユーザー全体のアクティビティ。
+	a := e.Group("/activity")
+	a.Use(echojwt.WithConfig(echojwt.Config{
+		SigningKey:  []byte(os.Getenv("SECRET")),
+		TokenLookup: "cookie:token",
+	}))
+	a.Use(wc.RequireWorkspace)
+	a.GET("", avc.GetActivities)
+
+	// カレンダーのフィードのURLの取得・作り直し・削除。
+	cal := e.Group("/calendar")
+	cal.Use(echojwt.WithConfig(echojwt.Config{
+		SigningKey:  []byte(os.Getenv("SECRET")),
+		TokenLookup: "cookie:token",
+	}))
+	cal.Use(wc.RequireWorkspace)
+	cal.GET("/feed", cc.GetFeed)
+	cal.POST("/feed/rotate", cc.RotateFeed)
+	cal.DELETE("/feed", cc.DeleteFeed)
+	// カレンダーアプリが購読するフィード。URLのトークンで認証するので、JWTのミドルウェアは適用しない。
+	e.GET("/feeds/:file", cc.ServeFeed)
+
 	return e
 }
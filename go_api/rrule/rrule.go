@@ -0,0 +1,419 @@
+// iCalendar(RFC 5545)のRRULE(繰り返しルール)を扱うパッケージ
+// 例: "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR" (平日毎日)、"FREQ=MONTHLY;BYDAY=-1FR" (毎月最終金曜日)
+// BYSETPOSやBYHOURなど、タスクの繰り返しに必要のないルールには対応していない。
+package rrule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// BYDAYの1つ分。Nは何番目の曜日か(1は第1、-1は最終)を表し、0の場合はすべての週が対象になる。
+type WeekdayNum struct {
+	N   int
+	Day time.Weekday
+}
+
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	// 0の場合は回数の制限なし
+	Count int
+	// nilの場合は終了日時なし
+	Until      *time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+	WeekStart  time.Weekday
+	// UNTILが日付だけ(YYYYMMDD)で指定されたかどうか。文字列に戻すときに使う。
+	untilIsDate bool
+}
+
+// 展開するときに、1日ずつ進める日数の上限。(約100年分)
+const maxIterationDays = 366 * 100
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+var weekdayNames = map[time.Weekday]string{
+	time.Sunday: "SU", time.Monday: "MO", time.Tuesday: "TU", time.Wednesday: "WE",
+	time.Thursday: "TH", time.Friday: "FR", time.Saturday: "SA",
+}
+
+// RRULEの文字列を解析する。先頭の"RRULE:"はあってもなくてもよい。
+// UNTILにタイムゾーンの指定(末尾のZ)がない場合は、locのタイムゾーンとして扱う。
+func Parse(s string, loc *time.Location) (*Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return nil, fmt.Errorf("rrule is empty")
+	}
+	r := &Rule{Interval: 1, WeekStart: time.Monday}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return nil, fmt.Errorf("invalid rrule part: %q", part)
+		}
+		key, value := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate rrule part: %s", key)
+		}
+		seen[key] = true
+		switch key {
+		case "FREQ":
+			switch Frequency(value) {
+			case Daily, Weekly, Monthly, Yearly:
+				r.Freq = Frequency(value)
+			default:
+				return nil, fmt.Errorf("unsupported FREQ: %s", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid INTERVAL: %s", value)
+			}
+			r.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid COUNT: %s", value)
+			}
+			r.Count = n
+		case "UNTIL":
+			t, isDate, err := parseUntil(value, loc)
+			if err != nil {
+				return nil, err
+			}
+			r.Until = &t
+			r.untilIsDate = isDate
+		case "BYDAY":
+			for _, v := range strings.Split(value, ",") {
+				wd, err := parseWeekdayNum(v)
+				if err != nil {
+					return nil, err
+				}
+				r.ByDay = append(r.ByDay, wd)
+			}
+		case "BYMONTHDAY":
+			for _, v := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(v)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, fmt.Errorf("invalid BYMONTHDAY: %s", v)
+				}
+				r.ByMonthDay = append(r.ByMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, v := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 1 || n > 12 {
+					return nil, fmt.Errorf("invalid BYMONTH: %s", v)
+				}
+				r.ByMonth = append(r.ByMonth, time.Month(n))
+			}
+		case "WKST":
+			wd, ok := weekdays[value]
+			if !ok {
+				return nil, fmt.Errorf("invalid WKST: %s", value)
+			}
+			r.WeekStart = wd
+		default:
+			return nil, fmt.Errorf("unsupported rrule part: %s", key)
+		}
+	}
+	if r.Freq == "" {
+		return nil, fmt.Errorf("FREQ is required")
+	}
+	if r.Count > 0 && r.Until != nil {
+		return nil, fmt.Errorf("COUNT and UNTIL cannot be used together")
+	}
+	// 第n週の指定は、月ごと・年ごとの繰り返しでしか意味を持たない。
+	for _, v := range r.ByDay {
+		if v.N != 0 && r.Freq != Monthly && r.Freq != Yearly {
+			return nil, fmt.Errorf("BYDAY with a position is only allowed with FREQ=MONTHLY or FREQ=YEARLY")
+		}
+	}
+	if len(r.ByMonthDay) > 0 && r.Freq == Weekly {
+		return nil, fmt.Errorf("BYMONTHDAY is not allowed with FREQ=WEEKLY")
+	}
+	return r, nil
+}
+
+func parseUntil(value string, loc *time.Location) (time.Time, bool, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.ParseInLocation("20060102T150405", value, loc); err == nil {
+		return t, false, nil
+	}
+	// 日付だけの場合は、その日の終わりまでを含める。
+	if t, err := time.ParseInLocation("20060102", value, loc); err == nil {
+		return t.Add(24*time.Hour - time.Second), true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("invalid UNTIL: %s", value)
+}
+
+// "MO"、"2TU"、"-1FR"のようなBYDAYの値を解析する。
+func parseWeekdayNum(v string) (WeekdayNum, error) {
+	if len(v) < 2 {
+		return WeekdayNum{}, fmt.Errorf("invalid BYDAY: %s", v)
+	}
+	day, ok := weekdays[v[len(v)-2:]]
+	if !ok {
+		return WeekdayNum{}, fmt.Errorf("invalid BYDAY: %s", v)
+	}
+	n := 0
+	if prefix := v[:len(v)-2]; prefix != "" {
+		var err error
+		n, err = strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -53 || n > 53 {
+			return WeekdayNum{}, fmt.Errorf("invalid BYDAY: %s", v)
+		}
+	}
+	return WeekdayNum{N: n, Day: day}, nil
+}
+
+// RRULEの文字列に戻す。("RRULE:"は付けない)
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		if r.untilIsDate {
+			parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+		} else {
+			parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+		}
+	}
+	if len(r.ByDay) > 0 {
+		days := []string{}
+		for _, v := range r.ByDay {
+			s := weekdayNames[v.Day]
+			if v.N != 0 {
+				s = strconv.Itoa(v.N) + s
+			}
+			days = append(days, s)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := []string{}
+		for _, v := range r.ByMonthDay {
+			days = append(days, strconv.Itoa(v))
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonth) > 0 {
+		months := []string{}
+		for _, v := range r.ByMonth {
+			months = append(months, strconv.Itoa(int(v)))
+		}
+		parts = append(parts, "BYMONTH="+strings.Join(months, ","))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayNames[r.WeekStart])
+	}
+	return strings.Join(parts, ";")
+}
+
+// dtstartを最初の発生日時として、fromからtoまで(両端を含む)の発生日時を最大limit件返す。
+// 発生日時の時刻はdtstartの時刻(壁時計の時刻)のままになる。
+func (r *Rule) Between(dtstart time.Time, from time.Time, to time.Time, limit int) []time.Time {
+	result := []time.Time{}
+	r.iterate(dtstart, func(t time.Time) bool {
+		if t.After(to) || len(result) >= limit {
+			return false
+		}
+		if !t.Before(from) {
+			result = append(result, t)
+		}
+		return true
+	})
+	return result
+}
+
+// dtstartを最初の発生日時として、afterより後の最初の発生日時を返す。ない場合はfalseを返す。
+func (r *Rule) Next(dtstart time.Time, after time.Time) (time.Time, bool) {
+	var next time.Time
+	found := false
+	r.iterate(dtstart, func(t time.Time) bool {
+		if t.After(after) {
+			next = t
+			found = true
+			return false
+		}
+		return true
+	})
+	return next, found
+}
+
+// dtstartの日から1日ずつ進めて、ルールに一致する日時をfnに渡す。
+// fnがfalseを返すか、COUNTやUNTILに達したら終了する。
+func (r *Rule) iterate(dtstart time.Time, fn func(t time.Time) bool) {
+	count := 0
+	for i := 0; i < maxIterationDays; i++ {
+		t := time.Date(dtstart.Year(), dtstart.Month(), dtstart.Day()+i,
+			dtstart.Hour(), dtstart.Minute(), dtstart.Second(), dtstart.Nanosecond(), dtstart.Location())
+		if r.Until != nil && t.After(*r.Until) {
+			return
+		}
+		// dtstart自体は、ルールに一致しなくても最初の発生日時として数える。(RFC 5545の仕様)
+		if i != 0 && !r.matches(dtstart, t) {
+			continue
+		}
+		count++
+		if !fn(t) {
+			return
+		}
+		if r.Count > 0 && count >= r.Count {
+			return
+		}
+	}
+}
+
+// tの日付がルールに一致するか判定する。
+func (r *Rule) matches(dtstart time.Time, t time.Time) bool {
+	if len(r.ByMonth) > 0 && !containsMonth(r.ByMonth, t.Month()) {
+		return false
+	}
+	switch r.Freq {
+	case Daily:
+		if daysBetween(dtstart, t)%r.Interval != 0 {
+			return false
+		}
+		return r.matchesByMonthDay(t) && r.matchesPlainByDay(t)
+	case Weekly:
+		weeks := daysBetween(startOfWeek(dtstart, r.WeekStart), startOfWeek(t, r.WeekStart)) / 7
+		if weeks%r.Interval != 0 {
+			return false
+		}
+		if len(r.ByDay) == 0 {
+			return t.Weekday() == dtstart.Weekday()
+		}
+		return r.matchesPlainByDay(t)
+	case Monthly:
+		months := (t.Year()-dtstart.Year())*12 + int(t.Month()) - int(dtstart.Month())
+		if months%r.Interval != 0 {
+			return false
+		}
+		return r.matchesDayInPeriod(dtstart, t, false)
+	case Yearly:
+		if (t.Year()-dtstart.Year())%r.Interval != 0 {
+			return false
+		}
+		// BYMONTHもBYDAYもない場合は、dtstartと同じ月だけが対象になる。
+		if len(r.ByMonth) == 0 && len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 && t.Month() != dtstart.Month() {
+			return false
+		}
+		// BYMONTHがなくBYDAYだけ指定されている場合、第n曜日は年の中で数える。
+		return r.matchesDayInPeriod(dtstart, t, len(r.ByMonth) == 0 && len(r.ByDay) > 0)
+	}
+	return false
+}
+
+// 月(または年)の中で、日付の指定に一致するか判定する。
+// BYMONTHDAYとBYDAYの両方がある場合は両方に一致する必要があり、どちらもない場合はdtstartと同じ日にする。
+func (r *Rule) matchesDayInPeriod(dtstart time.Time, t time.Time, yearly bool) bool {
+	if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+		return t.Day() == dtstart.Day()
+	}
+	if !r.matchesByMonthDay(t) {
+		return false
+	}
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, v := range r.ByDay {
+		if t.Weekday() != v.Day {
+			continue
+		}
+		if v.N == 0 {
+			return true
+		}
+		// 期間の最初から数えて何番目か(nth)と、最後から数えて何番目か(last)を計算する。
+		var nth, last int
+		if yearly {
+			nth = (t.YearDay()-1)/7 + 1
+			last = -((daysInYear(t.Year())-t.YearDay())/7 + 1)
+		} else {
+			nth = (t.Day()-1)/7 + 1
+			last = -((daysInMonth(t)-t.Day())/7 + 1)
+		}
+		if v.N == nth || v.N == last {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesByMonthDay(t time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	for _, d := range r.ByMonthDay {
+		// マイナスの場合は月末から数える。(-1は月末日)
+		if d > 0 && t.Day() == d || d < 0 && t.Day() == daysInMonth(t)+d+1 {
+			return true
+		}
+	}
+	return false
+}
+
+// 第n週の指定がないBYDAYに一致するか判定する。BYDAYがない場合は常に一致する。
+func (r *Rule) matchesPlainByDay(t time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, v := range r.ByDay {
+		if t.Weekday() == v.Day {
+			return true
+		}
+	}
+	return false
+}
+
+func containsMonth(months []time.Month, m time.Month) bool {
+	for _, v := range months {
+		if v == m {
+			return true
+		}
+	}
+	return false
+}
+
+// 2つの日時の、カレンダー上の日数の差。夏時間の切り替えに影響されないようにUTCの日付で計算する。
+func daysBetween(a time.Time, b time.Time) int {
+	da := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	db := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(db.Sub(da).Hours() / 24)
+}
+
+// tを含む週の最初の日(weekStartの曜日)を返す。
+func startOfWeek(t time.Time, weekStart time.Weekday) time.Time {
+	diff := (int(t.Weekday()) - int(weekStart) + 7) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-diff, 0, 0, 0, 0, t.Location())
+}
+
+func daysInMonth(t time.Time) int {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func daysInYear(year int) int {
+	return time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC).YearDay()
+}
@@ -0,0 +1,179 @@
+package rrule
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"RRULE:",
+		"FREQ",
+		"FREQ=",
+		"FREQ=HOURLY",
+		"INTERVAL=2",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=0",
+		"FREQ=DAILY;COUNT=2;UNTIL=20230101",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=DAILY;BYDAY=XX",
+		"FREQ=DAILY;BYDAY=1MO",
+		"FREQ=WEEKLY;BYDAY=-1FR",
+		"FREQ=MONTHLY;BYDAY=0MO",
+		"FREQ=MONTHLY;BYDAY=54MO",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=YEARLY;BYMONTH=13",
+		"FREQ=DAILY;WKST=XX",
+		"FREQ=DAILY;BYHOUR=9",
+	}
+	for _, s := range tests {
+		if r, err := Parse(s, time.UTC); err == nil {
+			t.Errorf("Parse(%q) = %v, want error", s, r)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	jst := time.FixedZone("JST", 9*60*60)
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY"},
+		{"byday=mo;freq=weekly", "FREQ=WEEKLY;BYDAY=MO"},
+		{"RRULE:FREQ=MONTHLY;INTERVAL=1;BYDAY=-1FR;WKST=MO", "FREQ=MONTHLY;BYDAY=-1FR"},
+		{"FREQ=WEEKLY;INTERVAL=2;COUNT=10;BYDAY=MO,WE,FR", "FREQ=WEEKLY;INTERVAL=2;COUNT=10;BYDAY=MO,WE,FR"},
+		{"FREQ=DAILY;UNTIL=20231231", "FREQ=DAILY;UNTIL=20231231"},
+		{"FREQ=DAILY;UNTIL=20231231T090000", "FREQ=DAILY;UNTIL=20231231T000000Z"},
+		{"FREQ=DAILY;UNTIL=20231231T090000Z", "FREQ=DAILY;UNTIL=20231231T090000Z"},
+		{"WKST=SU;BYMONTH=2,8;BYMONTHDAY=1;FREQ=YEARLY", "FREQ=YEARLY;BYMONTHDAY=1;BYMONTH=2,8;WKST=SU"},
+	}
+	for _, tt := range tests {
+		r, err := Parse(tt.in, jst)
+		if err != nil {
+			t.Errorf("Parse(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got := r.String(); got != tt.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.in, got, tt.want)
+		}
+		// 文字列に戻したルールを、もう一度解析しても同じになる。
+		again, err := Parse(r.String(), jst)
+		if err != nil || again.String() != tt.want {
+			t.Errorf("Parse(%q) again = %v, %v, want %q", r.String(), again, err, tt.want)
+		}
+	}
+}
+
+func TestBetween(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 9, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart time.Time
+		to      time.Time
+		want    []time.Time
+	}{
+		{"daily count", "FREQ=DAILY;COUNT=3", day(2023, 7, 3), day(2023, 12, 31),
+			[]time.Time{day(2023, 7, 3), day(2023, 7, 4), day(2023, 7, 5)}},
+		{"daily until date includes the whole day", "FREQ=DAILY;UNTIL=20230705", day(2023, 7, 3), day(2023, 12, 31),
+			[]time.Time{day(2023, 7, 3), day(2023, 7, 4), day(2023, 7, 5)}},
+		{"weekdays", "FREQ=WEEKLY;BYDAY=MO,WE,FR", day(2023, 7, 3), day(2023, 7, 10),
+			[]time.Time{day(2023, 7, 3), day(2023, 7, 5), day(2023, 7, 7), day(2023, 7, 10)}},
+		{"every other week", "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU", day(2023, 7, 4), day(2023, 8, 1),
+			[]time.Time{day(2023, 7, 4), day(2023, 7, 18), day(2023, 8, 1)}},
+		{"monthly on the same day", "FREQ=MONTHLY", day(2023, 1, 15), day(2023, 3, 15),
+			[]time.Time{day(2023, 1, 15), day(2023, 2, 15), day(2023, 3, 15)}},
+		{"31st skips short months", "FREQ=MONTHLY;BYMONTHDAY=31", day(2023, 1, 31), day(2023, 5, 31),
+			[]time.Time{day(2023, 1, 31), day(2023, 3, 31), day(2023, 5, 31)}},
+		{"last day of month", "FREQ=MONTHLY;BYMONTHDAY=-1", day(2023, 1, 31), day(2023, 4, 30),
+			[]time.Time{day(2023, 1, 31), day(2023, 2, 28), day(2023, 3, 31), day(2023, 4, 30)}},
+		{"second tuesday", "FREQ=MONTHLY;BYDAY=2TU", day(2023, 7, 11), day(2023, 9, 30),
+			[]time.Time{day(2023, 7, 11), day(2023, 8, 8), day(2023, 9, 12)}},
+		{"last friday", "FREQ=MONTHLY;BYDAY=-1FR", day(2023, 7, 28), day(2023, 9, 30),
+			[]time.Time{day(2023, 7, 28), day(2023, 8, 25), day(2023, 9, 29)}},
+		{"leap day", "FREQ=YEARLY", day(2020, 2, 29), day(2028, 12, 31),
+			[]time.Time{day(2020, 2, 29), day(2024, 2, 29), day(2028, 2, 29)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Parse(tt.rule, time.UTC)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := r.Between(tt.dtstart, tt.dtstart, tt.to, 100)
+			if !equalTimes(got, tt.want) {
+				t.Errorf("Between() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBetweenRange(t *testing.T) {
+	r, err := Parse("FREQ=DAILY", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dtstart := time.Date(2023, 7, 1, 9, 0, 0, 0, time.UTC)
+	from := time.Date(2023, 7, 10, 9, 0, 0, 0, time.UTC)
+	to := time.Date(2023, 7, 12, 9, 0, 0, 0, time.UTC)
+	want := []time.Time{from, from.AddDate(0, 0, 1), to}
+	if got := r.Between(dtstart, from, to, 100); !equalTimes(got, want) {
+		t.Errorf("Between() = %v, want %v", got, want)
+	}
+	if got := r.Between(dtstart, from, to, 2); !equalTimes(got, want[:2]) {
+		t.Errorf("Between() with limit = %v, want %v", got, want[:2])
+	}
+}
+
+// 夏時間の切り替えをまたいでも、壁時計の時刻(9時)のままにする。
+func TestBetweenKeepsWallClock(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := Parse("FREQ=DAILY;COUNT=3", ny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dtstart := time.Date(2023, 3, 11, 9, 0, 0, 0, ny)
+	got := r.Between(dtstart, dtstart, dtstart.AddDate(0, 0, 10), 10)
+	want := []time.Time{dtstart, time.Date(2023, 3, 12, 9, 0, 0, 0, ny), time.Date(2023, 3, 13, 9, 0, 0, 0, ny)}
+	if !equalTimes(got, want) {
+		t.Errorf("Between() = %v, want %v", got, want)
+	}
+}
+
+func TestNext(t *testing.T) {
+	r, err := Parse("FREQ=WEEKLY;COUNT=2", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dtstart := time.Date(2023, 7, 3, 9, 0, 0, 0, time.UTC)
+	next, ok := r.Next(dtstart, dtstart)
+	if want := dtstart.AddDate(0, 0, 7); !ok || !next.Equal(want) {
+		t.Errorf("Next() = %v, %v, want %v, true", next, ok, want)
+	}
+	if next, ok := r.Next(dtstart, dtstart.AddDate(0, 0, 7)); ok {
+		t.Errorf("Next() after COUNT = %v, want none", next)
+	}
+}
+
+func equalTimes(a []time.Time, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3互換のオブジェクトストレージの接続先。
+// Endpointはhttps://s3.ap-northeast-1.amazonaws.comのようなURL。MinIOなどではhttp://localhost:9000のように指定する。
+// ForcePathStyleがtrueの場合は、バケット名をホスト名ではなくパスに入れる。(MinIOなどで必要)
+type S3Config struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyId     string
+	SecretAccessKey string
+	ForcePathStyle  bool
+}
+
+// S3のREST APIを、署名バージョン4(SigV4)で署名したリクエストで直接呼び出す。
+type s3Storage struct {
+	endpoint *url.URL
+	config   S3Config
+	client   *http.Client
+}
+
+// リージョンを指定しなかったときの初期値。MinIOはこの値を使う。
+const defaultS3Region = "us-east-1"
+
+func NewS3Storage(config S3Config) (Storage, error) {
+	if config.Endpoint == "" || config.Bucket == "" || config.AccessKeyId == "" || config.SecretAccessKey == "" {
+		return nil, errors.New("S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required")
+	}
+	endpoint, err := url.Parse(strings.TrimSuffix(config.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("invalid S3_ENDPOINT: %s", config.Endpoint)
+	}
+	if config.Region == "" {
+		config.Region = defaultS3Region
+	}
+	// 大きなファイルの転送に時間がかかるので、全体のタイムアウトではなく接続とレスポンスヘッダーまでのタイムアウトにする。
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = 30 * time.Second
+	return &s3Storage{endpoint: endpoint, config: config, client: &http.Client{Transport: transport}}, nil
+}
+
+func (ss *s3Storage) Put(key string, r io.Reader, size int64, contentType string) error {
+	req, err := ss.newRequest(http.MethodPut, key, r)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	res, err := ss.do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+func (ss *s3Storage) Get(key string) (io.ReadCloser, error) {
+	req, err := ss.newRequest(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := ss.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+func (ss *s3Storage) Delete(key string) error {
+	req, err := ss.newRequest(http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	res, err := ss.do(req)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+func (ss *s3Storage) newRequest(method string, key string, body io.Reader) (*http.Request, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+	u := *ss.endpoint
+	path := "/" + key
+	if ss.config.ForcePathStyle {
+		path = "/" + ss.config.Bucket + path
+	} else {
+		u.Host = ss.config.Bucket + "." + u.Host
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// 署名してリクエストを送る。2xx以外のレスポンスはエラーにする。(404はErrNotFound)
+func (ss *s3Storage) do(req *http.Request) (*http.Response, error) {
+	ss.sign(req, time.Now())
+	res, err := ss.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return res, nil
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	// エラーのレスポンスはXMLで返ってくる。長すぎる場合に備えて先頭だけを読む。
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+	return nil, fmt.Errorf("s3: %s %s: %s: %s", req.Method, req.URL.Path, res.Status, strings.TrimSpace(string(body)))
+}
+
+const (
+	s3SigningAlgorithm = "AWS4-HMAC-SHA256"
+	// ボディのハッシュを計算するとファイル全体を2回読むことになるので、ボディは署名に含めない。
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// 署名バージョン4の署名をAuthorizationヘッダーに付ける。
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func (ss *s3Storage) sign(req *http.Request, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", s3UnsignedPayload)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "content-type" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	if req.ContentLength > 0 {
+		headers["content-length"] = strconv.FormatInt(req.ContentLength, 10)
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3EscapePath(req.URL.Path),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		s3UnsignedPayload,
+	}, "\n")
+	scope := date + "/" + ss.config.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		s3SigningAlgorithm,
+		amzDate,
+		scope,
+		hexSHA256(canonicalRequest),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+ss.config.SecretAccessKey), date)
+	key = hmacSHA256(key, ss.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3SigningAlgorithm, ss.config.AccessKeyId, scope, signedHeaders, signature))
+}
+
+// パスを署名用にエンコードする。英数字と「-_.~/」以外はパーセントエンコードする。
+func s3EscapePath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hexSHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
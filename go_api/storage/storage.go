@@ -0,0 +1,76 @@
+// 添付ファイルなどの中身(バイト列)を保存するパッケージ
+// 保存先はStorageインターフェースで抽象化していて、ローカルのファイルシステムとS3互換のオブジェクトストレージを選べる。
+// どちらもキー(例: tasks/1/xxxx)でファイルを区別する。ファイル名や種類などのメタデータはDBに保存する。
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// キーのファイルが存在しないときのエラー。
+var ErrNotFound = errors.New("object not found")
+
+type Storage interface {
+	// rからsizeバイトを読み込んで保存する。同じキーのファイルがある場合は上書きする。
+	Put(key string, r io.Reader, size int64, contentType string) error
+	// ファイルを開く。読み終わったら必ずCloseを呼ぶ。
+	Get(key string) (io.ReadCloser, error)
+	// ファイルを削除する。存在しない場合もエラーにしない。
+	Delete(key string) error
+}
+
+// 保存先の種類。環境変数STORAGE_DRIVERで指定する。
+const (
+	DriverLocal = "local"
+	DriverS3    = "s3"
+)
+
+// ローカルに保存する場合の、保存先のディレクトリの初期値。
+const defaultLocalDir = "uploads"
+
+// 環境変数から保存先を作る。
+// local: STORAGE_LOCAL_DIRのディレクトリに保存する。
+// s3: S3_ENDPOINT、S3_REGION、S3_BUCKET、S3_ACCESS_KEY_ID、S3_SECRET_ACCESS_KEY、S3_FORCE_PATH_STYLEを使う。
+func NewStorageFromEnv() (Storage, error) {
+	switch driver := os.Getenv("STORAGE_DRIVER"); driver {
+	case "", DriverLocal:
+		dir := os.Getenv("STORAGE_LOCAL_DIR")
+		if dir == "" {
+			dir = defaultLocalDir
+		}
+		return NewLocalStorage(dir)
+	case DriverS3:
+		return NewS3Storage(S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyId:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			ForcePathStyle:  os.Getenv("S3_FORCE_PATH_STYLE") == "true",
+		})
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_DRIVER: %s", driver)
+	}
+}
+
+// キーは「/」区切りの英数字と「-_.」だけにする。(ディレクトリの外を指す「..」などを防ぐ)
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.HasSuffix(key, "/") {
+		return fmt.Errorf("invalid key: %q", key)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("invalid key: %q", key)
+		}
+		for _, r := range segment {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+				return fmt.Errorf("invalid key: %q", key)
+			}
+		}
+	}
+	return nil
+}
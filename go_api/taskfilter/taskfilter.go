@@ -0,0 +1,482 @@
+// taskfilterパッケージは、保存したビューなどで使うタスクの絞り込みの式を解析する。
+//
+// 式は「フィールド 演算子 値」の条件と、overdue・blockedのフラグを、and・or・notと括弧で組み合わせて書く。
+// (例: overdue and project = 3 and label = "急ぎ")
+//
+//	status      = !=           todo, in_progress, done, cancelled
+//	title       = != ~         文字列 (~は大文字・小文字を区別しない部分一致)
+//	description = != ~         文字列
+//	label       = !=           ラベル名 (=はそのラベルが付いている、!=は付いていない)
+//	project     = !=           プロジェクトのid, none (プロジェクトなし)
+//	parent      = !=           親タスクのid, none (サブタスクではない)
+//	assignee    = !=           ユーザーのid, me, none
+//	creator     = !=           ユーザーのid, me
+//	due, start, completed      = != < <= > >=  日時, none (=と!=だけ)
+//	created, updated           = != < <= > >=  日時
+//
+// 日時は、YYYY-MM-DD(その日全体)、RFC3339、today(今日全体)、now(現在時刻)と、
+// today+3dやnow-7dのような日数のずらしで書く。
+// 値は空白や記号を含まない場合はそのまま書き、含む場合は"..."で囲む。(\"と\\でエスケープする)
+//
+// ここでは式を解析して構文木にするだけで、SQLにはしない。SQLへの変換はrepositoryで行い、値はすべてプレースホルダーで渡す。
+package taskfilter
+
+import (
+	"errors"
+	"fmt"
+	"go_api/model"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 式の長さ、括弧やnotの入れ子の深さ、条件の数の上限。
+const (
+	MaxLength     = 500
+	MaxDepth      = 10
+	MaxConditions = 30
+)
+
+// 解析できない式のエラー。どこがおかしいかは、エラーのメッセージに入れる。
+var ErrInvalidQuery = errors.New("invalid query")
+
+// 式の構文木。
+type Expr interface {
+	expr()
+}
+
+type And struct {
+	Left  Expr
+	Right Expr
+}
+
+type Or struct {
+	Left  Expr
+	Right Expr
+}
+
+type Not struct {
+	Expr Expr
+}
+
+// overdue(期限切れ)、blocked(終わっていないタスクに依存している)のフラグ。
+type Flag struct {
+	Name string
+}
+
+// 「フィールド 演算子 値」の条件。
+type Condition struct {
+	Field string
+	Op    string
+	Value Value
+}
+
+func (And) expr()       {}
+func (Or) expr()        {}
+func (Not) expr()       {}
+func (Flag) expr()      {}
+func (Condition) expr() {}
+
+// 値の種類。
+const (
+	ValueString = "string"
+	ValueId     = "id"
+	ValueNone   = "none"
+	ValueMe     = "me"
+	ValueTime   = "time"
+)
+
+// 条件の値。Kindによって、Str・Id・Timeのどれかを使う。
+type Value struct {
+	Kind string
+	Str  string
+	Id   uint
+	Time TimeValue
+}
+
+// 日時の値。today・日付は1日全体を、now・RFC3339は時刻を表す。
+// todayとnowは、式を評価するときの現在時刻から決める。
+type TimeValue struct {
+	// today, now, date, instantのどれか。
+	Base string
+	// Baseがdateかinstantの場合の日時。
+	At time.Time
+	// today+3dのような、ずらす日数。
+	Days int
+}
+
+// 1日全体を表すかどうか。
+func (t TimeValue) IsDay() bool {
+	return t.Base == "today" || t.Base == "date"
+}
+
+// nowを現在時刻として、値が表す範囲の始まりと終わりを返す。1日全体の場合は、nowのタイムゾーンの0時から翌日の0時まで。
+// 時刻の場合は、始まりと終わりが同じになる。
+func (t TimeValue) Range(now time.Time) (time.Time, time.Time) {
+	var start time.Time
+	switch t.Base {
+	case "today":
+		y, m, d := now.Date()
+		start = time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	case "now":
+		start = now
+	case "date":
+		y, m, d := t.At.Date()
+		start = time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	default:
+		start = t.At
+	}
+	start = start.AddDate(0, 0, t.Days)
+	if t.IsDay() {
+		return start, start.AddDate(0, 0, 1)
+	}
+	return start, start
+}
+
+// フィールドで使える演算子と値の種類。
+type fieldSpec struct {
+	ops   []string
+	kinds []string
+}
+
+var (
+	equalityOps   = []string{"=", "!="}
+	textOps       = []string{"=", "!=", "~"}
+	comparisonOps = []string{"=", "!=", "<", "<=", ">", ">="}
+)
+
+var fields = map[string]fieldSpec{
+	"status":      {equalityOps, []string{ValueString}},
+	"title":       {textOps, []string{ValueString}},
+	"description": {textOps, []string{ValueString}},
+	"label":       {equalityOps, []string{ValueString}},
+	"project":     {equalityOps, []string{ValueId, ValueNone}},
+	"parent":      {equalityOps, []string{ValueId, ValueNone}},
+	"assignee":    {equalityOps, []string{ValueId, ValueMe, ValueNone}},
+	"creator":     {equalityOps, []string{ValueId, ValueMe}},
+	"due":         {comparisonOps, []string{ValueTime, ValueNone}},
+	"start":       {comparisonOps, []string{ValueTime, ValueNone}},
+	"completed":   {comparisonOps, []string{ValueTime, ValueNone}},
+	"created":     {comparisonOps, []string{ValueTime}},
+	"updated":     {comparisonOps, []string{ValueTime}},
+}
+
+var flags = map[string]bool{
+	"overdue": true,
+	"blocked": true,
+}
+
+// 字句の種類。
+const (
+	tokenWord   = "word"
+	tokenString = "string"
+	tokenOp     = "op"
+	tokenLParen = "("
+	tokenRParen = ")"
+	tokenEOF    = "eof"
+)
+
+type token struct {
+	kind string
+	text string
+	pos  int
+}
+
+// 値として、引用符なしで書ける文字。
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_-+.:", r)
+}
+
+func invalid(pos int, format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s at %d", ErrInvalidQuery, fmt.Sprintf(format, args...), pos)
+}
+
+func tokenize(s string) ([]token, error) {
+	tokens := []token{}
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{tokenLParen, "(", i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{tokenRParen, ")", i})
+			i++
+		case r == '=' || r == '~':
+			tokens = append(tokens, token{tokenOp, string(r), i})
+			i++
+		case r == '!' || r == '<' || r == '>':
+			op := string(r)
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, invalid(i, "unexpected !")
+			}
+			tokens = append(tokens, token{tokenOp, op, i})
+			i += len(op)
+		case r == '"':
+			start := i
+			var b strings.Builder
+			i++
+			closed := false
+			for i < len(runes) {
+				if runes[i] == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+					b.WriteRune(runes[i+1])
+					i += 2
+					continue
+				}
+				if runes[i] == '"' {
+					closed = true
+					i++
+					break
+				}
+				b.WriteRune(runes[i])
+				i++
+			}
+			if !closed {
+				return nil, invalid(start, "unterminated string")
+			}
+			tokens = append(tokens, token{tokenString, b.String(), start})
+		case isWordRune(r):
+			start := i
+			for i < len(runes) && isWordRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokenWord, string(runes[start:i]), start})
+		default:
+			return nil, invalid(i, "unexpected %q", r)
+		}
+	}
+	return append(tokens, token{tokenEOF, "", len(runes)}), nil
+}
+
+type parser struct {
+	tokens     []token
+	pos        int
+	conditions int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// 大文字・小文字を区別せずに、キーワードかどうか調べる。
+func (p *parser) keyword(word string) bool {
+	t := p.peek()
+	return t.kind == tokenWord && strings.EqualFold(t.text, word)
+}
+
+// or → and ("or" and)*
+func (p *parser) parseOr(depth int) (Expr, error) {
+	left, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		p.next()
+		right, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = Or{left, right}
+	}
+	return left, nil
+}
+
+// and → unary ("and" unary)*
+func (p *parser) parseAnd(depth int) (Expr, error) {
+	left, err := p.parseUnary(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		p.next()
+		right, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = And{left, right}
+	}
+	return left, nil
+}
+
+// unary → "not" unary | "(" or ")" | フラグ | 条件
+func (p *parser) parseUnary(depth int) (Expr, error) {
+	t := p.peek()
+	if depth > MaxDepth {
+		return nil, invalid(t.pos, "query is nested too deeply")
+	}
+	if p.keyword("not") {
+		p.next()
+		e, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return Not{e}, nil
+	}
+	if t.kind == tokenLParen {
+		p.next()
+		e, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, invalid(closing.pos, "expected )")
+		}
+		return e, nil
+	}
+	if t.kind != tokenWord {
+		return nil, invalid(t.pos, "expected field or flag")
+	}
+	p.conditions++
+	if p.conditions > MaxConditions {
+		return nil, invalid(t.pos, "query has too many conditions")
+	}
+	name := strings.ToLower(p.next().text)
+	if flags[name] {
+		return Flag{name}, nil
+	}
+	spec, ok := fields[name]
+	if !ok {
+		return nil, invalid(t.pos, "unknown field or flag %q", name)
+	}
+	op := p.next()
+	if op.kind != tokenOp {
+		return nil, invalid(op.pos, "expected operator after %s", name)
+	}
+	if !contains(spec.ops, op.text) {
+		return nil, invalid(op.pos, "%s cannot be used with %s", op.text, name)
+	}
+	raw := p.next()
+	if raw.kind != tokenWord && raw.kind != tokenString {
+		return nil, invalid(raw.pos, "expected value after %s %s", name, op.text)
+	}
+	value, err := parseValue(raw, spec.kinds)
+	if err != nil {
+		return nil, err
+	}
+	if value.Kind == ValueNone && op.text != "=" && op.text != "!=" {
+		return nil, invalid(raw.pos, "none can only be used with = or !=")
+	}
+	if name == "status" && !contains(statusNames(), value.Str) {
+		return nil, invalid(raw.pos, "status must be one of todo, in_progress, done, cancelled")
+	}
+	return Condition{Field: name, Op: op.text, Value: value}, nil
+}
+
+// 値を、フィールドで使える種類のどれかとして解釈する。none・me・today・nowは、引用符で囲むと文字列になる。
+func parseValue(t token, kinds []string) (Value, error) {
+	word := t.kind == tokenWord
+	for _, kind := range kinds {
+		switch kind {
+		case ValueNone, ValueMe:
+			if word && strings.EqualFold(t.text, kind) {
+				return Value{Kind: kind}, nil
+			}
+		case ValueId:
+			if id, err := strconv.ParseUint(t.text, 10, 32); err == nil && word && id > 0 {
+				return Value{Kind: ValueId, Id: uint(id)}, nil
+			}
+		case ValueTime:
+			if tv, ok := parseTime(t.text); ok {
+				return Value{Kind: ValueTime, Time: tv}, nil
+			}
+		case ValueString:
+			return Value{Kind: ValueString, Str: t.text}, nil
+		}
+	}
+	return Value{}, invalid(t.pos, "invalid value %q", t.text)
+}
+
+// today・now・日付・RFC3339と、その後ろの+Nd・-Ndを解釈する。
+// 符号は1つだけで、Nは数字だけにする。(++3dや-+3dは不正) ずらせる日数は前後3650日まで。
+func parseTime(s string) (TimeValue, bool) {
+	lower := strings.ToLower(s)
+	for _, base := range []string{"today", "now"} {
+		if !strings.HasPrefix(lower, base) {
+			continue
+		}
+		rest := lower[len(base):]
+		if rest == "" {
+			return TimeValue{Base: base}, true
+		}
+		if len(rest) < 3 || (rest[0] != '+' && rest[0] != '-') || rest[len(rest)-1] != 'd' {
+			return TimeValue{}, false
+		}
+		digits := rest[1 : len(rest)-1]
+		if strings.Trim(digits, "0123456789") != "" {
+			return TimeValue{}, false
+		}
+		days, err := strconv.Atoi(digits)
+		if err != nil {
+			return TimeValue{}, false
+		}
+		if rest[0] == '-' {
+			days = -days
+		}
+		if days < -3650 || days > 3650 {
+			return TimeValue{}, false
+		}
+		return TimeValue{Base: base, Days: days}, true
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return TimeValue{Base: "date", At: t}, true
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return TimeValue{Base: "instant", At: t}, true
+	}
+	return TimeValue{}, false
+}
+
+func statusNames() []string {
+	names := []string{}
+	for _, v := range model.TaskStatuses {
+		names = append(names, v.(string))
+	}
+	return names
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// 式を解析して構文木を返す。空の式(空白だけの場合も含む)は、条件なしとしてnilを返す。
+func Parse(s string) (Expr, error) {
+	if len(s) > MaxLength {
+		return nil, fmt.Errorf("%w: query is limited max %d char", ErrInvalidQuery, MaxLength)
+	}
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	e, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, invalid(t.pos, "unexpected %q", t.text)
+	}
+	return e, nil
+}
@@ -0,0 +1,181 @@
+package taskfilter
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func cond(field string, op string, value Value) Condition {
+	return Condition{Field: field, Op: op, Value: value}
+}
+
+func str(s string) Value {
+	return Value{Kind: ValueString, Str: s}
+}
+
+func TestParse(t *testing.T) {
+	overdue, blocked := Flag{"overdue"}, Flag{"blocked"}
+	done := cond("status", "=", str("done"))
+	tests := []struct {
+		name  string
+		query string
+		want  Expr
+	}{
+		{"empty", "", nil},
+		{"blank", "  \t ", nil},
+		{"flag", "overdue", overdue},
+		{"keywords ignore case", "OVERDUE AND Blocked", And{overdue, blocked}},
+		{"and binds tighter than or", "overdue or blocked and status = done", Or{overdue, And{blocked, done}}},
+		{"and before or", "overdue and blocked or status = done", Or{And{overdue, blocked}, done}},
+		{"parentheses", "(overdue or blocked) and status = done", And{Or{overdue, blocked}, done}},
+		{"or is left associative", "overdue or blocked or status = done", Or{Or{overdue, blocked}, done}},
+		{"not binds tighter than and", "not overdue and blocked", And{Not{overdue}, blocked}},
+		{"not parentheses", "not (overdue and blocked)", Not{And{overdue, blocked}}},
+		{"double not", "not not overdue", Not{Not{overdue}}},
+		{"no spaces", "status=done", done},
+		{"quoted", `label = "急ぎ"`, cond("label", "=", str("急ぎ"))},
+		{"quoted with escapes", `title ~ "a \"b\" \\ c"`, cond("title", "~", str(`a "b" \ c`))},
+		{"quoted keyword", `title = "and or not"`, cond("title", "=", str("and or not"))},
+		{"quoted empty", `description = ""`, cond("description", "=", str(""))},
+		{"id", "project = 3", cond("project", "=", Value{Kind: ValueId, Id: 3})},
+		{"none", "parent != none", cond("parent", "!=", Value{Kind: ValueNone})},
+		{"me", "assignee = ME", cond("assignee", "=", Value{Kind: ValueMe})},
+		{"date", "due >= 2023-07-01", cond("due", ">=", Value{Kind: ValueTime, Time: TimeValue{Base: "date", At: time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)}})},
+		{"instant", "updated < 2023-07-01T09:00:00Z", cond("updated", "<", Value{Kind: ValueTime, Time: TimeValue{Base: "instant", At: time.Date(2023, 7, 1, 9, 0, 0, 0, time.UTC)}})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tt.query, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %#v, want %#v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"unknown field", "priority = 1"},
+		{"unknown flag", "urgent"},
+		{"operator not allowed for field", "status ~ done"},
+		{"comparison on id", "project < 3"},
+		{"comparison on text", "title > a"},
+		{"unknown status", "status = started"},
+		{"none on field without none", "created = none"},
+		{"none with comparison", "due < none"},
+		{"me on project", "project = me"},
+		{"quoted me", `assignee = "me"`},
+		{"zero id", "project = 0"},
+		{"negative id", "project = -1"},
+		{"invalid time", "due < tomorrow"},
+		{"missing operator", "title done"},
+		{"missing value", "title ="},
+		{"missing operand", "overdue and"},
+		{"bare bang", "title ! done"},
+		{"unsupported character", "title = a;b"},
+		{"unterminated string", `title = "abc`},
+		{"unclosed parenthesis", "(overdue or blocked"},
+		{"extra parenthesis", "overdue)"},
+		{"empty parentheses", "()"},
+		{"missing and", "overdue blocked"},
+		{"too deep", strings.Repeat("not ", MaxDepth+2) + "overdue"},
+		{"too many conditions", strings.Repeat("overdue or ", MaxConditions) + "overdue"},
+		{"too long", "title = " + strings.Repeat("a", MaxLength)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.query)
+			if !errors.Is(err, ErrInvalidQuery) {
+				t.Errorf("Parse(%q) = %#v, %v, want ErrInvalidQuery", tt.query, got, err)
+			}
+		})
+	}
+}
+
+func TestParseRelativeTime(t *testing.T) {
+	tests := []struct {
+		value string
+		want  TimeValue
+		ok    bool
+	}{
+		{"today", TimeValue{Base: "today"}, true},
+		{"now", TimeValue{Base: "now"}, true},
+		{"Today", TimeValue{Base: "today"}, true},
+		{"today+3d", TimeValue{Base: "today", Days: 3}, true},
+		{"today-3d", TimeValue{Base: "today", Days: -3}, true},
+		{"now-7d", TimeValue{Base: "now", Days: -7}, true},
+		{"today+0d", TimeValue{Base: "today"}, true},
+		{"today+03d", TimeValue{Base: "today", Days: 3}, true},
+		{"TODAY+3D", TimeValue{Base: "today", Days: 3}, true},
+		{"today+3650d", TimeValue{Base: "today", Days: 3650}, true},
+		{"today-3650d", TimeValue{Base: "today", Days: -3650}, true},
+		{"today+3651d", TimeValue{}, false},
+		{"today-3651d", TimeValue{}, false},
+		{"today--5000d", TimeValue{}, false},
+		{"today++3d", TimeValue{}, false},
+		{"today+-3d", TimeValue{}, false},
+		{"today-+3d", TimeValue{}, false},
+		{"today+d", TimeValue{}, false},
+		{"today+3", TimeValue{}, false},
+		{"today3d", TimeValue{}, false},
+		{"today+3.5d", TimeValue{}, false},
+		{"today+99999999999999999999d", TimeValue{}, false},
+		{"now+3h", TimeValue{}, false},
+		{"todays", TimeValue{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := Parse("due < " + tt.value)
+			if !tt.ok {
+				if !errors.Is(err, ErrInvalidQuery) {
+					t.Errorf("Parse(due < %s) = %#v, %v, want ErrInvalidQuery", tt.value, got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(due < %s) error: %v", tt.value, err)
+			}
+			want := cond("due", "<", Value{Kind: ValueTime, Time: tt.want})
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Parse(due < %s) = %#v, want %#v", tt.value, got, want)
+			}
+		})
+	}
+}
+
+func TestTimeValueRange(t *testing.T) {
+	loc := time.FixedZone("JST", 9*60*60)
+	now := time.Date(2023, 7, 10, 15, 30, 0, 0, loc)
+	day := func(d int) time.Time {
+		return time.Date(2023, 7, d, 0, 0, 0, 0, loc)
+	}
+	tests := []struct {
+		name      string
+		value     TimeValue
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{"today", TimeValue{Base: "today"}, day(10), day(11)},
+		{"today+3d", TimeValue{Base: "today", Days: 3}, day(13), day(14)},
+		{"today-3d", TimeValue{Base: "today", Days: -3}, day(7), day(8)},
+		{"now-1d", TimeValue{Base: "now", Days: -1}, now.AddDate(0, 0, -1), now.AddDate(0, 0, -1)},
+		{"date in the location of now", TimeValue{Base: "date", At: time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)}, day(1), day(2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := tt.value.Range(now)
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("Range() = %v, %v, want %v, %v", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package taskio
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_api/model"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// CSVの列。1行目に列名を書き、インポートのときは列名で値を探すので、列の順番は入れ替わっていてもよい。
+// チェックリストはJSONの配列、ラベルは名前のセミコロン区切りで書く。
+var csvColumns = []string{
+	"id", "title", "description", "status", "completed_at", "start_at", "due_at",
+	"project_id", "parent_id", "checklist", "recurrence", "labels", "created_at", "updated_at",
+}
+
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (cw *csvWriter) Write(task model.TaskResponse) error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+	checklist, err := json.Marshal(task.Checklist)
+	if err != nil {
+		return err
+	}
+	createdAt, updatedAt := task.CreatedAt, task.UpdatedAt
+	return cw.w.Write([]string{
+		strconv.FormatUint(uint64(task.ID), 10),
+		task.Title,
+		task.Description,
+		task.Status,
+		formatTime(task.CompletedAt),
+		formatTime(task.StartAt),
+		formatTime(task.DueAt),
+		formatId(task.ProjectId),
+		formatId(task.ParentId),
+		string(checklist),
+		task.Recurrence,
+		joinLabels(task.Labels),
+		formatTime(&createdAt),
+		formatTime(&updatedAt),
+	})
+}
+
+func (cw *csvWriter) writeHeader() error {
+	if cw.headerWritten {
+		return nil
+	}
+	cw.headerWritten = true
+	return cw.w.Write(csvColumns)
+}
+
+func (cw *csvWriter) Close() error {
+	// タスクが1件もない場合も、列名の行だけは書き出す。
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type csvReader struct {
+	r       *csv.Reader
+	columns map[string]int
+	// 最後に読めた行の番号。csvパッケージ以外のエラーの行番号に使う。
+	line int
+}
+
+func newCSVReader(r io.Reader) *csvReader {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	return &csvReader{r: cr}
+}
+
+func (cr *csvReader) Read() (Record, error) {
+	if cr.columns == nil {
+		header, err := cr.r.Read()
+		if err == io.EOF {
+			return Record{}, io.EOF
+		}
+		if err != nil {
+			return Record{}, csvLineError(err, 1)
+		}
+		cr.line = 1
+		// Excelで保存したCSVの先頭に付くBOMは取り除く。
+		cr.columns = map[string]int{}
+		for i, name := range header {
+			cr.columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+		}
+		if _, ok := cr.columns["title"]; !ok {
+			return Record{}, &LineError{Line: 1, Err: fmt.Errorf("title column is required")}
+		}
+	}
+	row, err := cr.r.Read()
+	if err == io.EOF {
+		return Record{}, io.EOF
+	}
+	// 列の数が1行目と違う行は、その行だけエラーにして続きを読む。
+	// それ以外のエラーでは行を読めていないので、FieldPosは呼べない。
+	if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+		return Record{}, csvLineError(err, cr.line+1)
+	}
+	cr.line, _ = cr.r.FieldPos(0)
+	rec := Record{Line: cr.line}
+	if err != nil {
+		rec.Err = fmt.Errorf("wrong number of fields")
+		return rec, nil
+	}
+	rec.Task, rec.Err = cr.toTask(row)
+	return rec, nil
+}
+
+func (cr *csvReader) toTask(row []string) (model.TaskResponse, error) {
+	get := func(name string) string {
+		if i, ok := cr.columns[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+	task := model.TaskResponse{
+		Title:       get("title"),
+		Description: get("description"),
+		Status:      strings.TrimSpace(get("status")),
+		Recurrence:  strings.TrimSpace(get("recurrence")),
+		Labels:      splitLabels(get("labels")),
+		Checklist:   model.Checklist{},
+	}
+	var err error
+	if task.ID, err = parseUint(get("id")); err != nil {
+		return task, fmt.Errorf("id: %w", err)
+	}
+	if task.StartAt, err = parseTime(get("start_at")); err != nil {
+		return task, fmt.Errorf("start_at: %w", err)
+	}
+	if task.DueAt, err = parseTime(get("due_at")); err != nil {
+		return task, fmt.Errorf("due_at: %w", err)
+	}
+	if task.ProjectId, err = parseId(get("project_id")); err != nil {
+		return task, fmt.Errorf("project_id: %w", err)
+	}
+	if task.ParentId, err = parseId(get("parent_id")); err != nil {
+		return task, fmt.Errorf("parent_id: %w", err)
+	}
+	if s := strings.TrimSpace(get("checklist")); s != "" {
+		if err := json.Unmarshal([]byte(s), &task.Checklist); err != nil {
+			return task, fmt.Errorf("checklist must be a JSON array")
+		}
+	}
+	return task, nil
+}
+
+// csvパッケージのエラーには行番号が含まれているので、それを使う。
+func csvLineError(err error, line int) error {
+	var pe *csv.ParseError
+	if errors.As(err, &pe) {
+		return &LineError{Line: pe.StartLine, Err: pe.Err}
+	}
+	return &LineError{Line: line, Err: err}
+}
+
+func formatId(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
+
+func parseUint(s string) (uint, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid id %q", s)
+	}
+	return uint(n), nil
+}
+
+func parseId(s string) (*uint, error) {
+	n, err := parseUint(s)
+	if err != nil || n == 0 {
+		return nil, err
+	}
+	return &n, nil
+}
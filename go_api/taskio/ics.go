@@ -0,0 +1,279 @@
+package taskio
+
+import (
+	"errors"
+	"fmt"
+	"go_api/ical"
+	"go_api/model"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// iCalendarのタスクのUIDのドメイン部分。このUIDのタスクは、インポートのときにidを元に戻せる。
+const uidDomain = "@regondor"
+
+// iCalendarのステータスとタスクのステータスの対応。
+var icalStatuses = map[string]string{
+	model.TaskStatusTodo:       "NEEDS-ACTION",
+	model.TaskStatusInProgress: "IN-PROCESS",
+	model.TaskStatusDone:       "COMPLETED",
+	model.TaskStatusCancelled:  "CANCELLED",
+}
+
+// タスクをiCalendarのVTODOまたはVEVENTとして書き出す。
+// VEVENTの場合は、カレンダーに予定として表示できない(開始日時も期限もない)タスクは書き出さない。
+type calendarWriter struct {
+	enc       *ical.Encoder
+	component string
+	name      string
+	begun     bool
+}
+
+// componentにはical.VTodoかical.VEventを指定する。nameはカレンダーアプリに表示されるカレンダーの名前。
+func NewCalendarWriter(w io.Writer, component string, name string) Writer {
+	return &calendarWriter{enc: ical.NewEncoder(w), component: component, name: name}
+}
+
+func (cw *calendarWriter) begin() error {
+	if cw.begun {
+		return nil
+	}
+	cw.begun = true
+	if err := cw.enc.Begin(ical.VCalendar); err != nil {
+		return err
+	}
+	header := ical.NewComponent(ical.VCalendar)
+	header.Add("VERSION", "2.0")
+	header.Add("PRODID", "-//regondor//tasks//JA")
+	header.Add("CALSCALE", "GREGORIAN")
+	header.Add("METHOD", "PUBLISH")
+	if cw.name != "" {
+		header.AddText("X-WR-CALNAME", cw.name)
+	}
+	for _, p := range header.Properties {
+		if err := cw.enc.WriteProperty(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cw *calendarWriter) Write(task model.TaskResponse) error {
+	if err := cw.begin(); err != nil {
+		return err
+	}
+	c := taskToComponent(task, cw.component)
+	if c == nil {
+		return nil
+	}
+	return cw.enc.Encode(c)
+}
+
+func (cw *calendarWriter) Close() error {
+	if err := cw.begin(); err != nil {
+		return err
+	}
+	if err := cw.enc.End(ical.VCalendar); err != nil {
+		return err
+	}
+	return cw.enc.Flush()
+}
+
+func taskToComponent(task model.TaskResponse, component string) *ical.Component {
+	c := ical.NewComponent(component)
+	c.Add("UID", taskUID(task.ID))
+	stamp := task.UpdatedAt
+	if stamp.IsZero() {
+		stamp = time.Now()
+	}
+	c.AddDateTime("DTSTAMP", stamp)
+	if !task.CreatedAt.IsZero() {
+		c.AddDateTime("CREATED", task.CreatedAt)
+		c.AddDateTime("LAST-MODIFIED", task.UpdatedAt)
+	}
+	if task.Version > 0 {
+		c.Add("SEQUENCE", strconv.FormatUint(uint64(task.Version-1), 10))
+	}
+	c.AddText("SUMMARY", task.Title)
+	if task.Description != "" {
+		c.AddText("DESCRIPTION", task.Description)
+	}
+	if component == ical.VEvent {
+		// 予定として表示するので、開始日時がない場合は期限の時刻の予定にする。
+		switch {
+		case task.StartAt != nil && task.DueAt != nil:
+			c.AddDateTime("DTSTART", *task.StartAt)
+			c.AddDateTime("DTEND", *task.DueAt)
+		case task.DueAt != nil:
+			c.AddDateTime("DTSTART", *task.DueAt)
+		case task.StartAt != nil:
+			c.AddDateTime("DTSTART", *task.StartAt)
+		default:
+			return nil
+		}
+		status := "CONFIRMED"
+		if task.Status == model.TaskStatusCancelled {
+			status = "CANCELLED"
+		}
+		c.Add("STATUS", status)
+		c.Add("TRANSP", "TRANSPARENT")
+	} else {
+		if task.StartAt != nil {
+			c.AddDateTime("DTSTART", *task.StartAt)
+		}
+		if task.DueAt != nil {
+			c.AddDateTime("DUE", *task.DueAt)
+		}
+		if status, ok := icalStatuses[task.Status]; ok {
+			c.Add("STATUS", status)
+		}
+		if task.CompletedAt != nil {
+			c.AddDateTime("COMPLETED", *task.CompletedAt)
+		}
+		c.Add("PERCENT-COMPLETE", strconv.Itoa(task.Progress))
+	}
+	if task.Recurrence != "" {
+		c.Add("RRULE", task.Recurrence)
+	}
+	if len(task.Labels) > 0 {
+		names := make([]string, 0, len(task.Labels))
+		for _, v := range task.Labels {
+			names = append(names, ical.EscapeText(v.Name))
+		}
+		c.Add("CATEGORIES", strings.Join(names, ","))
+	}
+	if task.ParentId != nil {
+		c.Add("RELATED-TO", taskUID(*task.ParentId), ical.Param{Name: "RELTYPE", Values: []string{"PARENT"}})
+	}
+	return c
+}
+
+func taskUID(id uint) string {
+	return fmt.Sprintf("task-%d%s", id, uidDomain)
+}
+
+// このアプリが書き出したUIDの場合だけ、タスクのidを返す。
+func parseTaskUID(uid string) uint {
+	s := strings.TrimPrefix(strings.TrimSuffix(uid, uidDomain), "task-")
+	if len(s) == len(uid) {
+		return 0
+	}
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
+
+// iCalendarのファイルから、VTODOとVEVENTをタスクとして読み込む。VTIMEZONEなどほかのコンポーネントは読み飛ばす。
+type calendarReader struct {
+	dec *ical.Decoder
+}
+
+func newCalendarReader(r io.Reader) *calendarReader {
+	return &calendarReader{dec: ical.NewDecoder(r)}
+}
+
+func (cr *calendarReader) Read() (Record, error) {
+	for {
+		c, err := cr.dec.Next()
+		if err == io.EOF {
+			return Record{}, io.EOF
+		}
+		if err != nil {
+			var pe *ical.ParseError
+			if errors.As(err, &pe) {
+				return Record{}, &LineError{Line: pe.Line, Err: pe.Err}
+			}
+			return Record{}, err
+		}
+		if c.Name != ical.VTodo && c.Name != ical.VEvent {
+			continue
+		}
+		rec := Record{Line: c.Line}
+		rec.Task, rec.Err = componentToTask(c)
+		return rec, nil
+	}
+}
+
+// タイムゾーンのない日時(フローティング)はUTCとして扱う。
+func componentToTask(c *ical.Component) (model.TaskResponse, error) {
+	task := model.TaskResponse{
+		Status:    model.TaskStatusTodo,
+		Checklist: model.Checklist{},
+		Labels:    []model.LabelResponse{},
+	}
+	if p, ok := c.Get("UID"); ok {
+		task.ID = parseTaskUID(p.Value)
+	}
+	if p, ok := c.Get("SUMMARY"); ok {
+		task.Title = strings.TrimSpace(p.TextValue())
+	}
+	if p, ok := c.Get("DESCRIPTION"); ok {
+		task.Description = p.TextValue()
+	}
+	getTime := func(name string) (*time.Time, error) {
+		p, ok := c.Get(name)
+		if !ok {
+			return nil, nil
+		}
+		t, _, err := ical.ParseDateTime(p, time.UTC)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", p.Line, name, err)
+		}
+		return &t, nil
+	}
+	status, _ := c.Get("STATUS")
+	var err error
+	if c.Name == ical.VEvent {
+		// 予定は、開始日時から終了日時までの作業として取り込む。終了日時がない場合は開始日時を期限にする。
+		var start, end *time.Time
+		if start, err = getTime("DTSTART"); err != nil {
+			return task, err
+		}
+		if end, err = getTime("DTEND"); err != nil {
+			return task, err
+		}
+		if end != nil {
+			task.StartAt, task.DueAt = start, end
+		} else {
+			task.DueAt = start
+		}
+		if strings.EqualFold(status.Value, "CANCELLED") {
+			task.Status = model.TaskStatusCancelled
+		}
+	} else {
+		if task.StartAt, err = getTime("DTSTART"); err != nil {
+			return task, err
+		}
+		if task.DueAt, err = getTime("DUE"); err != nil {
+			return task, err
+		}
+		for k, v := range icalStatuses {
+			if strings.EqualFold(status.Value, v) {
+				task.Status = k
+			}
+		}
+	}
+	if p, ok := c.Get("RRULE"); ok {
+		task.Recurrence = strings.TrimSpace(p.Value)
+	}
+	for _, p := range c.GetAll("CATEGORIES") {
+		for _, name := range ical.SplitText(p.Value) {
+			if name = strings.TrimSpace(name); name != "" {
+				task.Labels = append(task.Labels, model.LabelResponse{Name: name})
+			}
+		}
+	}
+	for _, p := range c.GetAll("RELATED-TO") {
+		reltype := p.Param("RELTYPE")
+		if reltype == "" || strings.EqualFold(reltype, "PARENT") {
+			if id := parseTaskUID(p.Value); id != 0 {
+				task.ParentId = &id
+			}
+		}
+	}
+	return task, nil
+}
@@ -0,0 +1,115 @@
+package taskio
+
+import (
+	"bytes"
+	"errors"
+	"go_api/ical"
+	"go_api/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+// iCalendarで読み書きできる項目だけを残す。日時はUTCで書き出すので、UTCに戻る。
+func icsFields(task model.TaskResponse) model.TaskResponse {
+	task = portableFields(task)
+	task.ProjectId = nil
+	task.Checklist = model.Checklist{}
+	for _, t := range []**time.Time{&task.StartAt, &task.DueAt} {
+		if *t != nil {
+			utc := (*t).UTC()
+			*t = &utc
+		}
+	}
+	return task
+}
+
+func TestICSRoundTrip(t *testing.T) {
+	tasks := sampleTasks()
+	tasks[0].Title = "買い物; 牛乳, 卵 " + strings.Repeat("とても長いタイトル", 10)
+	tasks[0].Labels = append(tasks[0].Labels, model.LabelResponse{Name: "a,b"})
+	out := write(t, FormatICS, tasks)
+	records := readAll(t, FormatICS, out)
+	if len(records) != len(tasks) {
+		t.Fatalf("read %d tasks, want %d\n%s", len(records), len(tasks), out)
+	}
+	if records[0].Line != 6 {
+		t.Errorf("record 0 line = %d, want 6", records[0].Line)
+	}
+	for i, rec := range records {
+		if rec.Err != nil {
+			t.Errorf("record %d error: %v", i, rec.Err)
+		}
+		assertTask(t, rec.Task, icsFields(tasks[i]))
+	}
+}
+
+// 予定として書き出すと、日時のないタスクは書き出さず、開始日時と期限は予定の開始と終了になる。
+func TestICSEventRoundTrip(t *testing.T) {
+	tasks := sampleTasks()
+	due := time.Date(2024, 5, 3, 9, 0, 0, 0, time.UTC)
+	tasks = append(tasks, model.TaskResponse{ID: 8, Title: "中止", Status: model.TaskStatusCancelled, DueAt: &due})
+	var buf bytes.Buffer
+	w := NewCalendarWriter(&buf, ical.VEvent, "仕事")
+	for _, task := range tasks {
+		if err := w.Write(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "X-WR-CALNAME:仕事\r\n") {
+		t.Errorf("calendar name is missing:\n%s", buf.String())
+	}
+	records := readAll(t, FormatICS, buf.String())
+	if len(records) != 2 {
+		t.Fatalf("read %d tasks, want 2\n%s", len(records), buf.String())
+	}
+	want := icsFields(tasks[0])
+	want.Status = model.TaskStatusTodo
+	assertTask(t, records[0].Task, want)
+	assertTask(t, records[1].Task, icsFields(tasks[2]))
+}
+
+// 他のアプリで作ったファイルも読み込む。
+func TestReadICS(t *testing.T) {
+	in := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VTODO",
+		"UID:abc@example.com",
+		"SUMMARY:買い物",
+		"DUE;VALUE=DATE:20240501",
+		"STATUS:COMPLETED",
+		"RELATED-TO:task-3@regondor",
+		"END:VTODO",
+		"BEGIN:VTODO",
+		"SUMMARY:壊れた日時",
+		"DTSTART:tomorrow",
+		"END:VTODO",
+		"END:VCALENDAR",
+	}, "\r\n")
+	records := readAll(t, FormatICS, in)
+	if len(records) != 2 {
+		t.Fatalf("read %d tasks, want 2", len(records))
+	}
+	due := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	parent := uint(3)
+	assertTask(t, records[0].Task, model.TaskResponse{
+		Title:     "買い物",
+		Status:    model.TaskStatusDone,
+		DueAt:     &due,
+		ParentId:  &parent,
+		Checklist: model.Checklist{},
+		Labels:    []model.LabelResponse{},
+	})
+	if rec := records[1]; rec.Line != 9 || rec.Err == nil || !strings.Contains(rec.Err.Error(), "line 11") {
+		t.Errorf("record 1 = line %d, %v, want DTSTART error on line 11", rec.Line, rec.Err)
+	}
+
+	r, _ := NewReader(FormatICS, strings.NewReader("BEGIN:VCALENDAR\nBEGIN:VTODO\nSUMMARY\n"))
+	var le *LineError
+	if _, err := r.Read(); !errors.As(err, &le) || le.Line != 3 {
+		t.Errorf("Read() = %v, want LineError on line 3", err)
+	}
+}
@@ -0,0 +1,114 @@
+package taskio
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go_api/model"
+	"io"
+	"sort"
+)
+
+// タスクの配列を書き出す。1件ごとに書き出すので、件数が多くてもメモリーにためない。
+type jsonWriter struct {
+	w     io.Writer
+	count int
+}
+
+func newJSONWriter(w io.Writer) *jsonWriter {
+	return &jsonWriter{w: w}
+}
+
+func (jw *jsonWriter) Write(task model.TaskResponse) error {
+	b, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	sep := ",\n  "
+	if jw.count == 0 {
+		sep = "[\n  "
+	}
+	jw.count++
+	if _, err := io.WriteString(jw.w, sep); err != nil {
+		return err
+	}
+	_, err = jw.w.Write(b)
+	return err
+}
+
+func (jw *jsonWriter) Close() error {
+	end := "\n]\n"
+	if jw.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(jw.w, end)
+	return err
+}
+
+// タスクの配列を1件ずつ読み込む。
+type jsonReader struct {
+	lines   *lineCounter
+	dec     *json.Decoder
+	started bool
+}
+
+func newJSONReader(r io.Reader) *jsonReader {
+	lines := &lineCounter{r: r}
+	return &jsonReader{lines: lines, dec: json.NewDecoder(lines)}
+}
+
+func (jr *jsonReader) Read() (Record, error) {
+	if !jr.started {
+		jr.started = true
+		tok, err := jr.dec.Token()
+		if err == io.EOF {
+			return Record{}, io.EOF
+		}
+		if err != nil || tok != json.Delim('[') {
+			return Record{}, &LineError{Line: jr.lines.lineAt(jr.dec.InputOffset()), Err: fmt.Errorf("expected an array of tasks")}
+		}
+	}
+	if !jr.dec.More() {
+		if _, err := jr.dec.Token(); err != nil {
+			return Record{}, &LineError{Line: jr.lines.lineAt(jr.dec.InputOffset()), Err: err}
+		}
+		return Record{}, io.EOF
+	}
+	raw := json.RawMessage{}
+	if err := jr.dec.Decode(&raw); err != nil {
+		return Record{}, &LineError{Line: jr.lines.lineAt(jr.dec.InputOffset()), Err: err}
+	}
+	// デコードした後の位置は値の終わりなので、値の中の改行の数だけ戻して始まりの行を求める。
+	rec := Record{Line: jr.lines.lineAt(jr.dec.InputOffset()) - bytes.Count(raw, []byte("\n"))}
+	if err := json.Unmarshal(raw, &rec.Task); err != nil {
+		rec.Err = err
+	}
+	return rec, nil
+}
+
+// 読み込んだバイト数の位置が何行目かを求めるために、改行の位置を記録しながら読み込む。
+// lineAtは前回より後ろの位置でしか呼ばれないので、確認済みの改行は数だけ残して捨てる。
+type lineCounter struct {
+	r        io.Reader
+	read     int64
+	newlines []int64
+	passed   int
+}
+
+func (lc *lineCounter) Read(p []byte) (int, error) {
+	n, err := lc.r.Read(p)
+	for i, b := range p[:n] {
+		if b == '\n' {
+			lc.newlines = append(lc.newlines, lc.read+int64(i))
+		}
+	}
+	lc.read += int64(n)
+	return n, err
+}
+
+func (lc *lineCounter) lineAt(offset int64) int {
+	i := sort.Search(len(lc.newlines), func(i int) bool { return lc.newlines[i] >= offset })
+	lc.passed += i
+	lc.newlines = lc.newlines[i:]
+	return lc.passed + 1
+}
@@ -0,0 +1,222 @@
+package taskio
+
+import (
+	"bufio"
+	"fmt"
+	"go_api/model"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Markdownでは、タスクをGitHub形式のタスクリストで表す。完了したタスクは[x]になる。
+// タスクの行の下に2文字下げて、項目(key: value)、説明文(> から始まる行)、チェックリスト(- [ ] から始まる行)を書く。
+//
+//	# やること
+//	- [ ] 買い物
+//	  due_at: 2024-05-01T18:00:00+09:00
+//	  labels: 家; 急ぎ
+//	  > 牛乳と卵
+//	  - [x] 牛乳
+//	  - [ ] 卵
+//
+// インポートのときは、チェックボックスのない箇条書き(- 買い物)もタスクとして読み込み、見出しや空行は無視する。
+// 字下げされた普通の文章も、説明文として読み込む。
+type markdownWriter struct {
+	w *bufio.Writer
+}
+
+func newMarkdownWriter(w io.Writer) *markdownWriter {
+	return &markdownWriter{w: bufio.NewWriter(w)}
+}
+
+func (mw *markdownWriter) Write(task model.TaskResponse) error {
+	check := " "
+	if task.Status == model.TaskStatusDone {
+		check = "x"
+	}
+	fmt.Fprintf(mw.w, "- [%s] %s\n", check, oneLine(task.Title))
+	if task.ID != 0 {
+		fmt.Fprintf(mw.w, "  id: %d\n", task.ID)
+	}
+	// 状態がチェックボックスで表せない場合だけ、ステータスを書く。
+	if task.Status != model.TaskStatusTodo && task.Status != model.TaskStatusDone {
+		fmt.Fprintf(mw.w, "  status: %s\n", task.Status)
+	}
+	fields := [][2]string{
+		{"start_at", formatTime(task.StartAt)},
+		{"due_at", formatTime(task.DueAt)},
+		{"recurrence", task.Recurrence},
+		{"project_id", formatId(task.ProjectId)},
+		{"parent_id", formatId(task.ParentId)},
+		{"labels", joinLabels(task.Labels)},
+	}
+	for _, f := range fields {
+		if f[1] != "" {
+			fmt.Fprintf(mw.w, "  %s: %s\n", f[0], f[1])
+		}
+	}
+	if task.Description != "" {
+		for _, line := range strings.Split(task.Description, "\n") {
+			fmt.Fprintf(mw.w, "  > %s\n", line)
+		}
+	}
+	for _, item := range task.Checklist {
+		check := " "
+		if item.Done {
+			check = "x"
+		}
+		fmt.Fprintf(mw.w, "  - [%s] %s\n", check, oneLine(item.Text))
+	}
+	return mw.w.Flush()
+}
+
+func (mw *markdownWriter) Close() error {
+	return mw.w.Flush()
+}
+
+// タイトルなどに改行が含まれていると次の行が別の項目として読まれてしまうので、空白に置き換える。
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+var (
+	markdownTaskPattern    = regexp.MustCompile(`^[-*+] +(?:\[([ xX])\] +)?(.*)$`)
+	markdownFieldPattern   = regexp.MustCompile(`^([a-z_]+): *(.*)$`)
+	markdownChecklistEntry = regexp.MustCompile(`^[-*+] +\[([ xX])\] +(.*)$`)
+)
+
+type markdownReader struct {
+	scanner *bufio.Scanner
+	line    int
+	// 次のタスクの行まで読んでしまった場合に、その行を取っておく。
+	pending *markdownLine
+}
+
+type markdownLine struct {
+	number int
+	text   string
+}
+
+func newMarkdownReader(r io.Reader) *markdownReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	return &markdownReader{scanner: scanner}
+}
+
+func (mr *markdownReader) next() (*markdownLine, error) {
+	if mr.pending != nil {
+		l := mr.pending
+		mr.pending = nil
+		return l, nil
+	}
+	if !mr.scanner.Scan() {
+		if err := mr.scanner.Err(); err != nil {
+			return nil, &LineError{Line: mr.line + 1, Err: err}
+		}
+		return nil, io.EOF
+	}
+	mr.line++
+	return &markdownLine{number: mr.line, text: strings.TrimRight(mr.scanner.Text(), " \t\r")}, nil
+}
+
+func (mr *markdownReader) Read() (Record, error) {
+	// タスクの行(字下げのない箇条書き)まで読み飛ばす。
+	var rec Record
+	for {
+		l, err := mr.next()
+		if err != nil {
+			return Record{}, err
+		}
+		if isIndented(l.text) {
+			continue
+		}
+		m := markdownTaskPattern.FindStringSubmatch(l.text)
+		if m == nil {
+			continue
+		}
+		rec = Record{Line: l.number, Task: model.TaskResponse{
+			Title:     strings.TrimSpace(m[2]),
+			Status:    model.TaskStatusTodo,
+			Checklist: model.Checklist{},
+			Labels:    []model.LabelResponse{},
+		}}
+		if m[1] == "x" || m[1] == "X" {
+			rec.Task.Status = model.TaskStatusDone
+		}
+		break
+	}
+	// 字下げされている行を、このタスクの内容として読み込む。
+	description := []string{}
+	for {
+		l, err := mr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Record{}, err
+		}
+		if l.text == "" {
+			continue
+		}
+		if !isIndented(l.text) {
+			mr.pending = l
+			break
+		}
+		text := strings.TrimSpace(l.text)
+		if strings.HasPrefix(text, ">") {
+			description = append(description, strings.TrimPrefix(strings.TrimPrefix(text, ">"), " "))
+			continue
+		}
+		if m := markdownChecklistEntry.FindStringSubmatch(text); m != nil {
+			rec.Task.Checklist = append(rec.Task.Checklist, model.ChecklistItem{Text: strings.TrimSpace(m[2]), Done: m[1] != " "})
+			continue
+		}
+		// 項目名として知らないものや、> のない文章は、他のツールで書いたメモとみなして説明文に入れる。
+		m := markdownFieldPattern.FindStringSubmatch(text)
+		if m == nil || !markdownFields[m[1]] {
+			description = append(description, text)
+			continue
+		}
+		if err := setMarkdownField(&rec.Task, m[1], m[2]); err != nil && rec.Err == nil {
+			rec.Err = fmt.Errorf("line %d: %s: %w", l.number, m[1], err)
+		}
+	}
+	rec.Task.Description = strings.Join(description, "\n")
+	return rec, nil
+}
+
+// タスクの行の下に書ける項目。
+var markdownFields = map[string]bool{
+	"id": true, "status": true, "start_at": true, "due_at": true,
+	"recurrence": true, "project_id": true, "parent_id": true, "labels": true,
+}
+
+func setMarkdownField(task *model.TaskResponse, key string, value string) error {
+	var err error
+	switch key {
+	case "id":
+		task.ID, err = parseUint(value)
+	case "status":
+		task.Status = strings.TrimSpace(value)
+	case "start_at":
+		task.StartAt, err = parseTime(value)
+	case "due_at":
+		task.DueAt, err = parseTime(value)
+	case "recurrence":
+		task.Recurrence = strings.TrimSpace(value)
+	case "project_id":
+		task.ProjectId, err = parseId(value)
+	case "parent_id":
+		task.ParentId, err = parseId(value)
+	case "labels":
+		task.Labels = splitLabels(value)
+	default:
+		err = fmt.Errorf("unknown field")
+	}
+	return err
+}
+
+func isIndented(s string) bool {
+	return strings.HasPrefix(s, " ") || strings.HasPrefix(s, "\t")
+}
@@ -0,0 +1,154 @@
+// タスクのインポート・エクスポートに使うファイル形式(JSON、CSV、Markdown、iCalendar)を読み書きするパッケージ
+// どの形式もmodel.TaskResponseの形を基準にしているので、エクスポートしたファイルはそのままインポートできる。
+package taskio
+
+import (
+	"fmt"
+	"go_api/ical"
+	"go_api/model"
+	"io"
+	"strings"
+	"time"
+)
+
+// ファイル形式。クエリパラメーターのformatで指定する。icsはiCalendar(VTODO)。
+const (
+	FormatJSON     = "json"
+	FormatCSV      = "csv"
+	FormatMarkdown = "md"
+	FormatICS      = "ics"
+)
+
+var Formats = []interface{}{FormatJSON, FormatCSV, FormatMarkdown, FormatICS}
+
+// 形式ごとのContent-Typeとファイルの拡張子。
+var contentTypes = map[string]string{
+	FormatJSON:     "application/json; charset=UTF-8",
+	FormatCSV:      "text/csv; charset=UTF-8",
+	FormatMarkdown: "text/markdown; charset=UTF-8",
+	FormatICS:      "text/calendar; charset=UTF-8",
+}
+
+func ContentType(format string) string {
+	return contentTypes[format]
+}
+
+// Content-Typeからファイル形式を判定する。判定できない場合は空文字を返す。
+func FormatFromContentType(contentType string) string {
+	mediaType := strings.TrimSpace(strings.Split(contentType, ";")[0])
+	switch mediaType {
+	case "application/json":
+		return FormatJSON
+	case "text/csv":
+		return FormatCSV
+	case "text/markdown", "text/x-markdown":
+		return FormatMarkdown
+	case "text/calendar":
+		return FormatICS
+	}
+	return ""
+}
+
+// タスクを1件ずつ書き出す。最後に必ずCloseを呼ぶ。(JSONの配列の閉じかっこなどを書き出す)
+type Writer interface {
+	Write(task model.TaskResponse) error
+	Close() error
+}
+
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatJSON:
+		return newJSONWriter(w), nil
+	case FormatCSV:
+		return newCSVWriter(w), nil
+	case FormatMarkdown:
+		return newMarkdownWriter(w), nil
+	case FormatICS:
+		return NewCalendarWriter(w, ical.VTodo, ""), nil
+	}
+	return nil, fmt.Errorf("unknown format: %s", format)
+}
+
+// 読み込んだタスク1件分。Lineはファイルの何行目から始まっているか(1始まり)。
+// 日時の形式が正しくないなど、そのタスクだけの問題はErrに入れ、続きのタスクは読み込める。
+type Record struct {
+	Line int
+	Task model.TaskResponse
+	Err  error
+}
+
+// タスクを1件ずつ読み込む。最後まで読んだらio.EOFを返す。
+// ファイルの構文が壊れていて続きを読めない場合は、*LineErrorを返す。
+type Reader interface {
+	Read() (Record, error)
+}
+
+func NewReader(format string, r io.Reader) (Reader, error) {
+	switch format {
+	case FormatJSON:
+		return newJSONReader(r), nil
+	case FormatCSV:
+		return newCSVReader(r), nil
+	case FormatMarkdown:
+		return newMarkdownReader(r), nil
+	case FormatICS:
+		return newCalendarReader(r), nil
+	}
+	return nil, fmt.Errorf("unknown format: %s", format)
+}
+
+// 何行目で起きたエラーかを持つエラー。
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// 日時はRFC3339で書き出す。読み込むときは日付だけ(YYYY-MM-DD、UTCの0時)も受け付ける。
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func parseTime(s string) (*time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time %q", s)
+	}
+	return &t, nil
+}
+
+// CSVとMarkdownでは、ラベルを名前のセミコロン区切り(例: 仕事; 急ぎ)で表す。
+func joinLabels(labels []model.LabelResponse) string {
+	names := make([]string, 0, len(labels))
+	for _, v := range labels {
+		names = append(names, v.Name)
+	}
+	return strings.Join(names, "; ")
+}
+
+func splitLabels(s string) []model.LabelResponse {
+	labels := []model.LabelResponse{}
+	for _, name := range strings.Split(s, ";") {
+		if name = strings.TrimSpace(name); name != "" {
+			labels = append(labels, model.LabelResponse{Name: name})
+		}
+	}
+	return labels
+}
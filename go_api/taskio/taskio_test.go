@@ -0,0 +1,305 @@
+package taskio
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"go_api/model"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sampleTasks() []model.TaskResponse {
+	jst := time.FixedZone("JST", 9*60*60)
+	at := func(d int, h int) *time.Time {
+		t := time.Date(2024, 5, d, h, 0, 0, 0, jst)
+		return &t
+	}
+	id := func(n uint) *uint {
+		return &n
+	}
+	return []model.TaskResponse{
+		{
+			ID:          1,
+			Title:       `買い物, "急ぎ"`,
+			Description: "牛乳と卵\n2行目",
+			Status:      model.TaskStatusInProgress,
+			StartAt:     at(1, 9),
+			DueAt:       at(1, 18),
+			CreatorId:   2,
+			ProjectId:   id(3),
+			Checklist:   model.Checklist{{Text: "牛乳", Done: true}, {Text: "卵"}},
+			Recurrence:  "FREQ=WEEKLY;BYDAY=MO",
+			Position:    "V",
+			Version:     4,
+			Labels:      []model.LabelResponse{{ID: 5, Name: "家"}, {ID: 6, Name: "急ぎ"}},
+			CreatedAt:   *at(1, 8),
+			UpdatedAt:   *at(1, 10),
+		},
+		{
+			ID:          7,
+			Title:       "完了したサブタスク",
+			Status:      model.TaskStatusDone,
+			CompletedAt: at(2, 12),
+			CreatorId:   2,
+			ParentId:    id(1),
+			Checklist:   model.Checklist{},
+			Position:    "W",
+			Version:     1,
+			Labels:      []model.LabelResponse{},
+			CreatedAt:   *at(2, 8),
+			UpdatedAt:   *at(2, 12),
+		},
+	}
+}
+
+// CSVとMarkdownで読み書きできる項目だけを残す。ラベルは名前だけになる。
+func portableFields(task model.TaskResponse) model.TaskResponse {
+	labels := []model.LabelResponse{}
+	for _, v := range task.Labels {
+		labels = append(labels, model.LabelResponse{Name: v.Name})
+	}
+	return model.TaskResponse{
+		ID:          task.ID,
+		Title:       task.Title,
+		Description: task.Description,
+		Status:      task.Status,
+		StartAt:     task.StartAt,
+		DueAt:       task.DueAt,
+		ProjectId:   task.ProjectId,
+		ParentId:    task.ParentId,
+		Checklist:   task.Checklist,
+		Recurrence:  task.Recurrence,
+		Labels:      labels,
+	}
+}
+
+func write(t *testing.T, format string, tasks []model.TaskResponse) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(format, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, task := range tasks {
+		if err := w.Write(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func readAll(t *testing.T, format string, s string) []Record {
+	t.Helper()
+	r, err := NewReader(format, strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := []Record{}
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return records
+		}
+		if err != nil {
+			t.Fatalf("Read() error: %v\n%s", err, s)
+		}
+		records = append(records, rec)
+	}
+}
+
+// 日時のタイムゾーンの違いなども比べられるように、JSONにして比べる。
+func assertTask(t *testing.T, got model.TaskResponse, want model.TaskResponse) {
+	t.Helper()
+	g, _ := json.Marshal(got)
+	w, _ := json.Marshal(want)
+	if !bytes.Equal(g, w) {
+		t.Errorf("task = %s, want %s", g, w)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		format string
+		want   func(model.TaskResponse) model.TaskResponse
+		lines  []int
+	}{
+		{FormatJSON, func(task model.TaskResponse) model.TaskResponse { return task }, []int{2, 3}},
+		{FormatCSV, portableFields, []int{2, 4}},
+		{FormatMarkdown, portableFields, []int{1, 13}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			tasks := sampleTasks()
+			out := write(t, tt.format, tasks)
+			records := readAll(t, tt.format, out)
+			if len(records) != len(tasks) {
+				t.Fatalf("read %d tasks, want %d\n%s", len(records), len(tasks), out)
+			}
+			for i, rec := range records {
+				if rec.Err != nil {
+					t.Errorf("record %d error: %v", i, rec.Err)
+				}
+				if rec.Line != tt.lines[i] {
+					t.Errorf("record %d line = %d, want %d", i, rec.Line, tt.lines[i])
+				}
+				assertTask(t, rec.Task, tt.want(tasks[i]))
+			}
+		})
+	}
+}
+
+// タスクが1件もなくても、読み込める形で書き出す。
+func TestRoundTripEmpty(t *testing.T) {
+	for _, format := range []string{FormatJSON, FormatCSV, FormatMarkdown} {
+		out := write(t, format, nil)
+		if records := readAll(t, format, out); len(records) != 0 {
+			t.Errorf("%s: read %d tasks from %q, want none", format, len(records), out)
+		}
+	}
+}
+
+func TestReadJSONErrors(t *testing.T) {
+	if _, err := NewReader("xml", strings.NewReader("")); err == nil {
+		t.Error("NewReader(xml) want error")
+	}
+
+	r, _ := NewReader(FormatJSON, strings.NewReader("\n{\"title\": \"a\"}"))
+	var le *LineError
+	if _, err := r.Read(); !errors.As(err, &le) || le.Line != 2 {
+		t.Errorf("Read() of an object = %v, want LineError on line 2", err)
+	}
+
+	// 型が違うタスクはそのタスクだけエラーになり、続きは読める。
+	records := readAll(t, FormatJSON, "[\n{\"title\": 1},\n{\"title\": \"b\"}\n]")
+	if len(records) != 2 {
+		t.Fatalf("read %d tasks, want 2", len(records))
+	}
+	if records[0].Err == nil || records[0].Line != 2 {
+		t.Errorf("record 0 = line %d, %v, want error on line 2", records[0].Line, records[0].Err)
+	}
+	if records[1].Err != nil || records[1].Task.Title != "b" || records[1].Line != 3 {
+		t.Errorf("record 1 = line %d, %q, %v, want b on line 3", records[1].Line, records[1].Task.Title, records[1].Err)
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	// 列の順番が違っても、BOMや大文字の列名があっても読める。
+	in := "\ufeffStatus,Title,due_at\ndone,a,2024-05-01\ntodo,b\nin_progress,c,tomorrow\n"
+	records := readAll(t, FormatCSV, in)
+	if len(records) != 3 {
+		t.Fatalf("read %d tasks, want 3", len(records))
+	}
+	due := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	if rec := records[0]; rec.Err != nil || rec.Line != 2 {
+		t.Errorf("record 0 = line %d, %v", rec.Line, rec.Err)
+	} else {
+		assertTask(t, rec.Task, model.TaskResponse{Title: "a", Status: "done", DueAt: &due, Checklist: model.Checklist{}, Labels: []model.LabelResponse{}})
+	}
+	if rec := records[1]; rec.Err == nil || rec.Line != 3 {
+		t.Errorf("record 1 = line %d, %v, want wrong number of fields on line 3", rec.Line, rec.Err)
+	}
+	if rec := records[2]; rec.Err == nil || !strings.Contains(rec.Err.Error(), "due_at") || rec.Line != 4 {
+		t.Errorf("record 2 = line %d, %v, want due_at error on line 4", rec.Line, rec.Err)
+	}
+}
+
+func TestReadCSVErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		line int
+	}{
+		{"no title column", "id,status\n1,done\n", 1},
+		{"broken quote", "title\n\"a\n", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := NewReader(FormatCSV, strings.NewReader(tt.in))
+			var err error
+			for err == nil {
+				_, err = r.Read()
+			}
+			var le *LineError
+			if !errors.As(err, &le) || le.Line != tt.line {
+				t.Errorf("Read() = %v, want LineError on line %d", err, tt.line)
+			}
+		})
+	}
+}
+
+// 他のツールで書いたMarkdownも読み込める。
+func TestReadMarkdown(t *testing.T) {
+	in := strings.Join([]string{
+		"# やること",
+		"",
+		"- 買い物",
+		"  牛乳と卵",
+		"  memo: 忘れずに",
+		"  - [X] 牛乳",
+		"* [x] 掃除",
+		"  due_at: 2024-05-01",
+		"  id: abc",
+		"",
+		"段落は無視する",
+	}, "\n")
+	records := readAll(t, FormatMarkdown, in)
+	if len(records) != 2 {
+		t.Fatalf("read %d tasks, want 2", len(records))
+	}
+	assertTask(t, records[0].Task, model.TaskResponse{
+		Title:       "買い物",
+		Description: "牛乳と卵\nmemo: 忘れずに",
+		Status:      model.TaskStatusTodo,
+		Checklist:   model.Checklist{{Text: "牛乳", Done: true}},
+		Labels:      []model.LabelResponse{},
+	})
+	if records[0].Line != 3 || records[0].Err != nil {
+		t.Errorf("record 0 = line %d, %v", records[0].Line, records[0].Err)
+	}
+	if rec := records[1]; rec.Line != 7 || rec.Task.Status != model.TaskStatusDone || rec.Err == nil || !strings.Contains(rec.Err.Error(), "line 9") {
+		t.Errorf("record 1 = line %d, %q, %v, want done with an id error on line 9", rec.Line, rec.Task.Status, rec.Err)
+	}
+}
+
+// タイトルやチェックリストの改行は、次の行が別の項目として読まれないように空白にする。
+func TestWriteMarkdownOneLine(t *testing.T) {
+	task := model.TaskResponse{
+		Title:     "1行目\n- [ ] 2行目",
+		Status:    model.TaskStatusTodo,
+		Checklist: model.Checklist{{Text: "a\nid: 3"}},
+	}
+	records := readAll(t, FormatMarkdown, write(t, FormatMarkdown, []model.TaskResponse{task}))
+	if len(records) != 1 {
+		t.Fatalf("read %d tasks, want 1", len(records))
+	}
+	if got := records[0].Task; got.Title != "1行目 - [ ] 2行目" || got.ID != 0 || len(got.Checklist) != 1 || got.Checklist[0].Text != "a id: 3" {
+		t.Errorf("task = %+v", got)
+	}
+}
+
+func TestFormatFromContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        string
+	}{
+		{"application/json", FormatJSON},
+		{"application/json; charset=UTF-8", FormatJSON},
+		{"text/csv", FormatCSV},
+		{" text/markdown ;charset=utf-8", FormatMarkdown},
+		{"text/x-markdown", FormatMarkdown},
+		{"text/calendar", FormatICS},
+		{"text/plain", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := FormatFromContentType(tt.contentType); got != tt.want {
+			t.Errorf("FormatFromContentType(%q) = %q, want %q", tt.contentType, got, tt.want)
+		}
+	}
+}
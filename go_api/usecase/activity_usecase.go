@@ -0,0 +1,77 @@
+package usecase
+
+import (
+	"fmt"
+	"go_api/model"
+	"go_api/repository"
+)
+
+type IActivityUsecase interface {
+	GetTaskHistory(userId uint, taskId uint) ([]model.ActivityResponse, error)
+	GetActivities(userId uint, before uint, limit int) (model.ActivityListResponse, error)
+	// workspaceIdのワークスペースを扱うユースケースを返す。
+	InWorkspace(workspaceId uint) IActivityUsecase
+}
+
+type activityUsecase struct {
+	ar repository.IActivityRepository
+}
+
+func NewActivityUsecase(ar repository.IActivityRepository) IActivityUsecase {
+	return &activityUsecase{ar}
+}
+
+func (au *activityUsecase) InWorkspace(workspaceId uint) IActivityUsecase {
+	return &activityUsecase{au.ar.InWorkspace(workspaceId)}
+}
+
+func toActivityResponse(activity model.Activity) model.ActivityResponse {
+	return model.ActivityResponse{
+		ID:        activity.ID,
+		Action:    activity.Action,
+		TaskId:    activity.TaskId,
+		TaskTitle: activity.TaskTitle,
+		Changes:   activity.Changes,
+		UserId:    activity.UserId,
+		UserName:  activity.User.Name,
+		CreatedAt: activity.CreatedAt,
+	}
+}
+
+// タスクの変更履歴を、新しい順に返す。
+func (au *activityUsecase) GetTaskHistory(userId uint, taskId uint) ([]model.ActivityResponse, error) {
+	activities := []model.Activity{}
+	if err := au.ar.GetTaskActivities(&activities, userId, taskId); err != nil {
+		return nil, err
+	}
+	resActivities := []model.ActivityResponse{}
+	for _, v := range activities {
+		resActivities = append(resActivities, toActivityResponse(v))
+	}
+	return resActivities, nil
+}
+
+// ユーザー全体のアクティビティを、新しい順にlimit件ずつ返す。
+// 次のページがあるかを調べるために、1件多く取得する。
+func (au *activityUsecase) GetActivities(userId uint, before uint, limit int) (model.ActivityListResponse, error) {
+	if limit == 0 {
+		limit = model.DefaultActivityLimit
+	}
+	if limit < 1 || limit > model.MaxActivityLimit {
+		return model.ActivityListResponse{}, fmt.Errorf("limit must be between 1 and %d", model.MaxActivityLimit)
+	}
+	activities := []model.Activity{}
+	if err := au.ar.GetActivities(&activities, userId, before, limit+1); err != nil {
+		return model.ActivityListResponse{}, err
+	}
+	res := model.ActivityListResponse{Activities: []model.ActivityResponse{}}
+	if len(activities) > limit {
+		activities = activities[:limit]
+		next := activities[limit-1].ID
+		res.NextBefore = &next
+	}
+	for _, v := range activities {
+		res.Activities = append(res.Activities, toActivityResponse(v))
+	}
+	return res, nil
+}
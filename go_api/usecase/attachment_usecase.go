@@ -0,0 +1,170 @@
+package usecase
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"go_api/model"
+	"go_api/repository"
+	"go_api/storage"
+	"go_api/validator"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+type IAttachmentUsecase interface {
+	GetAttachments(userId uint, taskId uint) ([]model.AttachmentResponse, error)
+	CreateAttachment(attachment model.Attachment, declaredType string, r io.Reader) (model.AttachmentResponse, error)
+	OpenAttachment(userId uint, taskId uint, attachmentId uint) (model.AttachmentResponse, io.ReadCloser, error)
+	DeleteAttachment(userId uint, taskId uint, attachmentId uint) error
+	// workspaceIdのワークスペースを扱うユースケースを返す。
+	InWorkspace(workspaceId uint) IAttachmentUsecase
+}
+
+type attachmentUsecase struct {
+	ar repository.IAttachmentRepository
+	tr repository.ITaskRepository
+	av validator.IAttachmentValidator
+	st storage.Storage
+}
+
+func NewAttachmentUsecase(ar repository.IAttachmentRepository, tr repository.ITaskRepository, av validator.IAttachmentValidator, st storage.Storage) IAttachmentUsecase {
+	return &attachmentUsecase{ar, tr, av, st}
+}
+
+func (au *attachmentUsecase) InWorkspace(workspaceId uint) IAttachmentUsecase {
+	return &attachmentUsecase{au.ar.InWorkspace(workspaceId), au.tr.InWorkspace(workspaceId), au.av, au.st}
+}
+
+func toAttachmentResponse(attachment model.Attachment) model.AttachmentResponse {
+	return model.AttachmentResponse{
+		ID:          attachment.ID,
+		FileName:    attachment.FileName,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		TaskId:      attachment.TaskId,
+		CreatedAt:   attachment.CreatedAt,
+	}
+}
+
+// 削除済み(ゴミ箱)のタスクや、アクセスできないタスクの添付ファイルは扱えないようにする。
+// 共有しているプロジェクトのタスクに添付ファイルを追加・削除するには、編集者以上の権限が必要になる。
+func (au *attachmentUsecase) checkTask(userId uint, taskId uint, role string) error {
+	task := model.Task{}
+	if err := au.tr.GetTaskById(&task, userId, taskId); err != nil {
+		return err
+	}
+	return checkTaskRole(au.tr, userId, taskId, role)
+}
+
+func (au *attachmentUsecase) GetAttachments(userId uint, taskId uint) ([]model.AttachmentResponse, error) {
+	if err := au.checkTask(userId, taskId, model.ProjectRoleViewer); err != nil {
+		return nil, err
+	}
+	attachments := []model.Attachment{}
+	if err := au.ar.GetAttachmentsByTaskId(&attachments, userId, taskId); err != nil {
+		return nil, err
+	}
+	resAttachments := []model.AttachmentResponse{}
+	for _, v := range attachments {
+		resAttachments = append(resAttachments, toAttachmentResponse(v))
+	}
+	return resAttachments, nil
+}
+
+// ファイルの種類を判定するときに読む、ファイルの先頭のバイト数。(http.DetectContentTypeが使うのは512バイトまで)
+const sniffLen = 512
+
+// ファイルの種類は、クライアントが送ってきたContent-Typeを信用せず、中身から判定する。
+// 中身からはテキストやzipとしか判定できない形式(CSVやWord)の場合だけ、送られてきた種類を使う。
+func attachmentContentType(declaredType string, head []byte) string {
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	declared, _, _ := mime.ParseMediaType(declaredType)
+	switch {
+	case sniffed == "text/plain" && strings.HasPrefix(declared, "text/"):
+		return declared
+	case sniffed == "application/zip" && strings.HasPrefix(declared, "application/vnd.openxmlformats-officedocument."):
+		return declared
+	}
+	return sniffed
+}
+
+// 保存先のキー。ファイル名は含めず、推測されないランダムな文字列にする。
+func newAttachmentKey(taskId uint) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("tasks/%d/%s", taskId, hex.EncodeToString(b)), nil
+}
+
+// ファイルを保存してから、メタデータを作成する。メタデータの作成に失敗した場合は、保存したファイルを削除する。
+// attachmentのSizeには、rから読めるバイト数を入れておく。
+func (au *attachmentUsecase) CreateAttachment(attachment model.Attachment, declaredType string, r io.Reader) (model.AttachmentResponse, error) {
+	if err := au.checkTask(attachment.UserId, attachment.TaskId, model.ProjectRoleEditor); err != nil {
+		return model.AttachmentResponse{}, err
+	}
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return model.AttachmentResponse{}, err
+	}
+	head = head[:n]
+	// ファイル名はパスを除いた部分だけにする。
+	attachment.FileName = filepath.Base(strings.ReplaceAll(attachment.FileName, "\\", "/"))
+	attachment.ContentType = attachmentContentType(declaredType, head)
+	if err := au.av.AttachmentValidate(attachment); err != nil {
+		return model.AttachmentResponse{}, err
+	}
+	key, err := newAttachmentKey(attachment.TaskId)
+	if err != nil {
+		return model.AttachmentResponse{}, err
+	}
+	if err := au.st.Put(key, io.MultiReader(bytes.NewReader(head), r), attachment.Size, attachment.ContentType); err != nil {
+		return model.AttachmentResponse{}, err
+	}
+	attachment.StorageKey = key
+	if err := au.ar.CreateAttachment(&attachment); err != nil {
+		au.st.Delete(key)
+		return model.AttachmentResponse{}, err
+	}
+	return toAttachmentResponse(attachment), nil
+}
+
+// 添付ファイルのメタデータと中身を返す。中身は読み終わったら必ずCloseする。
+func (au *attachmentUsecase) OpenAttachment(userId uint, taskId uint, attachmentId uint) (model.AttachmentResponse, io.ReadCloser, error) {
+	if err := au.checkTask(userId, taskId, model.ProjectRoleViewer); err != nil {
+		return model.AttachmentResponse{}, nil, err
+	}
+	attachment := model.Attachment{}
+	if err := au.ar.GetAttachmentById(&attachment, userId, taskId, attachmentId); err != nil {
+		return model.AttachmentResponse{}, nil, err
+	}
+	body, err := au.st.Get(attachment.StorageKey)
+	if err != nil {
+		return model.AttachmentResponse{}, nil, err
+	}
+	return toAttachmentResponse(attachment), body, nil
+}
+
+// メタデータを削除してから、ファイルの中身を削除する。
+func (au *attachmentUsecase) DeleteAttachment(userId uint, taskId uint, attachmentId uint) error {
+	if err := au.checkTask(userId, taskId, model.ProjectRoleEditor); err != nil {
+		return err
+	}
+	attachment := model.Attachment{}
+	if err := au.ar.GetAttachmentById(&attachment, userId, taskId, attachmentId); err != nil {
+		return err
+	}
+	if err := au.ar.DeleteAttachment(&attachment); err != nil {
+		return err
+	}
+	if err := au.st.Delete(attachment.StorageKey); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"go_api/model"
+	"go_api/repository"
+	"go_api/validator"
+
+	"gorm.io/gorm"
+)
+
+type IBoardUsecase interface {
+	GetBoards(userId uint) ([]model.BoardResponse, error)
+	// ボードを、列とそれぞれの列のカードを入れて返す。
+	GetBoardById(userId uint, boardId uint) (model.BoardResponse, error)
+	// 列を指定しない場合は、todo・in_progress・doneの3つの列を作る。
+	CreateBoard(board model.Board) (model.BoardResponse, error)
+	UpdateBoard(board model.Board, userId uint, boardId uint) (model.BoardResponse, error)
+	DeleteBoard(userId uint, boardId uint) error
+	// 列をボードの末尾に追加する。
+	CreateColumn(column model.BoardColumn, userId uint, boardId uint) (model.BoardColumnResponse, error)
+	UpdateColumn(column model.BoardColumn, userId uint, boardId uint, columnId uint) (model.BoardColumnResponse, error)
+	DeleteColumn(userId uint, boardId uint, columnId uint) error
+	PlaceCard(move model.BoardCardMove, userId uint, boardId uint, taskId uint) (model.BoardCardResponse, error)
+	RemoveCard(userId uint, boardId uint, taskId uint) error
+	// workspaceIdのワークスペースを扱うユースケースを返す。
+	InWorkspace(workspaceId uint) IBoardUsecase
+}
+
+type boardUsecase struct {
+	br repository.IBoardRepository
+	tu ITaskUsecase
+	bv validator.IBoardValidator
+}
+
+// カードを動かすとタスクのステータスも変わることがあるので、カードの操作はタスクのユースケースに任せる。
+func NewBoardUsecase(br repository.IBoardRepository, tu ITaskUsecase, bv validator.IBoardValidator) IBoardUsecase {
+	return &boardUsecase{br, tu, bv}
+}
+
+func (bu *boardUsecase) InWorkspace(workspaceId uint) IBoardUsecase {
+	return &boardUsecase{bu.br.InWorkspace(workspaceId), bu.tu.InWorkspace(workspaceId), bu.bv}
+}
+
+// 列を指定せずに作成したボードの列。
+var defaultBoardColumns = []model.BoardColumn{
+	{Name: "To Do", Status: model.TaskStatusTodo},
+	{Name: "In Progress", Status: model.TaskStatusInProgress},
+	{Name: "Done", Status: model.TaskStatusDone},
+}
+
+func toBoardResponse(board model.Board) model.BoardResponse {
+	return model.BoardResponse{
+		ID:        board.ID,
+		Name:      board.Name,
+		UserId:    board.UserId,
+		CreatedAt: board.CreatedAt,
+		UpdatedAt: board.UpdatedAt,
+	}
+}
+
+func toBoardColumnResponse(column model.BoardColumn) model.BoardColumnResponse {
+	return model.BoardColumnResponse{
+		ID:       column.ID,
+		Name:     column.Name,
+		Status:   column.Status,
+		WipLimit: column.WipLimit,
+		Position: column.Position,
+		Cards:    []model.BoardCardResponse{},
+	}
+}
+
+// ボードが見つからない場合のエラーを、ほかのリソースと同じメッセージにそろえる。
+func (bu *boardUsecase) getBoard(board *model.Board, userId uint, boardId uint) error {
+	if err := bu.br.GetBoardById(board, userId, boardId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("object does not exist")
+		}
+		return err
+	}
+	return nil
+}
+
+func (bu *boardUsecase) GetBoards(userId uint) ([]model.BoardResponse, error) {
+	boards := []model.Board{}
+	if err := bu.br.GetBoards(&boards, userId); err != nil {
+		return nil, err
+	}
+	resBoards := []model.BoardResponse{}
+	for _, v := range boards {
+		resBoards = append(resBoards, toBoardResponse(v))
+	}
+	return resBoards, nil
+}
+
+// カードは見ることができるタスクのものだけを返すが、card_countにはWIP制限と比べられるように、すべてのカードを数える。
+func (bu *boardUsecase) GetBoardById(userId uint, boardId uint) (model.BoardResponse, error) {
+	board := model.Board{}
+	if err := bu.getBoard(&board, userId, boardId); err != nil {
+		return model.BoardResponse{}, err
+	}
+	cards := []model.BoardCard{}
+	if err := bu.br.GetCards(&cards, userId, boardId); err != nil {
+		return model.BoardResponse{}, err
+	}
+	counts := []model.BoardColumnCount{}
+	if err := bu.br.CountCards(&counts, boardId); err != nil {
+		return model.BoardResponse{}, err
+	}
+	countByColumn := map[uint]int{}
+	for _, v := range counts {
+		countByColumn[v.ColumnId] = v.Count
+	}
+	cardsByColumn := map[uint][]model.BoardCardResponse{}
+	for _, v := range cards {
+		cardsByColumn[v.ColumnId] = append(cardsByColumn[v.ColumnId], model.BoardCardResponse{
+			TaskId:   v.TaskId,
+			ColumnId: v.ColumnId,
+			Position: v.Position,
+			Task:     toTaskResponse(v.Task),
+		})
+	}
+	res := toBoardResponse(board)
+	res.Columns = []model.BoardColumnResponse{}
+	for _, v := range board.Columns {
+		column := toBoardColumnResponse(v)
+		column.CardCount = countByColumn[v.ID]
+		if cards, ok := cardsByColumn[v.ID]; ok {
+			column.Cards = cards
+		}
+		res.Columns = append(res.Columns, column)
+	}
+	return res, nil
+}
+
+func (bu *boardUsecase) CreateBoard(board model.Board) (model.BoardResponse, error) {
+	if len(board.Columns) == 0 {
+		board.Columns = append([]model.BoardColumn{}, defaultBoardColumns...)
+	}
+	if err := bu.bv.BoardValidate(board); err != nil {
+		return model.BoardResponse{}, err
+	}
+	// 列は送られてきた順番に並べる。
+	for i := range board.Columns {
+		board.Columns[i].ID = 0
+		board.Columns[i].Position = i
+	}
+	if err := bu.br.CreateBoard(&board); err != nil {
+		return model.BoardResponse{}, err
+	}
+	return bu.GetBoardById(board.UserId, board.ID)
+}
+
+// 名前だけを変える。列は列のエンドポイントで変える。
+func (bu *boardUsecase) UpdateBoard(board model.Board, userId uint, boardId uint) (model.BoardResponse, error) {
+	board.Columns = nil
+	if err := bu.bv.BoardValidate(board); err != nil {
+		return model.BoardResponse{}, err
+	}
+	if err := bu.br.UpdateBoard(&board, userId, boardId); err != nil {
+		return model.BoardResponse{}, err
+	}
+	return bu.GetBoardById(userId, boardId)
+}
+
+func (bu *boardUsecase) DeleteBoard(userId uint, boardId uint) error {
+	return bu.br.DeleteBoard(userId, boardId)
+}
+
+func (bu *boardUsecase) CreateColumn(column model.BoardColumn, userId uint, boardId uint) (model.BoardColumnResponse, error) {
+	if err := bu.bv.ColumnValidate(column); err != nil {
+		return model.BoardColumnResponse{}, err
+	}
+	board := model.Board{}
+	if err := bu.getBoard(&board, userId, boardId); err != nil {
+		return model.BoardColumnResponse{}, err
+	}
+	if len(board.Columns) >= model.MaxBoardColumns {
+		return model.BoardColumnResponse{}, fmt.Errorf("columns is limited max %d", model.MaxBoardColumns)
+	}
+	column.ID = 0
+	column.BoardId = boardId
+	column.Position = 0
+	if len(board.Columns) > 0 {
+		column.Position = board.Columns[len(board.Columns)-1].Position + 1
+	}
+	if err := bu.br.CreateColumn(&column); err != nil {
+		return model.BoardColumnResponse{}, err
+	}
+	return toBoardColumnResponse(column), nil
+}
+
+// 列の名前・ステータス・WIP制限・並び順を変える。WIP制限を今のカードの数より小さくした場合も、置かれているカードはそのままにする。
+func (bu *boardUsecase) UpdateColumn(column model.BoardColumn, userId uint, boardId uint, columnId uint) (model.BoardColumnResponse, error) {
+	if err := bu.bv.ColumnValidate(column); err != nil {
+		return model.BoardColumnResponse{}, err
+	}
+	if err := bu.getBoard(&model.Board{}, userId, boardId); err != nil {
+		return model.BoardColumnResponse{}, err
+	}
+	if err := bu.br.UpdateColumn(&column, boardId, columnId); err != nil {
+		return model.BoardColumnResponse{}, err
+	}
+	column.ID = columnId
+	return toBoardColumnResponse(column), nil
+}
+
+func (bu *boardUsecase) DeleteColumn(userId uint, boardId uint, columnId uint) error {
+	if err := bu.getBoard(&model.Board{}, userId, boardId); err != nil {
+		return err
+	}
+	return bu.br.DeleteColumn(boardId, columnId)
+}
+
+func (bu *boardUsecase) PlaceCard(move model.BoardCardMove, userId uint, boardId uint, taskId uint) (model.BoardCardResponse, error) {
+	if err := bu.getBoard(&model.Board{}, userId, boardId); err != nil {
+		return model.BoardCardResponse{}, err
+	}
+	return bu.tu.PlaceBoardCard(move, userId, boardId, taskId)
+}
+
+func (bu *boardUsecase) RemoveCard(userId uint, boardId uint, taskId uint) error {
+	if err := bu.getBoard(&model.Board{}, userId, boardId); err != nil {
+		return err
+	}
+	return bu.tu.RemoveBoardCard(userId, boardId, taskId)
+}
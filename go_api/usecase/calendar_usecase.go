@@ -0,0 +1,127 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"go_api/ical"
+	"go_api/model"
+	"go_api/repository"
+	"go_api/taskio"
+	"io"
+
+	"gorm.io/gorm"
+)
+
+type ICalendarUsecase interface {
+	GetFeed(userId uint) (model.CalendarFeedResponse, error)
+	RotateFeed(userId uint) (model.CalendarFeedResponse, error)
+	DeleteFeed(userId uint) error
+	WriteFeed(token string, feedType string, w io.Writer) error
+	// workspaceIdのワークスペースを扱うユースケースを返す。
+	InWorkspace(workspaceId uint) ICalendarUsecase
+}
+
+type calendarUsecase struct {
+	cr repository.ICalendarFeedRepository
+	tr repository.ITaskRepository
+}
+
+func NewCalendarUsecase(cr repository.ICalendarFeedRepository, tr repository.ITaskRepository) ICalendarUsecase {
+	return &calendarUsecase{cr, tr}
+}
+
+func (cu *calendarUsecase) InWorkspace(workspaceId uint) ICalendarUsecase {
+	return &calendarUsecase{cu.cr.InWorkspace(workspaceId), cu.tr.InWorkspace(workspaceId)}
+}
+
+// カレンダーアプリに表示されるカレンダーの名前。
+const calendarFeedName = "regondor"
+
+func toCalendarFeedResponse(feed model.CalendarFeed) model.CalendarFeedResponse {
+	return model.CalendarFeedResponse{
+		Token:     feed.Token,
+		CreatedAt: feed.CreatedAt,
+		UpdatedAt: feed.UpdatedAt,
+	}
+}
+
+// URLに含めるトークン。推測されないように、暗号論的な乱数から作る。
+func newCalendarFeedToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// フィードを返す。まだない場合は作成する。
+func (cu *calendarUsecase) GetFeed(userId uint) (model.CalendarFeedResponse, error) {
+	feed := model.CalendarFeed{}
+	err := cu.cr.GetFeedByUserId(&feed, userId)
+	if err == nil {
+		return toCalendarFeedResponse(feed), nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.CalendarFeedResponse{}, err
+	}
+	token, err := newCalendarFeedToken()
+	if err != nil {
+		return model.CalendarFeedResponse{}, err
+	}
+	feed = model.CalendarFeed{Token: token, UserId: userId}
+	if err := cu.cr.CreateFeed(&feed); err != nil {
+		return model.CalendarFeedResponse{}, err
+	}
+	return toCalendarFeedResponse(feed), nil
+}
+
+// トークンを作り直す。古いURLでは読めなくなる。
+func (cu *calendarUsecase) RotateFeed(userId uint) (model.CalendarFeedResponse, error) {
+	token, err := newCalendarFeedToken()
+	if err != nil {
+		return model.CalendarFeedResponse{}, err
+	}
+	feed := model.CalendarFeed{}
+	if err := cu.cr.UpdateFeedToken(&feed, userId, token); err != nil {
+		return model.CalendarFeedResponse{}, err
+	}
+	return toCalendarFeedResponse(feed), nil
+}
+
+func (cu *calendarUsecase) DeleteFeed(userId uint) error {
+	if err := cu.cr.DeleteFeed(userId); err != nil {
+		return err
+	}
+	return nil
+}
+
+// トークンのユーザーの、期限のあるタスクをiCalendarで書き出す。
+// トークンが見つからない場合はgorm.ErrRecordNotFoundを返す。
+func (cu *calendarUsecase) WriteFeed(token string, feedType string, w io.Writer) error {
+	feed := model.CalendarFeed{}
+	if err := cu.cr.GetFeedByToken(&feed, token); err != nil {
+		return err
+	}
+	component := ical.VTodo
+	if feedType == model.CalendarFeedTypeEvent {
+		component = ical.VEvent
+	}
+	writer := taskio.NewCalendarWriter(w, component, calendarFeedName)
+	// トークンだけで認証するので、ワークスペースはフィードに保存したものを使う。
+	err := cu.tr.InWorkspace(feed.WorkspaceId).ExportTasks(feed.UserId, taskExportBatchSize, func(tasks []model.Task) error {
+		for _, v := range tasks {
+			if v.DueAt == nil {
+				continue
+			}
+			if err := writer.Write(toTaskResponse(v)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return writer.Close()
+}
@@ -0,0 +1,126 @@
+package usecase
+
+import (
+	"go_api/markdown"
+	"go_api/model"
+	"go_api/repository"
+	"go_api/validator"
+)
+
+type ICommentUsecase interface {
+	GetComments(userId uint, taskId uint) ([]model.CommentResponse, error)
+	CreateComment(comment model.Comment) (model.CommentResponse, error)
+	UpdateComment(comment model.Comment, userId uint, taskId uint, commentId uint) (model.CommentResponse, error)
+	DeleteComment(userId uint, taskId uint, commentId uint) error
+	// workspaceIdのワークスペースを扱うユースケースを返す。
+	InWorkspace(workspaceId uint) ICommentUsecase
+}
+
+type commentUsecase struct {
+	cr repository.ICommentRepository
+	tr repository.ITaskRepository
+	cv validator.ICommentValidator
+}
+
+func NewCommentUsecase(cr repository.ICommentRepository, tr repository.ITaskRepository, cv validator.ICommentValidator) ICommentUsecase {
+	return &commentUsecase{cr, tr, cv}
+}
+
+func (cu *commentUsecase) InWorkspace(workspaceId uint) ICommentUsecase {
+	return &commentUsecase{cu.cr.InWorkspace(workspaceId), cu.tr.InWorkspace(workspaceId), cu.cv}
+}
+
+func toCommentResponse(comment model.Comment) model.CommentResponse {
+	return model.CommentResponse{
+		ID:         comment.ID,
+		TaskId:     comment.TaskId,
+		Body:       comment.Body,
+		AuthorId:   comment.UserId,
+		AuthorName: comment.User.Name,
+		EditedAt:   comment.EditedAt,
+		CreatedAt:  comment.CreatedAt,
+	}
+}
+
+// コメントを読み書きできるのは、タスクを見られるユーザーだけにする。(削除済みのタスクには書けない)
+// 共有しているプロジェクトのタスクにコメントを書くには、編集者以上の権限が必要になる。
+func (cu *commentUsecase) checkTask(userId uint, taskId uint, role string) error {
+	task := model.Task{}
+	if err := cu.tr.GetTaskById(&task, userId, taskId); err != nil {
+		return err
+	}
+	return checkTaskRole(cu.tr, userId, taskId, role)
+}
+
+func (cu *commentUsecase) GetComments(userId uint, taskId uint) ([]model.CommentResponse, error) {
+	if err := cu.checkTask(userId, taskId, model.ProjectRoleViewer); err != nil {
+		return nil, err
+	}
+	comments := []model.Comment{}
+	if err := cu.cr.GetCommentsByTaskId(&comments, userId, taskId); err != nil {
+		return nil, err
+	}
+	resComments := []model.CommentResponse{}
+	for _, v := range comments {
+		resComments = append(resComments, toCommentResponse(v))
+	}
+	return resComments, nil
+}
+
+func (cu *commentUsecase) CreateComment(comment model.Comment) (model.CommentResponse, error) {
+	if err := cu.checkTask(comment.UserId, comment.TaskId, model.ProjectRoleEditor); err != nil {
+		return model.CommentResponse{}, err
+	}
+	comment.Body = markdown.Sanitize(comment.Body)
+	comment.EditedAt = nil
+	if err := cu.cv.CommentValidate(comment); err != nil {
+		return model.CommentResponse{}, err
+	}
+	if err := cu.cr.CreateComment(&comment); err != nil {
+		return model.CommentResponse{}, err
+	}
+	// 書いたユーザーの名前を返すために、取得し直す。
+	if err := cu.cr.GetCommentById(&comment, comment.UserId, comment.TaskId, comment.ID); err != nil {
+		return model.CommentResponse{}, err
+	}
+	return toCommentResponse(comment), nil
+}
+
+// 編集と削除は、書いたユーザー本人だけができる。(あとから閲覧者になった場合も、自分のコメントは編集・削除できる)
+func (cu *commentUsecase) checkAuthor(userId uint, taskId uint, commentId uint) error {
+	if err := cu.checkTask(userId, taskId, model.ProjectRoleViewer); err != nil {
+		return err
+	}
+	comment := model.Comment{}
+	if err := cu.cr.GetCommentById(&comment, userId, taskId, commentId); err != nil {
+		return err
+	}
+	if comment.UserId != userId {
+		return model.ErrCommentForbidden
+	}
+	return nil
+}
+
+func (cu *commentUsecase) UpdateComment(comment model.Comment, userId uint, taskId uint, commentId uint) (model.CommentResponse, error) {
+	if err := cu.checkAuthor(userId, taskId, commentId); err != nil {
+		return model.CommentResponse{}, err
+	}
+	comment.Body = markdown.Sanitize(comment.Body)
+	if err := cu.cv.CommentValidate(comment); err != nil {
+		return model.CommentResponse{}, err
+	}
+	if err := cu.cr.UpdateComment(&comment, userId, taskId, commentId); err != nil {
+		return model.CommentResponse{}, err
+	}
+	return toCommentResponse(comment), nil
+}
+
+func (cu *commentUsecase) DeleteComment(userId uint, taskId uint, commentId uint) error {
+	if err := cu.checkAuthor(userId, taskId, commentId); err != nil {
+		return err
+	}
+	if err := cu.cr.DeleteComment(userId, taskId, commentId); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package usecase
+
+import (
+	"go_api/model"
+	"go_api/repository"
+	"go_api/validator"
+)
+
+type ILabelUsecase interface {
+	GetAllLabels(userId uint) ([]model.LabelResponse, error)
+	CreateLabel(label model.Label) (model.LabelResponse, error)
+	UpdateLabel(label model.Label, userId uint, labelId uint) (model.LabelResponse, error)
+	DeleteLabel(userId uint, labelId uint) error
+	// workspaceIdのワークスペースを扱うユースケースを返す。
+	InWorkspace(workspaceId uint) ILabelUsecase
+}
+
+type labelUsecase struct {
+	lr repository.ILabelRepository
+	lv validator.ILabelValidator
+}
+
+func NewLabelUsecase(lr repository.ILabelRepository, lv validator.ILabelValidator) ILabelUsecase {
+	return &labelUsecase{lr, lv}
+}
+
+func (lu *labelUsecase) InWorkspace(workspaceId uint) ILabelUsecase {
+	return &labelUsecase{lu.lr.InWorkspace(workspaceId), lu.lv}
+}
+
+// Label構造体からクライアントへのレスポンス用のLabelResponse構造体を作成する。
+func toLabelResponse(label model.Label) model.LabelResponse {
+	return model.LabelResponse{
+		ID:        label.ID,
+		Name:      label.Name,
+		Color:     label.Color,
+		CreatedAt: label.CreatedAt,
+		UpdatedAt: label.UpdatedAt,
+	}
+}
+
+func (lu *labelUsecase) GetAllLabels(userId uint) ([]model.LabelResponse, error) {
+	labels := []model.Label{}
+	if err := lu.lr.GetAllLabels(&labels, userId); err != nil {
+		return nil, err
+	}
+	resLabels := []model.LabelResponse{}
+	for _, v := range labels {
+		resLabels = append(resLabels, toLabelResponse(v))
+	}
+	return resLabels, nil
+}
+
+func (lu *labelUsecase) CreateLabel(label model.Label) (model.LabelResponse, error) {
+	// 色の指定がない場合は、グレーにする。
+	if label.Color == "" {
+		label.Color = model.DefaultLabelColor
+	}
+	if err := lu.lv.LabelValidate(label); err != nil {
+		return model.LabelResponse{}, err
+	}
+	if err := lu.lr.CreateLabel(&label); err != nil {
+		return model.LabelResponse{}, err
+	}
+	return toLabelResponse(label), nil
+}
+
+func (lu *labelUsecase) UpdateLabel(label model.Label, userId uint, labelId uint) (model.LabelResponse, error) {
+	if err := lu.lv.LabelValidate(label); err != nil {
+		return model.LabelResponse{}, err
+	}
+	if err := lu.lr.UpdateLabel(&label, userId, labelId); err != nil {
+		return model.LabelResponse{}, err
+	}
+	return toLabelResponse(label), nil
+}
+
+func (lu *labelUsecase) DeleteLabel(userId uint, labelId uint) error {
+	if err := lu.lr.DeleteLabel(userId, labelId); err != nil {
+		return err
+	}
+	return nil
+}
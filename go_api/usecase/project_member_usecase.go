@@ -0,0 +1,305 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"go_api/model"
+	"go_api/repository"
+	"go_api/validator"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type IProjectMemberUsecase interface {
+	GetMembers(userId uint, projectId uint) ([]model.ProjectMemberResponse, error)
+	// メンバーの権限を変更する。オーナーだけが変更できる。
+	UpdateMember(update model.ProjectMemberUpdate, userId uint, projectId uint, memberId uint) (model.ProjectMemberResponse, error)
+	// メンバーを外す。オーナーはほかのメンバーを外すことができ、メンバーは自分から抜けることができる。
+	DeleteMember(userId uint, projectId uint, memberId uint) error
+	// プロジェクトの招待の一覧。オーナーだけが見ることができる。
+	GetInvitations(userId uint, projectId uint) ([]model.ProjectInvitationResponse, error)
+	CreateInvitation(invitation model.ProjectInvitation, userId uint, projectId uint) (model.ProjectInvitationResponse, error)
+	// 招待を取り消す。
+	DeleteInvitation(userId uint, projectId uint, invitationId uint) error
+	// ログインしているユーザーのメールアドレス宛ての招待の一覧。
+	GetMyInvitations(userId uint) ([]model.ProjectInvitationResponse, error)
+	// 招待を承認してメンバーになり、参加したプロジェクトを返す。
+	AcceptInvitation(userId uint, token string) (model.ProjectResponse, error)
+	DeclineInvitation(userId uint, token string) error
+	// workspaceIdのワークスペースを扱うユースケースを返す。
+	InWorkspace(workspaceId uint) IProjectMemberUsecase
+}
+
+type projectMemberUsecase struct {
+	pmr repository.IProjectMemberRepository
+	pr  repository.IProjectRepository
+	mr  repository.IMypageRepository
+	pmv validator.IProjectMemberValidator
+}
+
+func NewProjectMemberUsecase(pmr repository.IProjectMemberRepository, pr repository.IProjectRepository, mr repository.IMypageRepository, pmv validator.IProjectMemberValidator) IProjectMemberUsecase {
+	return &projectMemberUsecase{pmr, pr, mr, pmv}
+}
+
+func (pmu *projectMemberUsecase) InWorkspace(workspaceId uint) IProjectMemberUsecase {
+	return &projectMemberUsecase{pmu.pmr, pmu.pr.InWorkspace(workspaceId), pmu.mr, pmu.pmv}
+}
+
+func toProjectMemberResponse(member model.ProjectMember) model.ProjectMemberResponse {
+	return model.ProjectMemberResponse{
+		UserId:    member.UserId,
+		Name:      member.User.Name,
+		Email:     member.User.Email,
+		Role:      member.Role,
+		CreatedAt: member.CreatedAt,
+	}
+}
+
+func toProjectInvitationResponse(invitation model.ProjectInvitation) model.ProjectInvitationResponse {
+	return model.ProjectInvitationResponse{
+		ID:          invitation.ID,
+		ProjectId:   invitation.ProjectId,
+		ProjectName: invitation.Project.Name,
+		Email:       invitation.Email,
+		Role:        invitation.Role,
+		Token:       invitation.Token,
+		InviterName: invitation.User.Name,
+		ExpiresAt:   invitation.ExpiresAt,
+		CreatedAt:   invitation.CreatedAt,
+	}
+}
+
+// 招待のURLに含めるトークン。推測されないように、暗号論的な乱数から作る。
+func newInvitationToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// プロジェクトに対してrole以上の権限があるか確認する。
+// メンバーでない場合はプロジェクトが見つからないエラー、権限が足りない場合はmodel.ErrProjectForbiddenを返す。
+func (pmu *projectMemberUsecase) checkRole(userId uint, projectId uint, role string) (model.Project, error) {
+	project := model.Project{}
+	if err := pmu.pr.GetProjectById(&project, userId, projectId); err != nil {
+		return model.Project{}, err
+	}
+	if !model.HasProjectRole(project.Role, role) {
+		return model.Project{}, model.ErrProjectForbidden
+	}
+	return project, nil
+}
+
+func (pmu *projectMemberUsecase) GetMembers(userId uint, projectId uint) ([]model.ProjectMemberResponse, error) {
+	if _, err := pmu.checkRole(userId, projectId, model.ProjectRoleViewer); err != nil {
+		return nil, err
+	}
+	members := []model.ProjectMember{}
+	if err := pmu.pmr.GetMembers(&members, projectId); err != nil {
+		return nil, err
+	}
+	resMembers := []model.ProjectMemberResponse{}
+	for _, v := range members {
+		resMembers = append(resMembers, toProjectMemberResponse(v))
+	}
+	return resMembers, nil
+}
+
+// オーナーがいなくなる変更は、最後のオーナーを外すことになるのでエラーにする。
+func (pmu *projectMemberUsecase) UpdateMember(update model.ProjectMemberUpdate, userId uint, projectId uint, memberId uint) (model.ProjectMemberResponse, error) {
+	if err := pmu.pmv.MemberUpdateValidate(update); err != nil {
+		return model.ProjectMemberResponse{}, err
+	}
+	if _, err := pmu.checkRole(userId, projectId, model.ProjectRoleOwner); err != nil {
+		return model.ProjectMemberResponse{}, err
+	}
+	member := model.ProjectMember{}
+	err := pmu.pmr.Transaction(func(pmr repository.IProjectMemberRepository) error {
+		if err := pmr.GetMember(&member, projectId, memberId); err != nil {
+			return err
+		}
+		if member.Role == model.ProjectRoleOwner && update.Role != model.ProjectRoleOwner {
+			if err := checkOtherOwners(pmr, projectId); err != nil {
+				return err
+			}
+		}
+		if err := pmr.UpdateMemberRole(projectId, memberId, update.Role); err != nil {
+			return err
+		}
+		member.Role = update.Role
+		return nil
+	})
+	if err != nil {
+		return model.ProjectMemberResponse{}, err
+	}
+	return toProjectMemberResponse(member), nil
+}
+
+// 自分から抜ける場合は、閲覧者でもよい。
+func (pmu *projectMemberUsecase) DeleteMember(userId uint, projectId uint, memberId uint) error {
+	required := model.ProjectRoleOwner
+	if memberId == userId {
+		required = model.ProjectRoleViewer
+	}
+	if _, err := pmu.checkRole(userId, projectId, required); err != nil {
+		return err
+	}
+	return pmu.pmr.Transaction(func(pmr repository.IProjectMemberRepository) error {
+		member := model.ProjectMember{}
+		if err := pmr.GetMember(&member, projectId, memberId); err != nil {
+			return err
+		}
+		if member.Role == model.ProjectRoleOwner {
+			if err := checkOtherOwners(pmr, projectId); err != nil {
+				return err
+			}
+		}
+		return pmr.DeleteMember(projectId, memberId)
+	})
+}
+
+// オーナーが2人以上いるか確認する。
+func checkOtherOwners(pmr repository.IProjectMemberRepository, projectId uint) error {
+	var owners int64
+	if err := pmr.CountOwners(&owners, projectId); err != nil {
+		return err
+	}
+	if owners < 2 {
+		return model.ErrLastProjectOwner
+	}
+	return nil
+}
+
+func (pmu *projectMemberUsecase) GetInvitations(userId uint, projectId uint) ([]model.ProjectInvitationResponse, error) {
+	if _, err := pmu.checkRole(userId, projectId, model.ProjectRoleOwner); err != nil {
+		return nil, err
+	}
+	invitations := []model.ProjectInvitation{}
+	if err := pmu.pmr.GetInvitations(&invitations, projectId); err != nil {
+		return nil, err
+	}
+	resInvitations := []model.ProjectInvitationResponse{}
+	for _, v := range invitations {
+		resInvitations = append(resInvitations, toProjectInvitationResponse(v))
+	}
+	return resInvitations, nil
+}
+
+// メールアドレスは小文字にそろえて保存する。すでにメンバーのユーザーは招待できない。(権限の変更はUpdateMemberで行う)
+func (pmu *projectMemberUsecase) CreateInvitation(invitation model.ProjectInvitation, userId uint, projectId uint) (model.ProjectInvitationResponse, error) {
+	// 送られてきた値のうち、メールアドレスと権限だけを使う。
+	invitation = model.ProjectInvitation{Email: strings.ToLower(strings.TrimSpace(invitation.Email)), Role: invitation.Role}
+	if err := pmu.pmv.InvitationValidate(invitation); err != nil {
+		return model.ProjectInvitationResponse{}, err
+	}
+	project, err := pmu.checkRole(userId, projectId, model.ProjectRoleOwner)
+	if err != nil {
+		return model.ProjectInvitationResponse{}, err
+	}
+	var isMember bool
+	if err := pmu.pmr.IsMemberByEmail(&isMember, projectId, invitation.Email); err != nil {
+		return model.ProjectInvitationResponse{}, err
+	}
+	if isMember {
+		return model.ProjectInvitationResponse{}, model.ErrAlreadyProjectMember
+	}
+	token, err := newInvitationToken()
+	if err != nil {
+		return model.ProjectInvitationResponse{}, err
+	}
+	invitation.Token = token
+	invitation.ExpiresAt = time.Now().Add(model.ProjectInvitationTTL)
+	invitation.ProjectId = projectId
+	invitation.UserId = userId
+	if err := pmu.pmr.SaveInvitation(&invitation); err != nil {
+		return model.ProjectInvitationResponse{}, err
+	}
+	if err := pmu.mr.GetUser(&invitation.User, userId); err != nil {
+		return model.ProjectInvitationResponse{}, err
+	}
+	invitation.Project = project
+	return toProjectInvitationResponse(invitation), nil
+}
+
+func (pmu *projectMemberUsecase) DeleteInvitation(userId uint, projectId uint, invitationId uint) error {
+	if _, err := pmu.checkRole(userId, projectId, model.ProjectRoleOwner); err != nil {
+		return err
+	}
+	return pmu.pmr.DeleteInvitation(projectId, invitationId)
+}
+
+func (pmu *projectMemberUsecase) GetMyInvitations(userId uint) ([]model.ProjectInvitationResponse, error) {
+	user := model.User{}
+	if err := pmu.mr.GetUser(&user, userId); err != nil {
+		return nil, err
+	}
+	invitations := []model.ProjectInvitation{}
+	if err := pmu.pmr.GetInvitationsByEmail(&invitations, user.Email); err != nil {
+		return nil, err
+	}
+	resInvitations := []model.ProjectInvitationResponse{}
+	for _, v := range invitations {
+		resInvitations = append(resInvitations, toProjectInvitationResponse(v))
+	}
+	return resInvitations, nil
+}
+
+// トークンの招待が、ログインしているユーザーのメールアドレス宛てで、期限が切れていないか確認する。
+// 他人宛ての招待があることを知られないように、どの場合もmodel.ErrInvitationInvalidを返す。
+func (pmu *projectMemberUsecase) getMyInvitation(pmr repository.IProjectMemberRepository, userId uint, token string) (model.ProjectInvitation, error) {
+	user := model.User{}
+	if err := pmu.mr.GetUser(&user, userId); err != nil {
+		return model.ProjectInvitation{}, err
+	}
+	invitation := model.ProjectInvitation{}
+	if err := pmr.GetInvitationByToken(&invitation, token); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.ProjectInvitation{}, model.ErrInvitationInvalid
+		}
+		return model.ProjectInvitation{}, err
+	}
+	if invitation.Email != strings.ToLower(user.Email) || time.Now().After(invitation.ExpiresAt) {
+		return model.ProjectInvitation{}, model.ErrInvitationInvalid
+	}
+	return invitation, nil
+}
+
+// すでにメンバーの場合は、招待の権限に変える。
+// プロジェクトのワークスペースのメンバーでない場合は、ワークスペースにもメンバーとして追加する。
+func (pmu *projectMemberUsecase) AcceptInvitation(userId uint, token string) (model.ProjectResponse, error) {
+	project := model.Project{}
+	err := pmu.pmr.Transaction(func(pmr repository.IProjectMemberRepository) error {
+		invitation, err := pmu.getMyInvitation(pmr, userId, token)
+		if err != nil {
+			return err
+		}
+		if err := pmr.JoinWorkspace(invitation.Project.WorkspaceId, userId); err != nil {
+			return err
+		}
+		member := model.ProjectMember{ProjectId: invitation.ProjectId, UserId: userId, Role: invitation.Role}
+		if err := pmr.SaveMember(&member); err != nil {
+			return err
+		}
+		project = invitation.Project
+		project.Role = member.Role
+		return pmr.DeleteInvitation(invitation.ProjectId, invitation.ID)
+	})
+	if err != nil {
+		return model.ProjectResponse{}, err
+	}
+	return toProjectResponse(project), nil
+}
+
+func (pmu *projectMemberUsecase) DeclineInvitation(userId uint, token string) error {
+	return pmu.pmr.Transaction(func(pmr repository.IProjectMemberRepository) error {
+		invitation, err := pmu.getMyInvitation(pmr, userId, token)
+		if err != nil {
+			return err
+		}
+		return pmr.DeleteInvitation(invitation.ProjectId, invitation.ID)
+	})
+}
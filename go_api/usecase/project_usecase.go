@@ -0,0 +1,116 @@
+package usecase
+
+import (
+	"go_api/model"
+	"go_api/repository"
+	"go_api/validator"
+)
+
+type IProjectUsecase interface {
+	GetAllProjects(userId uint, includeArchived bool) ([]model.ProjectResponse, error)
+	GetProjectById(userId uint, projectId uint) (model.ProjectResponse, error)
+	CreateProject(project model.Project) (model.ProjectResponse, error)
+	UpdateProject(project model.Project, userId uint, projectId uint) (model.ProjectResponse, error)
+	DeleteProject(userId uint, projectId uint, mode string) error
+	// workspaceIdのワークスペースを扱うユースケースを返す。
+	InWorkspace(workspaceId uint) IProjectUsecase
+}
+
+type projectUsecase struct {
+	pr repository.IProjectRepository
+	pv validator.IProjectValidator
+}
+
+func NewProjectUsecase(pr repository.IProjectRepository, pv validator.IProjectValidator) IProjectUsecase {
+	return &projectUsecase{pr, pv}
+}
+
+func (pu *projectUsecase) InWorkspace(workspaceId uint) IProjectUsecase {
+	return &projectUsecase{pu.pr.InWorkspace(workspaceId), pu.pv}
+}
+
+func toProjectResponse(project model.Project) model.ProjectResponse {
+	return model.ProjectResponse{
+		ID:          project.ID,
+		Name:        project.Name,
+		Color:       project.Color,
+		Archived:    project.Archived,
+		Role:        project.Role,
+		WorkspaceId: project.WorkspaceId,
+		CreatedAt:   project.CreatedAt,
+		UpdatedAt:   project.UpdatedAt,
+	}
+}
+
+func (pu *projectUsecase) GetAllProjects(userId uint, includeArchived bool) ([]model.ProjectResponse, error) {
+	projects := []model.Project{}
+	if err := pu.pr.GetAllProjects(&projects, userId, includeArchived); err != nil {
+		return nil, err
+	}
+	resProjects := []model.ProjectResponse{}
+	for _, v := range projects {
+		resProjects = append(resProjects, toProjectResponse(v))
+	}
+	return resProjects, nil
+}
+
+func (pu *projectUsecase) GetProjectById(userId uint, projectId uint) (model.ProjectResponse, error) {
+	project := model.Project{}
+	if err := pu.pr.GetProjectById(&project, userId, projectId); err != nil {
+		return model.ProjectResponse{}, err
+	}
+	return toProjectResponse(project), nil
+}
+
+func (pu *projectUsecase) CreateProject(project model.Project) (model.ProjectResponse, error) {
+	// 色の指定がない場合は、青にする。
+	if project.Color == "" {
+		project.Color = "#3b82f6"
+	}
+	if err := pu.pv.ProjectValidate(project); err != nil {
+		return model.ProjectResponse{}, err
+	}
+	if err := pu.pr.CreateProject(&project); err != nil {
+		return model.ProjectResponse{}, err
+	}
+	return toProjectResponse(project), nil
+}
+
+// プロジェクトの変更・削除は、オーナーだけができる。
+func (pu *projectUsecase) checkOwner(userId uint, projectId uint) error {
+	project := model.Project{}
+	if err := pu.pr.GetProjectById(&project, userId, projectId); err != nil {
+		return err
+	}
+	if !model.HasProjectRole(project.Role, model.ProjectRoleOwner) {
+		return model.ErrProjectForbidden
+	}
+	return nil
+}
+
+func (pu *projectUsecase) UpdateProject(project model.Project, userId uint, projectId uint) (model.ProjectResponse, error) {
+	if err := pu.pv.ProjectValidate(project); err != nil {
+		return model.ProjectResponse{}, err
+	}
+	if err := pu.checkOwner(userId, projectId); err != nil {
+		return model.ProjectResponse{}, err
+	}
+	if err := pu.pr.UpdateProject(&project, userId, projectId); err != nil {
+		return model.ProjectResponse{}, err
+	}
+	return toProjectResponse(project), nil
+}
+
+// modeの指定がない場合は、タスクを消してしまわないようにインボックスへ移す。
+func (pu *projectUsecase) DeleteProject(userId uint, projectId uint, mode string) error {
+	if mode == "" {
+		mode = model.ProjectDeleteMoveToInbox
+	}
+	if err := pu.checkOwner(userId, projectId); err != nil {
+		return err
+	}
+	if err := pu.pr.DeleteProject(userId, projectId, mode); err != nil {
+		return err
+	}
+	return nil
+}
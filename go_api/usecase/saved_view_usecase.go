@@ -0,0 +1,103 @@
+package usecase
+
+import (
+	"go_api/model"
+	"go_api/repository"
+	"go_api/validator"
+	"time"
+)
+
+type ISavedViewUsecase interface {
+	GetSavedViews(userId uint) ([]model.SavedViewResponse, error)
+	GetSavedViewById(userId uint, viewId uint) (model.SavedViewResponse, error)
+	CreateSavedView(view model.SavedView) (model.SavedViewResponse, error)
+	UpdateSavedView(view model.SavedView, userId uint, viewId uint) (model.SavedViewResponse, error)
+	DeleteSavedView(userId uint, viewId uint) error
+	// ビューの条件と並び順で、タスクの一覧を1ページ分取得する。locは、条件の中のtodayや日付を解釈するタイムゾーン。
+	GetViewTasks(userId uint, viewId uint, page model.TaskPage, loc *time.Location) (model.TaskListResponse, error)
+	// workspaceIdのワークスペースを扱うユースケースを返す。
+	InWorkspace(workspaceId uint) ISavedViewUsecase
+}
+
+type savedViewUsecase struct {
+	vr repository.ISavedViewRepository
+	tu ITaskUsecase
+	vv validator.ISavedViewValidator
+}
+
+// タスクの一覧の取得(ページングやアクセスできるタスクの絞り込み)は、タスクのユースケースに任せる。
+func NewSavedViewUsecase(vr repository.ISavedViewRepository, tu ITaskUsecase, vv validator.ISavedViewValidator) ISavedViewUsecase {
+	return &savedViewUsecase{vr, tu, vv}
+}
+
+func (vu *savedViewUsecase) InWorkspace(workspaceId uint) ISavedViewUsecase {
+	return &savedViewUsecase{vu.vr.InWorkspace(workspaceId), vu.tu.InWorkspace(workspaceId), vu.vv}
+}
+
+func toSavedViewResponse(view model.SavedView) model.SavedViewResponse {
+	return model.SavedViewResponse{
+		ID:        view.ID,
+		Name:      view.Name,
+		Query:     view.Query,
+		Sort:      view.Sort,
+		Order:     view.Order,
+		CreatedAt: view.CreatedAt,
+		UpdatedAt: view.UpdatedAt,
+	}
+}
+
+func (vu *savedViewUsecase) GetSavedViews(userId uint) ([]model.SavedViewResponse, error) {
+	views := []model.SavedView{}
+	if err := vu.vr.GetSavedViews(&views, userId); err != nil {
+		return nil, err
+	}
+	resViews := []model.SavedViewResponse{}
+	for _, v := range views {
+		resViews = append(resViews, toSavedViewResponse(v))
+	}
+	return resViews, nil
+}
+
+func (vu *savedViewUsecase) GetSavedViewById(userId uint, viewId uint) (model.SavedViewResponse, error) {
+	view := model.SavedView{}
+	if err := vu.vr.GetSavedViewById(&view, userId, viewId); err != nil {
+		return model.SavedViewResponse{}, err
+	}
+	return toSavedViewResponse(view), nil
+}
+
+func (vu *savedViewUsecase) CreateSavedView(view model.SavedView) (model.SavedViewResponse, error) {
+	if err := vu.vv.SavedViewValidate(view); err != nil {
+		return model.SavedViewResponse{}, err
+	}
+	if err := vu.vr.CreateSavedView(&view); err != nil {
+		return model.SavedViewResponse{}, err
+	}
+	return toSavedViewResponse(view), nil
+}
+
+func (vu *savedViewUsecase) UpdateSavedView(view model.SavedView, userId uint, viewId uint) (model.SavedViewResponse, error) {
+	if err := vu.vv.SavedViewValidate(view); err != nil {
+		return model.SavedViewResponse{}, err
+	}
+	if err := vu.vr.UpdateSavedView(&view, userId, viewId); err != nil {
+		return model.SavedViewResponse{}, err
+	}
+	return toSavedViewResponse(view), nil
+}
+
+func (vu *savedViewUsecase) DeleteSavedView(userId uint, viewId uint) error {
+	return vu.vr.DeleteSavedView(userId, viewId)
+}
+
+// 並び順はビューに保存したものを使い、件数とカーソルだけをpageから受け取る。
+func (vu *savedViewUsecase) GetViewTasks(userId uint, viewId uint, page model.TaskPage, loc *time.Location) (model.TaskListResponse, error) {
+	view := model.SavedView{}
+	if err := vu.vr.GetSavedViewById(&view, userId, viewId); err != nil {
+		return model.TaskListResponse{}, err
+	}
+	page.Sort = view.Sort
+	page.Order = view.Order
+	filter := model.TaskFilter{Query: view.Query, Location: loc}
+	return vu.tu.GetAllTasks(userId, filter, page)
+}
@@ -0,0 +1,169 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"go_api/model"
+	"go_api/repository"
+
+	"gorm.io/gorm"
+)
+
+type ITaskDependencyUsecase interface {
+	// taskIdのタスクの依存関係のグラフ(DAG)を返す。
+	GetDependencies(userId uint, taskId uint) (model.TaskDependencyGraphResponse, error)
+	// taskIdのタスクが、req.DependsOnIdのタスクに依存するようにする。依存関係が循環する場合はmodel.ErrTaskDependencyCycleを返す。
+	AddDependency(req model.TaskDependencyRequest, userId uint, taskId uint) (model.TaskDependencyResponse, error)
+	RemoveDependency(userId uint, taskId uint, dependsOnId uint) error
+	// workspaceIdのワークスペースを扱うユースケースを返す。
+	InWorkspace(workspaceId uint) ITaskDependencyUsecase
+}
+
+type taskDependencyUsecase struct {
+	dr repository.ITaskDependencyRepository
+	tr repository.ITaskRepository
+}
+
+func NewTaskDependencyUsecase(dr repository.ITaskDependencyRepository, tr repository.ITaskRepository) ITaskDependencyUsecase {
+	return &taskDependencyUsecase{dr, tr}
+}
+
+func (du *taskDependencyUsecase) InWorkspace(workspaceId uint) ITaskDependencyUsecase {
+	return &taskDependencyUsecase{du.dr.InWorkspace(workspaceId), du.tr.InWorkspace(workspaceId)}
+}
+
+func toTaskDependencyResponse(dependency model.TaskDependency) model.TaskDependencyResponse {
+	return model.TaskDependencyResponse{
+		TaskId:      dependency.TaskId,
+		DependsOnId: dependency.DependsOnId,
+		CreatedAt:   dependency.CreatedAt,
+	}
+}
+
+// fromのタスクから、nextの辺をたどって行けるタスクのidを返す。(fromは含めない)
+func reachableTasks(from uint, next map[uint][]uint) map[uint]bool {
+	visited := map[uint]bool{}
+	stack := []uint{from}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, v := range next[id] {
+			if !visited[v] {
+				visited[v] = true
+				stack = append(stack, v)
+			}
+		}
+	}
+	return visited
+}
+
+// 依存関係のグラフを、依存している方向(task_id → depends_on_id)と依存されている方向の隣接リストにする。
+func dependencyAdjacency(edges []model.TaskDependency) (map[uint][]uint, map[uint][]uint) {
+	dependsOn := map[uint][]uint{}
+	dependents := map[uint][]uint{}
+	for _, v := range edges {
+		dependsOn[v.TaskId] = append(dependsOn[v.TaskId], v.DependsOnId)
+		dependents[v.DependsOnId] = append(dependents[v.DependsOnId], v.TaskId)
+	}
+	return dependsOn, dependents
+}
+
+// 依存しているタスクをたどっていったものと、依存されているタスクをたどっていったものを合わせて返す。
+// 見ることができないタスクは頂点から外し、その先もたどらない。
+func (du *taskDependencyUsecase) GetDependencies(userId uint, taskId uint) (model.TaskDependencyGraphResponse, error) {
+	if err := checkTaskRole(du.tr, userId, taskId, model.ProjectRoleViewer); err != nil {
+		return model.TaskDependencyGraphResponse{}, err
+	}
+	edges := []model.TaskDependency{}
+	if err := du.dr.GetEdges(&edges); err != nil {
+		return model.TaskDependencyGraphResponse{}, err
+	}
+	dependsOn, dependents := dependencyAdjacency(edges)
+	candidates := []uint{taskId}
+	for id := range reachableTasks(taskId, dependsOn) {
+		candidates = append(candidates, id)
+	}
+	for id := range reachableTasks(taskId, dependents) {
+		candidates = append(candidates, id)
+	}
+	nodes := []model.TaskDependencyNode{}
+	if err := du.dr.GetNodes(&nodes, userId, candidates); err != nil {
+		return model.TaskDependencyGraphResponse{}, err
+	}
+	visible := map[uint]bool{}
+	for _, v := range nodes {
+		visible[v.ID] = true
+	}
+	// 見ることができるタスクだけの辺で、もう一度たどり直す。
+	visibleEdges := []model.TaskDependency{}
+	for _, v := range edges {
+		if visible[v.TaskId] && visible[v.DependsOnId] {
+			visibleEdges = append(visibleEdges, v)
+		}
+	}
+	dependsOn, dependents = dependencyAdjacency(visibleEdges)
+	inGraph := reachableTasks(taskId, dependsOn)
+	for id := range reachableTasks(taskId, dependents) {
+		inGraph[id] = true
+	}
+	inGraph[taskId] = true
+	res := model.TaskDependencyGraphResponse{TaskId: taskId, Nodes: []model.TaskDependencyNode{}, Edges: []model.TaskDependencyResponse{}}
+	for _, v := range nodes {
+		if inGraph[v.ID] {
+			res.Nodes = append(res.Nodes, v)
+		}
+	}
+	for _, v := range visibleEdges {
+		if inGraph[v.TaskId] && inGraph[v.DependsOnId] {
+			res.Edges = append(res.Edges, toTaskDependencyResponse(v))
+		}
+	}
+	return res, nil
+}
+
+// 依存関係を追加するには、依存する側のタスクに編集者以上の権限が、依存される側のタスクを見る権限が必要になる。
+// 依存される側のタスクから依存関係をたどって依存する側のタスクに行ける場合は、追加すると循環するのでエラーにする。
+func (du *taskDependencyUsecase) AddDependency(req model.TaskDependencyRequest, userId uint, taskId uint) (model.TaskDependencyResponse, error) {
+	if err := checkTaskRole(du.tr, userId, taskId, model.ProjectRoleEditor); err != nil {
+		return model.TaskDependencyResponse{}, err
+	}
+	if req.DependsOnId == taskId {
+		return model.TaskDependencyResponse{}, model.ErrTaskDependencyCycle
+	}
+	// ゴミ箱のタスクには依存できない。
+	if err := du.tr.GetTaskById(&model.Task{}, userId, req.DependsOnId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.TaskDependencyResponse{}, fmt.Errorf("depends_on task does not exist")
+		}
+		return model.TaskDependencyResponse{}, err
+	}
+	dependency := model.TaskDependency{TaskId: taskId, DependsOnId: req.DependsOnId, UserId: userId}
+	err := du.dr.Transaction(func(dr repository.ITaskDependencyRepository) error {
+		edges := []model.TaskDependency{}
+		if err := dr.GetEdges(&edges); err != nil {
+			return err
+		}
+		for _, v := range edges {
+			if v.TaskId == taskId && v.DependsOnId == req.DependsOnId {
+				dependency = v
+				return nil
+			}
+		}
+		dependsOn, _ := dependencyAdjacency(edges)
+		if reachableTasks(req.DependsOnId, dependsOn)[taskId] {
+			return model.ErrTaskDependencyCycle
+		}
+		return dr.CreateDependency(&dependency)
+	})
+	if err != nil {
+		return model.TaskDependencyResponse{}, err
+	}
+	return toTaskDependencyResponse(dependency), nil
+}
+
+func (du *taskDependencyUsecase) RemoveDependency(userId uint, taskId uint, dependsOnId uint) error {
+	if err := checkTaskRole(du.tr, userId, taskId, model.ProjectRoleEditor); err != nil {
+		return err
+	}
+	return du.dr.DeleteDependency(taskId, dependsOnId)
+}
@@ -1,57 +1,454 @@
 package usecase
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"go_api/model"
+	"go_api/position"
 	"go_api/repository"
+	"go_api/rrule"
+	"go_api/storage"
+	"go_api/taskio"
 	"go_api/validator"
+	"html"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
 )
 
 type ITaskUsecase interface {
-	GetAllTasks(userId uint) ([]model.TaskResponse, error)
+	GetAllTasks(userId uint, filter model.TaskFilter, page model.TaskPage) (model.TaskListResponse, error)
 	GetTaskById(userId uint, taskId uint) (model.TaskResponse, error)
+	SearchTasks(userId uint, query string, limit int) ([]model.TaskSearchResponse, error)
+	// 繰り返しタスクの、fromからtoまでの発生日時を展開する。
+	GetOccurrences(userId uint, taskId uint, from time.Time, to time.Time) (model.TaskOccurrencesResponse, error)
 	CreateTask(task model.Task) (model.TaskResponse, error)
 	UpdateTask(task model.Task, userId uint, taskId uint) (model.TaskResponse, error)
-	DeleteTask(userId uint, taskId uint) error
+	PatchTask(patch map[string]json.RawMessage, version uint, userId uint, taskId uint) (model.TaskResponse, error)
+	// 担当者を変える。assigneeIdがnilの場合は、担当者を外す。
+	AssignTask(assigneeId *uint, version uint, userId uint, taskId uint) (model.TaskResponse, error)
+	DeleteTask(userId uint, taskId uint, version uint) error
+	MoveTask(move model.TaskMove, userId uint, taskId uint) (model.TaskResponse, error)
+	GetDeletedTasks(userId uint) ([]model.TaskResponse, error)
+	RestoreTask(userId uint, taskId uint) (model.TaskResponse, error)
+	// 削除してからretentionより長く経ったゴミ箱のタスクを完全に削除し、削除した件数を返す。
+	PurgeDeletedTasks(retention time.Duration) (int64, error)
+	// ユーザーの最後のタスクの操作を取り消す。
+	UndoTask(userId uint) (model.TaskUndoResponse, error)
+	// タスクを、バージョンがto以下のときの状態に戻す。
+	RevertTask(userId uint, taskId uint, to uint, version uint) (model.TaskResponse, error)
+	// 作成・更新・削除・完了の操作をまとめて、1つのトランザクションの中で実行する。
+	BulkTasks(req model.TaskBulkRequest, userId uint) (model.TaskBulkResponse, error)
+	// ユーザーのタスクをformatの形式でwに書き出す。
+	ExportTasks(userId uint, format string, w io.Writer) error
+	// rから読み込んだタスクを作成する。
+	ImportTasks(userId uint, opts model.TaskImportOptions, r io.Reader) (model.TaskImportResponse, error)
+	// boardIdのボードの列にタスクのカードを置く。ステータスに対応する列の場合は、タスクのステータスも変える。
+	PlaceBoardCard(move model.BoardCardMove, userId uint, boardId uint, taskId uint) (model.BoardCardResponse, error)
+	RemoveBoardCard(userId uint, boardId uint, taskId uint) error
+	// workspaceIdのワークスペースのタスクを扱うユースケースを返す。ワークスペースのメンバーかどうかは、リポジトリのクエリで確認する。
+	InWorkspace(workspaceId uint) ITaskUsecase
+}
+
+// ステータスの遷移ルール。キーが現在のステータス、値が遷移できるステータスの一覧。
+// 完了(done)や中止(cancelled)にしたタスクも、todoに戻すことで再開できる。
+var taskStatusTransitions = map[string][]string{
+	model.TaskStatusTodo:       {model.TaskStatusInProgress, model.TaskStatusDone, model.TaskStatusCancelled},
+	model.TaskStatusInProgress: {model.TaskStatusTodo, model.TaskStatusDone, model.TaskStatusCancelled},
+	model.TaskStatusDone:       {model.TaskStatusTodo, model.TaskStatusInProgress},
+	model.TaskStatusCancelled:  {model.TaskStatusTodo},
 }
 
 // taskUsecaseの構造体
 // trというフィールド名でRepositoryパッケージ内のITaskRepositoryインターフェースの値を格納できるようにしておく。
 // tvというフィールド名でTaskValidatorというフィールドを追加しておく。
+// prはタスクを入れるプロジェクトが自分のものか確認するために使う。
 type taskUsecase struct {
 	tr repository.ITaskRepository
+	pr repository.IProjectRepository
 	tv validator.ITaskValidator
+	lv validator.ILabelValidator
+	st storage.Storage
 }
 
 // NewTaskUsecaseのコンストラクター
 // 外側でインスタンス化されているtaskValidatorを注入できるように引数にItaskValidatorを追加。
-func NewTaskUsecase(tr repository.ITaskRepository, tv validator.ITaskValidator) ITaskUsecase {
-	// taskRepository, taskValidatorの機能をtaskUsecaseの中で使用できるようにしておく、
-	return &taskUsecase{tr, tv} // アドレスを取得し返す。
+// インポートでラベルを作成するときのために、labelValidatorも受け取る。
+func NewTaskUsecase(tr repository.ITaskRepository, pr repository.IProjectRepository, tv validator.ITaskValidator, lv validator.ILabelValidator, st storage.Storage) ITaskUsecase {
+	// taskRepository, projectRepository, taskValidator, labelValidator, storageの機能をtaskUsecaseの中で使用できるようにしておく、
+	return &taskUsecase{tr, pr, tv, lv, st} // アドレスを取得し返す。
+}
+
+func (tu *taskUsecase) InWorkspace(workspaceId uint) ITaskUsecase {
+	return &taskUsecase{tu.tr.InWorkspace(workspaceId), tu.pr.InWorkspace(workspaceId), tu.tv, tu.lv, tu.st}
+}
+
+// Task構造体からクライアントへのレスポンス用のTaskResponse構造体を作成する。
+func toTaskResponse(task model.Task) model.TaskResponse {
+	labels := []model.LabelResponse{}
+	for _, v := range task.Labels {
+		labels = append(labels, toLabelResponse(v))
+	}
+	checklist := task.Checklist
+	if checklist == nil {
+		checklist = model.Checklist{}
+	}
+	res := model.TaskResponse{
+		ID:          task.ID,
+		Title:       task.Title,
+		Description: task.Description,
+		Status:      task.Status,
+		CompletedAt: task.CompletedAt,
+		StartAt:     task.StartAt,
+		DueAt:       task.DueAt,
+		CreatorId:   task.UserId,
+		AssigneeId:  task.AssigneeId,
+		ProjectId:   task.ProjectId,
+		ParentId:    task.ParentId,
+		Checklist:   checklist,
+		Recurrence:  task.Recurrence,
+		Position:    task.Position,
+		Version:     task.Version,
+		Progress:    taskProgress(task, 0, 0),
+		Labels:      labels,
+		CreatedAt:   task.CreatedAt,
+		UpdatedAt:   task.UpdatedAt,
+	}
+	if task.DeletedAt.Valid {
+		res.DeletedAt = &task.DeletedAt.Time
+	}
+	return res
+}
+
+// タスクの進み具合(0〜100)を計算する。
+// チェックリストの項目と直下のサブタスクを1つずつ数え、そのうち完了したものの割合を返す。
+// どちらもない場合は、タスク自体が完了していれば100、そうでなければ0にする。
+func taskProgress(task model.Task, subtaskTotal int, subtaskDone int) int {
+	total := len(task.Checklist) + subtaskTotal
+	done := subtaskDone
+	for _, v := range task.Checklist {
+		if v.Done {
+			done++
+		}
+	}
+	if total == 0 {
+		if task.Status == model.TaskStatusDone {
+			return 100
+		}
+		return 0
+	}
+	return done * 100 / total
+}
+
+// サブタスクの一覧から、rootIdのタスクの直下のサブタスクを階層のままレスポンスにする。
+// 進み具合は、それぞれのタスクの直下のサブタスクを使って計算する。
+func buildSubtaskTree(rootId uint, subtasks []model.Task) ([]model.TaskResponse, int, int) {
+	children := map[uint][]model.Task{}
+	for _, v := range subtasks {
+		if v.ParentId != nil {
+			children[*v.ParentId] = append(children[*v.ParentId], v)
+		}
+	}
+	var build func(parentId uint) ([]model.TaskResponse, int, int)
+	build = func(parentId uint) ([]model.TaskResponse, int, int) {
+		resTasks := []model.TaskResponse{}
+		total, done := 0, 0
+		for _, v := range children[parentId] {
+			res := toTaskResponse(v)
+			subs, subTotal, subDone := build(v.ID)
+			res.Subtasks = subs
+			res.Progress = taskProgress(v, subTotal, subDone)
+			resTasks = append(resTasks, res)
+			if v.Status == model.TaskStatusCancelled {
+				continue
+			}
+			total++
+			if v.Status == model.TaskStatusDone {
+				done++
+			}
+		}
+		return resTasks, total, done
+	}
+	return build(rootId)
+}
+
+// タスクを変更できるか確認する。共有しているプロジェクトのタスクは、編集者以上の権限が必要になる。
+// 見ることもできないタスクの場合は、見つからないエラーを返す。
+func (tu *taskUsecase) checkEditable(userId uint, taskId uint) error {
+	return checkTaskRole(tu.tr, userId, taskId, model.ProjectRoleEditor)
+}
+
+// タスクに対して、role以上の権限があるか確認する。権限が足りない場合はmodel.ErrProjectForbiddenを返す。
+// (添付ファイルやコメントのusecaseでも使う)
+func checkTaskRole(tr repository.ITaskRepository, userId uint, taskId uint, role string) error {
+	var actual string
+	if err := tr.GetTaskRole(&actual, userId, taskId); err != nil {
+		return err
+	}
+	if !model.HasProjectRole(actual, role) {
+		return model.ErrProjectForbidden
+	}
+	return nil
 }
 
-// 返り値の一つ目の型として、taskResponse構造体の配列の型を指定しておく。
-func (tu *taskUsecase) GetAllTasks(userId uint) ([]model.TaskResponse, error) {
+// 担当者にできるか確認する。担当者は、タスクを見ることができるユーザーでなければいけない。
+// repositoryのtaskScopeと同じく、プロジェクトに入っていないタスクは作成したユーザーだけが、
+// プロジェクトのタスクはワークスペースのメンバーでもあるプロジェクトのメンバーが見ることができる。
+func (tu *taskUsecase) checkAssignee(task model.Task) error {
+	if task.AssigneeId == nil {
+		return nil
+	}
+	if task.ProjectId == nil {
+		if *task.AssigneeId != task.UserId {
+			return model.ErrTaskUserNoAccess
+		}
+		return nil
+	}
+	project := model.Project{}
+	if err := tu.pr.GetProjectById(&project, *task.AssigneeId, *task.ProjectId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.ErrTaskUserNoAccess
+		}
+		return err
+	}
+	return nil
+}
+
+// 親タスクにできるか確認する。親タスクは自分のタスクで、自分自身やその子孫であってはいけない。(循環を防ぐ)
+// 新しく作成するタスクの場合は、taskIdに0を渡す。
+func (tu *taskUsecase) checkParent(userId uint, taskId uint, parentId *uint) error {
+	if parentId == nil {
+		return nil
+	}
+	parent := model.Task{}
+	if err := tu.tr.GetTaskById(&parent, userId, *parentId); err != nil {
+		return fmt.Errorf("parent task does not exist")
+	}
+	if taskId == 0 {
+		return nil
+	}
+	if parent.ID == taskId {
+		return fmt.Errorf("task cannot be its own parent")
+	}
+	subtasks := []model.Task{}
+	if err := tu.tr.GetSubtasks(&subtasks, userId, taskId); err != nil {
+		return err
+	}
+	for _, v := range subtasks {
+		if v.ID == parent.ID {
+			return fmt.Errorf("task cannot be moved under its own subtask")
+		}
+	}
+	return nil
+}
+
+// 繰り返しタスクの次の回のタスクを作る。次の発生日時がない(COUNTやUNTILに達した)場合はnilを返す。
+// 次の回は期限を次の発生日時にし、開始日時も同じだけずらす。チェックリストは未完了に戻す。
+// 繰り返しルールは次の回のタスクに引き継ぎ、COUNTは1つ減らしておく。
+func nextRecurringTask(task model.Task) (*model.Task, error) {
+	rule, err := rrule.Parse(task.Recurrence, task.DueAt.Location())
+	if err != nil {
+		return nil, err
+	}
+	next, ok := rule.Next(*task.DueAt, *task.DueAt)
+	if !ok {
+		return nil, nil
+	}
+	if rule.Count > 0 {
+		rule.Count--
+	}
+	checklist := model.Checklist{}
+	for _, v := range task.Checklist {
+		checklist = append(checklist, model.ChecklistItem{Text: v.Text})
+	}
+	labelIds := []uint{}
+	for _, v := range task.Labels {
+		labelIds = append(labelIds, v.ID)
+	}
+	newTask := model.Task{
+		Title:       task.Title,
+		Description: task.Description,
+		Status:      model.TaskStatusTodo,
+		DueAt:       &next,
+		UserId:      task.UserId,
+		AssigneeId:  task.AssigneeId,
+		ProjectId:   task.ProjectId,
+		ParentId:    task.ParentId,
+		Checklist:   checklist,
+		Recurrence:  rule.String(),
+		LabelIds:    labelIds,
+	}
+	if task.StartAt != nil {
+		startAt := task.StartAt.Add(next.Sub(*task.DueAt))
+		newTask.StartAt = &startAt
+	}
+	return &newTask, nil
+}
+
+// 繰り返しルールを、大文字で決まった順番の形式にそろえる。(例: "byday=MO;freq=weekly" → "FREQ=WEEKLY;BYDAY=MO")
+// バリデーションの後に呼ぶので、ここでは解析に失敗しない。
+func normalizeRecurrence(task *model.Task) error {
+	if task.Recurrence == "" {
+		return nil
+	}
+	rule, err := rrule.Parse(task.Recurrence, task.DueAt.Location())
+	if err != nil {
+		return err
+	}
+	task.Recurrence = rule.String()
+	return nil
+}
+
+// 現在のステータスから次のステータスに遷移できるか判定する。
+// 同じステータスのままの場合は遷移しないので、常に許可する。
+func canTransition(from string, to string) bool {
+	if from == to {
+		return true
+	}
+	for _, s := range taskStatusTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// カーソルはクライアントから見て中身を意識しなくてよいように、JSONをbase64でエンコードした文字列にする。
+func encodeTaskCursor(cursor model.TaskCursor) string {
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeTaskCursor(s string) (*model.TaskCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	cursor := model.TaskCursor{}
+	if err := json.Unmarshal(b, &cursor); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &cursor, nil
+}
+
+// 次のページのカーソルに入れる、並び替えキーの値を取り出す。
+// 日時はデータベースの精度(マイクロ秒)のまま比較できるように、ナノ秒まで含めた形式にする。
+func taskSortValue(task model.Task, sort string) string {
+	switch sort {
+	case model.TaskSortUpdatedAt:
+		return task.UpdatedAt.Format(time.RFC3339Nano)
+	case model.TaskSortTitle:
+		return task.Title
+	case model.TaskSortDueAt:
+		if task.DueAt == nil {
+			return ""
+		}
+		return task.DueAt.Format(time.RFC3339Nano)
+	case model.TaskSortPosition:
+		return task.Position
+	default:
+		return task.CreatedAt.Format(time.RFC3339Nano)
+	}
+}
+
+// タスクを入れるプロジェクトに、ログインしているユーザーが編集者以上の権限を持っていて、アーカイブされていないか確認する。
+func (tu *taskUsecase) checkProject(userId uint, projectId *uint) error {
+	if projectId == nil {
+		return nil
+	}
+	project := model.Project{}
+	if err := tu.pr.GetProjectById(&project, userId, *projectId); err != nil {
+		return fmt.Errorf("project does not exist")
+	}
+	if !model.HasProjectRole(project.Role, model.ProjectRoleEditor) {
+		return model.ErrProjectForbidden
+	}
+	if project.Archived {
+		return fmt.Errorf("project is archived")
+	}
+	return nil
+}
+
+// 返り値の一つ目の型として、ページングの情報を含むTaskListResponse構造体を指定しておく。
+func (tu *taskUsecase) GetAllTasks(userId uint, filter model.TaskFilter, page model.TaskPage) (model.TaskListResponse, error) {
+	// 指定がない場合は、ユーザーが並び替えた順番にする。(並び替えていなければ作成した順番)
+	if page.Sort == "" {
+		page.Sort = model.TaskSortPosition
+	}
+	if page.Order == "" {
+		page.Order = "asc"
+	}
+	if page.Limit == 0 {
+		page.Limit = model.DefaultTaskPageLimit
+	}
+	// 絞り込み条件とページングのバリデーションを先に行う。
+	if err := tu.tv.TaskFilterValidate(filter); err != nil {
+		return model.TaskListResponse{}, err
+	}
+	if err := tu.tv.TaskPageValidate(page); err != nil {
+		return model.TaskListResponse{}, err
+	}
+	var after *model.TaskCursor
+	if page.Cursor != "" {
+		cursor, err := decodeTaskCursor(page.Cursor)
+		if err != nil {
+			return model.TaskListResponse{}, err
+		}
+		after = cursor
+	}
 	// 取得するタスクの一覧を格納するためのTask構造体のスライスを定義。
 	tasks := []model.Task{}
 	// RepositoryにあるGetAllTasks()を呼び出す。
-	if err := tu.tr.GetAllTasks(&tasks, userId); err != nil {
-		return nil, err
+	if err := tu.tr.GetAllTasks(&tasks, userId, filter, page, after); err != nil {
+		return model.TaskListResponse{}, err
+	}
+	// Limitより多く取得できた場合は、次のページがある。多い分は取り除いておく。
+	hasMore := len(tasks) > page.Limit
+	if hasMore {
+		tasks = tasks[:page.Limit]
 	}
 	// 取得に成功した場合は、クライアントへのレスポンス用のTaskResponse構造体を0値で作成する。
 	resTasks := []model.TaskResponse{}
 	// for文でタスクを1つ1つ取り出してタスクレスポンス構造体を新しく作っていく。
 	// 作成した新しい構造体をresTasksのスライスにappendで追加していく。
+	// 進み具合の計算に使うサブタスクの数を、ページ内のタスクの分だけまとめて取得する。
+	ids := []uint{}
 	for _, v := range tasks {
-		t := model.TaskResponse{
-			ID:        v.ID,
-			Title:     v.Title,
-			CreatedAt: v.CreatedAt,
-			UpdatedAt: v.UpdatedAt,
-		}
+		ids = append(ids, v.ID)
+	}
+	counts := []model.SubtaskCount{}
+	if err := tu.tr.GetSubtaskCounts(&counts, userId, ids); err != nil {
+		return model.TaskListResponse{}, err
+	}
+	countMap := map[uint]model.SubtaskCount{}
+	for _, v := range counts {
+		countMap[v.ParentId] = v
+	}
+	for _, v := range tasks {
+		t := toTaskResponse(v)
+		t.Progress = taskProgress(v, countMap[v.ID].Total, countMap[v.ID].Done)
 		resTasks = append(resTasks, t)
 	}
-	// resTasks外に返すようにしている。
-	return resTasks, nil
+	res := model.TaskListResponse{
+		Tasks:   resTasks,
+		HasMore: hasMore,
+		Limit:   page.Limit,
+		Sort:    page.Sort,
+		Order:   page.Order,
+	}
+	// 次のページがある場合は、このページの最後のタスクからカーソルを作る。
+	if hasMore {
+		last := tasks[len(tasks)-1]
+		res.NextCursor = encodeTaskCursor(model.TaskCursor{Value: taskSortValue(last, page.Sort), ID: last.ID})
+	}
+	return res, nil
 }
 
 // idからタスクを取り出す。
@@ -61,58 +458,1463 @@ func (tu *taskUsecase) GetTaskById(userId uint, taskId uint) (model.TaskResponse
 	if err := tu.tr.GetTaskById(&task, userId, taskId); err != nil {
 		return model.TaskResponse{}, err
 	}
-	resTask := model.TaskResponse{
-		ID:        task.ID,
-		Title:     task.Title,
-		CreatedAt: task.CreatedAt,
-		UpdatedAt: task.UpdatedAt,
+	// サブタスクをすべて取得して、階層のままレスポンスに入れる。
+	subtasks := []model.Task{}
+	if err := tu.tr.GetSubtasks(&subtasks, userId, taskId); err != nil {
+		return model.TaskResponse{}, err
 	}
+	resTask := toTaskResponse(task)
+	subs, subTotal, subDone := buildSubtaskTree(task.ID, subtasks)
+	resTask.Subtasks = subs
+	resTask.Progress = taskProgress(task, subTotal, subDone)
 	return resTask, nil
 }
 
+// 全文検索。検索語に一致したタスクを関連度の高い順に返す。
+func (tu *taskUsecase) SearchTasks(userId uint, query string, limit int) ([]model.TaskSearchResponse, error) {
+	if limit == 0 {
+		limit = 20
+	}
+	if err := tu.tv.TaskSearchValidate(query, limit); err != nil {
+		return nil, err
+	}
+	hits := []model.TaskSearchHit{}
+	if err := tu.tr.SearchTasks(&hits, userId, query, limit); err != nil {
+		return nil, err
+	}
+	// 抜粋はHTMLとして表示できるように、先にエスケープしてからハイライト部分を<mark>タグに置き換える。
+	highlighter := strings.NewReplacer(repository.SearchHighlightStart, "<mark>", repository.SearchHighlightStop, "</mark>")
+	resHits := []model.TaskSearchResponse{}
+	for _, v := range hits {
+		resHits = append(resHits, model.TaskSearchResponse{
+			Task:    toTaskResponse(v.Task),
+			Rank:    v.Rank,
+			Snippet: highlighter.Replace(html.EscapeString(v.Snippet)),
+		})
+	}
+	return resHits, nil
+}
+
+// 繰り返しタスクの発生日時を展開する。件数が多くなりすぎないように、最大500件までにする。
+func (tu *taskUsecase) GetOccurrences(userId uint, taskId uint, from time.Time, to time.Time) (model.TaskOccurrencesResponse, error) {
+	if err := tu.tv.TaskOccurrencesValidate(from, to); err != nil {
+		return model.TaskOccurrencesResponse{}, err
+	}
+	task := model.Task{}
+	if err := tu.tr.GetTaskById(&task, userId, taskId); err != nil {
+		return model.TaskOccurrencesResponse{}, err
+	}
+	res := model.TaskOccurrencesResponse{
+		TaskId:      task.ID,
+		Recurrence:  task.Recurrence,
+		Occurrences: []time.Time{},
+	}
+	if task.Recurrence == "" || task.DueAt == nil {
+		return res, nil
+	}
+	// 壁時計の時刻を保つように、fromのタイムゾーンで展開する。
+	dtstart := task.DueAt.In(from.Location())
+	rule, err := rrule.Parse(task.Recurrence, dtstart.Location())
+	if err != nil {
+		return model.TaskOccurrencesResponse{}, err
+	}
+	res.Occurrences = rule.Between(dtstart, from, to, 500)
+	return res, nil
+}
+
 // createTasks
+// 作成・更新・削除は、タスクの変更とアクティビティの記録を1つのトランザクションで行う。
 func (tu *taskUsecase) CreateTask(task model.Task) (model.TaskResponse, error) {
+	taskRes := model.TaskResponse{}
+	err := tu.inTransaction(func(txu *taskUsecase) error {
+		var err error
+		taskRes, err = txu.createTask(task)
+		return err
+	})
+	return taskRes, err
+}
+
+func (tu *taskUsecase) createTask(task model.Task) (model.TaskResponse, error) {
+	// ステータスの指定がない場合は、todoとして作成する。
+	if task.Status == "" {
+		task.Status = model.TaskStatusTodo
+	}
+	// バージョンと削除日時はサーバー側で決めるので、送られてきても使わない。
+	task.Version = 0
+	task.DeletedAt.Valid = false
 	// リポジトリのCreateTaskを呼び出す前にtaskValidationを実行する。
 	if err := tu.tv.TaskValidate(task); err != nil {
 		return model.TaskResponse{}, err
 	}
+	// 共有しているプロジェクトのタスクのサブタスクがほかのメンバーから見えるように、プロジェクトの指定がない場合は親タスクと同じプロジェクトに入れる。
+	if task.ParentId != nil && task.ProjectId == nil {
+		parent := model.Task{}
+		if err := tu.tr.GetTaskById(&parent, task.UserId, *task.ParentId); err == nil {
+			task.ProjectId = parent.ProjectId
+		}
+	}
+	if err := tu.checkProject(task.UserId, task.ProjectId); err != nil {
+		return model.TaskResponse{}, err
+	}
+	if err := tu.checkParent(task.UserId, 0, task.ParentId); err != nil {
+		return model.TaskResponse{}, err
+	}
+	if err := tu.checkAssignee(task); err != nil {
+		return model.TaskResponse{}, err
+	}
+	if task.Checklist == nil {
+		task.Checklist = model.Checklist{}
+	}
+	if err := normalizeRecurrence(&task); err != nil {
+		return model.TaskResponse{}, err
+	}
+	// ラベルはlabel_idsで指定されたものだけを、作成した後に関連付ける。
+	labelIds := task.LabelIds
+	task.Labels = nil
+	// 新しいタスクは一番後ろに並べる。
+	pos, err := tu.positionAtEnd(0)
+	if err != nil {
+		return model.TaskResponse{}, err
+	}
+	task.Position = pos
+	// 最初から完了として作成する場合は、完了日時を記録しておく。
+	task.CompletedAt = nil
+	if task.Status == model.TaskStatusDone {
+		now := time.Now()
+		task.CompletedAt = &now
+	}
 	// エラーが発生した場合、TaskResponse構造体の0値の実体とエラーをreturnで返す。
 	if err := tu.tr.CreateTask(&task); err != nil {
 		return model.TaskResponse{}, err
 	}
+	if labelIds != nil {
+		if err := tu.tr.ReplaceTaskLabels(&task, task.UserId, labelIds); err != nil {
+			return model.TaskResponse{}, err
+		}
+	}
 	// 成功した場合は、引数で渡したアドレスが指し示す先の値が新規作成したタスクの値で書き変わる。
-	resTask := model.TaskResponse{
-		ID:        task.ID,
-		Title:     task.Title,
-		CreatedAt: task.CreatedAt,
-		UpdatedAt: task.UpdatedAt,
+	resTask := toTaskResponse(task)
+	if err := tu.recordChange(model.ActivityActionCreated, task.UserId, nil, &resTask); err != nil {
+		return model.TaskResponse{}, err
 	}
 	return resTask, nil
 }
 
 func (tu *taskUsecase) UpdateTask(task model.Task, userId uint, taskId uint) (model.TaskResponse, error) {
-	// リポジトリのCreateTaskを呼び出す前にtaskValidationを実行する。
+	taskRes := model.TaskResponse{}
+	err := tu.inTransaction(func(txu *taskUsecase) error {
+		var err error
+		taskRes, err = txu.updateTask(task, userId, taskId)
+		return err
+	})
+	return taskRes, err
+}
+
+func (tu *taskUsecase) updateTask(task model.Task, userId uint, taskId uint) (model.TaskResponse, error) {
+	if err := tu.checkEditable(userId, taskId); err != nil {
+		return model.TaskResponse{}, err
+	}
+	// ステータスの遷移を判定するために、更新前のタスクを取得しておく。
+	current := model.Task{}
+	if err := tu.tr.GetTaskById(&current, userId, taskId); err != nil {
+		return model.TaskResponse{}, err
+	}
+	// ステータスが送られてこなかった場合は、現在のステータスのままにする。
+	if task.Status == "" {
+		task.Status = current.Status
+	}
+	// 担当者はラベルと同じく、送られてこなかった場合は今の担当者のままにする。(外す場合はPATCHか担当者の変更を使う)
+	if task.AssigneeId == nil {
+		task.AssigneeId = current.AssigneeId
+	}
+	// リポジトリのUpdateTaskを呼び出す前にtaskValidationを実行する。
 	// バリデーションをかけたいtaskを引数に入れる。
 	if err := tu.tv.TaskValidate(task); err != nil {
 		return model.TaskResponse{}, err
 	}
+	return tu.saveTask(task, current, userId, taskId)
+}
+
+// 部分更新(JSON Merge Patch)。送られてきたフィールドだけを今のタスクに上書きし、変更したフィールドだけをバリデーションする。
+// 値がnullのフィールドは、未設定(空文字や空の配列、null)に戻す。
+// versionが0より大きい場合は、そのバージョンのときだけ更新する。
+func (tu *taskUsecase) PatchTask(patch map[string]json.RawMessage, version uint, userId uint, taskId uint) (model.TaskResponse, error) {
+	taskRes := model.TaskResponse{}
+	err := tu.inTransaction(func(txu *taskUsecase) error {
+		var err error
+		taskRes, err = txu.patchTask(patch, version, userId, taskId)
+		return err
+	})
+	return taskRes, err
+}
+
+func (tu *taskUsecase) patchTask(patch map[string]json.RawMessage, version uint, userId uint, taskId uint) (model.TaskResponse, error) {
+	if err := tu.checkEditable(userId, taskId); err != nil {
+		return model.TaskResponse{}, err
+	}
+	current := model.Task{}
+	if err := tu.tr.GetTaskById(&current, userId, taskId); err != nil {
+		return model.TaskResponse{}, err
+	}
+	task := current
+	task.User = model.User{}
+	task.LabelIds = nil
+	task.Version = version
+	fields, err := applyTaskPatch(&task, patch)
+	if err != nil {
+		return model.TaskResponse{}, err
+	}
+	if err := tu.tv.TaskPatchValidate(task, fields); err != nil {
+		return model.TaskResponse{}, err
+	}
+	return tu.saveTask(task, current, userId, taskId)
+}
+
+// 担当者だけを変える部分更新。PATCHでassignee_idを送った場合と同じように、バージョンを上げて変更を記録する。
+func (tu *taskUsecase) AssignTask(assigneeId *uint, version uint, userId uint, taskId uint) (model.TaskResponse, error) {
+	raw, err := json.Marshal(assigneeId)
+	if err != nil {
+		return model.TaskResponse{}, err
+	}
+	return tu.PatchTask(map[string]json.RawMessage{"assignee_id": raw}, version, userId, taskId)
+}
+
+// 2つのidが同じか比べる。どちらもnilの場合も同じとする。
+func equalUintPtr(a *uint, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// PATCHで変更できないフィールド。サーバー側で決まる値なので、送られてきた場合はエラーにする。
+var taskReadOnlyFields = map[string]bool{
+	"id":              true,
+	"creator_id":      true,
+	"completed_at":    true,
+	"position":        true,
+	"version":         true,
+	"progress":        true,
+	"labels":          true,
+	"subtasks":        true,
+	"next_occurrence": true,
+	"created_at":      true,
+	"updated_at":      true,
+	"deleted_at":      true,
+}
+
+// マージパッチをタスクに適用し、変更したフィールドの名前を返す。
+func applyTaskPatch(task *model.Task, patch map[string]json.RawMessage) ([]string, error) {
+	targets := map[string]interface{}{
+		"title":       &task.Title,
+		"description": &task.Description,
+		"status":      &task.Status,
+		"start_at":    &task.StartAt,
+		"due_at":      &task.DueAt,
+		"assignee_id": &task.AssigneeId,
+		"project_id":  &task.ProjectId,
+		"parent_id":   &task.ParentId,
+		"checklist":   &task.Checklist,
+		"recurrence":  &task.Recurrence,
+		"label_ids":   &task.LabelIds,
+	}
+	fields := make([]string, 0, len(patch))
+	for name, raw := range patch {
+		if taskReadOnlyFields[name] {
+			return nil, fmt.Errorf("%s cannot be changed", name)
+		}
+		target, ok := targets[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %s", name)
+		}
+		if string(bytes.TrimSpace(raw)) == "null" {
+			// json.Unmarshalはnullを受け取っても値を変えないので、ゼロ値を直接入れる。
+			v := reflect.ValueOf(target).Elem()
+			v.Set(reflect.Zero(v.Type()))
+		} else if err := json.Unmarshal(raw, target); err != nil {
+			return nil, fmt.Errorf("invalid value for %s", name)
+		}
+		fields = append(fields, name)
+	}
+	// label_idsのnilは「今のラベルのまま」を意味するので、nullが送られてきた場合は空にしてラベルを外す。
+	if _, ok := patch["label_ids"]; ok && task.LabelIds == nil {
+		task.LabelIds = []uint{}
+	}
+	return fields, nil
+}
+
+// 更新前のタスク(current)と比べながら、バリデーション済みのタスクを保存する。PUTとPATCHで共通の処理。
+// task.Versionが0より大きい場合は、そのバージョンのときだけ更新する。
+func (tu *taskUsecase) saveTask(task model.Task, current model.Task, userId uint, taskId uint) (model.TaskResponse, error) {
+	if task.Version > 0 && task.Version != current.Version {
+		return model.TaskResponse{}, model.ErrTaskVersionConflict
+	}
+	if !canTransition(current.Status, task.Status) {
+		return model.TaskResponse{}, fmt.Errorf("cannot change status from %s to %s", current.Status, task.Status)
+	}
+	// 終わっていないタスクに依存しているタスクは、完了にできない。(中止にはできる)
+	if task.Status == model.TaskStatusDone && current.Status != model.TaskStatusDone {
+		var blockers int64
+		if err := tu.tr.CountBlockers(&blockers, taskId); err != nil {
+			return model.TaskResponse{}, err
+		}
+		if blockers > 0 {
+			return model.TaskResponse{}, model.ErrTaskBlocked
+		}
+	}
+	// 別のプロジェクトに移す場合だけ、移動先のプロジェクトを確認する。
+	if task.ProjectId != nil && (current.ProjectId == nil || *current.ProjectId != *task.ProjectId) {
+		if err := tu.checkProject(userId, task.ProjectId); err != nil {
+			return model.TaskResponse{}, err
+		}
+	}
+	// 親タスクを変える場合だけ、循環しないか確認する。
+	if task.ParentId != nil && (current.ParentId == nil || *current.ParentId != *task.ParentId) {
+		if err := tu.checkParent(userId, taskId, task.ParentId); err != nil {
+			return model.TaskResponse{}, err
+		}
+	}
+	// 担当者を変える場合と、担当者のいるタスクを別のプロジェクトに移す場合は、担当者がタスクを見ることができるか確認する。
+	task.UserId = current.UserId
+	if !equalUintPtr(task.AssigneeId, current.AssigneeId) || !equalUintPtr(task.ProjectId, current.ProjectId) {
+		if err := tu.checkAssignee(task); err != nil {
+			return model.TaskResponse{}, err
+		}
+	}
+	if task.Checklist == nil {
+		task.Checklist = model.Checklist{}
+	}
+	if err := normalizeRecurrence(&task); err != nil {
+		return model.TaskResponse{}, err
+	}
+	// 繰り返しタスクを完了にした場合は、次の回のタスクを作る準備をする。
+	// 繰り返しルールは次の回のタスクに移すので、完了したタスクからは外しておく。(再開して完了し直しても、次の回が二重に作られない)
+	var nextTask *model.Task
+	if task.Recurrence != "" && task.Status == model.TaskStatusDone && current.Status != model.TaskStatusDone {
+		// 次の回にも同じラベルを付けるので、更新後のラベルを渡す。
+		task.Labels = current.Labels
+		if task.LabelIds != nil {
+			task.Labels = nil
+			for _, id := range task.LabelIds {
+				task.Labels = append(task.Labels, model.Label{ID: id})
+			}
+		}
+		next, err := nextRecurringTask(task)
+		if err != nil {
+			return model.TaskResponse{}, err
+		}
+		// 次の回はタスクの持ち主として作成するので、ほかのメンバーのラベルは付けられない。持ち主のラベルだけを引き継ぐ。
+		if next != nil {
+			labelIds := []uint{}
+			if err := tu.tr.GetExistingLabelIds(&labelIds, task.UserId, next.LabelIds); err != nil {
+				return model.TaskResponse{}, err
+			}
+			next.LabelIds = labelIds
+		}
+		nextTask = next
+		task.Recurrence = ""
+	}
+	// 完了にしたときは完了日時を記録し、完了以外に戻したときは完了日時を消す。
+	task.CompletedAt = current.CompletedAt
+	if task.Status == model.TaskStatusDone && current.Status != model.TaskStatusDone {
+		now := time.Now()
+		task.CompletedAt = &now
+	} else if task.Status != model.TaskStatusDone {
+		task.CompletedAt = nil
+	}
 	// UpdateTaskでタスクオブジェクトのアドレスとユーザーID、タスクIDを渡している。
+	labelIds := task.LabelIds
+	task.Labels = nil
 	if err := tu.tr.UpdateTask(&task, userId, taskId); err != nil {
 		return model.TaskResponse{}, err
 	}
+	if task.Status != current.Status {
+		if err := tu.syncBoardCards(taskId, task.Status); err != nil {
+			return model.TaskResponse{}, err
+		}
+	}
+	// label_idsが送られてきた場合だけラベルを付け替え、送られてこなかった場合は今のラベルのままにする。
+	if labelIds != nil {
+		if err := tu.tr.ReplaceTaskLabels(&task, userId, labelIds); err != nil {
+			return model.TaskResponse{}, err
+		}
+	} else {
+		task.Labels = current.Labels
+	}
+	resTask := toTaskResponse(task)
+	before := toTaskResponse(current)
+	if err := tu.recordChange(model.ActivityActionUpdated, userId, &before, &resTask); err != nil {
+		return model.TaskResponse{}, err
+	}
+	if nextTask != nil {
+		nextRes, err := tu.createNextOccurrence(*nextTask)
+		if err != nil {
+			return model.TaskResponse{}, err
+		}
+		resTask.NextOccurrence = &nextRes
+	}
+	return resTask, nil
+}
 
-	resTask := model.TaskResponse{
-		ID:        task.ID,
-		Title:     task.Title,
-		CreatedAt: task.CreatedAt,
-		UpdatedAt: task.UpdatedAt,
+// 繰り返しタスクの次の回のタスクを、完了にした更新と同じトランザクションで作成する。
+// 中身は完了したタスクから作ったものなので、リクエストのタスクを作成するときのようなプロジェクトや親タスクの権限の確認はしない。
+// (作成したユーザーがプロジェクトの編集権限を失っていたり、プロジェクトがアーカイブされていても、次の回は作る)
+func (tu *taskUsecase) createNextOccurrence(task model.Task) (model.TaskResponse, error) {
+	labelIds := task.LabelIds
+	task.Labels = nil
+	pos, err := tu.positionAtEnd(0)
+	if err != nil {
+		return model.TaskResponse{}, err
+	}
+	task.Position = pos
+	if err := tu.tr.CreateTask(&task); err != nil {
+		return model.TaskResponse{}, err
+	}
+	if err := tu.tr.ReplaceTaskLabels(&task, task.UserId, labelIds); err != nil {
+		return model.TaskResponse{}, err
+	}
+	resTask := toTaskResponse(task)
+	if err := tu.recordChange(model.ActivityActionCreated, task.UserId, nil, &resTask); err != nil {
+		return model.TaskResponse{}, err
 	}
 	return resTask, nil
 }
 
-func (tu *taskUsecase) DeleteTask(userId uint, taskId uint) error {
-	if err := tu.tr.DeleteTask(userId, taskId); err != nil {
+// 一番後ろの位置を作る。位置の文字列が長くなりすぎた場合は、振り直してから作り直す。
+// excludeIdのタスク(移動するタスク自身)は、一番後ろにあっても無視する。
+func (tu *taskUsecase) positionAtEnd(excludeId uint) (string, error) {
+	for i := 0; i < 2; i++ {
+		last := ""
+		if err := tu.tr.GetLastPosition(&last, excludeId); err != nil {
+			return "", err
+		}
+		pos, err := position.Between(last, "")
+		if err == nil && len(pos) <= position.MaxLength {
+			return pos, nil
+		}
+		if err := tu.tr.RebalancePositions(); err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("failed to create position")
+}
+
+// 移動先の前後のタスクの位置から、間に入る位置を作る。
+// 前後のタスクの位置が空(並び替えの機能を追加する前のタスク)だったり、位置が長くなりすぎたりした場合は、
+// すべてのタスクの位置を振り直してからもう一度作る。
+func (tu *taskUsecase) positionBetween(move model.TaskMove, userId uint, taskId uint) (string, error) {
+	if move.AfterId == nil && move.BeforeId == nil {
+		return tu.positionAtEnd(taskId)
+	}
+	for i := 0; i < 2; i++ {
+		prev, next := "", ""
+		legacy := false
+		if move.AfterId != nil {
+			after := model.Task{}
+			if err := tu.tr.GetTaskById(&after, userId, *move.AfterId); err != nil {
+				return "", fmt.Errorf("after_id task does not exist")
+			}
+			prev = after.Position
+			legacy = legacy || prev == ""
+			// 直前のタスクだけ指定された場合は、その次のタスクとの間に入れる。
+			if move.BeforeId == nil {
+				if err := tu.tr.GetAdjacentPosition(&next, prev, true, taskId); err != nil {
+					return "", err
+				}
+			}
+		}
+		if move.BeforeId != nil {
+			before := model.Task{}
+			if err := tu.tr.GetTaskById(&before, userId, *move.BeforeId); err != nil {
+				return "", fmt.Errorf("before_id task does not exist")
+			}
+			next = before.Position
+			legacy = legacy || next == ""
+			// 直後のタスクだけ指定された場合は、その前のタスクとの間に入れる。
+			if move.AfterId == nil {
+				if err := tu.tr.GetAdjacentPosition(&prev, next, false, taskId); err != nil {
+					return "", err
+				}
+			}
+		}
+		if !legacy {
+			pos, err := position.Between(prev, next)
+			if err == nil && len(pos) <= position.MaxLength {
+				return pos, nil
+			}
+			// 前後のタスクが指定した順番に並んでいない場合は、振り直しても直らないのでエラーにする。
+			if err != nil && move.AfterId != nil && move.BeforeId != nil && prev > next {
+				return "", fmt.Errorf("after_id task must be placed before before_id task")
+			}
+		}
+		if err := tu.tr.RebalancePositions(); err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("failed to create position")
+}
+
+// タスクを、指定した前後のタスクの間に移動する。更新するのは移動したタスクの位置だけ。
+func (tu *taskUsecase) MoveTask(move model.TaskMove, userId uint, taskId uint) (model.TaskResponse, error) {
+	taskRes := model.TaskResponse{}
+	err := tu.inTransaction(func(txu *taskUsecase) error {
+		var err error
+		taskRes, err = txu.moveTask(move, userId, taskId)
+		return err
+	})
+	return taskRes, err
+}
+
+func (tu *taskUsecase) moveTask(move model.TaskMove, userId uint, taskId uint) (model.TaskResponse, error) {
+	if (move.AfterId != nil && *move.AfterId == taskId) || (move.BeforeId != nil && *move.BeforeId == taskId) {
+		return model.TaskResponse{}, fmt.Errorf("task cannot be moved next to itself")
+	}
+	if err := tu.checkEditable(userId, taskId); err != nil {
+		return model.TaskResponse{}, err
+	}
+	pos, err := tu.positionBetween(move, userId, taskId)
+	if err != nil {
+		return model.TaskResponse{}, err
+	}
+	current := model.Task{}
+	if err := tu.tr.GetTaskById(&current, userId, taskId); err != nil {
+		return model.TaskResponse{}, err
+	}
+	if err := tu.tr.UpdatePosition(userId, taskId, pos); err != nil {
+		return model.TaskResponse{}, err
+	}
+	taskRes, err := tu.GetTaskById(userId, taskId)
+	if err != nil {
+		return model.TaskResponse{}, err
+	}
+	before := toTaskResponse(current)
+	if err := tu.recordChange(model.ActivityActionMoved, userId, &before, &taskRes); err != nil {
+		return model.TaskResponse{}, err
+	}
+	return taskRes, nil
+}
+
+// サブタスクも一緒に削除されるが、アクティビティは削除を指示したタスクの分だけ記録する。
+func (tu *taskUsecase) DeleteTask(userId uint, taskId uint, version uint) error {
+	return tu.inTransaction(func(txu *taskUsecase) error {
+		if err := txu.checkEditable(userId, taskId); err != nil {
+			return err
+		}
+		current := model.Task{}
+		if err := txu.tr.GetTaskById(&current, userId, taskId); err != nil {
+			return err
+		}
+		if err := txu.tr.DeleteTask(userId, taskId, version); err != nil {
+			return err
+		}
+		before := toTaskResponse(current)
+		return txu.recordChange(model.ActivityActionDeleted, userId, &before, nil)
+	})
+}
+
+// ゴミ箱のタスクの一覧。
+func (tu *taskUsecase) GetDeletedTasks(userId uint) ([]model.TaskResponse, error) {
+	tasks := []model.Task{}
+	if err := tu.tr.GetDeletedTasks(&tasks, userId); err != nil {
+		return nil, err
+	}
+	resTasks := []model.TaskResponse{}
+	for _, v := range tasks {
+		resTasks = append(resTasks, toTaskResponse(v))
+	}
+	return resTasks, nil
+}
+
+// ゴミ箱のタスクを復元し、サブタスクも含めた復元後のタスクを返す。
+// 復元のアクティビティには、フィールドの変更は記録しない。
+func (tu *taskUsecase) RestoreTask(userId uint, taskId uint) (model.TaskResponse, error) {
+	taskRes := model.TaskResponse{}
+	err := tu.inTransaction(func(txu *taskUsecase) error {
+		if err := txu.checkEditable(userId, taskId); err != nil {
+			return err
+		}
+		task := model.Task{}
+		if err := txu.tr.RestoreTask(&task, userId, taskId); err != nil {
+			return err
+		}
+		var err error
+		if taskRes, err = txu.GetTaskById(userId, taskId); err != nil {
+			return err
+		}
+		return txu.recordChange(model.ActivityActionRestored, userId, nil, &taskRes)
+	})
+	return taskRes, err
+}
+
+func (tu *taskUsecase) PurgeDeletedTasks(retention time.Duration) (int64, error) {
+	var count int64
+	storageKeys := []string{}
+	if err := tu.tr.PurgeDeletedTasks(&count, &storageKeys, time.Now().Add(-retention)); err != nil {
+		return 0, err
+	}
+	// DBからは削除できているので、ファイルの削除に失敗しても続けて削除し、エラーはまとめて返す。
+	errs := []error{}
+	for _, key := range storageKeys {
+		if err := tu.st.Delete(key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return count, errors.Join(errs...)
+}
+
+// atomicモードで操作が失敗したときに、トランザクションをロールバックさせるためのエラー。
+var errTaskBulkFailed = errors.New("bulk operation failed")
+
+// 一括操作。atomicモードでは1つでも失敗したらすべてロールバックし、
+// best_effortモードでは操作ごとにセーブポイントを作って、失敗した操作だけを取り消す。
+func (tu *taskUsecase) BulkTasks(req model.TaskBulkRequest, userId uint) (model.TaskBulkResponse, error) {
+	if req.Mode == "" {
+		req.Mode = model.TaskBulkModeAtomic
+	}
+	if err := tu.tv.TaskBulkValidate(req); err != nil {
+		return model.TaskBulkResponse{}, err
+	}
+	res := model.TaskBulkResponse{Mode: req.Mode, Results: make([]model.TaskBulkResult, len(req.Operations))}
+	for i, op := range req.Operations {
+		res.Results[i] = model.TaskBulkResult{Index: i, Op: op.Op, TaskId: op.TaskId, Status: model.TaskBulkStatusSkipped}
+	}
+	err := tu.tr.Transaction(func(tr repository.ITaskRepository) error {
+		for i, op := range req.Operations {
+			var taskRes *model.TaskResponse
+			var opErr error
+			if req.Mode == model.TaskBulkModeAtomic {
+				taskRes, opErr = tu.withRepository(tr).runBulkOperation(op, userId)
+			} else {
+				opErr = tr.Transaction(func(tr repository.ITaskRepository) error {
+					var err error
+					taskRes, err = tu.withRepository(tr).runBulkOperation(op, userId)
+					return err
+				})
+			}
+			result := &res.Results[i]
+			if opErr != nil {
+				result.Status = model.TaskBulkStatusError
+				result.Error = opErr.Error()
+				res.Failed++
+				if req.Mode == model.TaskBulkModeAtomic {
+					return errTaskBulkFailed
+				}
+				continue
+			}
+			result.Status = model.TaskBulkStatusOk
+			result.Task = taskRes
+			if taskRes != nil {
+				result.TaskId = taskRes.ID
+			}
+			res.Succeeded++
+		}
+		return nil
+	})
+	if errors.Is(err, errTaskBulkFailed) {
+		// ロールバックしたので、成功していた操作も取り消されたことにする。
+		for i := range res.Results {
+			if res.Results[i].Status == model.TaskBulkStatusOk {
+				res.Results[i].Status = model.TaskBulkStatusRolledBack
+				res.Results[i].Task = nil
+			}
+		}
+		res.Succeeded = 0
+		return res, nil
+	}
+	if err != nil {
+		return model.TaskBulkResponse{}, err
+	}
+	res.Committed = true
+	return res, nil
+}
+
+// リポジトリだけをトランザクションのものに差し替えたtaskUsecaseを作る。
+func (tu *taskUsecase) withRepository(tr repository.ITaskRepository) *taskUsecase {
+	return &taskUsecase{tr: tr, pr: tu.pr, tv: tu.tv, lv: tu.lv, st: tu.st}
+}
+
+// fnの中の変更を、1つのトランザクションで行う。すでにトランザクションの中の場合は、セーブポイントになる。
+func (tu *taskUsecase) inTransaction(fn func(txu *taskUsecase) error) error {
+	return tu.tr.Transaction(func(tr repository.ITaskRepository) error {
+		return fn(tu.withRepository(tr))
+	})
+}
+
+// アクティビティに記録するフィールドの、JSONの名前と並び順。
+var taskActivityFieldNames = []string{
+	"title", "description", "status", "start_at", "due_at", "completed_at",
+	"assignee_id", "project_id", "parent_id", "checklist", "recurrence", "labels", "position",
+}
+
+// 記録するフィールドの値。DBから取得した日時とリクエストで受け取った日時を比べられるように、
+// 日時はUTCにしてDBの精度(マイクロ秒)にそろえ、ラベルは名前の順に並べた名前の一覧にする。
+func taskActivityFields(task model.TaskResponse) map[string]interface{} {
+	activityTime := func(t *time.Time) *time.Time {
+		if t == nil {
+			return nil
+		}
+		u := t.UTC().Truncate(time.Microsecond)
+		return &u
+	}
+	labels := []string{}
+	for _, v := range task.Labels {
+		labels = append(labels, v.Name)
+	}
+	sort.Strings(labels)
+	checklist := task.Checklist
+	if checklist == nil {
+		checklist = model.Checklist{}
+	}
+	return map[string]interface{}{
+		"title":        task.Title,
+		"description":  task.Description,
+		"status":       task.Status,
+		"start_at":     activityTime(task.StartAt),
+		"due_at":       activityTime(task.DueAt),
+		"completed_at": activityTime(task.CompletedAt),
+		"assignee_id":  task.AssigneeId,
+		"project_id":   task.ProjectId,
+		"parent_id":    task.ParentId,
+		"checklist":    checklist,
+		"recurrence":   task.Recurrence,
+		"labels":       labels,
+		"position":     task.Position,
+	}
+}
+
+// 変更前と変更後のタスクを比べて、値が変わったフィールドを返す。
+// 作成(beforeがnil)と削除(afterがnil)の場合は、値が空でないフィールドを返す。(並び順の位置は除く)
+func diffTasks(before *model.TaskResponse, after *model.TaskResponse) (model.FieldChanges, error) {
+	marshal := func(task *model.TaskResponse) (map[string]json.RawMessage, error) {
+		values := map[string]json.RawMessage{}
+		if task == nil {
+			return values, nil
+		}
+		for name, v := range taskActivityFields(*task) {
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			values[name] = b
+		}
+		return values, nil
+	}
+	beforeValues, err := marshal(before)
+	if err != nil {
+		return nil, err
+	}
+	afterValues, err := marshal(after)
+	if err != nil {
+		return nil, err
+	}
+	null := json.RawMessage("null")
+	changes := model.FieldChanges{}
+	for _, name := range taskActivityFieldNames {
+		if name == "position" && (before == nil || after == nil) {
+			continue
+		}
+		b, ok := beforeValues[name]
+		if !ok {
+			b = null
+		}
+		a, ok := afterValues[name]
+		if !ok {
+			a = null
+		}
+		if bytes.Equal(b, a) {
+			continue
+		}
+		if (before == nil && isEmptyJSON(a)) || (after == nil && isEmptyJSON(b)) {
+			continue
+		}
+		changes[name] = model.FieldChange{Before: b, After: a}
+	}
+	return changes, nil
+}
+
+func isEmptyJSON(v json.RawMessage) bool {
+	switch string(v) {
+	case "null", `""`, "[]":
+		return true
+	}
+	return false
+}
+
+// タスクの変更を、アクティビティと、元に戻すためのスナップショットに記録する。
+// 更新で値が何も変わっていない場合は、アクティビティは記録しない。(バージョンは上がるので、スナップショットは保存する)
+// 復元のアクティビティには、フィールドの変更は記録しない。
+func (tu *taskUsecase) recordChange(action string, userId uint, before *model.TaskResponse, after *model.TaskResponse) error {
+	changes := model.FieldChanges{}
+	if action != model.ActivityActionRestored {
+		var err error
+		if changes, err = diffTasks(before, after); err != nil {
+			return err
+		}
+	}
+	activity := model.Activity{Action: action, Changes: changes, UserId: userId}
+	revision := model.TaskRevision{Action: action, UserId: userId}
+	if after != nil {
+		activity.TaskId, activity.TaskTitle = after.ID, after.Title
+		revision.TaskId, revision.Version, revision.Snapshot = after.ID, after.Version, taskSnapshot(*after)
+	} else {
+		activity.TaskId, activity.TaskTitle = before.ID, before.Title
+		revision.TaskId, revision.Version, revision.Snapshot = before.ID, before.Version, taskSnapshot(*before)
+		revision.Deleted = true
+	}
+	if len(changes) > 0 || (action != model.ActivityActionUpdated && action != model.ActivityActionMoved) {
+		if err := tu.tr.CreateActivity(&activity); err != nil {
+			return err
+		}
+	}
+	return tu.tr.CreateRevision(&revision)
+}
+
+func taskSnapshot(task model.TaskResponse) model.TaskSnapshot {
+	labelIds := []uint{}
+	for _, v := range task.Labels {
+		labelIds = append(labelIds, v.ID)
+	}
+	return model.TaskSnapshot{
+		Title:       task.Title,
+		Description: task.Description,
+		Status:      task.Status,
+		CompletedAt: task.CompletedAt,
+		StartAt:     task.StartAt,
+		DueAt:       task.DueAt,
+		AssigneeId:  task.AssigneeId,
+		ProjectId:   task.ProjectId,
+		ParentId:    task.ParentId,
+		Checklist:   task.Checklist,
+		Recurrence:  task.Recurrence,
+		Position:    task.Position,
+		LabelIds:    labelIds,
+		CreatedAt:   task.CreatedAt,
+		UserId:      task.CreatorId,
+	}
+}
+
+// ユーザーの最後の操作を取り消す。取り消しも1つの操作として記録するが、取り消しの対象にはならない。(続けて呼ぶと、さらに前の操作を取り消す)
+//   - 作成・復元の取り消し: タスクを削除する。
+//   - 削除の取り消し: ゴミ箱にあれば復元し、完全に削除されていれば同じidで作り直す。
+//   - 更新・移動などの取り消し: 1つ前のスナップショットの状態に戻す。
+//
+// 取り消す操作の後に、取り消していない別の操作でタスクが変わっている場合はmodel.ErrTaskVersionConflictを返す。
+func (tu *taskUsecase) UndoTask(userId uint) (model.TaskUndoResponse, error) {
+	res := model.TaskUndoResponse{}
+	err := tu.inTransaction(func(txu *taskUsecase) error {
+		last := model.TaskRevision{}
+		if err := txu.tr.GetLastUndoableRevision(&last, userId); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return model.ErrNothingToUndo
+			}
+			return err
+		}
+		res.UndoneAction, res.TaskId = last.Action, last.TaskId
+		var newer int64
+		if err := txu.tr.CountNewerRevisions(&newer, last.TaskId, last.ID); err != nil {
+			return err
+		}
+		if newer > 0 {
+			return model.ErrTaskVersionConflict
+		}
+		// 取り消す前に、プロジェクトの権限が閲覧者に変わっている場合などは取り消せない。
+		if err := txu.checkEditable(userId, last.TaskId); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		current := model.Task{}
+		found := true
+		if err := txu.tr.GetTaskWithDeleted(&current, userId, last.TaskId); err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			found = false
+		}
+		var taskRes model.TaskResponse
+		var err error
+		switch {
+		case last.Deleted && !found:
+			taskRes, err = txu.recreateTask(model.ActivityActionUndone, userId, last)
+		case last.Deleted:
+			if !current.DeletedAt.Valid {
+				return model.ErrTaskVersionConflict
+			}
+			taskRes, err = txu.restoreForUndo(userId, last.TaskId)
+		case !found || current.DeletedAt.Valid:
+			return model.ErrTaskVersionConflict
+		case last.Action == model.ActivityActionCreated || last.Action == model.ActivityActionRestored:
+			if err := txu.tr.DeleteTask(userId, last.TaskId, current.Version); err != nil {
+				return err
+			}
+			before := toTaskResponse(current)
+			err = txu.recordChange(model.ActivityActionUndone, userId, &before, nil)
+		default:
+			prev := model.TaskRevision{}
+			if err := txu.tr.GetPreviousRevision(&prev, last.TaskId, last.ID); err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return model.ErrNothingToUndo
+				}
+				return err
+			}
+			taskRes, err = txu.applySnapshot(model.ActivityActionUndone, userId, current, prev.Snapshot)
+		}
+		if err != nil {
+			return err
+		}
+		if taskRes.ID != 0 {
+			res.Task = &taskRes
+		}
+		return txu.tr.MarkRevisionUndone(last.ID)
+	})
+	return res, err
+}
+
+// タスクを、バージョンがto以下で一番新しいスナップショットの状態に戻す。
+// ゴミ箱にある場合は復元してから戻し、完全に削除されている場合は同じidで作り直す。
+// versionが0より大きい場合は、今のタスクがそのバージョンのときだけ戻す。
+func (tu *taskUsecase) RevertTask(userId uint, taskId uint, to uint, version uint) (model.TaskResponse, error) {
+	taskRes := model.TaskResponse{}
+	err := tu.inTransaction(func(txu *taskUsecase) error {
+		revision := model.TaskRevision{}
+		if err := txu.tr.GetRevisionAtVersion(&revision, userId, taskId, to); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return model.ErrTaskRevisionNotFound
+			}
+			return err
+		}
+		if err := txu.checkEditable(userId, taskId); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		current := model.Task{}
+		var err error
+		if err = txu.tr.GetTaskWithDeleted(&current, userId, taskId); err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			taskRes, err = txu.recreateTask(model.ActivityActionReverted, userId, revision)
+			return err
+		}
+		if version > 0 && current.Version != version {
+			return model.ErrTaskVersionConflict
+		}
+		if current.DeletedAt.Valid {
+			if err := txu.tr.RestoreTask(&current, userId, taskId); err != nil {
+				return err
+			}
+		}
+		taskRes, err = txu.applySnapshot(model.ActivityActionReverted, userId, current, revision.Snapshot)
 		return err
+	})
+	return taskRes, err
+}
+
+// スナップショットのプロジェクトや親タスクがもうない場合は、インボックスのタスクや親のないタスクにする。
+// 担当者がタスクを見ることができなくなっている場合は、担当者を外す。
+// タスクの作成者はスナップショットのとおりに戻し、作成者を保存していない古いスナップショットの場合だけuserIdのユーザーにする。
+func (tu *taskUsecase) snapshotToTask(snapshot model.TaskSnapshot, userId uint, taskId uint) model.Task {
+	creatorId := snapshot.UserId
+	if creatorId == 0 {
+		creatorId = userId
+	}
+	task := model.Task{
+		Title:       snapshot.Title,
+		Description: snapshot.Description,
+		Status:      snapshot.Status,
+		CompletedAt: snapshot.CompletedAt,
+		StartAt:     snapshot.StartAt,
+		DueAt:       snapshot.DueAt,
+		AssigneeId:  snapshot.AssigneeId,
+		ProjectId:   snapshot.ProjectId,
+		ParentId:    snapshot.ParentId,
+		Checklist:   snapshot.Checklist,
+		Recurrence:  snapshot.Recurrence,
+		Position:    snapshot.Position,
+		UserId:      creatorId,
+	}
+	if task.Checklist == nil {
+		task.Checklist = model.Checklist{}
+	}
+	if tu.checkProject(userId, task.ProjectId) != nil {
+		task.ProjectId = nil
+	}
+	if tu.checkParent(userId, taskId, task.ParentId) != nil {
+		task.ParentId = nil
+	}
+	if tu.checkAssignee(task) != nil {
+		task.AssigneeId = nil
+	}
+	return task
+}
+
+// 今のタスクを、スナップショットの状態で上書きする。ラベルは、今もあるものだけを付け直す。
+func (tu *taskUsecase) applySnapshot(action string, userId uint, current model.Task, snapshot model.TaskSnapshot) (model.TaskResponse, error) {
+	task := tu.snapshotToTask(snapshot, userId, current.ID)
+	task.Version = current.Version
+	if err := tu.tr.ReplaceTask(&task, userId, current.ID); err != nil {
+		return model.TaskResponse{}, err
+	}
+	if task.Status != current.Status {
+		if err := tu.syncBoardCards(current.ID, task.Status); err != nil {
+			return model.TaskResponse{}, err
+		}
+	}
+	labelIds := []uint{}
+	if err := tu.tr.GetExistingLabelIds(&labelIds, userId, snapshot.LabelIds); err != nil {
+		return model.TaskResponse{}, err
+	}
+	if err := tu.tr.ReplaceTaskLabels(&task, userId, labelIds); err != nil {
+		return model.TaskResponse{}, err
+	}
+	taskRes, err := tu.GetTaskById(userId, current.ID)
+	if err != nil {
+		return model.TaskResponse{}, err
+	}
+	before := toTaskResponse(current)
+	if err := tu.recordChange(action, userId, &before, &taskRes); err != nil {
+		return model.TaskResponse{}, err
+	}
+	return taskRes, nil
+}
+
+// 完全に削除されたタスクを、スナップショットから同じidで作り直す。
+// 作り直したタスクのバージョンは、スナップショットの次のバージョンにする。
+func (tu *taskUsecase) recreateTask(action string, userId uint, revision model.TaskRevision) (model.TaskResponse, error) {
+	task := tu.snapshotToTask(revision.Snapshot, userId, revision.TaskId)
+	task.ID = revision.TaskId
+	task.Version = revision.Version + 1
+	task.CreatedAt = revision.Snapshot.CreatedAt
+	if err := tu.tr.CreateTask(&task); err != nil {
+		return model.TaskResponse{}, err
+	}
+	labelIds := []uint{}
+	if err := tu.tr.GetExistingLabelIds(&labelIds, userId, revision.Snapshot.LabelIds); err != nil {
+		return model.TaskResponse{}, err
+	}
+	if err := tu.tr.ReplaceTaskLabels(&task, userId, labelIds); err != nil {
+		return model.TaskResponse{}, err
+	}
+	taskRes, err := tu.GetTaskById(userId, task.ID)
+	if err != nil {
+		return model.TaskResponse{}, err
+	}
+	if err := tu.recordChange(action, userId, nil, &taskRes); err != nil {
+		return model.TaskResponse{}, err
+	}
+	return taskRes, nil
+}
+
+// ゴミ箱のタスクを、サブタスクも含めて復元する。
+func (tu *taskUsecase) restoreForUndo(userId uint, taskId uint) (model.TaskResponse, error) {
+	task := model.Task{}
+	if err := tu.tr.RestoreTask(&task, userId, taskId); err != nil {
+		return model.TaskResponse{}, err
+	}
+	taskRes, err := tu.GetTaskById(userId, taskId)
+	if err != nil {
+		return model.TaskResponse{}, err
+	}
+	if err := tu.recordChange(model.ActivityActionUndone, userId, nil, &taskRes); err != nil {
+		return model.TaskResponse{}, err
+	}
+	return taskRes, nil
+}
+
+// 一括操作の1つ分を実行する。削除のときは返すタスクがないのでnilになる。
+func (tu *taskUsecase) runBulkOperation(op model.TaskBulkOperation, userId uint) (*model.TaskResponse, error) {
+	var taskRes model.TaskResponse
+	var err error
+	switch op.Op {
+	case model.TaskBulkOpCreate:
+		task := model.Task{}
+		if err := json.Unmarshal(op.Task, &task); err != nil {
+			return nil, fmt.Errorf("invalid task")
+		}
+		task.ID = 0
+		task.UserId = userId
+		taskRes, err = tu.CreateTask(task)
+	case model.TaskBulkOpUpdate:
+		patch := map[string]json.RawMessage{}
+		if err := json.Unmarshal(op.Task, &patch); err != nil {
+			return nil, fmt.Errorf("task must be a JSON object")
+		}
+		taskRes, err = tu.PatchTask(patch, op.Version, userId, op.TaskId)
+	case model.TaskBulkOpComplete:
+		patch := map[string]json.RawMessage{"status": json.RawMessage(`"` + model.TaskStatusDone + `"`)}
+		taskRes, err = tu.PatchTask(patch, op.Version, userId, op.TaskId)
+	case model.TaskBulkOpDelete:
+		return nil, tu.DeleteTask(userId, op.TaskId, op.Version)
+	default:
+		return nil, fmt.Errorf("unknown op: %s", op.Op)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &taskRes, nil
+}
+
+// エクスポートで、一度に取得するタスクの数。
+const taskExportBatchSize = 500
+
+func (tu *taskUsecase) ExportTasks(userId uint, format string, w io.Writer) error {
+	writer, err := taskio.NewWriter(format, w)
+	if err != nil {
+		return err
+	}
+	err = tu.tr.ExportTasks(userId, taskExportBatchSize, func(tasks []model.Task) error {
+		for _, v := range tasks {
+			if err := writer.Write(toTaskResponse(v)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// ドライランや、エラーのタスクがあったときに、トランザクションをロールバックさせるためのエラー。
+var errTaskImportRollback = errors.New("import rolled back")
+
+// インポート。ファイルを1件ずつ読みながら、1つのトランザクションの中でタスクを作成する。
+// ドライランの場合とエラーのタスクが1件でもあった場合は、最後にロールバックするので何も反映されない。
+// (ドライランでも実際に作成してからロールバックするので、親子関係やラベルの作成も含めて本番と同じ結果になる)
+func (tu *taskUsecase) ImportTasks(userId uint, opts model.TaskImportOptions, r io.Reader) (model.TaskImportResponse, error) {
+	if opts.Duplicates == "" {
+		opts.Duplicates = model.TaskImportDuplicatesSkip
+	}
+	if err := tu.tv.TaskImportValidate(opts); err != nil {
+		return model.TaskImportResponse{}, err
+	}
+	reader, err := taskio.NewReader(opts.Format, r)
+	if err != nil {
+		return model.TaskImportResponse{}, err
+	}
+	res := model.TaskImportResponse{Format: opts.Format, DryRun: opts.DryRun, Results: []model.TaskImportResult{}}
+	err = tu.tr.Transaction(func(tr repository.ITaskRepository) error {
+		txu := tu.withRepository(tr)
+		// 重複の確認のために、ファイルの中で作成したタスクのタイトルと期限を覚えておく。
+		seen := map[string]bool{}
+		// エクスポートしたときのタスクのidと、作成したタスクのidの対応。親タスクの付け替えに使う。
+		idMap := map[uint]uint{}
+		for {
+			rec, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			res.Total++
+			if res.Total > model.MaxTaskImportRecords {
+				return fmt.Errorf("limited max %d tasks", model.MaxTaskImportRecords)
+			}
+			result := txu.importTask(rec, userId, opts, seen, idMap)
+			switch result.Action {
+			case model.TaskImportActionCreate:
+				res.Created++
+			case model.TaskImportActionSkip:
+				res.Skipped++
+			case model.TaskImportActionError:
+				res.Failed++
+			}
+			res.Results = append(res.Results, result)
+		}
+		if opts.DryRun || res.Failed > 0 {
+			return errTaskImportRollback
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errTaskImportRollback) {
+		return model.TaskImportResponse{}, err
+	}
+	res.Committed = err == nil
+	if !res.Committed {
+		// ロールバックしたので、作成したタスクのidは存在しない。
+		for i := range res.Results {
+			res.Results[i].TaskId = 0
+		}
+	}
+	return res, nil
+}
+
+// インポートするタスク1件分を作成する。エラーになっても続きのタスクを作成できるように、セーブポイントの中で作成する。
+func (tu *taskUsecase) importTask(rec taskio.Record, userId uint, opts model.TaskImportOptions, seen map[string]bool, idMap map[uint]uint) model.TaskImportResult {
+	result := model.TaskImportResult{Line: rec.Line, Title: rec.Task.Title, Action: model.TaskImportActionCreate}
+	fail := func(err error) model.TaskImportResult {
+		result.Action = model.TaskImportActionError
+		result.Message = err.Error()
+		return result
+	}
+	if rec.Err != nil {
+		return fail(rec.Err)
+	}
+	src := rec.Task
+	task := model.Task{
+		Title:       src.Title,
+		Description: src.Description,
+		Status:      src.Status,
+		StartAt:     src.StartAt,
+		DueAt:       src.DueAt,
+		Checklist:   src.Checklist,
+		Recurrence:  src.Recurrence,
+		UserId:      userId,
+	}
+	if task.Status == "" {
+		task.Status = model.TaskStatusTodo
+	}
+	messages := []string{}
+	// 親タスクは、このファイルの中で先に作成したタスクの場合だけ付け替える。
+	if src.ParentId != nil {
+		if id, ok := idMap[*src.ParentId]; ok {
+			task.ParentId = &id
+		} else {
+			messages = append(messages, "parent task is not in the file, imported as a top-level task")
+		}
+	}
+	// 他のアカウントからエクスポートしたファイルなど、プロジェクトがない場合はインボックスに入れる。
+	if src.ProjectId != nil {
+		if err := tu.checkProject(userId, src.ProjectId); err == nil {
+			task.ProjectId = src.ProjectId
+		} else {
+			messages = append(messages, "project does not exist, imported to the inbox")
+		}
+	}
+	if err := tu.tv.TaskValidate(task); err != nil {
+		return fail(err)
+	}
+	wanted := []model.Label{}
+	for _, v := range src.Labels {
+		label := model.Label{Name: v.Name, Color: v.Color}
+		if label.Color == "" {
+			label.Color = model.DefaultLabelColor
+		}
+		if err := tu.lv.LabelValidate(label); err != nil {
+			return fail(fmt.Errorf("labels: %w", err))
+		}
+		wanted = append(wanted, label)
+	}
+	key := task.Title + "\x00"
+	if task.DueAt != nil {
+		key += task.DueAt.UTC().Format(time.RFC3339Nano)
+	}
+	if opts.Duplicates == model.TaskImportDuplicatesSkip {
+		if seen[key] {
+			result.Action = model.TaskImportActionSkip
+			result.Message = "duplicate of an earlier task in the file"
+			return result
+		}
+		var count int64
+		if err := tu.tr.CountDuplicateTasks(&count, userId, task.Title, task.DueAt); err != nil {
+			return fail(err)
+		}
+		if count > 0 {
+			result.Action = model.TaskImportActionSkip
+			result.Message = "duplicate of an existing task"
+			return result
+		}
+	}
+	err := tu.tr.Transaction(func(tr repository.ITaskRepository) error {
+		labels := []model.Label{}
+		if err := tr.FindOrCreateLabels(&labels, userId, wanted); err != nil {
+			return err
+		}
+		task.LabelIds = []uint{}
+		for _, v := range labels {
+			task.LabelIds = append(task.LabelIds, v.ID)
+		}
+		taskRes, err := tu.withRepository(tr).CreateTask(task)
+		if err != nil {
+			return err
+		}
+		result.TaskId = taskRes.ID
+		return nil
+	})
+	if err != nil {
+		return fail(err)
+	}
+	seen[key] = true
+	if src.ID != 0 {
+		idMap[src.ID] = result.TaskId
+	}
+	result.Message = strings.Join(messages, "; ")
+	return result
+}
+
+// 列にカードを置けるか、WIP制限を確認する。すでに列に置かれているカードを動かす場合は、そのカードを数えない。
+func (tu *taskUsecase) checkWipLimit(column model.BoardColumn, taskId uint) error {
+	if column.WipLimit == 0 {
+		return nil
+	}
+	var count int64
+	if err := tu.tr.CountColumnCards(&count, column.ID, taskId); err != nil {
+		return err
+	}
+	if count >= int64(column.WipLimit) {
+		return model.ErrBoardWipLimitExceeded
+	}
+	return nil
+}
+
+// 列の中の、指定した前後のカードの間に入る位置を作る。前後のカードを指定しない場合は、列の末尾にする。
+// 位置が重なっていたり、長くなりすぎたりした場合は、列のカードの位置を振り直してからもう一度作る。
+func (tu *taskUsecase) cardPosition(move model.BoardCardMove, taskId uint) (string, error) {
+	for i := 0; i < 2; i++ {
+		all := []model.BoardCard{}
+		if err := tu.tr.GetColumnCards(&all, move.ColumnId); err != nil {
+			return "", err
+		}
+		cards := []model.BoardCard{}
+		for _, v := range all {
+			if v.TaskId != taskId {
+				cards = append(cards, v)
+			}
+		}
+		indexOf := func(id uint) int {
+			for j, v := range cards {
+				if v.TaskId == id {
+					return j
+				}
+			}
+			return -1
+		}
+		prev, next := "", ""
+		if move.AfterId == nil && move.BeforeId == nil && len(cards) > 0 {
+			prev = cards[len(cards)-1].Position
+		}
+		if move.AfterId != nil {
+			j := indexOf(*move.AfterId)
+			if j < 0 {
+				return "", fmt.Errorf("after_id card is not in the column")
+			}
+			prev = cards[j].Position
+			if move.BeforeId == nil && j+1 < len(cards) {
+				next = cards[j+1].Position
+			}
+		}
+		if move.BeforeId != nil {
+			j := indexOf(*move.BeforeId)
+			if j < 0 {
+				return "", fmt.Errorf("before_id card is not in the column")
+			}
+			next = cards[j].Position
+			if move.AfterId == nil && j > 0 {
+				prev = cards[j-1].Position
+			}
+		}
+		pos, err := position.Between(prev, next)
+		if err == nil && len(pos) <= position.MaxLength {
+			return pos, nil
+		}
+		if err != nil && move.AfterId != nil && move.BeforeId != nil && prev > next {
+			return "", fmt.Errorf("after_id card must be placed before before_id card")
+		}
+		if err := tu.tr.RebalanceColumnCards(move.ColumnId); err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("failed to create position")
+}
+
+// カードを置いたり移したりするには、タスクの編集者以上の権限が必要になる。
+// 列をロックしてから数えるので、同時に置こうとしてもWIP制限を超えない。
+func (tu *taskUsecase) PlaceBoardCard(move model.BoardCardMove, userId uint, boardId uint, taskId uint) (model.BoardCardResponse, error) {
+	cardRes := model.BoardCardResponse{}
+	err := tu.inTransaction(func(txu *taskUsecase) error {
+		var err error
+		cardRes, err = txu.placeBoardCard(move, userId, boardId, taskId)
+		return err
+	})
+	return cardRes, err
+}
+
+func (tu *taskUsecase) placeBoardCard(move model.BoardCardMove, userId uint, boardId uint, taskId uint) (model.BoardCardResponse, error) {
+	if (move.AfterId != nil && *move.AfterId == taskId) || (move.BeforeId != nil && *move.BeforeId == taskId) {
+		return model.BoardCardResponse{}, fmt.Errorf("card cannot be moved next to itself")
+	}
+	if err := tu.checkEditable(userId, taskId); err != nil {
+		return model.BoardCardResponse{}, err
+	}
+	// ゴミ箱のタスクのカードは置けない。
+	taskRes, err := tu.GetTaskById(userId, taskId)
+	if err != nil {
+		return model.BoardCardResponse{}, err
+	}
+	column := model.BoardColumn{}
+	if err := tu.tr.GetBoardColumn(&column, boardId, move.ColumnId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.BoardCardResponse{}, fmt.Errorf("column does not exist")
+		}
+		return model.BoardCardResponse{}, err
+	}
+	if err := tu.checkWipLimit(column, taskId); err != nil {
+		return model.BoardCardResponse{}, err
+	}
+	pos, err := tu.cardPosition(move, taskId)
+	if err != nil {
+		return model.BoardCardResponse{}, err
+	}
+	card := model.BoardCard{BoardId: boardId, ColumnId: column.ID, TaskId: taskId, Position: pos}
+	if err := tu.tr.SaveBoardCard(&card); err != nil {
+		return model.BoardCardResponse{}, err
+	}
+	// カードを先に移しておくので、ステータスを変えたときにこのボードのカードは移し直されない。
+	// ステータスの遷移ルールや依存関係で変えられない場合は、カードも移さない。(ロールバックする)
+	if column.Status != "" && column.Status != taskRes.Status {
+		raw, err := json.Marshal(column.Status)
+		if err != nil {
+			return model.BoardCardResponse{}, err
+		}
+		taskRes, err = tu.patchTask(map[string]json.RawMessage{"status": raw}, 0, userId, taskId)
+		if err != nil {
+			return model.BoardCardResponse{}, err
+		}
+	}
+	return model.BoardCardResponse{
+		TaskId:   card.TaskId,
+		ColumnId: card.ColumnId,
+		Position: card.Position,
+		Task:     taskRes,
+	}, nil
+}
+
+func (tu *taskUsecase) RemoveBoardCard(userId uint, boardId uint, taskId uint) error {
+	if err := tu.checkEditable(userId, taskId); err != nil {
+		return err
+	}
+	return tu.tr.DeleteBoardCard(boardId, taskId)
+}
+
+// タスクのステータスを変えたときに、ステータスに対応する列に置かれているカードを、新しいステータスに対応する列の末尾に移す。
+// 独自の状態の列に置かれているカードと、新しいステータスに対応する列がないボードのカードは、そのままにする。
+// 移す先の列がWIP制限に達している場合は、ステータスも変えられない。
+func (tu *taskUsecase) syncBoardCards(taskId uint, status string) error {
+	cards := []model.BoardCard{}
+	if err := tu.tr.GetTaskBoardCards(&cards, taskId); err != nil {
+		return err
+	}
+	for _, v := range cards {
+		if v.Column.Status == "" || v.Column.Status == status {
+			continue
+		}
+		column := model.BoardColumn{}
+		if err := tu.tr.GetStatusColumn(&column, v.BoardId, status); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			return err
+		}
+		if err := tu.checkWipLimit(column, taskId); err != nil {
+			return err
+		}
+		pos, err := tu.cardPosition(model.BoardCardMove{ColumnId: column.ID}, taskId)
+		if err != nil {
+			return err
+		}
+		card := model.BoardCard{BoardId: v.BoardId, ColumnId: column.ID, TaskId: taskId, Position: pos}
+		if err := tu.tr.SaveBoardCard(&card); err != nil {
+			return err
+		}
 	}
 	return nil
 }
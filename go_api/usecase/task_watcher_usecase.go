@@ -0,0 +1,109 @@
+package usecase
+
+import (
+	"errors"
+	"go_api/model"
+	"go_api/repository"
+
+	"gorm.io/gorm"
+)
+
+type ITaskWatcherUsecase interface {
+	GetWatchers(userId uint, taskId uint) ([]model.TaskWatcherResponse, error)
+	// ウォッチャーを追加する。自分を追加する場合は閲覧者、ほかのユーザーを追加する場合は編集者以上の権限が必要になる。
+	AddWatcher(req model.TaskWatcherRequest, userId uint, taskId uint) (model.TaskWatcherResponse, error)
+	// ウォッチャーを外す。自分はいつでも外すことができ、ほかのユーザーを外すには編集者以上の権限が必要になる。
+	RemoveWatcher(userId uint, taskId uint, watcherId uint) error
+	// workspaceIdのワークスペースを扱うユースケースを返す。
+	InWorkspace(workspaceId uint) ITaskWatcherUsecase
+}
+
+type taskWatcherUsecase struct {
+	wr repository.ITaskWatcherRepository
+	tr repository.ITaskRepository
+}
+
+func NewTaskWatcherUsecase(wr repository.ITaskWatcherRepository, tr repository.ITaskRepository) ITaskWatcherUsecase {
+	return &taskWatcherUsecase{wr, tr}
+}
+
+func (wu *taskWatcherUsecase) InWorkspace(workspaceId uint) ITaskWatcherUsecase {
+	return &taskWatcherUsecase{wu.wr.InWorkspace(workspaceId), wu.tr.InWorkspace(workspaceId)}
+}
+
+func toTaskWatcherResponse(watcher model.TaskWatcher) model.TaskWatcherResponse {
+	return model.TaskWatcherResponse{
+		UserId:    watcher.UserId,
+		Name:      watcher.User.Name,
+		Email:     watcher.User.Email,
+		CreatedAt: watcher.CreatedAt,
+	}
+}
+
+// ウォッチャーを変更できるか確認する。自分のことは閲覧者でも、ほかのユーザーのことは編集者以上の権限があれば変更できる。
+// ウォッチャーにするユーザーも、タスクを見ることができなければいけない。
+func (wu *taskWatcherUsecase) checkWatcher(userId uint, taskId uint, watcherId uint) error {
+	role := model.ProjectRoleEditor
+	if watcherId == userId {
+		role = model.ProjectRoleViewer
+	}
+	if err := checkTaskRole(wu.tr, userId, taskId, role); err != nil {
+		return err
+	}
+	if err := checkTaskRole(wu.tr, watcherId, taskId, model.ProjectRoleViewer); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.ErrTaskUserNoAccess
+		}
+		return err
+	}
+	return nil
+}
+
+func (wu *taskWatcherUsecase) GetWatchers(userId uint, taskId uint) ([]model.TaskWatcherResponse, error) {
+	if err := checkTaskRole(wu.tr, userId, taskId, model.ProjectRoleViewer); err != nil {
+		return nil, err
+	}
+	watchers := []model.TaskWatcher{}
+	if err := wu.wr.GetWatchers(&watchers, userId, taskId); err != nil {
+		return nil, err
+	}
+	resWatchers := []model.TaskWatcherResponse{}
+	for _, v := range watchers {
+		resWatchers = append(resWatchers, toTaskWatcherResponse(v))
+	}
+	return resWatchers, nil
+}
+
+func (wu *taskWatcherUsecase) AddWatcher(req model.TaskWatcherRequest, userId uint, taskId uint) (model.TaskWatcherResponse, error) {
+	watcherId := req.UserId
+	if watcherId == 0 {
+		watcherId = userId
+	}
+	if err := wu.checkWatcher(userId, taskId, watcherId); err != nil {
+		return model.TaskWatcherResponse{}, err
+	}
+	if err := wu.wr.CreateWatcher(&model.TaskWatcher{TaskId: taskId, UserId: watcherId}); err != nil {
+		return model.TaskWatcherResponse{}, err
+	}
+	// すでにウォッチャーだった場合も、追加したときの内容を返せるように取得し直す。
+	watchers := []model.TaskWatcher{}
+	if err := wu.wr.GetWatchers(&watchers, userId, taskId); err != nil {
+		return model.TaskWatcherResponse{}, err
+	}
+	for _, v := range watchers {
+		if v.UserId == watcherId {
+			return toTaskWatcherResponse(v), nil
+		}
+	}
+	return model.TaskWatcherResponse{}, gorm.ErrRecordNotFound
+}
+
+// 自分を外す場合は、もうタスクを見ることができなくなっていても外せるようにする。(ほかのユーザーのウォッチャーは変わらない)
+func (wu *taskWatcherUsecase) RemoveWatcher(userId uint, taskId uint, watcherId uint) error {
+	if watcherId != userId {
+		if err := checkTaskRole(wu.tr, userId, taskId, model.ProjectRoleEditor); err != nil {
+			return err
+		}
+	}
+	return wu.wr.DeleteWatcher(taskId, watcherId)
+}
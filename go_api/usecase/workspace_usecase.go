@@ -0,0 +1,277 @@
+package usecase
+
+import (
+	"errors"
+	"go_api/model"
+	"go_api/repository"
+	"go_api/storage"
+	"go_api/validator"
+
+	"gorm.io/gorm"
+)
+
+type IWorkspaceUsecase interface {
+	GetWorkspaces(userId uint) ([]model.WorkspaceResponse, error)
+	// リクエストで使うワークスペースを決める。workspaceIdが0の場合は、ユーザーの個人用のワークスペースになる。
+	// 指定したワークスペースのメンバーでない場合は、model.ErrWorkspaceForbiddenを返す。
+	GetCurrentWorkspace(userId uint, workspaceId uint) (model.WorkspaceResponse, error)
+	CreateWorkspace(workspace model.Workspace) (model.WorkspaceResponse, error)
+	// 名前の変更と削除は、オーナーだけができる。個人用のワークスペースは削除できない。
+	UpdateWorkspace(workspace model.Workspace, userId uint, workspaceId uint) (model.WorkspaceResponse, error)
+	DeleteWorkspace(userId uint, workspaceId uint) error
+	GetMembers(userId uint, workspaceId uint) ([]model.WorkspaceMemberResponse, error)
+	// 登録済みのユーザーをメールアドレスで指定して、メンバーに追加する。オーナーだけが追加できる。
+	CreateMember(req model.WorkspaceMemberRequest, userId uint, workspaceId uint) (model.WorkspaceMemberResponse, error)
+	UpdateMember(req model.WorkspaceMemberRequest, userId uint, workspaceId uint, memberId uint) (model.WorkspaceMemberResponse, error)
+	// メンバーを外す。オーナーはほかのメンバーを外すことができ、メンバーは自分から抜けることができる。
+	DeleteMember(userId uint, workspaceId uint, memberId uint) error
+}
+
+type workspaceUsecase struct {
+	wr repository.IWorkspaceRepository
+	ur repository.IUserRepository
+	wv validator.IWorkspaceValidator
+	st storage.Storage
+}
+
+func NewWorkspaceUsecase(wr repository.IWorkspaceRepository, ur repository.IUserRepository, wv validator.IWorkspaceValidator, st storage.Storage) IWorkspaceUsecase {
+	return &workspaceUsecase{wr, ur, wv, st}
+}
+
+func toWorkspaceResponse(workspace model.Workspace) model.WorkspaceResponse {
+	return model.WorkspaceResponse{
+		ID:        workspace.ID,
+		Name:      workspace.Name,
+		Personal:  workspace.Personal,
+		Role:      workspace.Role,
+		CreatedAt: workspace.CreatedAt,
+		UpdatedAt: workspace.UpdatedAt,
+	}
+}
+
+func toWorkspaceMemberResponse(member model.WorkspaceMember) model.WorkspaceMemberResponse {
+	return model.WorkspaceMemberResponse{
+		UserId:    member.UserId,
+		Name:      member.User.Name,
+		Email:     member.User.Email,
+		Role:      member.Role,
+		CreatedAt: member.CreatedAt,
+	}
+}
+
+// ワークスペースのメンバーで、roleの権限があるか確認する。
+// メンバーでない場合はmodel.ErrWorkspaceNotFound、オーナーでない場合はmodel.ErrWorkspaceForbiddenを返す。
+func (wu *workspaceUsecase) checkRole(userId uint, workspaceId uint, role string) (model.Workspace, error) {
+	workspace := model.Workspace{}
+	if err := wu.wr.GetWorkspaceById(&workspace, userId, workspaceId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.Workspace{}, model.ErrWorkspaceNotFound
+		}
+		return model.Workspace{}, err
+	}
+	if role == model.WorkspaceRoleOwner && workspace.Role != model.WorkspaceRoleOwner {
+		return model.Workspace{}, model.ErrWorkspaceForbidden
+	}
+	return workspace, nil
+}
+
+func (wu *workspaceUsecase) GetWorkspaces(userId uint) ([]model.WorkspaceResponse, error) {
+	workspaces := []model.Workspace{}
+	if err := wu.wr.GetWorkspaces(&workspaces, userId); err != nil {
+		return nil, err
+	}
+	resWorkspaces := []model.WorkspaceResponse{}
+	for _, v := range workspaces {
+		resWorkspaces = append(resWorkspaces, toWorkspaceResponse(v))
+	}
+	return resWorkspaces, nil
+}
+
+func (wu *workspaceUsecase) GetCurrentWorkspace(userId uint, workspaceId uint) (model.WorkspaceResponse, error) {
+	if workspaceId == 0 {
+		workspace := model.Workspace{}
+		if err := wu.wr.GetPersonalWorkspace(&workspace, userId); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return model.WorkspaceResponse{}, model.ErrWorkspaceNotFound
+			}
+			return model.WorkspaceResponse{}, err
+		}
+		return toWorkspaceResponse(workspace), nil
+	}
+	workspace, err := wu.checkRole(userId, workspaceId, model.WorkspaceRoleMember)
+	if errors.Is(err, model.ErrWorkspaceNotFound) {
+		return model.WorkspaceResponse{}, model.ErrWorkspaceForbidden
+	}
+	if err != nil {
+		return model.WorkspaceResponse{}, err
+	}
+	return toWorkspaceResponse(workspace), nil
+}
+
+// 作成したワークスペースは、個人用にはしない。
+func (wu *workspaceUsecase) CreateWorkspace(workspace model.Workspace) (model.WorkspaceResponse, error) {
+	workspace.Personal = false
+	if err := wu.wv.WorkspaceValidate(workspace); err != nil {
+		return model.WorkspaceResponse{}, err
+	}
+	if err := wu.wr.CreateWorkspace(&workspace); err != nil {
+		return model.WorkspaceResponse{}, err
+	}
+	return toWorkspaceResponse(workspace), nil
+}
+
+func (wu *workspaceUsecase) UpdateWorkspace(workspace model.Workspace, userId uint, workspaceId uint) (model.WorkspaceResponse, error) {
+	if err := wu.wv.WorkspaceValidate(workspace); err != nil {
+		return model.WorkspaceResponse{}, err
+	}
+	if _, err := wu.checkRole(userId, workspaceId, model.WorkspaceRoleOwner); err != nil {
+		return model.WorkspaceResponse{}, err
+	}
+	if err := wu.wr.UpdateWorkspace(&workspace, workspaceId); err != nil {
+		return model.WorkspaceResponse{}, err
+	}
+	workspace.Role = model.WorkspaceRoleOwner
+	return toWorkspaceResponse(workspace), nil
+}
+
+// ワークスペースのタスクの添付ファイルも削除する。
+func (wu *workspaceUsecase) DeleteWorkspace(userId uint, workspaceId uint) error {
+	workspace, err := wu.checkRole(userId, workspaceId, model.WorkspaceRoleOwner)
+	if err != nil {
+		return err
+	}
+	if workspace.Personal {
+		return model.ErrPersonalWorkspace
+	}
+	storageKeys := []string{}
+	if err := wu.wr.DeleteWorkspace(&storageKeys, workspaceId); err != nil {
+		return err
+	}
+	// DBからは削除できているので、ファイルの削除に失敗しても続けて削除し、エラーはまとめて返す。
+	errs := []error{}
+	for _, key := range storageKeys {
+		if err := wu.st.Delete(key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (wu *workspaceUsecase) GetMembers(userId uint, workspaceId uint) ([]model.WorkspaceMemberResponse, error) {
+	if _, err := wu.checkRole(userId, workspaceId, model.WorkspaceRoleMember); err != nil {
+		return nil, err
+	}
+	members := []model.WorkspaceMember{}
+	if err := wu.wr.GetMembers(&members, workspaceId); err != nil {
+		return nil, err
+	}
+	resMembers := []model.WorkspaceMemberResponse{}
+	for _, v := range members {
+		resMembers = append(resMembers, toWorkspaceMemberResponse(v))
+	}
+	return resMembers, nil
+}
+
+// 個人用のワークスペースには、メンバーを追加できない。
+func (wu *workspaceUsecase) CreateMember(req model.WorkspaceMemberRequest, userId uint, workspaceId uint) (model.WorkspaceMemberResponse, error) {
+	if err := wu.wv.MemberValidate(req); err != nil {
+		return model.WorkspaceMemberResponse{}, err
+	}
+	workspace, err := wu.checkRole(userId, workspaceId, model.WorkspaceRoleOwner)
+	if err != nil {
+		return model.WorkspaceMemberResponse{}, err
+	}
+	if workspace.Personal {
+		return model.WorkspaceMemberResponse{}, model.ErrPersonalWorkspace
+	}
+	user := model.User{}
+	if err := wu.ur.GetUserByEmail(&user, req.Email); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.WorkspaceMemberResponse{}, model.ErrWorkspaceUserNotFound
+		}
+		return model.WorkspaceMemberResponse{}, err
+	}
+	member := model.WorkspaceMember{WorkspaceId: workspaceId, UserId: user.ID, Role: req.Role}
+	err = wu.wr.Transaction(func(wr repository.IWorkspaceRepository) error {
+		if err := wr.GetMember(&model.WorkspaceMember{}, workspaceId, user.ID); err == nil {
+			return model.ErrAlreadyWorkspaceMember
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		return wr.CreateMember(&member)
+	})
+	if err != nil {
+		return model.WorkspaceMemberResponse{}, err
+	}
+	member.User = user
+	return toWorkspaceMemberResponse(member), nil
+}
+
+// オーナーがいなくなる変更は、最後のオーナーを外すことになるのでエラーにする。
+func (wu *workspaceUsecase) UpdateMember(req model.WorkspaceMemberRequest, userId uint, workspaceId uint, memberId uint) (model.WorkspaceMemberResponse, error) {
+	if err := wu.wv.MemberUpdateValidate(req); err != nil {
+		return model.WorkspaceMemberResponse{}, err
+	}
+	if _, err := wu.checkRole(userId, workspaceId, model.WorkspaceRoleOwner); err != nil {
+		return model.WorkspaceMemberResponse{}, err
+	}
+	member := model.WorkspaceMember{}
+	err := wu.wr.Transaction(func(wr repository.IWorkspaceRepository) error {
+		if err := wr.GetMember(&member, workspaceId, memberId); err != nil {
+			return err
+		}
+		if member.Role == model.WorkspaceRoleOwner && req.Role != model.WorkspaceRoleOwner {
+			if err := checkOtherWorkspaceOwners(wr, workspaceId); err != nil {
+				return err
+			}
+		}
+		if err := wr.UpdateMemberRole(workspaceId, memberId, req.Role); err != nil {
+			return err
+		}
+		member.Role = req.Role
+		return nil
+	})
+	if err != nil {
+		return model.WorkspaceMemberResponse{}, err
+	}
+	return toWorkspaceMemberResponse(member), nil
+}
+
+// 個人用のワークスペースからは、自分も抜けられない。
+func (wu *workspaceUsecase) DeleteMember(userId uint, workspaceId uint, memberId uint) error {
+	required := model.WorkspaceRoleOwner
+	if memberId == userId {
+		required = model.WorkspaceRoleMember
+	}
+	workspace, err := wu.checkRole(userId, workspaceId, required)
+	if err != nil {
+		return err
+	}
+	if workspace.Personal {
+		return model.ErrPersonalWorkspace
+	}
+	return wu.wr.Transaction(func(wr repository.IWorkspaceRepository) error {
+		member := model.WorkspaceMember{}
+		if err := wr.GetMember(&member, workspaceId, memberId); err != nil {
+			return err
+		}
+		if member.Role == model.WorkspaceRoleOwner {
+			if err := checkOtherWorkspaceOwners(wr, workspaceId); err != nil {
+				return err
+			}
+		}
+		return wr.DeleteMember(workspaceId, memberId)
+	})
+}
+
+// オーナーが2人以上いるか確認する。
+func checkOtherWorkspaceOwners(wr repository.IWorkspaceRepository, workspaceId uint) error {
+	var owners int64
+	if err := wr.CountOwners(&owners, workspaceId); err != nil {
+		return err
+	}
+	if owners < 2 {
+		return model.ErrLastWorkspaceOwner
+	}
+	return nil
+}
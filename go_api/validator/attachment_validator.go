@@ -0,0 +1,36 @@
+package validator
+
+import (
+	"go_api/model"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type IAttachmentValidator interface {
+	AttachmentValidate(attachment model.Attachment) error
+}
+
+type attachmentValidator struct{}
+
+func NewAttachmentValidator() IAttachmentValidator {
+	return &attachmentValidator{}
+}
+
+func (av *attachmentValidator) AttachmentValidate(attachment model.Attachment) error {
+	return validation.ValidateStruct(&attachment,
+		validation.Field(
+			&attachment.FileName,
+			validation.Required.Error("file name is required"),
+			validation.RuneLength(1, 255).Error("limited max 255 char"),
+		),
+		validation.Field(
+			&attachment.Size,
+			validation.Required.Error("file is empty"),
+			validation.Max(model.MaxAttachmentSize).Error("file is too large"),
+		),
+		validation.Field(
+			&attachment.ContentType,
+			validation.In(model.AttachmentContentTypes...).Error("file type is not allowed"),
+		),
+	)
+}
@@ -0,0 +1,60 @@
+package validator
+
+import (
+	"go_api/model"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type IBoardValidator interface {
+	BoardValidate(board model.Board) error
+	ColumnValidate(column model.BoardColumn) error
+}
+
+type boardValidator struct{}
+
+func NewBoardValidator() IBoardValidator {
+	return &boardValidator{}
+}
+
+// 作成するときに送られてきた列も、1つずつバリデーションする。
+func (bv *boardValidator) BoardValidate(board model.Board) error {
+	return validation.ValidateStruct(&board,
+		validation.Field(
+			&board.Name,
+			validation.Required.Error("name is required"),
+			validation.RuneLength(1, 30).Error("limited max 30 char"),
+		),
+		validation.Field(
+			&board.Columns,
+			validation.Length(0, model.MaxBoardColumns).Error("columns is limited max 20"),
+			validation.By(func(value interface{}) error {
+				for _, v := range board.Columns {
+					if err := bv.ColumnValidate(v); err != nil {
+						return err
+					}
+				}
+				return nil
+			}),
+		),
+	)
+}
+
+// statusは空(独自の状態)か、タスクのステータスのどれかにする。
+func (bv *boardValidator) ColumnValidate(column model.BoardColumn) error {
+	return validation.ValidateStruct(&column,
+		validation.Field(
+			&column.Name,
+			validation.Required.Error("column name is required"),
+			validation.RuneLength(1, 30).Error("column name is limited max 30 char"),
+		),
+		validation.Field(
+			&column.Status,
+			validation.In(model.TaskStatuses...).Error("status must be empty or one of todo, in_progress, done, cancelled"),
+		),
+		validation.Field(
+			&column.WipLimit,
+			validation.Max(uint(1000)).Error("wip_limit is limited max 1000"),
+		),
+	)
+}
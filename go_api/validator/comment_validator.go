@@ -0,0 +1,27 @@
+package validator
+
+import (
+	"go_api/model"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type ICommentValidator interface {
+	CommentValidate(comment model.Comment) error
+}
+
+type commentValidator struct{}
+
+func NewCommentValidator() ICommentValidator {
+	return &commentValidator{}
+}
+
+func (cv *commentValidator) CommentValidate(comment model.Comment) error {
+	return validation.ValidateStruct(&comment,
+		validation.Field(
+			&comment.Body,
+			validation.Required.Error("body is required"),
+			validation.RuneLength(1, 10000).Error("limited max 10000 char"),
+		),
+	)
+}
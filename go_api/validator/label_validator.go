@@ -0,0 +1,36 @@
+package validator
+
+import (
+	"go_api/model"
+	"regexp"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type ILabelValidator interface {
+	LabelValidate(label model.Label) error
+}
+
+type labelValidator struct{}
+
+func NewLabelValidator() ILabelValidator {
+	return &labelValidator{}
+}
+
+// 色は#から始まる6桁の16進数(例: #ff0000)で指定する。
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+func (lv *labelValidator) LabelValidate(label model.Label) error {
+	return validation.ValidateStruct(&label,
+		validation.Field(
+			&label.Name,
+			validation.Required.Error("name is required"),
+			validation.RuneLength(1, 20).Error("limited max 20 char"),
+		),
+		validation.Field(
+			&label.Color,
+			validation.Required.Error("color is required"),
+			validation.Match(colorPattern).Error("color must be like #ff0000"),
+		),
+	)
+}
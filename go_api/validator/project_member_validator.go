@@ -0,0 +1,48 @@
+package validator
+
+import (
+	"go_api/model"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+type IProjectMemberValidator interface {
+	InvitationValidate(invitation model.ProjectInvitation) error
+	MemberUpdateValidate(update model.ProjectMemberUpdate) error
+}
+
+type projectMemberValidator struct{}
+
+func NewProjectMemberValidator() IProjectMemberValidator {
+	return &projectMemberValidator{}
+}
+
+// 招待・変更できる権限。オーナーも招待できる。
+var projectRoles = []interface{}{model.ProjectRoleViewer, model.ProjectRoleEditor, model.ProjectRoleOwner}
+
+func (pmv *projectMemberValidator) InvitationValidate(invitation model.ProjectInvitation) error {
+	return validation.ValidateStruct(&invitation,
+		validation.Field(
+			&invitation.Email,
+			validation.Required.Error("email is required"),
+			validation.RuneLength(1, 30).Error("limited max 30 char"),
+			is.Email.Error("is not valida email format"),
+		),
+		validation.Field(
+			&invitation.Role,
+			validation.Required.Error("role is required"),
+			validation.In(projectRoles...).Error("role must be viewer, editor or owner"),
+		),
+	)
+}
+
+func (pmv *projectMemberValidator) MemberUpdateValidate(update model.ProjectMemberUpdate) error {
+	return validation.ValidateStruct(&update,
+		validation.Field(
+			&update.Role,
+			validation.Required.Error("role is required"),
+			validation.In(projectRoles...).Error("role must be viewer, editor or owner"),
+		),
+	)
+}
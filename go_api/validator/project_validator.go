@@ -0,0 +1,32 @@
+package validator
+
+import (
+	"go_api/model"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type IProjectValidator interface {
+	ProjectValidate(project model.Project) error
+}
+
+type projectValidator struct{}
+
+func NewProjectValidator() IProjectValidator {
+	return &projectValidator{}
+}
+
+func (pv *projectValidator) ProjectValidate(project model.Project) error {
+	return validation.ValidateStruct(&project,
+		validation.Field(
+			&project.Name,
+			validation.Required.Error("name is required"),
+			validation.RuneLength(1, 30).Error("limited max 30 char"),
+		),
+		validation.Field(
+			&project.Color,
+			validation.Required.Error("color is required"),
+			validation.Match(colorPattern).Error("color must be like #ff0000"),
+		),
+	)
+}
@@ -0,0 +1,44 @@
+package validator
+
+import (
+	"go_api/model"
+	"go_api/taskfilter"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type ISavedViewValidator interface {
+	SavedViewValidate(view model.SavedView) error
+}
+
+type savedViewValidator struct{}
+
+func NewSavedViewValidator() ISavedViewValidator {
+	return &savedViewValidator{}
+}
+
+// queryは、保存する前に解析できるか確認しておく。
+func (vv *savedViewValidator) SavedViewValidate(view model.SavedView) error {
+	return validation.ValidateStruct(&view,
+		validation.Field(
+			&view.Name,
+			validation.Required.Error("name is required"),
+			validation.RuneLength(1, 30).Error("limited max 30 char"),
+		),
+		validation.Field(
+			&view.Query,
+			validation.By(func(value interface{}) error {
+				_, err := taskfilter.Parse(view.Query)
+				return err
+			}),
+		),
+		validation.Field(
+			&view.Sort,
+			validation.In(model.TaskSortKeys...).Error("sort must be one of position, created_at, updated_at, title, due_at"),
+		),
+		validation.Field(
+			&view.Order,
+			validation.In("asc", "desc").Error("order must be asc or desc"),
+		),
+	)
+}
@@ -1,7 +1,13 @@
 package validator
 
 import (
+	"errors"
+	"fmt"
 	"go_api/model"
+	"go_api/rrule"
+	"go_api/taskfilter"
+	"go_api/taskio"
+	"time"
 
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 )
@@ -9,6 +15,13 @@ import (
 // インターフェースを作成
 type ITaskValidator interface {
 	TaskValidate(task model.Task) error
+	TaskPatchValidate(task model.Task, fields []string) error
+	TaskFilterValidate(filter model.TaskFilter) error
+	TaskPageValidate(page model.TaskPage) error
+	TaskSearchValidate(query string, limit int) error
+	TaskOccurrencesValidate(from time.Time, to time.Time) error
+	TaskBulkValidate(req model.TaskBulkRequest) error
+	TaskImportValidate(opts model.TaskImportOptions) error
 }
 
 // 構造体を作成
@@ -24,12 +37,227 @@ func NewTaskValidator() ITaskValidator {
 // taskValidatorをポインターレシーバーとして受け取る形で定義。
 // 引数で、バリデーションで評価したいTaskのオブジェクトを受け取る。
 func (tv *taskValidator) TaskValidate(task model.Task) error {
-	// validationStruct関数を実行。第一引数にtaskオブジェクトのアドレス。第二引数にタスクのタイトルに対するバリデーションを実装している。
-	return validation.ValidateStruct(&task,
-		validation.Field(
+	// validationStruct関数を実行。第一引数にtaskオブジェクトのアドレス。第二引数以降に各フィールドに対するバリデーションを渡す。
+	// 各フィールドのバリデーションはtaskFieldRulesにまとめている。
+	rules := taskFieldRules(&task)
+	fields := make([]*validation.FieldRules, 0, len(rules))
+	for _, r := range rules {
+		fields = append(fields, r.rules)
+	}
+	return validation.ValidateStruct(&task, fields...)
+}
+
+// 部分更新(PATCH)のバリデーション。fieldsで渡された(JSONのフィールド名の)フィールドのルールだけを評価する。
+// 他のフィールドとの関係を見るルールは、関係するフィールドが変更された場合にも評価する。
+func (tv *taskValidator) TaskPatchValidate(task model.Task, fields []string) error {
+	touched := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		touched[f] = true
+		for _, d := range taskFieldDependents[f] {
+			touched[d] = true
+		}
+	}
+	rules := []*validation.FieldRules{}
+	for _, r := range taskFieldRules(&task) {
+		if touched[r.name] {
+			rules = append(rules, r.rules)
+		}
+	}
+	return validation.ValidateStruct(&task, rules...)
+}
+
+// JSONのフィールド名と、そのフィールドに対するバリデーションの組。
+type taskFieldRule struct {
+	name  string
+	rules *validation.FieldRules
+}
+
+// あるフィールドが変更されたときに、合わせて評価が必要になるフィールド。
+// 開始日時を変えると期限との前後関係を、期限を変えると繰り返しルールの最初の発生日時を確認し直す必要がある。
+var taskFieldDependents = map[string][]string{
+	"start_at": {"due_at"},
+	"due_at":   {"recurrence"},
+}
+
+// タスクの各フィールドに対するバリデーション。
+func taskFieldRules(task *model.Task) []taskFieldRule {
+	return []taskFieldRule{
+		{"title", validation.Field(
 			&task.Title,
 			validation.Required.Error("title is required"),
 			validation.RuneLength(1, 12).Error("limited max 12 char"),
+		)},
+		{"description", validation.Field(
+			&task.Description,
+			validation.RuneLength(0, 1000).Error("limited max 1000 char"),
+		)},
+		{"status", validation.Field(
+			&task.Status,
+			validation.Required.Error("status is required"),
+			validation.In(model.TaskStatuses...).Error("status must be one of todo, in_progress, done, cancelled"),
+		)},
+		// チェックリストは50項目まで。各項目のテキストは1〜100文字。
+		{"checklist", validation.Field(
+			&task.Checklist,
+			validation.Length(0, 50).Error("checklist is limited max 50 items"),
+			validation.By(func(value interface{}) error {
+				for _, v := range task.Checklist {
+					if err := validation.Validate(v.Text,
+						validation.Required.Error("checklist text is required"),
+						validation.RuneLength(1, 100).Error("checklist text is limited max 100 char"),
+					); err != nil {
+						return err
+					}
+				}
+				return nil
+			}),
+		)},
+		// 繰り返しルールは、RRULEとして解析できるものだけを受け付ける。最初の発生日時として期限が必要。
+		{"recurrence", validation.Field(
+			&task.Recurrence,
+			validation.By(func(value interface{}) error {
+				if task.Recurrence == "" {
+					return nil
+				}
+				if task.DueAt == nil {
+					return errors.New("due_at is required for recurring tasks")
+				}
+				if _, err := rrule.Parse(task.Recurrence, task.DueAt.Location()); err != nil {
+					return err
+				}
+				return nil
+			}),
+		)},
+		// 開始日時と期限の両方が指定されている場合は、期限が開始日時より後になっているか確認する。
+		// 時刻はタイムゾーン付きで受け取っているので、比較は同じ瞬間かどうかで行われる。
+		{"due_at", validation.Field(
+			&task.DueAt,
+			validation.By(func(value interface{}) error {
+				if task.StartAt != nil && task.DueAt != nil && !task.DueAt.After(*task.StartAt) {
+					return errors.New("due_at must be after start_at")
+				}
+				return nil
+			}),
+		)},
+	}
+}
+
+// タスク一覧の絞り込み条件のバリデーション。ステータスは空(指定なし)でもよい。
+func (tv *taskValidator) TaskFilterValidate(filter model.TaskFilter) error {
+	return validation.ValidateStruct(&filter,
+		validation.Field(
+			&filter.Status,
+			validation.In(model.TaskStatuses...).Error("status must be one of todo, in_progress, done, cancelled"),
+		),
+		validation.Field(
+			&filter.DueBefore,
+			validation.By(func(value interface{}) error {
+				if filter.DueBefore != nil && filter.DueAfter != nil && !filter.DueBefore.After(*filter.DueAfter) {
+					return errors.New("due_before must be after due_after")
+				}
+				return nil
+			}),
+		),
+		validation.Field(
+			&filter.Query,
+			validation.By(func(value interface{}) error {
+				_, err := taskfilter.Parse(filter.Query)
+				return err
+			}),
+		),
+	)
+}
+
+// ページングと並び替えの指定のバリデーション。
+func (tv *taskValidator) TaskPageValidate(page model.TaskPage) error {
+	return validation.ValidateStruct(&page,
+		validation.Field(
+			&page.Sort,
+			validation.In(model.TaskSortKeys...).Error("sort must be one of position, created_at, updated_at, title, due_at"),
+		),
+		validation.Field(
+			&page.Order,
+			validation.In("asc", "desc").Error("order must be asc or desc"),
+		),
+		validation.Field(
+			&page.Limit,
+			validation.Min(1).Error("limit must be at least 1"),
+			validation.Max(model.MaxTaskPageLimit).Error(fmt.Sprintf("limited max %d tasks", model.MaxTaskPageLimit)),
+		),
+	)
+}
+
+// 全文検索の検索語と件数のバリデーション。
+func (tv *taskValidator) TaskSearchValidate(query string, limit int) error {
+	if err := validation.Validate(query,
+		validation.Required.Error("q is required"),
+		validation.RuneLength(1, 100).Error("limited max 100 char"),
+	); err != nil {
+		return err
+	}
+	return validation.Validate(limit,
+		validation.Min(1).Error("limit must be at least 1"),
+		validation.Max(100).Error("limited max 100 tasks"),
+	)
+}
+
+// 繰り返しタスクの発生日時を展開する期間のバリデーション。toはfrom以降にする。
+func (tv *taskValidator) TaskOccurrencesValidate(from time.Time, to time.Time) error {
+	return validation.Validate(to,
+		validation.Min(from).Error("to must be after from"),
+	)
+}
+
+// 一括操作のリクエストのバリデーション。操作の中身(タスクのタイトルなど)は、操作ごとに実行するときに確認する。
+func (tv *taskValidator) TaskBulkValidate(req model.TaskBulkRequest) error {
+	if err := validation.ValidateStruct(&req,
+		validation.Field(
+			&req.Mode,
+			validation.Required.Error("mode is required"),
+			validation.In(model.TaskBulkModeAtomic, model.TaskBulkModeBestEffort).Error("mode must be atomic or best_effort"),
+		),
+		validation.Field(
+			&req.Operations,
+			validation.Required.Error("operations is required"),
+			validation.Length(1, model.MaxTaskBulkOperations).Error(fmt.Sprintf("limited max %d operations", model.MaxTaskBulkOperations)),
+		),
+	); err != nil {
+		return err
+	}
+	for i, op := range req.Operations {
+		err := validation.ValidateStruct(&op,
+			validation.Field(
+				&op.Op,
+				validation.Required.Error("op is required"),
+				validation.In(model.TaskBulkOps...).Error("op must be one of create, update, delete, complete"),
+			),
+			validation.Field(
+				&op.TaskId,
+				validation.When(op.Op != model.TaskBulkOpCreate, validation.Required.Error("task_id is required")),
+			),
+			validation.Field(
+				&op.Task,
+				validation.When(op.Op == model.TaskBulkOpCreate || op.Op == model.TaskBulkOpUpdate, validation.Required.Error("task is required")),
+			),
+		)
+		if err != nil {
+			return fmt.Errorf("operations[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// インポートの指定のバリデーション。
+func (tv *taskValidator) TaskImportValidate(opts model.TaskImportOptions) error {
+	return validation.ValidateStruct(&opts,
+		validation.Field(
+			&opts.Format,
+			validation.Required.Error("format is required"),
+			validation.In(taskio.Formats...).Error("format must be one of json, csv, md, ics"),
+		),
+		validation.Field(
+			&opts.Duplicates,
+			validation.In(model.TaskImportDuplicatesSkip, model.TaskImportDuplicatesCreate).Error("duplicates must be skip or create"),
 		),
 	)
 }
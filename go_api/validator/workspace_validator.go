@@ -0,0 +1,59 @@
+package validator
+
+import (
+	"go_api/model"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+type IWorkspaceValidator interface {
+	WorkspaceValidate(workspace model.Workspace) error
+	MemberValidate(member model.WorkspaceMemberRequest) error
+	MemberUpdateValidate(member model.WorkspaceMemberRequest) error
+}
+
+type workspaceValidator struct{}
+
+func NewWorkspaceValidator() IWorkspaceValidator {
+	return &workspaceValidator{}
+}
+
+var workspaceRoles = []interface{}{model.WorkspaceRoleMember, model.WorkspaceRoleOwner}
+
+func (wv *workspaceValidator) WorkspaceValidate(workspace model.Workspace) error {
+	return validation.ValidateStruct(&workspace,
+		validation.Field(
+			&workspace.Name,
+			validation.Required.Error("name is required"),
+			validation.RuneLength(1, 30).Error("limited max 30 char"),
+		),
+	)
+}
+
+func (wv *workspaceValidator) MemberValidate(member model.WorkspaceMemberRequest) error {
+	return validation.ValidateStruct(&member,
+		validation.Field(
+			&member.Email,
+			validation.Required.Error("email is required"),
+			validation.RuneLength(1, 30).Error("limited max 30 char"),
+			is.Email.Error("is not valida email format"),
+		),
+		validation.Field(
+			&member.Role,
+			validation.Required.Error("role is required"),
+			validation.In(workspaceRoles...).Error("role must be member or owner"),
+		),
+	)
+}
+
+// 権限の変更では、メールアドレスは使わない。
+func (wv *workspaceValidator) MemberUpdateValidate(member model.WorkspaceMemberRequest) error {
+	return validation.ValidateStruct(&member,
+		validation.Field(
+			&member.Role,
+			validation.Required.Error("role is required"),
+			validation.In(workspaceRoles...).Error("role must be member or owner"),
+		),
+	)
+}